@@ -14,6 +14,7 @@
 package main
 
 import (
+	"fmt"
 	"github.com/BurntSushi/toml"
 	"github.com/je4/indexer/v3/pkg/indexer"
 	"log"
@@ -40,11 +41,19 @@ type ConfigClamAV struct {
 	Wsl      bool
 }
 
+// ConfigReload rebuilds the actions from this configuration file on SIGHUP, file change or admin request
+type ConfigReload struct {
+	Enabled  bool
+	Interval duration // check for file changes, disabled if zero
+	Token    string   // bearer token for /admin/reload, disabled if empty
+}
+
 type ConfigSiegfried struct {
 	//Address string
 	Enabled       bool
 	SignatureFile string
 	MimeMap       map[string]string
+	TypeMap       map[string]indexer.TypeSubtype
 }
 
 type ConfigTika struct {
@@ -87,6 +96,13 @@ type ExternalAction struct {
 	Mimetype string
 	ActionCapabilities []indexer.ActionCapability
 	CallType           indexer.ExternalActionCalltype
+	Headers            map[string]string
+	BearerToken        string
+	Timeout            duration
+	Retries            int
+	RetryDelay         duration
+	Response           *indexer.ExternalResponseMapping
+	Args               []string // EACTCOMMAND: address is the executable
 }
 
 type FileMap struct {
@@ -138,12 +154,22 @@ type Config struct {
 	NSRL            ConfigNSRL
 	Clamav          ConfigClamAV
 	MimeRelevance   map[string]MimeWeight
+	Reload          ConfigReload
 }
 
 func LoadConfig(fp string) *Config {
+	conf, err := ReadConfig(fp)
+	if err != nil {
+		log.Fatalln("Error on loading config: ", err)
+	}
+	return conf
+}
+
+// ReadConfig decodes the configuration file, i.e. for a reload
+func ReadConfig(fp string) (*Config, error) {
 	user, err := user.Current()
 	if err != nil {
-		log.Fatalln("cannot get current user", err)
+		return nil, fmt.Errorf("cannot get current user: %w", err)
 	}
 	var conf = &Config{
 		LogFormat:    `%{time:2006-01-02T15:04:05.000} %{shortpkg}::%{longfunc} [%{shortfile}] > %{level:.5s} - %{message}`,
@@ -154,12 +180,12 @@ func LoadConfig(fp string) *Config {
 	}
 
 	if _, err := toml.DecodeFile(fp, conf); err != nil {
-		log.Fatalln("Error on loading config: ", err)
+		return nil, fmt.Errorf("cannot decode '%s': %w", fp, err)
 	}
 	pwd := os.Getenv("SFTP_PASSWORD")
 	if pwd != "" {
 		conf.SFTP.Password = pwd
 	}
 
-	return conf
+	return conf, nil
 }
@@ -7,8 +7,10 @@ import (
 	"fmt"
 	"github.com/je4/indexer/v3/pkg/indexer"
 	"github.com/phayes/freeport"
+	"html/template"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -39,17 +41,26 @@ func TestMain(m *testing.M) {
 	}
 	addr = fmt.Sprintf("localhost:%v", port)
 
+	errorTpl, err := template.ParseFiles(fmt.Sprintf("%s/../../web/template/error.gohtml", workingDirectory))
+	if err != nil {
+		log.Fatalf("cannot parse error template: %v", err)
+	}
+
 	srv, err := indexer.NewServer(
 		10*time.Second,
 		1024,
 		".*/.*",
 		1024,
+		map[int]indexer.MimeWeightString{},
 		"swordfish",
 		[]string{"HS386"},
+		false,
 		log,
 		accesslog,
-		fmt.Sprintf("%s/../../web/template/error.gohtml", workingDirectory),
+		errorTpl,
 		workingDirectory,
+		indexer.NewFileMapper(map[string]string{}),
+		nil,
 	)
 	if err != nil {
 		log.Panicf("cannot initialize server: %v", err)
@@ -65,6 +76,16 @@ func TestMain(m *testing.M) {
 		fmt.Println("server stopped")
 	}()
 
+	// wait for the listener
+	for i := 0; i < 50; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
 	exitCode := m.Run()
 
 	shutdown = true
@@ -20,6 +20,8 @@ import (
 	"github.com/dgraph-io/badger/v4"
 	"github.com/je4/indexer/v3/pkg/indexer"
 	lm "github.com/je4/utils/v2/pkg/logger"
+	"github.com/je4/utils/v2/pkg/zLogger"
+	"github.com/rs/zerolog"
 	"html/template"
 	"io"
 	"log"
@@ -87,17 +89,10 @@ func main() {
 		return
 	}
 
-	mimeRelevance := map[int]indexer.MimeWeightString{}
-	for key, val := range config.MimeRelevance {
-		keyInt, err := strconv.ParseInt(key, 10, 64)
-		if err != nil {
-			log.Panicf("cannot convert mimeRelevance %s to string", key)
-			return
-		}
-		mimeRelevance[int(keyInt)] = indexer.MimeWeightString{
-			Regexp: val.Regexp,
-			Weight: val.Weight,
-		}
+	mimeRelevance, err := loadMimeRelevance(config)
+	if err != nil {
+		log.Panicf("%v", err)
+		return
 	}
 	errorTpl, err := template.ParseFiles(config.ErrorTemplate)
 	if err != nil {
@@ -126,48 +121,146 @@ func main() {
 		return
 	}
 
+	if config.Reload.Enabled {
+		// the actions are rebuilt from the identify configuration, not from the indexer configuration
+		load := func() (*indexer.ActionDispatcher, string, []string, error) {
+			conf, err := ReadConfig(*configFile)
+			if err != nil {
+				return nil, "", nil, err
+			}
+			files := []string{*configFile}
+			if conf.Siegfried.Enabled {
+				files = append(files, conf.Siegfried.SignatureFile)
+			}
+			hash, err := indexer.ConfigHash(conf, files)
+			if err != nil {
+				return nil, "", nil, err
+			}
+			ad, err := initActions(conf, srv, log)
+			if err != nil {
+				return nil, "", nil, err
+			}
+			return ad, hash, files, nil
+		}
+		zlogger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+		reloader, err := indexer.NewDispatcherReloader(load, &zlogger)
+		if err != nil {
+			log.Panicf("cannot initialize reloader: %v", err)
+			return
+		}
+		defer reloader.Close()
+		srv.SetReloader(reloader, config.Reload.Token)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go reloader.HandleSignal(ctx)
+		if config.Reload.Interval.Duration > 0 {
+			go reloader.Watch(ctx, config.Reload.Interval.Duration)
+		}
+	} else {
+		ad, err := initActions(config, srv, log)
+		if err != nil {
+			log.Panicf("cannot initialize actions: %v", err)
+			return
+		}
+		defer ad.Close()
+		for _, a := range ad.GetActions() {
+			srv.AddActions(a)
+		}
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(config.Addr, config.CertPEM, config.KeyPEM); err != nil {
+			log.Errorf("server died: %v", err)
+		}
+	}()
+
+	end := make(chan bool, 1)
+
+	// process waiting for interrupt signal (TERM or KILL)
+	go func() {
+		sigint := make(chan os.Signal, 1)
+
+		// interrupt signal sent from terminal
+		signal.Notify(sigint, os.Interrupt)
+
+		signal.Notify(sigint, syscall.SIGTERM)
+		signal.Notify(sigint, syscall.SIGKILL)
+
+		<-sigint
+
+		// We received an interrupt signal, shut down.
+		log.Infof("shutdown requested")
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		srv.Shutdown(ctx)
+
+		end <- true
+	}()
+
+	<-end
+	log.Info("server stopped")
+}
+
+func loadMimeRelevance(config *Config) (map[int]indexer.MimeWeightString, error) {
+	mimeRelevance := map[int]indexer.MimeWeightString{}
+	for key, val := range config.MimeRelevance {
+		keyInt, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert mimeRelevance %s to string", key)
+		}
+		mimeRelevance[int(keyInt)] = indexer.MimeWeightString{
+			Regexp: val.Regexp,
+			Weight: val.Weight,
+		}
+	}
+	return mimeRelevance, nil
+}
+
+// initActions creates the actions of the configuration. the nsrl database is closed with the ActionDispatcher
+func initActions(config *Config, srv *indexer.Server, log zLogger.ZWrapper) (*indexer.ActionDispatcher, error) {
+	mimeRelevance, err := loadMimeRelevance(config)
+	if err != nil {
+		return nil, err
+	}
 	ad := indexer.NewActionDispatcher(mimeRelevance)
 
-	var nsrldb *badger.DB
 	if config.NSRL.Enabled {
 		stat2, err := os.Stat(config.NSRL.Badger)
 		if err != nil {
-			fmt.Printf("cannot stat badger folder %s: %v\n", config.NSRL.Badger, err)
-			return
+			return nil, fmt.Errorf("cannot stat badger folder %s: %w", config.NSRL.Badger, err)
 		}
 		if !stat2.IsDir() {
-			fmt.Printf("%s is not a directory\n", config.NSRL.Badger)
-			return
+			return nil, fmt.Errorf("%s is not a directory", config.NSRL.Badger)
 		}
 
 		bconfig := badger.DefaultOptions(config.NSRL.Badger)
 		bconfig.ReadOnly = true
-		nsrldb, err = badger.Open(bconfig)
+		nsrldb, err := badger.Open(bconfig)
 		if err != nil {
-			log.Panicf("cannot open badger database in %s: %v\n", config.NSRL.Badger, err)
-			return
+			return nil, fmt.Errorf("cannot open badger database in %s: %w", config.NSRL.Badger, err)
 		}
-		//log.Infof("nsrl max batch count: %v", nsrldb.MaxBatchCount())
-		defer nsrldb.Close()
+		ad.AddCloser(nsrldb)
 		var keyCount uint32
 		for _, tbl := range nsrldb.Tables() {
 			keyCount += tbl.KeyCount
 		}
 		log.Infof("NSRL-Table: %v keys", keyCount)
 		indexer.NewActionNSRL("nsrl", nsrldb, srv, ad)
-		//return
 	}
 
 	if config.Siegfried.Enabled {
 		if _, err := os.Stat(config.Siegfried.SignatureFile); err != nil {
-			log.Panicf("siegfried signature file at %s not found. Please use 'sf -update' to download it: %v", config.Siegfried.SignatureFile, err)
+			ad.Close()
+			return nil, fmt.Errorf("siegfried signature file at %s not found. Please use 'sf -update' to download it: %w", config.Siegfried.SignatureFile, err)
 		}
 		signatureData, err := os.ReadFile(config.Siegfried.SignatureFile)
 		if err != nil {
-			log.Panicf("cannot read signature file at %s: %v", config.Siegfried.SignatureFile, err)
+			ad.Close()
+			return nil, fmt.Errorf("cannot read signature file at %s: %w", config.Siegfried.SignatureFile, err)
 		}
-		indexer.NewActionSiegfried("siegfried", signatureData, config.Siegfried.MimeMap, srv, ad)
-		//srv.AddActions(sf)
+		indexer.NewActionSiegfried("siegfried", signatureData, config.Siegfried.MimeMap, config.Siegfried.TypeMap, srv, ad)
 	}
 
 	if config.FFMPEG.Enabled {
@@ -190,7 +283,6 @@ func main() {
 
 	if config.Tika.Enabled {
 		indexer.NewActionTika("tika", config.Tika.Address, config.Tika.Timeout.Duration, config.Tika.RegexpMime, config.Tika.RegexpMimeNot, "", config.Tika.Online, srv, ad)
-		//srv.AddActions(tika)
 	}
 
 	if config.Clamav.Enabled {
@@ -207,44 +299,16 @@ func main() {
 		for _, c := range eaconfig.ActionCapabilities {
 			caps |= uint(c)
 		}
-		indexer.NewActionExternal(eaconfig.Name, eaconfig.Address, indexer.ActionCapability(caps), eaconfig.CallType, eaconfig.Mimetype, srv, ad)
-		//srv.AddActions(ea)
+		indexer.NewActionExternal(eaconfig.Name, eaconfig.Address, indexer.ActionCapability(caps), eaconfig.CallType, eaconfig.Mimetype, &indexer.ExternalActionOptions{
+			Headers:     eaconfig.Headers,
+			BearerToken: eaconfig.BearerToken,
+			Timeout:     eaconfig.Timeout.Duration,
+			Retries:     eaconfig.Retries,
+			RetryDelay:  eaconfig.RetryDelay.Duration,
+			Response:    eaconfig.Response,
+			Args:        eaconfig.Args,
+			TempDir:     config.TempDir,
+		}, srv, ad)
 	}
-
-	for _, a := range ad.GetActions() {
-		srv.AddActions(a)
-	}
-
-	go func() {
-		if err := srv.ListenAndServe(config.Addr, config.CertPEM, config.KeyPEM); err != nil {
-			log.Errorf("server died: %v", err)
-		}
-	}()
-
-	end := make(chan bool, 1)
-
-	// process waiting for interrupt signal (TERM or KILL)
-	go func() {
-		sigint := make(chan os.Signal, 1)
-
-		// interrupt signal sent from terminal
-		signal.Notify(sigint, os.Interrupt)
-
-		signal.Notify(sigint, syscall.SIGTERM)
-		signal.Notify(sigint, syscall.SIGKILL)
-
-		<-sigint
-
-		// We received an interrupt signal, shut down.
-		log.Infof("shutdown requested")
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-
-		srv.Shutdown(ctx)
-
-		end <- true
-	}()
-
-	<-end
-	log.Info("server stopped")
+	return ad, nil
 }
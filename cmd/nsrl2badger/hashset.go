@@ -0,0 +1,142 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"emperror.dev/errors"
+	"encoding/csv"
+	"fmt"
+	badger "github.com/dgraph-io/badger/v4"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// hashSetDigest maps csv column names like "sha256" or "SHA-1" to the key prefix
+func hashSetDigest(column string) string {
+	switch strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(column), "-", "")) {
+	case "MD5":
+		return "MD5"
+	case "SHA1":
+		return "SHA-1"
+	case "SHA256":
+		return "SHA-256"
+	default:
+		return ""
+	}
+}
+
+// hashSet imports a csv file with a header line into a hash set database.
+// every digest column (md5, sha1, sha256) becomes a key, all other columns
+// and the labels given as key=value pairs are stored as labels of the entry
+func hashSet(csvFile, badgerFolder string, labels []string, checkonly bool) error {
+	var fixed = map[string]string{}
+	for _, label := range labels {
+		if label == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(label, "=")
+		if !ok {
+			return fmt.Errorf("invalid label '%s', need key=value", label)
+		}
+		fixed[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+
+	stat2, err := os.Stat(badgerFolder)
+	if err != nil {
+		return errors.Wrapf(err, "cannot stat badger folder %s", badgerFolder)
+	}
+	if !stat2.IsDir() {
+		return fmt.Errorf("%s is not a directory", badgerFolder)
+	}
+
+	fp, err := os.Open(csvFile)
+	if err != nil {
+		return errors.Wrapf(err, "cannot open %s", csvFile)
+	}
+	defer fp.Close()
+	csvR := csv.NewReader(fp)
+	csvR.FieldsPerRecord = -1
+	fields, err := csvR.Read()
+	if err != nil {
+		return errors.Wrapf(err, "cannot read csv fields from %s", csvFile)
+	}
+	var digestCols = map[int]string{}
+	for key, val := range fields {
+		if digest := hashSetDigest(val); digest != "" {
+			digestCols[key] = digest
+		}
+	}
+	if len(digestCols) == 0 {
+		return errors.Errorf("no md5, sha1 or sha256 column in %v", fields)
+	}
+
+	bconfig := badger.DefaultOptions(badgerFolder)
+	db, err := badger.Open(bconfig)
+	if err != nil {
+		return errors.Wrapf(err, "cannot open badger database")
+	}
+	defer db.Close()
+
+	ri := &rdsImport{
+		db:        db,
+		checkonly: checkonly,
+		batch:     map[string][]map[string]string{},
+		current:   &rdsStats{Table: filepath.Base(csvFile)},
+	}
+	ri.stats = append(ri.stats, ri.current)
+	start := time.Now()
+	for {
+		data, err := csvR.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Printf("cannot read csv data: %v\n", err)
+			continue
+		}
+		ri.current.Rows++
+		var record = map[string]string{}
+		for key, val := range fixed {
+			record[key] = val
+		}
+		for key, name := range fields {
+			if _, ok := digestCols[key]; ok || key >= len(data) {
+				continue
+			}
+			record[name] = data[key]
+		}
+		for key, digest := range digestCols {
+			if key >= len(data) {
+				continue
+			}
+			sum := strings.ToUpper(strings.TrimSpace(data[key]))
+			if sum == "" {
+				continue
+			}
+			if err := ri.add(digest+"-"+sum, record); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+	if err := ri.flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	fmt.Println()
+	fmt.Printf("import of %s finished in %v\n", csvFile, time.Since(start))
+	printStats(ri.stats)
+	return nil
+}
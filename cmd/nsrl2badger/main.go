@@ -372,12 +372,16 @@ func main() {
 
 	isoFile := flag.String("iso", "", "NSRL ISO file")
 	zipFile := flag.String("zip", "", "NSRL ISO file")
+	rdsv3File := flag.String("rdsv3", "", "NSRL RDSv3 sqlite database or sql script of a delta release (.db, .sql or .zip)")
+	hashSetFile := flag.String("hashset", "", "csv file with md5, sha1 or sha256 column for a custom hash set")
+	labels := flag.String("label", "", "comma separated key=value labels added to every hash set entry")
+	tempDir := flag.String("temp", os.TempDir(), "folder for extracting zipped rdsv3 databases")
 	badgerFolder := flag.String("badger", "./", "badger folder")
 	fileFile := flag.String("file", "", "nsrl file hashes")
 	mfgFile := flag.String("mfg", "/nsrlmfg.txt", "nsrl mfg code and name")
 	osFile := flag.String("os", "/nsrlos.txt", "nsrl os code and name")
 	prodFile := flag.String("prod", "/nsrlprod.txt", "nsrl prod code and name")
-	checkSum := flag.String("checksum", "MD5", "MD5 OR SHA-1 as key value")
+	checkSum := flag.String("checksum", "MD5", "MD5 OR SHA-1 as key value (rdsv3: comma separated list of SHA-256, SHA-1, MD5)")
 	noFile := flag.Bool("nofile", false, "ignore nsrlfile.zip if true")
 	checkOnly := flag.Bool("checkonly", false, "true, of only one entry per checksum is needed")
 
@@ -385,7 +389,15 @@ func main() {
 
 	*fileFile = strings.ToLower(*fileFile)
 
-	if *zipFile != "" {
+	if *hashSetFile != "" {
+		if err := hashSet(*hashSetFile, *badgerFolder, strings.Split(*labels, ","), *checkOnly); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	} else if *rdsv3File != "" {
+		if err := rdsv3(*rdsv3File, *badgerFolder, *checkSum, *tempDir, *checkOnly); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	} else if *zipFile != "" {
 		if *fileFile == "" {
 			*fileFile = "/nsrlfile.txt"
 		}
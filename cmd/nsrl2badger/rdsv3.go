@@ -0,0 +1,558 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"archive/zip"
+	"emperror.dev/errors"
+	"encoding/json"
+	"fmt"
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/golang/snappy"
+	"github.com/je4/indexer/v3/pkg/sqlite"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// key prefixes and field names of the legacy RDS 2.x layout used by indexer.ActionNSRL
+const (
+	keyOS      = "OpSystemCode-"
+	keyProd    = "ProductCode-"
+	keyMfg     = "MfgCode-"
+	keyVersion = "NSRLVersion"
+)
+
+// rdsv3Digests maps the key prefix to the column of the FILE table
+var rdsv3Digests = map[string]string{
+	"SHA-256": "sha256",
+	"SHA-1":   "sha1",
+	"MD5":     "md5",
+}
+
+// rdsv3Keys maps the tables PKG, OS and MFG to the key prefix and the id column
+var rdsv3Keys = map[string][2]string{
+	"MFG": {keyMfg, "manufacturer_id"},
+	"OS":  {keyOS, "operating_system_id"},
+	"PKG": {keyProd, "package_id"},
+}
+
+// rdsv3Fields maps the columns of the RDSv3 tables to the fields of the legacy layout
+var rdsv3Fields = map[string]map[string]string{
+	"MFG": {"manufacturer_id": "MfgCode", "name": "MfgName"},
+	"OS":  {"operating_system_id": "OpSystemCode", "name": "OpSystemName", "version": "OpSystemVersion", "manufacturer_id": "MfgCode"},
+	"PKG": {"package_id": "ProductCode", "name": "ProductName", "version": "ProductVersion", "operating_system_id": "OpSystemCode",
+		"manufacturer_id": "MfgCode", "language": "Language", "application_type": "ApplicationType"},
+	"FILE": {"file_name": "FileName", "file_size": "FileSize", "package_id": "ProductCode"},
+}
+
+type rdsStats struct {
+	Table   string
+	Rows    int64
+	Keys    int64
+	Merged  int64
+	Deleted int64
+	Skipped int64
+}
+
+type rdsImport struct {
+	db        *badger.DB
+	rds       *sqlite.DB
+	digests   []string
+	checkonly bool
+	stats     []*rdsStats
+	batch     map[string][]map[string]string
+	removes   map[string][]map[string]string
+	pkgOS     map[string]string
+	current   *rdsStats
+}
+
+// rdsTable reads all rows of a table as column name to string map.
+// an INTEGER PRIMARY KEY column is filled with the rowid
+func (ri *rdsImport) rdsTable(name string, fn func(row map[string]string) error) error {
+	table, err := ri.rds.Table(name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	columns := sqlite.Columns(table.SQL)
+	pk := sqlite.IntegerPrimaryKey(table.SQL)
+	return ri.rds.Scan(table.RootPage, func(rowid int64, values []any) error {
+		var row = make(map[string]string, len(columns))
+		for i, col := range columns {
+			if i >= len(values) {
+				break
+			}
+			switch v := values[i].(type) {
+			case nil:
+				if col == pk {
+					row[strings.ToLower(col)] = strconv.FormatInt(rowid, 10)
+					break
+				}
+				row[strings.ToLower(col)] = ""
+			case string:
+				row[strings.ToLower(col)] = v
+			case int64:
+				row[strings.ToLower(col)] = strconv.FormatInt(v, 10)
+			case float64:
+				row[strings.ToLower(col)] = strconv.FormatFloat(v, 'f', -1, 64)
+			case []byte:
+				row[strings.ToLower(col)] = string(v)
+			}
+		}
+		return fn(row)
+	})
+}
+
+// record converts a row of table to a record of the legacy layout
+func (ri *rdsImport) record(table string, row map[string]string) map[string]string {
+	var record = map[string]string{}
+	for col, field := range rdsv3Fields[table] {
+		record[field] = row[col]
+	}
+	if table == "FILE" {
+		record["OpSystemCode"] = ri.opSystem(row["package_id"])
+		record["SpecialCode"] = ""
+	}
+	return record
+}
+
+// keys returns the badger keys of a row of table
+func (ri *rdsImport) keys(table string, row map[string]string) []string {
+	if table != "FILE" {
+		if k, ok := rdsv3Keys[table]; ok && row[k[1]] != "" {
+			return []string{k[0] + row[k[1]]}
+		}
+		return nil
+	}
+	var keys []string
+	for _, digest := range ri.digests {
+		if sum := strings.ToUpper(row[rdsv3Digests[digest]]); sum != "" {
+			keys = append(keys, digest+"-"+sum)
+		}
+	}
+	return keys
+}
+
+// opSystem returns the operating system of a package. packages of previous imports are read from badger
+func (ri *rdsImport) opSystem(packageID string) string {
+	if osCode, ok := ri.pkgOS[packageID]; ok {
+		return osCode
+	}
+	var osCode string
+	if err := ri.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(keyProd + packageID))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			dec, err := snappy.Decode(nil, val)
+			if err != nil {
+				return err
+			}
+			var ds []map[string]string
+			if err := json.Unmarshal(dec, &ds); err != nil {
+				return err
+			}
+			if len(ds) > 0 {
+				osCode = ds[0]["OpSystemCode"]
+			}
+			return nil
+		})
+	}); err != nil && err != badger.ErrKeyNotFound {
+		fmt.Printf("cannot read package %s: %v\n", packageID, err)
+	}
+	ri.pkgOS[packageID] = osCode
+	return osCode
+}
+
+func (ri *rdsImport) add(key string, record map[string]string) error {
+	// keep the order of deletes and inserts
+	if len(ri.removes) > 0 {
+		if err := ri.flush(); err != nil {
+			return err
+		}
+	}
+	ri.batch[key] = append(ri.batch[key], record)
+	if len(ri.batch) >= 1000 {
+		return ri.flush()
+	}
+	return nil
+}
+
+// remove deletes the records of key, which contain all fields of match
+func (ri *rdsImport) remove(key string, match map[string]string) error {
+	if len(ri.batch) > 0 {
+		if err := ri.flush(); err != nil {
+			return err
+		}
+	}
+	ri.removes[key] = append(ri.removes[key], match)
+	if len(ri.removes) >= 1000 {
+		return ri.flush()
+	}
+	return nil
+}
+
+// flushRemoves deletes the collected records from badger. keys without records are deleted
+func (ri *rdsImport) flushRemoves() error {
+	if len(ri.removes) == 0 {
+		return nil
+	}
+	if err := ri.db.Update(func(txn *badger.Txn) error {
+		for key, matches := range ri.removes {
+			item, err := txn.Get([]byte(key))
+			if err == badger.ErrKeyNotFound {
+				ri.current.Skipped++
+				continue
+			}
+			if err != nil {
+				return errors.Wrapf(err, "cannot get key %s", key)
+			}
+			var ds []map[string]string
+			if err := item.Value(func(val []byte) error {
+				dec, err := snappy.Decode(nil, val)
+				if err != nil {
+					return errors.Wrapf(err, "cannot decode %s", key)
+				}
+				return errors.Wrapf(json.Unmarshal(dec, &ds), "cannot unmarshal %s", string(dec))
+			}); err != nil {
+				return err
+			}
+			var kept []map[string]string
+			for _, d := range ds {
+				if !slices.ContainsFunc(matches, func(match map[string]string) bool { return matchRecord(d, match) }) {
+					kept = append(kept, d)
+				}
+			}
+			ri.current.Deleted += int64(len(ds) - len(kept))
+			if len(kept) == 0 {
+				if err := txn.Delete([]byte(key)); err != nil {
+					return errors.Wrapf(err, "cannot delete %s", key)
+				}
+				continue
+			}
+			d, err := json.Marshal(kept)
+			if err != nil {
+				return errors.Wrapf(err, "cannot marshal data %v", kept)
+			}
+			if err := txn.Set([]byte(key), snappy.Encode(nil, d)); err != nil {
+				return errors.Wrapf(err, "cannot store %s", key)
+			}
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "cannot delete records")
+	}
+	ri.removes = map[string][]map[string]string{}
+	return nil
+}
+
+// matchRecord checks, whether record contains all fields of match
+func matchRecord(record, match map[string]string) bool {
+	for key, val := range match {
+		if record[key] != val {
+			return false
+		}
+	}
+	return true
+}
+
+// flush merges the collected records with the existing ones and writes them to badger
+func (ri *rdsImport) flush() error {
+	if err := ri.flushRemoves(); err != nil {
+		return err
+	}
+	if len(ri.batch) == 0 {
+		return nil
+	}
+	if err := ri.db.View(func(txn *badger.Txn) error {
+		for key, list := range ri.batch {
+			item, err := txn.Get([]byte(key))
+			if err == badger.ErrKeyNotFound {
+				ri.current.Keys++
+				continue
+			}
+			if err != nil {
+				return errors.Wrapf(err, "cannot get key %s", key)
+			}
+			if err := item.Value(func(val []byte) error {
+				dec, err := snappy.Decode(nil, val)
+				if err != nil {
+					return errors.Wrapf(err, "cannot decode %s", key)
+				}
+				var ds []map[string]string
+				if err := json.Unmarshal(dec, &ds); err != nil {
+					return errors.Wrapf(err, "cannot unmarshal %s", string(dec))
+				}
+				if ri.checkonly && len(ds) > 0 {
+					ri.current.Skipped++
+					delete(ri.batch, key)
+					return nil
+				}
+				ri.current.Merged++
+				ri.batch[key] = appendIfNotExists(ds, list...)
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "cannot read existing records")
+	}
+	if err := ri.db.Update(func(txn *badger.Txn) error {
+		for key, list := range ri.batch {
+			if ri.checkonly && len(list) > 1 {
+				list = list[:1]
+			}
+			d, err := json.Marshal(appendIfNotExists(nil, list...))
+			if err != nil {
+				return errors.Wrapf(err, "cannot marshal data %v", list)
+			}
+			if err := txn.Set([]byte(key), snappy.Encode(nil, d)); err != nil {
+				return errors.Wrapf(err, "cannot store %s", key)
+			}
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "cannot store records")
+	}
+	fmt.Printf("%s: %v rows    \r", ri.current.Table, ri.current.Rows)
+	ri.batch = map[string][]map[string]string{}
+	return nil
+}
+
+// insert adds a row of table to all its keys
+func (ri *rdsImport) insert(table string, row map[string]string) error {
+	record := ri.record(table, row)
+	for _, key := range ri.keys(table, row) {
+		if err := ri.add(key, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ri *rdsImport) importTable(name string, fn func(row map[string]string) error) error {
+	defer fmt.Println()
+	ri.current = &rdsStats{Table: name}
+	ri.stats = append(ri.stats, ri.current)
+	if err := ri.rdsTable(name, func(row map[string]string) error {
+		ri.current.Rows++
+		return fn(row)
+	}); err != nil {
+		return errors.Wrapf(err, "cannot import table %s", name)
+	}
+	return ri.flush()
+}
+
+// openRDSv3 opens the sqlite database or the sql script of a delta release.
+// zip archives are extracted to tempDir first. the returned function removes temporary files
+func openRDSv3(rdsFile string, tempDir string) (fp *os.File, delta bool, cleanup func(), err error) {
+	if !strings.EqualFold(filepath.Ext(rdsFile), ".zip") {
+		fp, err := os.Open(rdsFile)
+		if err != nil {
+			return nil, false, nil, errors.Wrapf(err, "cannot open %s", rdsFile)
+		}
+		return fp, strings.EqualFold(filepath.Ext(rdsFile), ".sql"), func() { fp.Close() }, nil
+	}
+	zr, err := zip.OpenReader(rdsFile)
+	if err != nil {
+		return nil, false, nil, errors.Wrapf(err, "cannot open zip file %s", rdsFile)
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		ext := strings.ToLower(filepath.Ext(f.Name))
+		if ext != ".db" && ext != ".sql" {
+			continue
+		}
+		fmt.Printf("extracting %s\n", f.Name)
+		r, err := f.Open()
+		if err != nil {
+			return nil, false, nil, errors.Wrapf(err, "cannot open %s", f.Name)
+		}
+		defer r.Close()
+		fp, err := os.CreateTemp(tempDir, "rdsv3")
+		if err != nil {
+			return nil, false, nil, errors.Wrap(err, "cannot create temp file")
+		}
+		cleanup := func() {
+			fp.Close()
+			os.Remove(fp.Name())
+		}
+		if _, err := io.Copy(fp, r); err != nil {
+			cleanup()
+			return nil, false, nil, errors.Wrapf(err, "cannot extract %s", f.Name)
+		}
+		if _, err := fp.Seek(0, io.SeekStart); err != nil {
+			cleanup()
+			return nil, false, nil, errors.Wrapf(err, "cannot rewind %s", fp.Name())
+		}
+		return fp, ext == ".sql", cleanup, nil
+	}
+	return nil, false, nil, errors.Errorf("no sqlite database or sql script in %s", rdsFile)
+}
+
+// rdsv3 imports the tables FILE, PKG, OS and MFG of a RDSv3 sqlite database.
+// several build sets (modern, android, ios, legacy) of the same version can be merged
+// into one badger folder. a new version must be imported into a new folder, since
+// records removed by NIST would remain in the merged records.
+// delta releases (sql scripts) are applied to the records of the previous import
+func rdsv3(rdsFile, badgerFolder, checksum, tempDir string, checkonly bool) error {
+	var digests []string
+	for _, c := range strings.Split(strings.ToUpper(checksum), ",") {
+		c = strings.TrimSpace(c)
+		if _, ok := rdsv3Digests[c]; !ok {
+			return fmt.Errorf("invalid checksum type: %s", c)
+		}
+		digests = append(digests, c)
+	}
+
+	stat2, err := os.Stat(badgerFolder)
+	if err != nil {
+		return errors.Wrapf(err, "cannot stat badger folder %s", badgerFolder)
+	}
+	if !stat2.IsDir() {
+		return fmt.Errorf("%s is not a directory", badgerFolder)
+	}
+
+	fp, delta, cleanup, err := openRDSv3(rdsFile, tempDir)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer cleanup()
+
+	bconfig := badger.DefaultOptions(badgerFolder)
+	db, err := badger.Open(bconfig)
+	if err != nil {
+		return errors.Wrapf(err, "cannot open badger database")
+	}
+	defer db.Close()
+
+	var previous string
+	if err := db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(keyVersion))
+		if err != nil {
+			return nil
+		}
+		return item.Value(func(val []byte) error {
+			previous = string(val)
+			return nil
+		})
+	}); err != nil {
+		return errors.Wrap(err, "cannot read imported version")
+	}
+	ri := &rdsImport{
+		db:        db,
+		digests:   digests,
+		checkonly: checkonly,
+		batch:     map[string][]map[string]string{},
+		removes:   map[string][]map[string]string{},
+		pkgOS:     map[string]string{},
+	}
+	if delta {
+		if previous == "" {
+			return errors.New("cannot import delta release: no previous rds version imported")
+		}
+		return ri.delta(fp, rdsFile, previous)
+	}
+
+	stat, err := fp.Stat()
+	if err != nil {
+		return errors.Wrapf(err, "cannot stat %s", fp.Name())
+	}
+	if ri.rds, err = sqlite.Open(fp, stat.Size()); err != nil {
+		return errors.Wrapf(err, "cannot open rdsv3 database %s", rdsFile)
+	}
+
+	var version = map[string]string{}
+	if err := ri.rdsTable("VERSION", func(row map[string]string) error {
+		version = row
+		return nil
+	}); err != nil {
+		fmt.Printf("no version information: %v\n", err)
+	}
+	fmt.Printf("rds version: %s %s (previous: %s)\n", version["version"], version["build_set"], previous)
+	if previous != "" && version["version"] != previous {
+		return errors.Errorf("badger folder contains rds version %s: import version '%s' into a new folder", previous, version["version"])
+	}
+	historyKey := []byte(keyVersion + "-" + version["version"] + "-" + version["build_set"])
+	if previous != "" {
+		if err := db.View(func(txn *badger.Txn) error {
+			_, err := txn.Get(historyKey)
+			return err
+		}); err == nil {
+			return errors.Errorf("rds version %s %s already imported", previous, version["build_set"])
+		}
+	}
+
+	start := time.Now()
+	// file records refer to the operating system of the package, so PKG is imported before FILE
+	for _, table := range []string{"MFG", "OS", "PKG", "FILE"} {
+		if err := ri.importTable(table, func(row map[string]string) error {
+			if table == "PKG" {
+				ri.pkgOS[row["package_id"]] = row["operating_system_id"]
+			}
+			return ri.insert(table, row)
+		}); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	if err := ri.storeVersion(version, previous, false); err != nil {
+		return errors.WithStack(err)
+	}
+
+	fmt.Printf("import of %s finished in %v\n", rdsFile, time.Since(start))
+	printStats(ri.stats)
+	return nil
+}
+
+// storeVersion stores the imported version and its history with the statistics
+func (ri *rdsImport) storeVersion(version map[string]string, previous string, delta bool) error {
+	if version["version"] == "" {
+		return nil
+	}
+	history, err := json.Marshal(map[string]any{
+		"version":  version,
+		"previous": previous,
+		"delta":    delta,
+		"digests":  ri.digests,
+		"imported": time.Now().Format(time.RFC3339),
+		"stats":    ri.stats,
+	})
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal version history")
+	}
+	if err := ri.db.Update(func(txn *badger.Txn) error {
+		if err := txn.Set([]byte(keyVersion), []byte(version["version"])); err != nil {
+			return err
+		}
+		return txn.Set([]byte(keyVersion+"-"+version["version"]+"-"+version["build_set"]), history)
+	}); err != nil {
+		return errors.Wrap(err, "cannot store rds version")
+	}
+	return nil
+}
+
+func printStats(stats []*rdsStats) {
+	fmt.Printf("%-8s %12s %12s %12s %12s %12s\n", "table", "rows", "new keys", "merged", "deleted", "skipped")
+	for _, s := range stats {
+		fmt.Printf("%-8s %12d %12d %12d %12d %12d\n", s.Table, s.Rows, s.Keys, s.Merged, s.Deleted, s.Skipped)
+	}
+}
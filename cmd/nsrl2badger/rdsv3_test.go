@@ -0,0 +1,169 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/golang/snappy"
+)
+
+// testZip packs the files into a zip archive in a temp folder
+func testZip(t *testing.T, files map[string][]byte) string {
+	name := filepath.Join(t.TempDir(), "rds.zip")
+	fp, err := os.Create(name)
+	if err != nil {
+		t.Fatalf("cannot create zip: %v", err)
+	}
+	defer fp.Close()
+	zw := zip.NewWriter(fp)
+	for fname, data := range files {
+		w, err := zw.Create(fname)
+		if err != nil {
+			t.Fatalf("cannot create %s: %v", fname, err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("cannot close zip: %v", err)
+	}
+	return name
+}
+
+func readRecords(t *testing.T, badgerFolder, key string) []map[string]string {
+	db, err := badger.Open(badger.DefaultOptions(badgerFolder).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("cannot open badger: %v", err)
+	}
+	defer db.Close()
+	var records []map[string]string
+	if err := db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			dec, err := snappy.Decode(nil, val)
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal(dec, &records)
+		})
+	}); err != nil {
+		t.Fatalf("cannot read %s: %v", key, err)
+	}
+	return records
+}
+
+func TestRDSv3(t *testing.T) {
+	badgerFolder := t.TempDir()
+	if err := rdsv3("testdata/rds_modern.db", badgerFolder, "SHA-256,MD5", t.TempDir(), false); err != nil {
+		t.Fatalf("cannot import modern set: %v", err)
+	}
+	// the same version of another build set is merged
+	android, err := os.ReadFile("testdata/rds_android.db")
+	if err != nil {
+		t.Fatalf("cannot read android set: %v", err)
+	}
+	if err := rdsv3(testZip(t, map[string][]byte{"RDS_android/RDS_android.db": android}), badgerFolder, "SHA-256,MD5", t.TempDir(), false); err != nil {
+		t.Fatalf("cannot import android set: %v", err)
+	}
+	if err := rdsv3("testdata/rds_modern.db", badgerFolder, "SHA-256", t.TempDir(), false); err == nil {
+		t.Error("second import of modern set not refused")
+	}
+	if err := rdsv3("testdata/rds_next.db", badgerFolder, "SHA-256", t.TempDir(), false); err == nil {
+		t.Error("import of new version into old folder not refused")
+	}
+
+	records := readRecords(t, badgerFolder, "SHA-256-"+strings.Repeat("A", 64))
+	if len(records) != 2 || records[0]["FileName"] != "tool.exe" || records[1]["FileName"] != "tool.apk" {
+		t.Errorf("wrong merged records: %v", records)
+	}
+	records = readRecords(t, badgerFolder, "MD5-"+strings.Repeat("F", 32))
+	if len(records) != 1 || records[0]["ProductCode"] != "100" || records[0]["OpSystemCode"] != "10" {
+		t.Errorf("wrong md5 record: %v", records)
+	}
+	records = readRecords(t, badgerFolder, keyProd+"100")
+	if len(records) != 1 || records[0]["ProductName"] != "Example Tool" || records[0]["MfgCode"] != "1" {
+		t.Errorf("wrong product record: %v", records)
+	}
+	records = readRecords(t, badgerFolder, keyOS+"10")
+	if len(records) != 1 || records[0]["OpSystemName"] != "ExampleOS" {
+		t.Errorf("wrong os record: %v", records)
+	}
+}
+
+const testDelta = `-- delta 2024.03.1 -> 2024.06.1
+PRAGMA foreign_keys=OFF;
+BEGIN TRANSACTION;
+DELETE FROM FILE WHERE sha256 = 'dddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddd' AND md5 = 'ffffffffffffffffffffffffffffffff' AND package_id = 100;
+INSERT INTO PKG VALUES(101,'Example Tool','3.0',10,1,'English','Utility');
+INSERT INTO "FILE" (sha256, sha1, md5, crc32, file_name, file_size, package_id) VALUES
+	('1111111111111111111111111111111111111111111111111111111111111111','2222222222222222222222222222222222222222','33333333333333333333333333333333','00000000','tool.exe',4096,101),
+	('aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa','bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb','cccccccccccccccccccccccccccccccc','00000000','it''s; a tool.exe',1024,100);
+/* the version of the delta */
+UPDATE VERSION SET version = '2024.06.1', description = 'delta release';
+COMMIT;
+`
+
+func TestRDSv3Delta(t *testing.T) {
+	delta := testZip(t, map[string][]byte{"RDS_delta/RDS_delta.sql": []byte(testDelta)})
+	if err := rdsv3(delta, t.TempDir(), "SHA-256,MD5", t.TempDir(), false); err == nil {
+		t.Error("delta without previous import not refused")
+	}
+	badgerFolder := t.TempDir()
+	if err := rdsv3("testdata/rds_modern.db", badgerFolder, "SHA-256,MD5", t.TempDir(), false); err != nil {
+		t.Fatalf("cannot import modern set: %v", err)
+	}
+	if err := rdsv3(delta, badgerFolder, "SHA-256,MD5", t.TempDir(), false); err != nil {
+		t.Fatalf("cannot apply delta: %v", err)
+	}
+
+	db, err := badger.Open(badger.DefaultOptions(badgerFolder).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("cannot open badger: %v", err)
+	}
+	if err := db.View(func(txn *badger.Txn) error {
+		for _, key := range []string{"SHA-256-" + strings.Repeat("D", 64), "MD5-" + strings.Repeat("F", 32)} {
+			if _, err := txn.Get([]byte(key)); err != badger.ErrKeyNotFound {
+				t.Errorf("deleted key %s: %v", key, err)
+			}
+		}
+		item, err := txn.Get([]byte(keyVersion))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			if string(val) != "2024.06.1" {
+				t.Errorf("wrong version %s", string(val))
+			}
+			return nil
+		})
+	}); err != nil {
+		t.Errorf("cannot read badger: %v", err)
+	}
+	db.Close()
+
+	records := readRecords(t, badgerFolder, "SHA-256-"+strings.Repeat("1", 64))
+	if len(records) != 1 || records[0]["ProductCode"] != "101" || records[0]["OpSystemCode"] != "10" || records[0]["FileSize"] != "4096" {
+		t.Errorf("wrong inserted record: %v", records)
+	}
+	// the operating system of a package from the previous import
+	records = readRecords(t, badgerFolder, "MD5-"+strings.Repeat("C", 32))
+	if len(records) != 2 || records[1]["FileName"] != "it's; a tool.exe" || records[1]["OpSystemCode"] != "10" {
+		t.Errorf("wrong merged record: %v", records)
+	}
+	records = readRecords(t, badgerFolder, keyProd+"101")
+	if len(records) != 1 || records[0]["ProductVersion"] != "3.0" {
+		t.Errorf("wrong product record: %v", records)
+	}
+
+	unsupported := testZip(t, map[string][]byte{"RDS_delta/RDS_delta.sql": []byte("DELETE FROM FILE;\n")})
+	if err := rdsv3(unsupported, badgerFolder, "SHA-256", t.TempDir(), false); err == nil {
+		t.Error("delete of all files not refused")
+	}
+}
@@ -0,0 +1,563 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bufio"
+	"emperror.dev/errors"
+	"fmt"
+	"github.com/je4/indexer/v3/pkg/sqlite"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// rdsv3Columns is the column order of the RDSv3 tables, if the delta script does not create them
+var rdsv3Columns = map[string][]string{
+	"VERSION": {"version", "build_set", "build_date", "release_date", "description"},
+	"MFG":     {"manufacturer_id", "name"},
+	"OS":      {"operating_system_id", "name", "version", "manufacturer_id"},
+	"PKG":     {"package_id", "name", "version", "operating_system_id", "manufacturer_id", "language", "application_type"},
+	"FILE":    {"sha256", "sha1", "md5", "crc32", "file_name", "file_size", "package_id"},
+}
+
+// maximum length of the statement text kept for CREATE TABLE and error messages
+const sqlMaxRaw = 64 * 1024
+
+const (
+	sqlIdent  = 'i'
+	sqlString = 's'
+	sqlNumber = 'n'
+	sqlSymbol = 'p'
+)
+
+type sqlToken struct {
+	kind byte
+	text string
+}
+
+type sqlStatement struct {
+	raw    string
+	tokens []sqlToken
+}
+
+// sqlScanner splits a sql script into statements. it knows enough sql for the
+// delta scripts of NIST: strings, quoted identifiers, numbers and comments
+type sqlScanner struct {
+	br *bufio.Reader
+}
+
+func newSQLScanner(r io.Reader) *sqlScanner {
+	return &sqlScanner{br: bufio.NewReaderSize(r, 64*1024)}
+}
+
+// next returns the next non-empty statement or io.EOF
+func (s *sqlScanner) next() (*sqlStatement, error) {
+	var stmt = &sqlStatement{}
+	var raw strings.Builder
+	for {
+		r, _, err := s.br.ReadRune()
+		if err == io.EOF {
+			if len(stmt.tokens) == 0 {
+				return nil, io.EOF
+			}
+			stmt.raw = raw.String()
+			return stmt, nil
+		}
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if r == ';' {
+			if len(stmt.tokens) == 0 {
+				raw.Reset()
+				continue
+			}
+			stmt.raw = raw.String()
+			return stmt, nil
+		}
+		var tok sqlToken
+		switch {
+		case unicode.IsSpace(r):
+			continue
+		case r == '-' && s.peek() == '-':
+			if _, err := s.br.ReadString('\n'); err != nil && err != io.EOF {
+				return nil, errors.WithStack(err)
+			}
+			continue
+		case r == '/' && s.peek() == '*':
+			if err := s.skipComment(); err != nil {
+				return nil, err
+			}
+			continue
+		case r == '\'':
+			text, err := s.quoted('\'')
+			if err != nil {
+				return nil, err
+			}
+			tok = sqlToken{kind: sqlString, text: text}
+		case r == '"' || r == '`' || r == '[':
+			end := r
+			if r == '[' {
+				end = ']'
+			}
+			text, err := s.quoted(end)
+			if err != nil {
+				return nil, err
+			}
+			tok = sqlToken{kind: sqlIdent, text: text}
+		case unicode.IsDigit(r) || (r == '-' || r == '+' || r == '.') && unicode.IsDigit(s.peek()):
+			tok = sqlToken{kind: sqlNumber, text: string(r) + s.while(func(r rune) bool {
+				return unicode.IsDigit(r) || r == '.' || r == 'e' || r == 'E'
+			})}
+		case unicode.IsLetter(r) || r == '_':
+			tok = sqlToken{kind: sqlIdent, text: string(r) + s.while(func(r rune) bool {
+				return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$'
+			})}
+		default:
+			tok = sqlToken{kind: sqlSymbol, text: string(r)}
+		}
+		stmt.tokens = append(stmt.tokens, tok)
+		if raw.Len() < sqlMaxRaw {
+			if len(stmt.tokens) > 1 {
+				raw.WriteByte(' ')
+			}
+			raw.WriteString(tok.text)
+		}
+	}
+}
+
+func (s *sqlScanner) peek() rune {
+	r, _, err := s.br.ReadRune()
+	if err != nil {
+		return 0
+	}
+	_ = s.br.UnreadRune()
+	return r
+}
+
+func (s *sqlScanner) while(fn func(r rune) bool) string {
+	var sb strings.Builder
+	for {
+		r, _, err := s.br.ReadRune()
+		if err != nil {
+			return sb.String()
+		}
+		if !fn(r) {
+			_ = s.br.UnreadRune()
+			return sb.String()
+		}
+		sb.WriteRune(r)
+	}
+}
+
+// quoted reads up to the closing quote. a doubled quote is an escaped quote
+func (s *sqlScanner) quoted(end rune) (string, error) {
+	var sb strings.Builder
+	for {
+		r, _, err := s.br.ReadRune()
+		if err != nil {
+			return "", errors.Wrap(err, "unterminated quote")
+		}
+		if r == end {
+			if end != ']' && s.peek() == end {
+				s.br.ReadRune()
+				sb.WriteRune(r)
+				continue
+			}
+			return sb.String(), nil
+		}
+		sb.WriteRune(r)
+	}
+}
+
+func (s *sqlScanner) skipComment() error {
+	s.br.ReadRune()
+	var last rune
+	for {
+		r, _, err := s.br.ReadRune()
+		if err != nil {
+			return errors.Wrap(err, "unterminated comment")
+		}
+		if last == '*' && r == '/' {
+			return nil
+		}
+		last = r
+	}
+}
+
+type sqlParser struct {
+	tokens []sqlToken
+	pos    int
+}
+
+func (p *sqlParser) next() sqlToken {
+	if p.pos >= len(p.tokens) {
+		return sqlToken{}
+	}
+	p.pos++
+	return p.tokens[p.pos-1]
+}
+
+func (p *sqlParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+// is consumes the next token, if it is one of the keywords or symbols
+func (p *sqlParser) is(words ...string) bool {
+	if p.done() || p.tokens[p.pos].kind == sqlString {
+		return false
+	}
+	for _, w := range words {
+		if strings.EqualFold(p.tokens[p.pos].text, w) {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *sqlParser) expect(word string) error {
+	if !p.is(word) {
+		return errors.Errorf("'%s' expected at token %d", word, p.pos)
+	}
+	return nil
+}
+
+// table returns the upper case table name without schema
+func (p *sqlParser) table() (string, error) {
+	tok := p.next()
+	if tok.kind != sqlIdent {
+		return "", errors.Errorf("table name expected at token %d", p.pos)
+	}
+	if p.is(".") {
+		if tok = p.next(); tok.kind != sqlIdent {
+			return "", errors.Errorf("table name expected at token %d", p.pos)
+		}
+	}
+	return strings.ToUpper(tok.text), nil
+}
+
+func (p *sqlParser) value() (string, error) {
+	tok := p.next()
+	switch tok.kind {
+	case sqlString, sqlNumber:
+		return tok.text, nil
+	case sqlIdent:
+		if strings.EqualFold(tok.text, "NULL") {
+			return "", nil
+		}
+	}
+	return "", errors.Errorf("value expected at token %d", p.pos)
+}
+
+// list reads a parenthesized list of values or identifiers
+func (p *sqlParser) list(ident bool) ([]string, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var values []string
+	for {
+		if ident {
+			tok := p.next()
+			if tok.kind != sqlIdent {
+				return nil, errors.Errorf("column name expected at token %d", p.pos)
+			}
+			values = append(values, strings.ToLower(tok.text))
+		} else {
+			val, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, val)
+		}
+		if p.is(")") {
+			return values, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// where reads the conditions "col = value" and "col IN (values)" combined with AND.
+// every combination of the IN lists results in one row
+func (p *sqlParser) where() ([]map[string]string, error) {
+	var rows = []map[string]string{{}}
+	for {
+		tok := p.next()
+		if tok.kind != sqlIdent {
+			return nil, errors.Errorf("column name expected at token %d", p.pos)
+		}
+		col := strings.ToLower(tok.text)
+		var values []string
+		switch {
+		case p.is("="):
+			// "==" is scanned as two symbols
+			p.is("=")
+			val, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			values = []string{val}
+		case p.is("IN"):
+			var err error
+			if values, err = p.list(false); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errors.Errorf("unsupported condition on %s", col)
+		}
+		var expanded []map[string]string
+		for _, row := range rows {
+			for _, val := range values {
+				r := make(map[string]string, len(row)+1)
+				for k, v := range row {
+					r[k] = v
+				}
+				r[col] = val
+				expanded = append(expanded, r)
+			}
+		}
+		rows = expanded
+		if p.done() {
+			return rows, nil
+		}
+		if err := p.expect("AND"); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// switchTable directs the statistics to table
+func (ri *rdsImport) switchTable(table string) error {
+	if ri.current != nil && ri.current.Table == table {
+		return nil
+	}
+	if ri.current != nil {
+		if err := ri.flush(); err != nil {
+			return err
+		}
+	}
+	for _, s := range ri.stats {
+		if s.Table == table {
+			ri.current = s
+			return nil
+		}
+	}
+	ri.current = &rdsStats{Table: table}
+	ri.stats = append(ri.stats, ri.current)
+	return nil
+}
+
+// delta applies the inserts and deletes of a delta release to the records of the previous import
+func (ri *rdsImport) delta(r io.Reader, rdsFile, previous string) error {
+	start := time.Now()
+	var version = map[string]string{"version": previous}
+	var columns = map[string][]string{}
+	for table, cols := range rdsv3Columns {
+		columns[table] = cols
+	}
+	scanner := newSQLScanner(r)
+	for {
+		stmt, err := scanner.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrapf(err, "cannot read %s", rdsFile)
+		}
+		if err := ri.exec(stmt, columns, version); err != nil {
+			return errors.Wrapf(err, "cannot apply '%.80s'", stmt.raw)
+		}
+	}
+	if ri.current != nil {
+		if err := ri.flush(); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	fmt.Printf("rds version: %s %s (previous: %s)\n", version["version"], version["build_set"], previous)
+	if err := ri.storeVersion(version, previous, true); err != nil {
+		return errors.WithStack(err)
+	}
+	fmt.Printf("delta %s applied in %v\n", rdsFile, time.Since(start))
+	printStats(ri.stats)
+	return nil
+}
+
+// exec applies a single statement. transaction control and schema changes are ignored
+func (ri *rdsImport) exec(stmt *sqlStatement, columns map[string][]string, version map[string]string) error {
+	p := &sqlParser{tokens: stmt.tokens}
+	switch {
+	case p.is("BEGIN", "COMMIT", "END", "PRAGMA", "DROP", "ANALYZE", "VACUUM", "REINDEX"):
+		return nil
+	case p.is("CREATE"):
+		if p.is("TABLE") {
+			p.is("IF")
+			p.is("NOT")
+			p.is("EXISTS")
+			table, err := p.table()
+			if err != nil {
+				return err
+			}
+			columns[table] = sqlite.Columns(stmt.raw)
+			for i, col := range columns[table] {
+				columns[table][i] = strings.ToLower(col)
+			}
+		}
+		return nil
+	case p.is("INSERT", "REPLACE"):
+		if p.is("OR") {
+			p.next()
+		}
+		if err := p.expect("INTO"); err != nil {
+			return err
+		}
+		table, err := p.table()
+		if err != nil {
+			return err
+		}
+		cols := columns[table]
+		if !p.is("VALUES") {
+			if cols, err = p.list(true); err != nil {
+				return err
+			}
+			if err := p.expect("VALUES"); err != nil {
+				return err
+			}
+		}
+		for {
+			values, err := p.list(false)
+			if err != nil {
+				return err
+			}
+			if len(values) != len(cols) {
+				return errors.Errorf("%d values for %d columns of %s", len(values), len(cols), table)
+			}
+			row := make(map[string]string, len(cols))
+			for i, col := range cols {
+				row[col] = values[i]
+			}
+			if err := ri.execInsert(table, row, version); err != nil {
+				return err
+			}
+			if !p.is(",") {
+				return nil
+			}
+		}
+	case p.is("DELETE"):
+		if err := p.expect("FROM"); err != nil {
+			return err
+		}
+		table, err := p.table()
+		if err != nil {
+			return err
+		}
+		if table == "VERSION" {
+			// the new version is inserted afterwards
+			return nil
+		}
+		if _, ok := rdsv3Fields[table]; !ok {
+			return nil
+		}
+		if !p.is("WHERE") {
+			return errors.Errorf("delete of all rows of %s not supported", table)
+		}
+		rows, err := p.where()
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := ri.execDelete(table, row); err != nil {
+				return err
+			}
+		}
+		return nil
+	case p.is("UPDATE"):
+		table, err := p.table()
+		if err != nil {
+			return err
+		}
+		if table != "VERSION" {
+			return errors.Errorf("update of %s not supported", table)
+		}
+		if err := p.expect("SET"); err != nil {
+			return err
+		}
+		for {
+			tok := p.next()
+			if tok.kind != sqlIdent {
+				return errors.Errorf("column name expected at token %d", p.pos)
+			}
+			if err := p.expect("="); err != nil {
+				return err
+			}
+			val, err := p.value()
+			if err != nil {
+				return err
+			}
+			version[strings.ToLower(tok.text)] = val
+			if !p.is(",") {
+				return nil
+			}
+		}
+	}
+	return errors.New("unsupported statement")
+}
+
+func (ri *rdsImport) execInsert(table string, row map[string]string, version map[string]string) error {
+	if table == "VERSION" {
+		clear(version)
+		for col, val := range row {
+			version[col] = val
+		}
+		return nil
+	}
+	if _, ok := rdsv3Fields[table]; !ok {
+		return nil
+	}
+	if err := ri.switchTable(table); err != nil {
+		return err
+	}
+	ri.current.Rows++
+	if table == "PKG" {
+		ri.pkgOS[row["package_id"]] = row["operating_system_id"]
+	}
+	return ri.insert(table, row)
+}
+
+// execDelete removes the records with the fields of the condition from the keys of the row.
+// file records can only be found by the digests in the condition
+func (ri *rdsImport) execDelete(table string, row map[string]string) error {
+	if err := ri.switchTable(table); err != nil {
+		return err
+	}
+	ri.current.Rows++
+	var match = map[string]string{}
+	for col, val := range row {
+		if field, ok := rdsv3Fields[table][col]; ok {
+			match[field] = val
+		}
+	}
+	keys := ri.keys(table, row)
+	if len(keys) == 0 || table == "FILE" && len(keys) < len(ri.digests) {
+		ri.current.Skipped++
+	}
+	for _, key := range keys {
+		if err := ri.remove(key, match); err != nil {
+			return err
+		}
+	}
+	return nil
+}
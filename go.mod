@@ -8,6 +8,7 @@ go 1.24.0
 require (
 	emperror.dev/errors v0.8.1
 	github.com/BurntSushi/toml v1.4.0
+	github.com/andybalholm/brotli v1.1.1
 	github.com/dgraph-io/badger/v4 v4.6.0
 	github.com/gabriel-vasile/mimetype v1.4.8
 	github.com/golang/snappy v0.0.4
@@ -15,19 +16,20 @@ require (
 	github.com/gorilla/mux v1.8.1
 	github.com/hooklift/iso9660 v1.0.0
 	github.com/je4/filesystem/v3 v3.0.25
-	github.com/je4/goffmpeg v0.0.0-20220114092308-33ab9986404d
 	github.com/je4/utils/v2 v2.0.58
 	github.com/ocfl-archive/error v1.0.5
 	github.com/op/go-logging v0.0.0-20160315200505-970db520ece7
 	github.com/phayes/freeport v0.0.0-20220201140144-74d24b5ae9f5
 	github.com/pkg/sftp v1.13.7
+	github.com/richardlehane/mscfb v1.0.4
 	github.com/richardlehane/siegfried v1.11.2
 	github.com/rs/zerolog v1.33.0
-	github.com/tamerh/xml-stream-parser v1.5.0
 	gitlab.switch.ch/ub-unibas/go-ublogger/v2 v2.0.1
 	go.ub.unibas.ch/cloud/certloader/v2 v2.0.18
 	golang.org/x/crypto v0.35.0
 	golang.org/x/exp v0.0.0-20250228200357-dead58393ab7
+	golang.org/x/image v0.24.0
+	golang.org/x/net v0.36.0
 )
 
 require (
@@ -83,7 +85,6 @@ require (
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/richardlehane/characterize v1.0.0 // indirect
 	github.com/richardlehane/match v1.0.5 // indirect
-	github.com/richardlehane/mscfb v1.0.4 // indirect
 	github.com/richardlehane/msoleps v1.0.4 // indirect
 	github.com/richardlehane/xmldetect v1.0.2 // indirect
 	github.com/ross-spencer/spargo v0.4.1 // indirect
@@ -93,7 +94,6 @@ require (
 	github.com/swaggo/files v1.0.1 // indirect
 	github.com/swaggo/gin-swagger v1.6.0 // indirect
 	github.com/swaggo/swag v1.16.4 // indirect
-	github.com/tamerh/xpath v1.0.0 // indirect
 	github.com/telkomdev/go-stash v1.0.6 // indirect
 	github.com/twitchyliquid64/golang-asm v0.15.1 // indirect
 	github.com/ugorji/go/codec v1.2.12 // indirect
@@ -105,8 +105,6 @@ require (
 	go.ub.unibas.ch/cloud/minivault/v2 v2.0.16 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	golang.org/x/arch v0.14.0 // indirect
-	golang.org/x/image v0.24.0 // indirect
-	golang.org/x/net v0.36.0 // indirect
 	golang.org/x/sys v0.30.0 // indirect
 	golang.org/x/text v0.22.0 // indirect
 	golang.org/x/tools v0.30.0 // indirect
@@ -65,6 +65,8 @@ func (a *ActionCapability) UnmarshalText(text []byte) error {
 
 var ErrMimeNotApplicable = errors.New("mime type not applicable for actions")
 
+var ErrConcurrencyLimit = errors.New("concurrency limit of action reached")
+
 type Action interface {
 	Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error)
 	DoV2(filename string) (*ResultV2, error)
@@ -74,3 +76,8 @@ type Action interface {
 	GetCaps() ActionCapability
 	GetWeight() uint
 }
+
+// ResultProcessor is implemented by actions, which work on the merged result of the other actions
+type ResultProcessor interface {
+	ProcessResult(result *ResultV2) error
+}
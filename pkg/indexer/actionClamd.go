@@ -0,0 +1,275 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indexer
+
+import (
+	"bufio"
+	"emperror.dev/errors"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ClamdClean    = "clean"
+	ClamdInfected = "infected"
+	ClamdError    = "error"
+)
+
+// ClamdResult is the verdict of a clamd scan
+type ClamdResult struct {
+	Status       string `json:"status"`
+	Virus        string `json:"virus,omitempty"`
+	Message      string `json:"message,omitempty"`
+	Engine       string `json:"engine,omitempty"`
+	Database     string `json:"database,omitempty"`
+	DatabaseDate string `json:"databasedate,omitempty"`
+}
+
+type clamdVersion struct {
+	engine, database, date string
+	loaded                 time.Time
+}
+
+// ActionClamd is a client for the clamd daemon using the INSTREAM command
+// see https://linux.die.net/man/8/clamd
+type ActionClamd struct {
+	name      string
+	network   string
+	address   string
+	timeout   time.Duration
+	chunkSize int
+	server    *Server
+	version   *clamdVersion
+	lock      sync.Mutex
+}
+
+func (ac *ActionClamd) CanHandle(contentType string, filename string) bool {
+	return true
+}
+
+// NewActionClamd creates a clamd client action. network is "unix" or "tcp"
+func NewActionClamd(name string, network string, address string, timeout time.Duration, chunkSize int, server *Server, ad *ActionDispatcher) Action {
+	if network == "" {
+		network = "tcp"
+	}
+	if chunkSize <= 0 {
+		chunkSize = 64 * 1024
+	}
+	ac := &ActionClamd{
+		name:      name,
+		network:   network,
+		address:   address,
+		timeout:   timeout,
+		chunkSize: chunkSize,
+		server:    server,
+	}
+	ad.RegisterAction(ac)
+	return ac
+}
+
+func (ac *ActionClamd) GetWeight() uint {
+	return 100
+}
+
+func (ac *ActionClamd) GetCaps() ActionCapability {
+	return ACTFILEFULL | ACTSTREAM
+}
+
+func (ac *ActionClamd) GetName() string {
+	return ac.name
+}
+
+func (ac *ActionClamd) dial() (net.Conn, error) {
+	conn, err := net.DialTimeout(ac.network, ac.address, ac.timeout)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot connect to clamd %s://%s", ac.network, ac.address)
+	}
+	if err := ac.extendDeadline(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// extendDeadline sets the deadline for the next network operation.
+// the timeout applies to every chunk and the reply, not to the whole upload
+func (ac *ActionClamd) extendDeadline(conn net.Conn) error {
+	if ac.timeout <= 0 {
+		return nil
+	}
+	if err := conn.SetDeadline(time.Now().Add(ac.timeout)); err != nil {
+		return errors.Wrap(err, "cannot set deadline")
+	}
+	return nil
+}
+
+// readClamdReply reads a null terminated reply
+func readClamdReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !(err == io.EOF && reply != "") {
+		return "", errors.Wrap(err, "cannot read clamd reply")
+	}
+	return strings.TrimRight(reply, "\x00\n"), nil
+}
+
+// getVersion returns the engine and database version. the version is cached for some minutes
+// since the signature database can be reloaded by clamd
+func (ac *ActionClamd) getVersion() (*clamdVersion, error) {
+	ac.lock.Lock()
+	defer ac.lock.Unlock()
+	if ac.version != nil && time.Since(ac.version.loaded) < 10*time.Minute {
+		return ac.version, nil
+	}
+	conn, err := ac.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("zVERSION\x00")); err != nil {
+		return nil, errors.Wrap(err, "cannot send VERSION command")
+	}
+	reply, err := readClamdReply(conn)
+	if err != nil {
+		return nil, err
+	}
+	// ClamAV 1.0.1/26863/Thu Mar 30 07:24:06 2023
+	parts := strings.SplitN(reply, "/", 3)
+	ac.version = &clamdVersion{engine: parts[0], loaded: time.Now()}
+	if len(parts) > 1 {
+		ac.version.database = parts[1]
+	}
+	if len(parts) > 2 {
+		ac.version.date = parts[2]
+	}
+	return ac.version, nil
+}
+
+// scan sends the data with INSTREAM. every chunk is prefixed with its length as 4 byte
+// network order integer. a zero length chunk terminates the stream
+func (ac *ActionClamd) scan(reader io.Reader) (*ClamdResult, error) {
+	conn, err := ac.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, errors.Wrap(err, "cannot send INSTREAM command")
+	}
+	var buf = make([]byte, 4+ac.chunkSize)
+	var writeErr error
+	for {
+		n, err := io.ReadFull(reader, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if err := ac.extendDeadline(conn); err != nil {
+				return nil, err
+			}
+			if _, writeErr = conn.Write(buf[:4+n]); writeErr != nil {
+				// clamd closes the connection if StreamMaxLength is exceeded, reply should be available
+				break
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read data")
+		}
+	}
+	if err := ac.extendDeadline(conn); err != nil {
+		return nil, err
+	}
+	if writeErr == nil {
+		if _, writeErr = conn.Write([]byte{0, 0, 0, 0}); writeErr != nil {
+			return nil, errors.Wrap(writeErr, "cannot terminate stream")
+		}
+	}
+	// clamd scans the data before it replies
+	if err := ac.extendDeadline(conn); err != nil {
+		return nil, err
+	}
+	reply, err := readClamdReply(conn)
+	if err != nil {
+		if writeErr != nil {
+			return nil, errors.Wrap(writeErr, "cannot send data")
+		}
+		return nil, err
+	}
+	return parseClamdReply(reply), nil
+}
+
+// parseClamdReply parses replies like "stream: OK", "stream: Eicar-Signature FOUND"
+// or "INSTREAM size limit exceeded. ERROR"
+func parseClamdReply(reply string) *ClamdResult {
+	msg := reply
+	if idx := strings.Index(reply, ": "); idx >= 0 {
+		msg = reply[idx+2:]
+	}
+	switch {
+	case msg == "OK":
+		return &ClamdResult{Status: ClamdClean}
+	case strings.HasSuffix(msg, " FOUND"):
+		return &ClamdResult{Status: ClamdInfected, Virus: strings.TrimSuffix(msg, " FOUND")}
+	case strings.HasSuffix(msg, " ERROR"):
+		return &ClamdResult{Status: ClamdError, Message: strings.TrimSuffix(msg, " ERROR")}
+	default:
+		return &ClamdResult{Status: ClamdError, Message: reply}
+	}
+}
+
+func (ac *ActionClamd) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
+	cresult, err := ac.scan(reader)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot scan '%s'", filename)
+	}
+	if version, err := ac.getVersion(); err == nil {
+		cresult.Engine = version.engine
+		cresult.Database = version.database
+		cresult.DatabaseDate = version.date
+	}
+	var result = NewResultV2()
+	result.Metadata[ac.GetName()] = cresult
+	return result, nil
+}
+
+func (ac *ActionClamd) DoV2(filename string) (*ResultV2, error) {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open file '%s'", filename)
+	}
+	defer fp.Close()
+	return ac.Stream("", fp, filename)
+}
+
+func (ac *ActionClamd) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
+	filename, err := ac.server.fm.Get(uri)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "no file url")
+	}
+	result, err := ac.DoV2(filename)
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+	return result.Metadata[ac.GetName()], nil, nil, nil
+}
+
+var (
+	_ Action = &ActionClamd{}
+)
@@ -0,0 +1,143 @@
+package indexer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd answers VERSION and INSTREAM like clamd with a stream limit of maxSize bytes
+func fakeClamd(t *testing.T, maxSize int) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				cmd, err := br.ReadString(0)
+				if err != nil {
+					return
+				}
+				switch cmd {
+				case "zVERSION\x00":
+					conn.Write([]byte("ClamAV 1.0.1/26863/Thu Mar 30 07:24:06 2023\x00"))
+				case "zINSTREAM\x00":
+					var data = &bytes.Buffer{}
+					var size = make([]byte, 4)
+					for {
+						if _, err := io.ReadFull(br, size); err != nil {
+							return
+						}
+						n := binary.BigEndian.Uint32(size)
+						if n == 0 {
+							break
+						}
+						if data.Len()+int(n) > maxSize {
+							conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+							return
+						}
+						if _, err := io.CopyN(data, br, int64(n)); err != nil {
+							return
+						}
+					}
+					if bytes.Contains(data.Bytes(), []byte(eicar)) {
+						conn.Write([]byte("stream: Eicar-Signature FOUND\x00"))
+					} else {
+						conn.Write([]byte("stream: OK\x00"))
+					}
+				default:
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+				}
+			}(conn)
+		}
+	}()
+	return l
+}
+
+// slowReader delays every read like a slow source
+type slowReader struct {
+	r     io.Reader
+	delay time.Duration
+}
+
+func (sr *slowReader) Read(p []byte) (int, error) {
+	time.Sleep(sr.delay)
+	return sr.r.Read(p)
+}
+
+func TestActionClamdSlowStream(t *testing.T) {
+	l := fakeClamd(t, 1024*1024)
+	defer l.Close()
+	ac := NewActionClamd(NameClamd, "tcp", l.Addr().String(), 200*time.Millisecond, 1024, nil, NewActionDispatcher(nil))
+	// the upload takes longer than the timeout, every single chunk is in time
+	reader := &slowReader{r: bytes.NewReader(bytes.Repeat([]byte("x"), 8*1024)), delay: 50 * time.Millisecond}
+	result, err := ac.Stream("", reader, "slow.bin")
+	if err != nil {
+		t.Fatalf("cannot scan slow stream: %v", err)
+	}
+	if cresult := result.Metadata[NameClamd].(*ClamdResult); cresult.Status != ClamdClean {
+		t.Errorf("status %s != %s (%s)", cresult.Status, ClamdClean, cresult.Message)
+	}
+}
+
+func TestActionClamd(t *testing.T) {
+	l := fakeClamd(t, 1024*1024)
+	defer l.Close()
+	ad := NewActionDispatcher(nil)
+	ac := NewActionClamd(NameClamd, "tcp", l.Addr().String(), 5*time.Second, 1024, nil, ad)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		status string
+		virus  string
+	}{
+		{"clean", bytes.Repeat([]byte("indexer "), 1000), ClamdClean, ""},
+		{"infected", []byte(strings.Repeat("x", 5000) + eicar), ClamdInfected, "Eicar-Signature"},
+		{"limit", bytes.Repeat([]byte("x"), 2*1024*1024), ClamdError, ""},
+	}
+	for _, test := range tests {
+		result, err := ac.Stream("", bytes.NewReader(test.data), test.name)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		cresult, ok := result.Metadata[NameClamd].(*ClamdResult)
+		if !ok {
+			t.Errorf("%s: no clamd result", test.name)
+			continue
+		}
+		if cresult.Status != test.status {
+			t.Errorf("%s: status %s != %s (%s)", test.name, cresult.Status, test.status, cresult.Message)
+		}
+		if cresult.Virus != test.virus {
+			t.Errorf("%s: virus %s != %s", test.name, cresult.Virus, test.virus)
+		}
+		if cresult.Engine != "ClamAV 1.0.1" || cresult.Database != "26863" {
+			t.Errorf("%s: invalid version %s/%s", test.name, cresult.Engine, cresult.Database)
+		}
+	}
+
+	// fan-out through the dispatcher
+	result, err := ad.Stream(bytes.NewReader([]byte(eicar)), []string{"eicar.com"}, []string{NameClamd})
+	if err != nil {
+		t.Fatalf("cannot stream: %v", err)
+	}
+	if cresult, ok := result.Metadata[NameClamd].(*ClamdResult); !ok || cresult.Status != ClamdInfected {
+		t.Errorf("dispatcher: no infected verdict: %v", result.Metadata[NameClamd])
+	}
+}
@@ -0,0 +1,450 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indexer
+
+import (
+	"bufio"
+	"compress/flate"
+	"emperror.dev/errors"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dicomImplicitLittle = "1.2.840.10008.1.2"
+	dicomExplicitLittle = "1.2.840.10008.1.2.1"
+	dicomDeflated       = "1.2.840.10008.1.2.1.99"
+	dicomExplicitBig    = "1.2.840.10008.1.2.2"
+)
+
+// maxDICOMValue limits the size of values, which are read. larger values are skipped
+const maxDICOMValue = 4096
+
+// maxDICOMDepth limits the nesting of sequences with undefined length
+const maxDICOMDepth = 32
+
+type dicomTag uint32
+
+func (t dicomTag) String() string {
+	return fmt.Sprintf("(%04X,%04X)", uint32(t)>>16, uint32(t)&0xffff)
+}
+
+const (
+	dicomTagItem          dicomTag = 0xFFFEE000
+	dicomTagItemDelim     dicomTag = 0xFFFEE00D
+	dicomTagSequenceDelim dicomTag = 0xFFFEE0DD
+	dicomTagPixelData     dicomTag = 0x7FE00010
+)
+
+// dicomElements are the data elements, which are reported
+var dicomElements = map[dicomTag]string{
+	0x00020002: "MediaStorageSOPClassUID",
+	0x00020010: "TransferSyntaxUID",
+	0x00020013: "ImplementationVersionName",
+	0x00080016: "SOPClassUID",
+	0x00080020: "StudyDate",
+	0x00080021: "SeriesDate",
+	0x00080022: "AcquisitionDate",
+	0x00080023: "ContentDate",
+	0x00080030: "StudyTime",
+	0x00080060: "Modality",
+	0x00080070: "Manufacturer",
+	0x00080080: "InstitutionName",
+	0x00080090: "ReferringPhysicianName",
+	0x00081030: "StudyDescription",
+	0x0008103E: "SeriesDescription",
+	0x00081090: "ManufacturerModelName",
+	0x00100010: "PatientName",
+	0x00100020: "PatientID",
+	0x00100030: "PatientBirthDate",
+	0x00100040: "PatientSex",
+	0x00101010: "PatientAge",
+	0x0020000D: "StudyInstanceUID",
+	0x0020000E: "SeriesInstanceUID",
+	0x00280002: "SamplesPerPixel",
+	0x00280004: "PhotometricInterpretation",
+	0x00280008: "NumberOfFrames",
+	0x00280010: "Rows",
+	0x00280011: "Columns",
+	0x00280100: "BitsAllocated",
+	0x00280101: "BitsStored",
+}
+
+// dicomIdentifyingTags are the elements of group 0008, which identify persons or institutions
+var dicomIdentifyingTags = []dicomTag{
+	0x00080080, // InstitutionName
+	0x00080081, // InstitutionAddress
+	0x00080090, // ReferringPhysicianName
+	0x00080092, // ReferringPhysicianAddress
+	0x00080094, // ReferringPhysicianTelephoneNumbers
+	0x00081040, // InstitutionalDepartmentName
+	0x00081048, // PhysiciansOfRecord
+	0x00081050, // PerformingPhysicianName
+	0x00081060, // NameOfPhysiciansReadingStudy
+	0x00081070, // OperatorsName
+}
+
+// dicomPatientTag returns true for elements, which identify a patient, a physician or an institution
+func dicomPatientTag(tag dicomTag) bool {
+	switch uint32(tag) >> 16 {
+	case 0x0010, 0x0032: // patient and study (requesting physician)
+		return true
+	}
+	return slices.Contains(dicomIdentifyingTags, tag)
+}
+
+// dicomLongVRs have a 4 byte length in explicit vr encoding
+var dicomLongVRs = []string{"OB", "OD", "OF", "OL", "OV", "OW", "SQ", "SV", "UC", "UN", "UR", "UT", "UV"}
+
+type DICOMResult struct {
+	TransferSyntax string            `json:"transfersyntax"`
+	Elements       map[string]string `json:"elements"`
+	PixelData      bool              `json:"pixeldata"`
+	Stripped       bool              `json:"stripped,omitempty"`
+	Error          string            `json:"error,omitempty"`
+}
+
+type ActionDICOM struct {
+	name         string
+	stripPatient bool
+	server       *Server
+}
+
+func (dc *ActionDICOM) CanHandle(contentType string, filename string) bool {
+	if contentType == "application/dicom" {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".dcm" || ext == ".dicom"
+}
+
+func NewActionDICOM(name string, stripPatient bool, server *Server, ad *ActionDispatcher) Action {
+	dc := &ActionDICOM{name: name, stripPatient: stripPatient, server: server}
+	ad.RegisterAction(dc)
+	return dc
+}
+
+func (dc *ActionDICOM) GetWeight() uint {
+	return 50
+}
+
+func (dc *ActionDICOM) GetCaps() ActionCapability {
+	return ACTFILEHEAD | ACTSTREAM
+}
+
+func (dc *ActionDICOM) GetName() string {
+	return dc.name
+}
+
+type dicomReader struct {
+	r        *bufio.Reader
+	order    binary.ByteOrder
+	explicit bool
+}
+
+func (dr *dicomReader) uint16() (uint16, error) {
+	var buf [2]byte
+	if _, err := io.ReadFull(dr.r, buf[:]); err != nil {
+		return 0, err
+	}
+	return dr.order.Uint16(buf[:]), nil
+}
+
+func (dr *dicomReader) uint32() (uint32, error) {
+	var buf [4]byte
+	if _, err := io.ReadFull(dr.r, buf[:]); err != nil {
+		return 0, err
+	}
+	return dr.order.Uint32(buf[:]), nil
+}
+
+// element reads tag, vr and length of the next data element
+func (dr *dicomReader) element() (dicomTag, string, uint32, error) {
+	group, err := dr.uint16()
+	if err != nil {
+		return 0, "", 0, err
+	}
+	elem, err := dr.uint16()
+	if err != nil {
+		return 0, "", 0, err
+	}
+	tag := dicomTag(uint32(group)<<16 | uint32(elem))
+	// items and delimiters have no vr
+	if group == 0xFFFE {
+		length, err := dr.uint32()
+		return tag, "", length, err
+	}
+	if !dr.explicit {
+		length, err := dr.uint32()
+		return tag, "", length, err
+	}
+	var vr [2]byte
+	if _, err := io.ReadFull(dr.r, vr[:]); err != nil {
+		return 0, "", 0, err
+	}
+	for _, long := range dicomLongVRs {
+		if long == string(vr[:]) {
+			if _, err := dr.uint16(); err != nil {
+				return 0, "", 0, err
+			}
+			length, err := dr.uint32()
+			return tag, string(vr[:]), length, err
+		}
+	}
+	length, err := dr.uint16()
+	return tag, string(vr[:]), uint32(length), err
+}
+
+func (dr *dicomReader) skip(length uint32) error {
+	_, err := io.CopyN(io.Discard, dr.r, int64(length))
+	return err
+}
+
+// skipUndefined skips a sequence or encapsulated data with undefined length
+func (dr *dicomReader) skipUndefined(depth int) error {
+	if depth > maxDICOMDepth {
+		return errors.Errorf("sequences nested deeper than %d", maxDICOMDepth)
+	}
+	for {
+		tag, _, length, err := dr.element()
+		if err != nil {
+			return err
+		}
+		switch tag {
+		case dicomTagSequenceDelim:
+			return nil
+		case dicomTagItem:
+			if length == 0xFFFFFFFF {
+				if err := dr.skipItem(depth); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := dr.skip(length); err != nil {
+				return err
+			}
+		default:
+			return errors.Errorf("unexpected element %s in sequence", tag)
+		}
+	}
+}
+
+// skipItem skips the elements of an item with undefined length
+func (dr *dicomReader) skipItem(depth int) error {
+	for {
+		tag, _, length, err := dr.element()
+		if err != nil {
+			return err
+		}
+		if tag == dicomTagItemDelim {
+			return nil
+		}
+		if length == 0xFFFFFFFF {
+			if err := dr.skipUndefined(depth + 1); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := dr.skip(length); err != nil {
+			return err
+		}
+	}
+}
+
+func (dr *dicomReader) value(vr string, length uint32) (string, error) {
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(dr.r, buf); err != nil {
+		return "", err
+	}
+	switch vr {
+	case "US":
+		if len(buf) >= 2 {
+			return strconv.Itoa(int(dr.order.Uint16(buf))), nil
+		}
+	case "UL":
+		if len(buf) >= 4 {
+			return strconv.Itoa(int(dr.order.Uint32(buf))), nil
+		}
+	}
+	return strings.TrimRight(string(buf), " \x00"), nil
+}
+
+// dicomVR returns the vr of the reported elements for implicit vr encoding
+func dicomVR(tag dicomTag) string {
+	switch tag {
+	case 0x00280002, 0x00280010, 0x00280011, 0x00280100, 0x00280101:
+		return "US"
+	}
+	return ""
+}
+
+// dicomDate converts YYYYMMDD to YYYY-MM-DD
+func dicomDate(date string) string {
+	if len(date) == 8 {
+		if _, err := strconv.Atoi(date); err == nil {
+			return date[:4] + "-" + date[4:6] + "-" + date[6:]
+		}
+	}
+	return date
+}
+
+func (dc *ActionDICOM) analyze(reader io.Reader) (*ResultV2, error) {
+	br := bufio.NewReaderSize(reader, 4096)
+	head, _ := br.Peek(132)
+	if len(head) < 132 || string(head[128:132]) != "DICM" {
+		return nil, nil
+	}
+	if _, err := br.Discard(132); err != nil {
+		return nil, errors.Wrap(err, "cannot read preamble")
+	}
+	dresult := &DICOMResult{Elements: map[string]string{}}
+	var result = NewResultV2()
+	result.Mimetype = "application/dicom"
+	result.Mimetypes = []string{result.Mimetype}
+	result.Metadata[dc.GetName()] = dresult
+
+	// the meta group is always explicit vr little endian
+	dr := &dicomReader{r: br, order: binary.LittleEndian, explicit: true}
+	if err := dc.readElements(dr, dresult, true); err != nil {
+		dresult.Error = err.Error()
+		return result, nil
+	}
+	dresult.TransferSyntax = dresult.Elements["TransferSyntaxUID"]
+	switch dresult.TransferSyntax {
+	case dicomImplicitLittle:
+		dr.explicit = false
+	case dicomExplicitBig:
+		dr.order = binary.BigEndian
+	case dicomDeflated:
+		dr.r = bufio.NewReader(flate.NewReader(br))
+	}
+	if err := dc.readElements(dr, dresult, false); err != nil && err != io.EOF {
+		dresult.Error = err.Error()
+	}
+	if dc.stripPatient {
+		dresult.Stripped = true
+	}
+
+	el := dresult.Elements
+	if v, err := strconv.Atoi(el["Columns"]); err == nil {
+		result.Width = uint(v)
+	}
+	if v, err := strconv.Atoi(el["Rows"]); err == nil {
+		result.Height = uint(v)
+	}
+	if v, err := strconv.Atoi(el["BitsStored"]); err == nil {
+		result.BitDepth = uint(v)
+	} else if v, err := strconv.Atoi(el["BitsAllocated"]); err == nil {
+		result.BitDepth = uint(v)
+	}
+	result.Instrument = el["Modality"]
+	for _, date := range []string{"StudyDate", "AcquisitionDate", "ContentDate", "SeriesDate"} {
+		if el[date] != "" {
+			result.Date = dicomDate(el[date])
+			break
+		}
+	}
+	return result, nil
+}
+
+// readElements reads the meta group or the dataset until pixel data
+func (dc *ActionDICOM) readElements(dr *dicomReader, dresult *DICOMResult, meta bool) error {
+	for {
+		if meta {
+			// stop at the end of group 0002
+			group, err := dr.r.Peek(2)
+			if err != nil {
+				return err
+			}
+			if binary.LittleEndian.Uint16(group) != 0x0002 {
+				return nil
+			}
+		}
+		tag, vr, length, err := dr.element()
+		if err != nil {
+			return err
+		}
+		if tag == dicomTagPixelData {
+			dresult.PixelData = true
+			return nil
+		}
+		if length == 0xFFFFFFFF {
+			if err := dr.skipUndefined(1); err != nil {
+				return errors.Wrapf(err, "cannot skip %s", tag)
+			}
+			continue
+		}
+		name, ok := dicomElements[tag]
+		if !ok || length > maxDICOMValue || (dc.stripPatient && dicomPatientTag(tag)) {
+			if err := dr.skip(length); err != nil {
+				return err
+			}
+			continue
+		}
+		if vr == "" {
+			vr = dicomVR(tag)
+		}
+		val, err := dr.value(vr, length)
+		if err != nil {
+			return err
+		}
+		dresult.Elements[name] = val
+	}
+}
+
+func (dc *ActionDICOM) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
+	return dc.analyze(reader)
+}
+
+func (dc *ActionDICOM) DoV2(filename string) (*ResultV2, error) {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open file '%s'", filename)
+	}
+	defer fp.Close()
+	return dc.analyze(fp)
+}
+
+func (dc *ActionDICOM) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
+	filename, err := dc.server.fm.Get(uri)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "no file url")
+	}
+	result, err := dc.DoV2(filename)
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+	if result == nil {
+		return nil, nil, nil, ErrMimeNotApplicable
+	}
+	if result.Width > *width {
+		*width = result.Width
+	}
+	if result.Height > *height {
+		*height = result.Height
+	}
+	return result.Metadata[dc.GetName()], result.Mimetypes, nil, nil
+}
+
+var (
+	_ Action = (*ActionDICOM)(nil)
+)
@@ -0,0 +1,135 @@
+package indexer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// buildTestDICOM creates a minimal explicit vr little endian file with patient data and a sequence
+func buildTestDICOM() []byte {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, 128))
+	buf.WriteString("DICM")
+	short := func(group, elem uint16, vr string, value []byte) {
+		_ = binary.Write(&buf, binary.LittleEndian, []uint16{group, elem})
+		buf.WriteString(vr)
+		_ = binary.Write(&buf, binary.LittleEndian, uint16(len(value)))
+		buf.Write(value)
+	}
+	us := func(v uint16) []byte {
+		return binary.LittleEndian.AppendUint16(nil, v)
+	}
+	short(0x0002, 0x0010, "UI", []byte(dicomExplicitLittle+"\x00"))
+	short(0x0008, 0x0020, "DA", []byte("20240131"))
+	short(0x0008, 0x0060, "CS", []byte("MR"))
+	short(0x0008, 0x0080, "LO", []byte("Hospital"))
+	short(0x0010, 0x0010, "PN", []byte("Doe^John"))
+	short(0x0010, 0x0020, "LO", []byte("12345 "))
+	// sequence with undefined length
+	_ = binary.Write(&buf, binary.LittleEndian, []uint16{0x0008, 0x1140})
+	buf.WriteString("SQ")
+	_ = binary.Write(&buf, binary.LittleEndian, []uint16{0})
+	_ = binary.Write(&buf, binary.LittleEndian, []uint32{0xFFFFFFFF})
+	_ = binary.Write(&buf, binary.LittleEndian, []uint16{0xFFFE, 0xE000})
+	_ = binary.Write(&buf, binary.LittleEndian, []uint32{0xFFFFFFFF})
+	short(0x0008, 0x1150, "UI", []byte("1.2.3\x00"))
+	_ = binary.Write(&buf, binary.LittleEndian, []uint16{0xFFFE, 0xE00D, 0, 0})
+	_ = binary.Write(&buf, binary.LittleEndian, []uint16{0xFFFE, 0xE0DD, 0, 0})
+	short(0x0028, 0x0010, "US", us(256))
+	short(0x0028, 0x0011, "US", us(512))
+	short(0x0028, 0x0100, "US", us(16))
+	short(0x0028, 0x0101, "US", us(12))
+	_ = binary.Write(&buf, binary.LittleEndian, []uint16{0x7FE0, 0x0010})
+	buf.WriteString("OW")
+	_ = binary.Write(&buf, binary.LittleEndian, []uint16{0})
+	_ = binary.Write(&buf, binary.LittleEndian, []uint32{4})
+	buf.Write(make([]byte, 4))
+	return buf.Bytes()
+}
+
+func TestActionDICOM(t *testing.T) {
+	data := buildTestDICOM()
+	for _, strip := range []bool{false, true} {
+		ad := NewActionDispatcher(nil)
+		dc := NewActionDICOM(NameDICOM, strip, nil, ad)
+		result, err := dc.Stream("", bytes.NewReader(data), "test.dcm")
+		if err != nil {
+			t.Fatalf("cannot analyze: %v", err)
+		}
+		dr := result.Metadata[NameDICOM].(*DICOMResult)
+		if dr.Error != "" || !dr.PixelData || dr.TransferSyntax != dicomExplicitLittle {
+			t.Fatalf("wrong result: %+v", dr)
+		}
+		if result.Width != 512 || result.Height != 256 || result.BitDepth != 12 || result.Instrument != "MR" || result.Date != "2024-01-31" {
+			t.Errorf("wrong normalized values: %dx%d %d %s %s", result.Width, result.Height, result.BitDepth, result.Instrument, result.Date)
+		}
+		_, hasName := dr.Elements["PatientName"]
+		if hasName == strip || dr.Stripped != strip {
+			t.Errorf("strip %v: wrong patient data %+v", strip, dr.Elements)
+		}
+		if !strip && dr.Elements["PatientID"] != "12345" {
+			t.Errorf("wrong patient id '%s'", dr.Elements["PatientID"])
+		}
+		if _, hasInstitution := dr.Elements["InstitutionName"]; hasInstitution == strip {
+			t.Errorf("strip %v: wrong institution %+v", strip, dr.Elements)
+		}
+	}
+	for tag, identifying := range map[dicomTag]bool{0x00100010: true, 0x00080080: true, 0x00081070: true, 0x00321032: true, 0x00080060: false, 0x00280010: false} {
+		if dicomPatientTag(tag) != identifying {
+			t.Errorf("%s: identifying must be %v", tag, identifying)
+		}
+	}
+
+	// deeply nested sequences are rejected
+	var nested bytes.Buffer
+	nested.Write(data[:132])
+	nested.Write(data[132 : 132+8+len(dicomExplicitLittle)+1])
+	for i := 0; i <= maxDICOMDepth; i++ {
+		_ = binary.Write(&nested, binary.LittleEndian, []uint16{0x0008, 0x1140})
+		nested.WriteString("SQ")
+		_ = binary.Write(&nested, binary.LittleEndian, []uint16{0})
+		_ = binary.Write(&nested, binary.LittleEndian, []uint32{0xFFFFFFFF})
+		_ = binary.Write(&nested, binary.LittleEndian, []uint16{0xFFFE, 0xE000})
+		_ = binary.Write(&nested, binary.LittleEndian, []uint32{0xFFFFFFFF})
+	}
+	result, err := NewActionDICOM(NameDICOM, false, nil, NewActionDispatcher(nil)).Stream("", bytes.NewReader(nested.Bytes()), "nested.dcm")
+	if err != nil {
+		t.Fatalf("cannot analyze nested: %v", err)
+	}
+	if dr := result.Metadata[NameDICOM].(*DICOMResult); !strings.Contains(dr.Error, "nested") {
+		t.Errorf("nesting must fail: %+v", dr)
+	}
+
+	ad := NewActionDispatcher(nil)
+	dc := NewActionDICOM(NameDICOM, false, nil, ad)
+	result, err = dc.Stream("", bytes.NewReader(make([]byte, 200)), "test.dcm")
+	if err != nil || result != nil {
+		t.Errorf("no dicom file: %v, %v", result, err)
+	}
+}
+
+func TestActionDICOMConfig(t *testing.T) {
+	if _, err := os.Stat("../../data/siegfried/default.sig"); err != nil {
+		t.Skipf("no signature file: %v", err)
+	}
+	logger := zerolog.Nop()
+	for _, keep := range []bool{false, true} {
+		conf := IndexerConfig{
+			Siegfried: ConfigSiegfried{SignatureFile: "../../data/siegfried/default.sig"},
+			DICOM:     ConfigDICOM{Enabled: true, KeepPatient: keep},
+		}
+		ad, err := InitActionDispatcher(nil, conf, &logger)
+		if err != nil {
+			t.Fatalf("cannot initialize action dispatcher: %v", err)
+		}
+		action, ok := ad.GetAction(NameDICOM)
+		if !ok || action.(*ActionDICOM).stripPatient == keep {
+			t.Errorf("keeppatient = %v: patient data stripped = %v", keep, !keep)
+		}
+	}
+}
@@ -19,6 +19,9 @@ import (
 type ActionDispatcher struct {
 	mimeRelevance []MimeWeight
 	actions       map[string]Action
+	closers       []io.Closer
+	limits        map[string]chan struct{}
+	limitsLock    sync.RWMutex
 }
 
 func NewActionDispatcher(mimeRelevance map[int]MimeWeightString) *ActionDispatcher {
@@ -49,11 +52,109 @@ func (ad *ActionDispatcher) RegisterAction(action Action) {
 	ad.actions[action.GetName()] = action
 }
 
+// AddCloser registers a resource of an action, which is closed with the dispatcher
+func (ad *ActionDispatcher) AddCloser(closer io.Closer) {
+	ad.closers = append(ad.closers, closer)
+}
+
+func (ad *ActionDispatcher) Close() error {
+	var errs []error
+	for _, closer := range ad.closers {
+		if err := closer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	ad.closers = nil
+	return errors.Combine(errs...)
+}
+
+// SetConcurrency limits the number of parallel executions of an action. n <= 0 removes the limit.
+// running executions keep the slot of the old limit
+func (ad *ActionDispatcher) SetConcurrency(name string, n int) {
+	ad.limitsLock.Lock()
+	defer ad.limitsLock.Unlock()
+	if ad.limits == nil {
+		ad.limits = map[string]chan struct{}{}
+	}
+	if n <= 0 {
+		delete(ad.limits, name)
+		return
+	}
+	ad.limits[name] = make(chan struct{}, n)
+}
+
+// acquire takes a slot of the action and returns the release function.
+// without wait, ok is false if all slots are in use
+func (ad *ActionDispatcher) acquire(name string, wait bool) (release func(), ok bool) {
+	ad.limitsLock.RLock()
+	limit, limited := ad.limits[name]
+	ad.limitsLock.RUnlock()
+	if !limited {
+		return func() {}, true
+	}
+	if wait {
+		limit <- struct{}{}
+		return func() { <-limit }, true
+	}
+	select {
+	case limit <- struct{}{}:
+		return func() { <-limit }, true
+	default:
+		return nil, false
+	}
+}
+
+// acquireAll takes the slots of all actions in a fixed order. it must be called before data is streamed
+// to the actions, a waiting action would block the other readers otherwise.
+// without wait, actions without a free slot are returned as saturated. release may be called more than once
+func (ad *ActionDispatcher) acquireAll(names []string, wait bool) (release func(), saturated []string) {
+	names = slices.Clone(names)
+	slices.Sort(names)
+	names = slices.Compact(names)
+	var releases []func()
+	for _, name := range names {
+		r, ok := ad.acquire(name, wait)
+		if !ok {
+			saturated = append(saturated, name)
+			continue
+		}
+		releases = append(releases, r)
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			for _, r := range releases {
+				r()
+			}
+		})
+	}, saturated
+}
+
 func (ad *ActionDispatcher) GetAction(name string) (Action, bool) {
 	action, ok := ad.actions[name]
 	return action, ok
 }
 
+// processResult calls the requested actions, which post process the merged result
+func (ad *ActionDispatcher) processResult(result *ResultV2, actions []string, wait bool) {
+	for _, name := range actions {
+		rp, ok := ad.actions[name].(ResultProcessor)
+		if !ok {
+			continue
+		}
+		release, ok := ad.acquire(name, wait)
+		if !ok {
+			result.Errors[name] = ErrConcurrencyLimit.Error()
+			continue
+		}
+		err := rp.ProcessResult(result)
+		release()
+		if err != nil {
+			result.Errors[name] = err.Error()
+		}
+	}
+}
+
 func (ad *ActionDispatcher) GetActionNames() []string {
 	var names []string
 	for name := range ad.actions {
@@ -72,7 +173,18 @@ func (ad *ActionDispatcher) GetActionNamesByCaps(caps ActionCapability) []string
 	return names
 }
 
+// Stream sends the data to all actions in parallel. it waits for free slots of actions with concurrency limit
 func (ad *ActionDispatcher) Stream(sourceReader io.Reader, stateFiles []string, actions []string) (*ResultV2, error) {
+	return ad.stream(sourceReader, stateFiles, actions, true)
+}
+
+// streamEmbedded is used by actions, which index embedded files. the calling action could hold the slot of a
+// limited action already, so actions without free slot are not waited for but reported as error
+func (ad *ActionDispatcher) streamEmbedded(sourceReader io.Reader, stateFiles []string, actions []string) (*ResultV2, error) {
+	return ad.stream(sourceReader, stateFiles, actions, false)
+}
+
+func (ad *ActionDispatcher) stream(sourceReader io.Reader, stateFiles []string, actions []string, wait bool) (*ResultV2, error) {
 
 	if len(stateFiles) == 0 {
 		stateFiles = []string{""}
@@ -85,9 +197,7 @@ func (ad *ActionDispatcher) Stream(sourceReader io.Reader, stateFiles []string,
 	parts := strings.Split(contentType, ";")
 	contentType = parts[0]
 
-	var actionWriters = []*iou.WriteIgnoreCloser{}
-	var wg = sync.WaitGroup{}
-	results := make(chan *ResultV2, len(ad.actions))
+	var streamActions = []Action{}
 	for _, actionStr := range actions {
 		var found bool
 		for _, action := range ad.actions {
@@ -96,30 +206,50 @@ func (ad *ActionDispatcher) Stream(sourceReader io.Reader, stateFiles []string,
 				if contentType != "applictation/octet-stream" && !action.CanHandle(contentType, stateFiles[0]) {
 					continue
 				}
-				wg.Add(1)
-				pr, pw := io.Pipe()
-				actionWriters = append(actionWriters, iou.NewWriteIgnoreCloser(pw))
-				go func(actionReader io.Reader, a Action) {
-					defer wg.Done()
-					// stream to actions
-					result, err := a.Stream(contentType, actionReader, stateFiles[0])
-					if err != nil {
-						result = NewResultV2()
-						result.Errors[a.GetName()] = err.Error()
-					}
-					// send result to channel
-					if result != nil {
-						results <- result
-					}
-					// discard remaining data
-					_, _ = io.Copy(io.Discard, actionReader)
-				}(iou.NewReadIgnoreCloser(pr), action)
+				streamActions = append(streamActions, action)
 			}
 		}
 		if !found {
 			return nil, errors.Errorf("action '%s' not configured", actionStr)
 		}
 	}
+
+	var names = []string{}
+	for _, action := range streamActions {
+		names = append(names, action.GetName())
+	}
+	release, saturated := ad.acquireAll(names, wait)
+	defer release()
+
+	var actionWriters = []*iou.WriteIgnoreCloser{}
+	var wg = sync.WaitGroup{}
+	results := make(chan *ResultV2, len(ad.actions))
+	for _, action := range streamActions {
+		if slices.Contains(saturated, action.GetName()) {
+			result := NewResultV2()
+			result.Errors[action.GetName()] = ErrConcurrencyLimit.Error()
+			results <- result
+			continue
+		}
+		wg.Add(1)
+		pr, pw := io.Pipe()
+		actionWriters = append(actionWriters, iou.NewWriteIgnoreCloser(pw))
+		go func(actionReader io.Reader, a Action) {
+			defer wg.Done()
+			// stream to actions
+			result, err := a.Stream(contentType, actionReader, stateFiles[0])
+			if err != nil {
+				result = NewResultV2()
+				result.Errors[a.GetName()] = err.Error()
+			}
+			// send result to channel
+			if result != nil {
+				results <- result
+			}
+			// discard remaining data
+			_, _ = io.Copy(io.Discard, actionReader)
+		}(iou.NewReadIgnoreCloser(pr), action)
+	}
 	var actionBufferWriters = []io.Writer{}
 	for _, w := range actionWriters {
 		actionBufferWriters = append(actionBufferWriters, bufio.NewWriterSize(w, 1024*1024))
@@ -148,11 +278,13 @@ func (ad *ActionDispatcher) Stream(sourceReader io.Reader, stateFiles []string,
 	}
 	// wait for all actions to finish
 	wg.Wait()
+	release()
 	close(results)
 	result := NewResultV2()
 	for r := range results {
 		result.Merge(r)
 	}
+	ad.processResult(result, actions, wait)
 
 	// sort mimetypes by weight
 	slices.Sort(result.Mimetypes)
@@ -234,7 +366,10 @@ func (ad *ActionDispatcher) DoV2(filename string, stateFiles []string, actions [
 					break
 				}
 				// stream to actions
+				// only one slot is held at a time
+				release, _ := ad.acquire(action.GetName(), true)
 				result, err := action.DoV2(filename)
+				release()
 				if err != nil {
 					result = NewResultV2()
 					result.Errors[action.GetName()] = err.Error()
@@ -250,6 +385,7 @@ func (ad *ActionDispatcher) DoV2(filename string, stateFiles []string, actions [
 			return nil, errors.Errorf("action '%s' not configured", actionStr)
 		}
 	}
+	ad.processResult(results, actions, true)
 
 	// sort mimetypes by weight
 	slices.Sort(results.Mimetypes)
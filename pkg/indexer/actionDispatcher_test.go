@@ -0,0 +1,111 @@
+package indexer
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowAction reads the whole stream and counts parallel executions
+type slowAction struct {
+	name         string
+	running, max atomic.Int32
+}
+
+func (sa *slowAction) CanHandle(contentType string, filename string) bool { return true }
+func (sa *slowAction) GetCaps() ActionCapability                          { return ACTSTREAM }
+func (sa *slowAction) GetName() string                                    { return sa.name }
+func (sa *slowAction) GetWeight() uint                                    { return 100 }
+func (sa *slowAction) DoV2(filename string) (*ResultV2, error)            { return NewResultV2(), nil }
+func (sa *slowAction) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
+	return nil, nil, nil, ErrMimeNotApplicable
+}
+func (sa *slowAction) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
+	n := sa.running.Add(1)
+	defer sa.running.Add(-1)
+	for {
+		m := sa.max.Load()
+		if n <= m || sa.max.CompareAndSwap(m, n) {
+			break
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return nil, err
+	}
+	return NewResultV2(), nil
+}
+
+func TestActionDispatcherConcurrency(t *testing.T) {
+	ad := NewActionDispatcher(nil)
+	sa := &slowAction{name: "slow"}
+	ad.RegisterAction(sa)
+	ad.SetConcurrency("slow", 1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ad.Stream(bytes.NewReader([]byte("data")), []string{"test.bin"}, []string{"slow"}); err != nil {
+				t.Errorf("cannot stream: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if sa.max.Load() != 1 {
+		t.Errorf("expected at most 1 parallel execution, got %d", sa.max.Load())
+	}
+}
+
+// two limited actions and data larger than the stream buffers must not block concurrent requests
+func TestActionDispatcherConcurrencyNoDeadlock(t *testing.T) {
+	ad := NewActionDispatcher(nil)
+	ad.RegisterAction(&slowAction{name: "a"})
+	ad.RegisterAction(&slowAction{name: "b"})
+	ad.SetConcurrency("a", 1)
+	ad.SetConcurrency("b", 1)
+
+	data := make([]byte, 3*1024*1024)
+	done := make(chan struct{})
+	go func() {
+		var wg sync.WaitGroup
+		for _, actions := range [][]string{{"a", "b"}, {"b", "a"}, {"a", "b"}, {"b", "a"}} {
+			wg.Add(1)
+			go func(actions []string) {
+				defer wg.Done()
+				if _, err := ad.Stream(bytes.NewReader(data), []string{"test.bin"}, actions); err != nil {
+					t.Errorf("cannot stream: %v", err)
+				}
+			}(actions)
+		}
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(20 * time.Second):
+		t.Fatal("deadlock of concurrent requests")
+	}
+}
+
+func TestActionDispatcherStreamEmbedded(t *testing.T) {
+	ad := NewActionDispatcher(nil)
+	ad.RegisterAction(&slowAction{name: "slow"})
+	ad.SetConcurrency("slow", 1)
+
+	// the slot is held by the calling action
+	release, _ := ad.acquire("slow", true)
+	defer release()
+	result, err := ad.streamEmbedded(bytes.NewReader([]byte("data")), []string{"test.bin"}, []string{"slow"})
+	if err != nil {
+		t.Fatalf("cannot stream: %v", err)
+	}
+	if result.Errors["slow"] != ErrConcurrencyLimit.Error() {
+		t.Errorf("expected concurrency limit error, got %v", result.Errors)
+	}
+}
@@ -0,0 +1,439 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indexer
+
+import (
+	"bufio"
+	"bytes"
+	"emperror.dev/errors"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type EmailPart struct {
+	ContentType string       `json:"contenttype"`
+	Charset     string       `json:"charset,omitempty"`
+	Disposition string       `json:"disposition,omitempty"`
+	Filename    string       `json:"filename,omitempty"`
+	Encoding    string       `json:"encoding,omitempty"`
+	Size        int64        `json:"size"`
+	Parts       []*EmailPart `json:"parts,omitempty"`
+	Message     *EmailHeader `json:"message,omitempty"` // embedded message/rfc822
+}
+
+// EmailAttachment lists an attachment. the index of the attachment is in ResultV2.Embedded with the same path
+type EmailAttachment struct {
+	Path        string `json:"path"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"contenttype"`
+	Size        int64  `json:"size"`
+	Error       string `json:"error,omitempty"`
+}
+
+type EmailHeader struct {
+	From      []string `json:"from,omitempty"`
+	To        []string `json:"to,omitempty"`
+	Cc        []string `json:"cc,omitempty"`
+	Date      string   `json:"date,omitempty"`
+	Subject   string   `json:"subject,omitempty"`
+	MessageID string   `json:"messageid,omitempty"`
+}
+
+type EmailMessage struct {
+	EmailHeader
+	Size        int64              `json:"size"`
+	Structure   *EmailPart         `json:"structure,omitempty"`
+	Attachments []*EmailAttachment `json:"attachments,omitempty"`
+	Error       string             `json:"error,omitempty"`
+	embedded    []*EmbeddedResource
+}
+
+type EmailResult struct {
+	Format       string          `json:"format"`
+	MessageCount int             `json:"messagecount"`
+	Messages     []*EmailMessage `json:"messages"`
+}
+
+type ActionEmail struct {
+	name             string
+	maxMessages      int
+	indexAttachments bool
+	actions          []string
+	server           *Server
+	ad               *ActionDispatcher
+}
+
+func (ae *ActionEmail) CanHandle(contentType string, filename string) bool {
+	if slices.Contains([]string{"message/rfc822", "application/mbox"}, contentType) {
+		return true
+	}
+	return slices.Contains([]string{".eml", ".mbox", ".mbx"}, strings.ToLower(filepath.Ext(filename)))
+}
+
+// NewActionEmail creates an action for rfc 5322 messages and mbox files.
+// if indexAttachments is true, attachments are indexed with the given actions of ad
+func NewActionEmail(name string, maxMessages int, indexAttachments bool, actions []string, server *Server, ad *ActionDispatcher) Action {
+	ae := &ActionEmail{
+		name:             name,
+		maxMessages:      maxMessages,
+		indexAttachments: indexAttachments,
+		server:           server,
+		ad:               ad,
+	}
+	// no endless recursion with attached messages
+	for _, a := range actions {
+		if a != name {
+			ae.actions = append(ae.actions, a)
+		}
+	}
+	ad.RegisterAction(ae)
+	return ae
+}
+
+func (ae *ActionEmail) GetWeight() uint {
+	return 50
+}
+
+func (ae *ActionEmail) GetCaps() ActionCapability {
+	return ACTFILEFULL | ACTSTREAM
+}
+
+func (ae *ActionEmail) GetName() string {
+	return ae.name
+}
+
+var mboxFromLine = []byte("From ")
+
+func (ae *ActionEmail) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
+	br := bufio.NewReaderSize(reader, 64*1024)
+	head, _ := br.Peek(5)
+	var eresult = &EmailResult{Messages: []*EmailMessage{}}
+	var result = NewResultV2()
+	if bytes.Equal(head, mboxFromLine) {
+		eresult.Format = "mbox"
+		result.Mimetypes = append(result.Mimetypes, "application/mbox")
+		if err := ae.readMBox(br, eresult); err != nil {
+			return nil, errors.Wrapf(err, "cannot read mbox '%s'", filename)
+		}
+		// attachments of different messages can have the same name
+		for i, em := range eresult.Messages {
+			for _, embedded := range em.embedded {
+				embedded.Path = fmt.Sprintf("/%d%s", i+1, embedded.Path)
+			}
+			for _, att := range em.Attachments {
+				att.Path = fmt.Sprintf("/%d%s", i+1, att.Path)
+			}
+		}
+	} else {
+		eresult.Format = "eml"
+		result.Mimetypes = append(result.Mimetypes, "message/rfc822")
+		eresult.MessageCount = 1
+		eresult.Messages = append(eresult.Messages, ae.readMessage(br))
+	}
+	for _, em := range eresult.Messages {
+		result.Embedded = append(result.Embedded, em.embedded...)
+	}
+	result.Metadata[ae.GetName()] = eresult
+	return result, nil
+}
+
+// readMBox splits the mbox at "From " lines. the messages are streamed to the parser, so they are never held in memory
+func (ae *ActionEmail) readMBox(br *bufio.Reader, eresult *EmailResult) error {
+	var pw *io.PipeWriter
+	var done chan *EmailMessage
+	var flush = func() {
+		if pw == nil {
+			return
+		}
+		pw.Close()
+		eresult.Messages = append(eresult.Messages, <-done)
+		pw = nil
+	}
+	var next = func() {
+		flush()
+		eresult.MessageCount++
+		if ae.maxMessages > 0 && len(eresult.Messages) >= ae.maxMessages {
+			return
+		}
+		var pr *io.PipeReader
+		pr, pw = io.Pipe()
+		done = make(chan *EmailMessage, 1)
+		go func() {
+			// readMessage consumes the whole message
+			done <- ae.readMessage(pr)
+		}()
+	}
+	defer flush()
+	// long lines are passed in pieces of the buffer size
+	var lineStart = true
+	for {
+		line, err := br.ReadSlice('\n')
+		if len(line) > 0 {
+			switch {
+			case lineStart && bytes.HasPrefix(line, mboxFromLine):
+				next()
+			case pw != nil:
+				// mboxrd quoting
+				if trimmed := bytes.TrimLeft(line, ">"); lineStart && len(trimmed) < len(line) && bytes.HasPrefix(trimmed, mboxFromLine) {
+					line = line[1:]
+				}
+				_, _ = pw.Write(line)
+			}
+		}
+		lineStart = err != bufio.ErrBufferFull
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			return errors.Wrap(err, "cannot read line")
+		}
+	}
+	return nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+func decodeEmailHeader(header mail.Header) EmailHeader {
+	var dec = &mime.WordDecoder{}
+	var addrParser = &mail.AddressParser{WordDecoder: dec}
+	var addresses = func(key string) []string {
+		val := header.Get(key)
+		if val == "" {
+			return nil
+		}
+		list, err := addrParser.ParseList(val)
+		if err != nil {
+			return []string{val}
+		}
+		var result []string
+		for _, addr := range list {
+			if addr.Name != "" {
+				result = append(result, fmt.Sprintf("%s <%s>", addr.Name, addr.Address))
+			} else {
+				result = append(result, addr.Address)
+			}
+		}
+		return result
+	}
+	eh := EmailHeader{
+		From:      addresses("From"),
+		To:        addresses("To"),
+		Cc:        addresses("Cc"),
+		MessageID: strings.Trim(header.Get("Message-Id"), "<> "),
+	}
+	if subject, err := dec.DecodeHeader(header.Get("Subject")); err == nil {
+		eh.Subject = subject
+	} else {
+		eh.Subject = header.Get("Subject")
+	}
+	if date, err := header.Date(); err == nil {
+		eh.Date = date.Format(time.RFC3339)
+	} else {
+		eh.Date = header.Get("Date")
+	}
+	return eh
+}
+
+func (ae *ActionEmail) readMessage(reader io.Reader) *EmailMessage {
+	cr := &countingReader{r: reader}
+	msg, err := mail.ReadMessage(bufio.NewReader(cr))
+	if err != nil {
+		_, _ = io.Copy(io.Discard, cr)
+		return &EmailMessage{Size: cr.n, Error: err.Error()}
+	}
+	em := &EmailMessage{
+		EmailHeader: decodeEmailHeader(msg.Header),
+		Attachments: []*EmailAttachment{},
+	}
+	em.Structure = ae.readPart(textproto.MIMEHeader(msg.Header), msg.Body, em)
+	_, _ = io.Copy(io.Discard, cr)
+	em.Size = cr.n
+	return em
+}
+
+func decodeTransferEncoding(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	default:
+		return body
+	}
+}
+
+func (ae *ActionEmail) readPart(header textproto.MIMEHeader, body io.Reader, em *EmailMessage) *EmailPart {
+	part := &EmailPart{ContentType: "text/plain", Encoding: header.Get("Content-Transfer-Encoding")}
+	var params map[string]string
+	if ct := header.Get("Content-Type"); ct != "" {
+		var err error
+		if part.ContentType, params, err = mime.ParseMediaType(ct); err != nil {
+			part.ContentType = strings.ToLower(strings.TrimSpace(strings.Split(ct, ";")[0]))
+		}
+		part.Charset = params["charset"]
+	}
+	if cd := header.Get("Content-Disposition"); cd != "" {
+		disposition, dparams, err := mime.ParseMediaType(cd)
+		if err == nil {
+			part.Disposition = disposition
+			part.Filename = dparams["filename"]
+		}
+	}
+	if part.Filename == "" {
+		part.Filename = params["name"]
+	}
+	if part.Filename != "" {
+		if fn, err := (&mime.WordDecoder{}).DecodeHeader(part.Filename); err == nil {
+			part.Filename = fn
+		}
+	}
+
+	if strings.HasPrefix(part.ContentType, "multipart/") && params["boundary"] != "" {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextRawPart()
+			if err != nil {
+				break
+			}
+			child := ae.readPart(p.Header, p, em)
+			part.Parts = append(part.Parts, child)
+			part.Size += child.Size
+		}
+		return part
+	}
+
+	cr := &countingReader{r: decodeTransferEncoding(part.Encoding, body)}
+	isAttachment := part.Disposition == "attachment" || part.Filename != ""
+	switch {
+	case part.ContentType == "message/rfc822" && !isAttachment:
+		if msg, err := mail.ReadMessage(bufio.NewReader(cr)); err == nil {
+			eh := decodeEmailHeader(msg.Header)
+			part.Message = &eh
+			part.Parts = append(part.Parts, ae.readPart(textproto.MIMEHeader(msg.Header), msg.Body, em))
+		}
+	case isAttachment:
+		att := &EmailAttachment{
+			Path:        em.attachmentPath(part.Filename),
+			Filename:    part.Filename,
+			ContentType: part.ContentType,
+		}
+		embedded := &EmbeddedResource{
+			Path:     att.Path,
+			Name:     part.Filename,
+			Mimetype: part.ContentType,
+			Depth:    1,
+			Source:   ae.GetName(),
+		}
+		if ae.indexAttachments && ae.ad != nil && len(ae.actions) > 0 {
+			index, err := ae.ad.streamEmbedded(cr, []string{part.Filename}, ae.actions)
+			if err != nil {
+				att.Error = err.Error()
+			} else {
+				if index.Mimetype != "" {
+					embedded.Mimetype = index.Mimetype
+				}
+				embedded.Metadata = index.Metadata
+				var errs []string
+				for name, e := range index.Errors {
+					errs = append(errs, fmt.Sprintf("%s: %s", name, e))
+				}
+				slices.Sort(errs)
+				att.Error = strings.Join(errs, "; ")
+			}
+		}
+		_, _ = io.Copy(io.Discard, cr)
+		att.Size = cr.n
+		embedded.Size = uint64(cr.n)
+		em.Attachments = append(em.Attachments, att)
+		em.embedded = append(em.embedded, embedded)
+	}
+	_, _ = io.Copy(io.Discard, cr)
+	part.Size = cr.n
+	return part
+}
+
+// attachmentPath returns a unique path within the message for an attachment.
+// the filename is untrusted, path separators and control characters are replaced
+func (em *EmailMessage) attachmentPath(filename string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(filename))
+	if name == "" || name == "." || name == ".." {
+		name = fmt.Sprintf("attachment%d", len(em.Attachments)+1)
+	}
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	path := "/" + name
+	for i := 2; slices.ContainsFunc(em.Attachments, func(att *EmailAttachment) bool { return att.Path == path }); i++ {
+		path = fmt.Sprintf("/%s-%d%s", base, i, ext)
+	}
+	return path
+}
+
+func (ae *ActionEmail) DoV2(filename string) (*ResultV2, error) {
+	reader, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open file '%s'", filename)
+	}
+	defer reader.Close()
+	return ae.Stream("", reader, filename)
+}
+
+func (ae *ActionEmail) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
+	filename, err := ae.server.fm.Get(uri)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "no file url")
+	}
+
+	fp, err := os.OpenFile(filename, os.O_RDONLY, 0644)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "cannot open file %s", filename)
+	}
+	defer fp.Close()
+
+	result, err := ae.Stream("", fp, filename)
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+	return result.Metadata[ae.GetName()], result.Mimetypes, result.Pronoms, nil
+}
+
+var (
+	_ Action = &ActionEmail{}
+)
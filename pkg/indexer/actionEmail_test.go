@@ -0,0 +1,124 @@
+package indexer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/je4/utils/v2/pkg/checksum"
+)
+
+const testEmail = "From: =?utf-8?q?J=C3=BCrgen?= <juergen@example.com>\r\n" +
+	"To: info@example.com\r\n" +
+	"Subject: Test\r\n" +
+	"Date: Mon, 02 Jan 2006 15:04:05 +0000\r\n" +
+	"Message-Id: <1234@example.com>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"XXX\"\r\n" +
+	"\r\n" +
+	"--XXX\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"Hello\r\n" +
+	"--XXX\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"Content-Disposition: attachment; filename=\"test.txt\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"aW5kZXhlcg==\r\n" +
+	"--XXX--\r\n"
+
+func TestActionEmailEML(t *testing.T) {
+	ad := NewActionDispatcher(nil)
+	NewActionChecksum(NameChecksum, []checksum.DigestAlgorithm{checksum.DigestSHA256}, nil, ad)
+	ae := NewActionEmail(NameEmail, 0, true, []string{NameChecksum}, nil, ad)
+	result, err := ae.Stream("", strings.NewReader(testEmail), "test.eml")
+	if err != nil {
+		t.Fatalf("cannot read email: %v", err)
+	}
+	eresult := result.Metadata[NameEmail].(*EmailResult)
+	if eresult.Format != "eml" || eresult.MessageCount != 1 {
+		t.Fatalf("wrong result: %+v", eresult)
+	}
+	msg := eresult.Messages[0]
+	if msg.Subject != "Test" || len(msg.From) != 1 || msg.From[0] != "Jürgen <juergen@example.com>" || msg.MessageID != "1234@example.com" {
+		t.Errorf("wrong header: %+v", msg.EmailHeader)
+	}
+	if len(msg.Attachments) != 1 || msg.Attachments[0].Path != "/test.txt" || msg.Attachments[0].Size != 7 {
+		t.Fatalf("wrong attachments: %+v", msg.Attachments)
+	}
+	if len(result.Embedded) != 1 {
+		t.Fatalf("expected 1 embedded resource, got %d", len(result.Embedded))
+	}
+	embedded := result.Embedded[0]
+	if embedded.Path != "/test.txt" || embedded.Size != 7 || embedded.Source != NameEmail {
+		t.Errorf("wrong embedded resource: %+v", embedded)
+	}
+	checksums, ok := embedded.Metadata[NameChecksum].(map[checksum.DigestAlgorithm]string)
+	sum := sha256.Sum256([]byte("indexer"))
+	if !ok || checksums[checksum.DigestSHA256] != hex.EncodeToString(sum[:]) {
+		t.Errorf("attachment not indexed: %+v", embedded.Metadata)
+	}
+}
+
+func TestActionEmailMBox(t *testing.T) {
+	ad := NewActionDispatcher(nil)
+	ae := NewActionEmail(NameEmail, 2, false, nil, nil, ad)
+	var mbox bytes.Buffer
+	for i := 0; i < 3; i++ {
+		mbox.WriteString("From sender@example.com Mon Jan  2 15:04:05 2006\n")
+		mbox.WriteString(strings.ReplaceAll(testEmail, "\r\n", "\n"))
+		mbox.WriteString(">From the quoted line\n")
+		// longer than the read buffer
+		mbox.WriteString(strings.Repeat("x", 100*1024) + "\n\n")
+	}
+	result, err := ae.Stream("", &mbox, "test.mbox")
+	if err != nil {
+		t.Fatalf("cannot read mbox: %v", err)
+	}
+	eresult := result.Metadata[NameEmail].(*EmailResult)
+	if eresult.Format != "mbox" || eresult.MessageCount != 3 || len(eresult.Messages) != 2 {
+		t.Fatalf("wrong result: format %s, count %d, messages %d", eresult.Format, eresult.MessageCount, len(eresult.Messages))
+	}
+	for i, msg := range eresult.Messages {
+		if msg.Error != "" || msg.Subject != "Test" {
+			t.Errorf("message %d: %+v", i, msg)
+		}
+		if len(msg.Attachments) != 1 || msg.Attachments[0].Path != []string{"/1/test.txt", "/2/test.txt"}[i] {
+			t.Errorf("message %d: wrong attachments %+v", i, msg.Attachments)
+		}
+	}
+	if len(result.Embedded) != 2 || result.Embedded[1].Path != "/2/test.txt" {
+		t.Errorf("wrong embedded resources: %+v", result.Embedded)
+	}
+}
+
+func TestActionEmailAttachmentPath(t *testing.T) {
+	ad := NewActionDispatcher(nil)
+	ae := NewActionEmail(NameEmail, 0, false, nil, nil, ad)
+	var eml strings.Builder
+	eml.WriteString("From: info@example.com\r\nContent-Type: multipart/mixed; boundary=\"XXX\"\r\n\r\n")
+	for _, disposition := range []string{`attachment; filename="../etc/passwd"`, `attachment; filename="test.txt"`, `attachment; filename="test.txt"`, `attachment; filename=".."`} {
+		eml.WriteString("--XXX\r\nContent-Type: text/plain\r\nContent-Disposition: " + disposition + "\r\n\r\nindexer\r\n")
+	}
+	eml.WriteString("--XXX--\r\n")
+	result, err := ae.Stream("", strings.NewReader(eml.String()), "test.eml")
+	if err != nil {
+		t.Fatalf("cannot read email: %v", err)
+	}
+	msg := result.Metadata[NameEmail].(*EmailResult).Messages[0]
+	expected := []string{"/.._etc_passwd", "/test.txt", "/test-2.txt", "/attachment4"}
+	if len(msg.Attachments) != len(expected) {
+		t.Fatalf("wrong attachments: %+v", msg.Attachments)
+	}
+	for i, att := range msg.Attachments {
+		if att.Path != expected[i] || result.Embedded[i].Path != expected[i] {
+			t.Errorf("attachment %d: path %s, expected %s", i, att.Path, expected[i])
+		}
+	}
+	if msg.Attachments[0].Filename != "../etc/passwd" {
+		t.Errorf("original filename lost: %s", msg.Attachments[0].Filename)
+	}
+}
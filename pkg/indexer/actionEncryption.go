@@ -0,0 +1,780 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indexer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"emperror.dev/errors"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"github.com/richardlehane/mscfb"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EncryptionResult is the normalized result of the encryption detection
+type EncryptionResult struct {
+	Encrypted bool           `json:"encrypted"`
+	Format    string         `json:"format"`
+	Method    string         `json:"method,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+type ActionEncryption struct {
+	name    string
+	tempDir string
+	server  *Server
+}
+
+func (ae *ActionEncryption) CanHandle(contentType string, filename string) bool {
+	return true
+}
+
+func NewActionEncryption(name string, tempDir string, server *Server, ad *ActionDispatcher) Action {
+	ae := &ActionEncryption{name: name, tempDir: tempDir, server: server}
+	ad.RegisterAction(ae)
+	return ae
+}
+
+func (ae *ActionEncryption) GetWeight() uint {
+	return 20
+}
+
+func (ae *ActionEncryption) GetCaps() ActionCapability {
+	return ACTFILEFULL | ACTSTREAM
+}
+
+func (ae *ActionEncryption) GetName() string {
+	return ae.name
+}
+
+var (
+	magicCFB   = []byte{0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1}
+	magic7z    = []byte{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}
+	magicRAR4  = []byte("Rar!\x1a\x07\x00")
+	magicRAR5  = []byte("Rar!\x1a\x07\x01\x00")
+	magicZIP   = []byte("PK\x03\x04")
+	magicAge   = []byte("age-encryption.org/v1\n")
+	armorAge   = []byte("-----BEGIN AGE ENCRYPTED FILE-----")
+	armorPGP   = []byte("-----BEGIN PGP MESSAGE-----")
+	magicPDF   = []byte("%PDF-")
+	aes7zCoder = []byte{0x06, 0xf1, 0x07, 0x01}
+)
+
+func (ae *ActionEncryption) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
+	br := bufio.NewReaderSize(reader, 64*1024)
+	head, _ := br.Peek(4096)
+	var eresult *EncryptionResult
+	var err error
+	switch {
+	case bytes.HasPrefix(head, magicPDF):
+		eresult, err = checkPDFEncryption(br)
+	case bytes.HasPrefix(head, magicAge), bytes.HasPrefix(head, armorAge):
+		eresult = checkAgeEncryption(head)
+	case bytes.HasPrefix(head, armorPGP):
+		eresult = checkPGPArmorEncryption(head)
+	case bytes.HasPrefix(head, magicZIP), bytes.HasPrefix(head, magicCFB), bytes.HasPrefix(head, magic7z),
+		bytes.HasPrefix(head, magicRAR4), bytes.HasPrefix(head, magicRAR5):
+		// container formats need random access
+		fp, err := spoolTempFile(br, ae.tempDir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot spool '%s'", filename)
+		}
+		defer func() {
+			fp.Close()
+			os.Remove(fp.Name())
+		}()
+		return ae.checkFile(fp, filename)
+	default:
+		eresult = checkPGPBinaryEncryption(head)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot check encryption of '%s'", filename)
+	}
+	if eresult == nil {
+		return nil, nil
+	}
+	return ae.result(eresult), nil
+}
+
+func (ae *ActionEncryption) result(eresult *EncryptionResult) *ResultV2 {
+	var result = NewResultV2()
+	result.Encrypted = eresult.Encrypted
+	result.Metadata[ae.GetName()] = eresult
+	return result
+}
+
+func (ae *ActionEncryption) checkFile(fp *os.File, filename string) (*ResultV2, error) {
+	stat, err := fp.Stat()
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot stat '%s'", filename)
+	}
+	head := make([]byte, 8)
+	if _, err := fp.ReadAt(head, 0); err != nil && err != io.EOF {
+		return nil, errors.Wrapf(err, "cannot read '%s'", filename)
+	}
+	var eresult *EncryptionResult
+	switch {
+	case bytes.HasPrefix(head, magicZIP):
+		eresult, err = checkZIPEncryption(fp, stat.Size())
+	case bytes.HasPrefix(head, magicCFB):
+		eresult, err = checkCFBEncryption(fp)
+	case bytes.HasPrefix(head, magic7z):
+		eresult, err = check7zEncryption(fp, stat.Size())
+	case bytes.HasPrefix(head, magicRAR4), bytes.HasPrefix(head, magicRAR5):
+		eresult, err = checkRAREncryption(io.NewSectionReader(fp, 0, stat.Size()))
+	default:
+		if _, err := fp.Seek(0, io.SeekStart); err != nil {
+			return nil, errors.Wrapf(err, "cannot seek '%s'", filename)
+		}
+		return ae.Stream("", fp, filename)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot check encryption of '%s'", filename)
+	}
+	if eresult == nil {
+		return nil, nil
+	}
+	return ae.result(eresult), nil
+}
+
+func (ae *ActionEncryption) DoV2(filename string) (*ResultV2, error) {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open file '%s'", filename)
+	}
+	defer fp.Close()
+	return ae.checkFile(fp, filename)
+}
+
+func (ae *ActionEncryption) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
+	filename, err := ae.server.fm.Get(uri)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "no file url")
+	}
+	result, err := ae.DoV2(filename)
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+	if result == nil {
+		return nil, nil, nil, ErrMimeNotApplicable
+	}
+	return result.Metadata[ae.GetName()], result.Mimetypes, result.Pronoms, nil
+}
+
+var (
+	regexpPDFTrailer  = regexp.MustCompile(`trailer\s*<<`)
+	regexpPDFXRefType = regexp.MustCompile(`/Type\s*/XRef\b`)
+	regexpPDFObj      = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\s*<<`)
+	regexpPDFEncrypt  = regexp.MustCompile(`/Encrypt\s*(?:(\d+)\s+(\d+)\s+R|<<)`)
+	regexpPDFSecurity = regexp.MustCompile(`/Filter\s*/(Standard|Adobe\.PubSec|Adobe\.PPKLite|[A-Za-z]+\.[A-Za-z.]+)`)
+	regexpPDFV        = regexp.MustCompile(`/V\s+(\d+)`)
+	regexpPDFR        = regexp.MustCompile(`/R\s+(\d+)`)
+	regexpPDFLength   = regexp.MustCompile(`/Length\s+(\d+)`)
+	regexpPDFCFM      = regexp.MustCompile(`/CFM\s*/(\w+)`)
+)
+
+// pdfDict returns the dictionary, which starts with "<<" at data[start], including nested dictionaries.
+// ok is false, if the dictionary does not end within data
+func pdfDict(data []byte, start int) (dict []byte, ok bool) {
+	var depth int
+	for i := start; i+1 < len(data); i++ {
+		switch {
+		case data[i] == '<' && data[i+1] == '<':
+			depth++
+			i++
+		case data[i] == '>' && data[i+1] == '>':
+			depth--
+			i++
+			if depth == 0 {
+				return data[start : i+1], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// pdfObjectDict returns the object reference and dictionary of the last indirect object, which starts before pos
+func pdfObjectDict(data []byte, pos int) (string, []byte, bool) {
+	locs := regexpPDFObj.FindAllSubmatchIndex(data[max(0, pos-pdfOverlap):pos], -1)
+	if len(locs) == 0 {
+		return "", nil, false
+	}
+	offset := max(0, pos-pdfOverlap)
+	loc := locs[len(locs)-1]
+	dict, ok := pdfDict(data, offset+loc[1]-2)
+	if !ok || offset+loc[1]-2+len(dict) < pos {
+		return "", nil, false
+	}
+	return string(data[offset+loc[2]:offset+loc[3]]) + " " + string(data[offset+loc[4]:offset+loc[5]]), dict, true
+}
+
+// pdfOverlap is kept from the previous chunk, trailer and encryption dictionaries are expected to be smaller
+const pdfOverlap = 8 * 1024
+
+// checkPDFEncryption looks for an /Encrypt entry in the trailer dictionaries and xref stream dictionaries.
+// /Encrypt anywhere else (i.e. in text content) is ignored. trailer and encryption dictionary are never compressed,
+// so a byte scan is sufficient
+func checkPDFEncryption(reader io.Reader) (*EncryptionResult, error) {
+	eresult := &EncryptionResult{Format: "pdf"}
+	// encryption dictionaries by object reference
+	var encDicts = map[string][]byte{}
+	var encRef string
+	var encDict []byte
+	var buf = make([]byte, 0, 64*1024+pdfOverlap)
+	var chunk = make([]byte, 64*1024)
+	for {
+		n, err := io.ReadFull(reader, chunk)
+		buf = append(buf, chunk[:n]...)
+
+		var trailers [][]byte
+		for _, loc := range regexpPDFTrailer.FindAllIndex(buf, -1) {
+			if dict, ok := pdfDict(buf, loc[1]-2); ok {
+				trailers = append(trailers, dict)
+			}
+		}
+		for _, loc := range regexpPDFXRefType.FindAllIndex(buf, -1) {
+			if _, dict, ok := pdfObjectDict(buf, loc[0]); ok {
+				trailers = append(trailers, dict)
+			}
+		}
+		for _, trailer := range trailers {
+			m := regexpPDFEncrypt.FindSubmatchIndex(trailer)
+			if m == nil {
+				continue
+			}
+			eresult.Encrypted = true
+			if m[2] >= 0 {
+				encRef = string(trailer[m[2]:m[3]]) + " " + string(trailer[m[4]:m[5]])
+			} else if dict, ok := pdfDict(trailer, m[1]-2); ok {
+				encDict = bytes.Clone(dict)
+			}
+		}
+		for _, loc := range regexpPDFSecurity.FindAllIndex(buf, -1) {
+			if ref, dict, ok := pdfObjectDict(buf, loc[0]); ok {
+				encDicts[ref] = bytes.Clone(dict)
+			}
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read pdf")
+		}
+		if len(buf) > pdfOverlap {
+			buf = append(buf[:0], buf[len(buf)-pdfOverlap:]...)
+		}
+	}
+	if !eresult.Encrypted {
+		return eresult, nil
+	}
+	if encDict == nil {
+		encDict = encDicts[encRef]
+	}
+	if encDict == nil {
+		return eresult, nil
+	}
+	eresult.Details = map[string]any{}
+	if m := regexpPDFSecurity.FindSubmatch(encDict); m != nil {
+		eresult.Details["filter"] = string(m[1])
+	}
+	for key, re := range map[string]*regexp.Regexp{"v": regexpPDFV, "r": regexpPDFR, "length": regexpPDFLength, "cfm": regexpPDFCFM} {
+		if m := re.FindSubmatch(encDict); m != nil {
+			eresult.Details[key] = string(m[1])
+		}
+	}
+	filter, _ := eresult.Details["filter"].(string)
+	v, _ := strconv.Atoi(fmt.Sprint(eresult.Details["v"]))
+	cfm, _ := eresult.Details["cfm"].(string)
+	switch {
+	case filter != "" && filter != "Standard":
+		eresult.Method = "public key (" + filter + ")"
+	case v >= 5 || cfm == "AESV3":
+		eresult.Method = "AES-256"
+	case cfm == "AESV2":
+		eresult.Method = "AES-128"
+	case v >= 1:
+		eresult.Method = "RC4"
+	}
+	return eresult, nil
+}
+
+func checkAgeEncryption(head []byte) *EncryptionResult {
+	eresult := &EncryptionResult{Encrypted: true, Format: "age", Method: "age-encryption.org/v1"}
+	if bytes.HasPrefix(head, armorAge) {
+		eresult.Details = map[string]any{"armored": true}
+		return eresult
+	}
+	var recipients = map[string]int{}
+	for _, line := range strings.Split(string(head), "\n") {
+		if strings.HasPrefix(line, "---") {
+			break
+		}
+		if strings.HasPrefix(line, "-> ") {
+			fields := strings.Fields(line)
+			if len(fields) > 1 {
+				recipients[fields[1]]++
+			}
+		}
+	}
+	eresult.Details = map[string]any{"recipients": recipients}
+	return eresult
+}
+
+var pgpPacketNames = map[byte]string{
+	1:  "public-key encrypted session key",
+	3:  "symmetric-key encrypted session key",
+	9:  "symmetrically encrypted data",
+	18: "symmetrically encrypted integrity protected data",
+	20: "aead encrypted data",
+}
+
+// pgpPacketHeader returns the tag of the first openpgp packet and the offset of its body
+func pgpPacketHeader(data []byte) (byte, int) {
+	if len(data) < 3 || data[0]&0x80 == 0 {
+		return 0, 0
+	}
+	if data[0]&0x40 != 0 {
+		switch l := data[1]; {
+		case l < 192, l >= 224 && l < 255:
+			return data[0] & 0x3f, 2
+		case l < 224:
+			return data[0] & 0x3f, 3
+		default:
+			return data[0] & 0x3f, 6
+		}
+	}
+	return (data[0] >> 2) & 0x0f, []int{2, 3, 5, 1}[data[0]&0x03]
+}
+
+// checkPGPBinaryEncryption accepts only messages starting with a session key packet
+// with a known version. everything else is too likely to be a false positive
+func checkPGPBinaryEncryption(head []byte) *EncryptionResult {
+	tag, offset := pgpPacketHeader(head)
+	if offset >= len(head) {
+		return nil
+	}
+	version := head[offset]
+	switch {
+	case tag == 1 && (version == 3 || version == 6):
+	case tag == 3 && (version == 4 || version == 5 || version == 6):
+	default:
+		return nil
+	}
+	return &EncryptionResult{
+		Encrypted: true,
+		Format:    "openpgp",
+		Method:    pgpPacketNames[tag],
+	}
+}
+
+func checkPGPArmorEncryption(head []byte) *EncryptionResult {
+	eresult := &EncryptionResult{Format: "openpgp", Details: map[string]any{"armored": true}}
+	// armor headers are separated from the base64 data by an empty line
+	sc := bufio.NewScanner(bytes.NewReader(head))
+	var inData bool
+	var b64 strings.Builder
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if !inData {
+			if line == "" {
+				inData = true
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "=") || strings.HasPrefix(line, "-----") {
+			break
+		}
+		b64.WriteString(line)
+		if b64.Len() > 64 {
+			break
+		}
+	}
+	data := b64.String()
+	data = data[:len(data)/4*4]
+	packet, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return eresult
+	}
+	tag, _ := pgpPacketHeader(packet)
+	if name, ok := pgpPacketNames[tag]; ok {
+		eresult.Encrypted = true
+		eresult.Method = name
+	}
+	return eresult
+}
+
+func checkZIPEncryption(ra io.ReaderAt, size int64) (*EncryptionResult, error) {
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open zip")
+	}
+	eresult := &EncryptionResult{Format: "zip"}
+	var encrypted int
+	var methods = map[string]int{}
+	var odfEncrypted bool
+	for _, f := range zr.File {
+		if f.Name == "META-INF/manifest.xml" {
+			if r, err := f.Open(); err == nil {
+				data, _ := io.ReadAll(io.LimitReader(r, 10*1024*1024))
+				r.Close()
+				odfEncrypted = bytes.Contains(data, []byte("encryption-data"))
+			}
+		}
+		if f.Flags&0x1 == 0 {
+			continue
+		}
+		encrypted++
+		switch {
+		case f.Method == 99:
+			methods["aes"]++
+		case f.Flags&0x40 != 0:
+			methods["strong"]++
+		default:
+			methods["zipcrypto"]++
+		}
+	}
+	eresult.Details = map[string]any{
+		"entries":   len(zr.File),
+		"encrypted": encrypted,
+	}
+	if len(methods) > 0 {
+		eresult.Details["methods"] = methods
+		var names []string
+		for m := range methods {
+			names = append(names, m)
+		}
+		eresult.Method = strings.Join(names, ",")
+	}
+	if odfEncrypted {
+		eresult.Format = "odf"
+		eresult.Method = "odf manifest encryption"
+	}
+	eresult.Encrypted = encrypted > 0 || odfEncrypted
+	return eresult, nil
+}
+
+func checkCFBEncryption(ra io.ReaderAt) (*EncryptionResult, error) {
+	doc, err := mscfb.New(ra)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open compound file")
+	}
+	eresult := &EncryptionResult{Format: "cfb"}
+	var streams = map[string]*mscfb.File{}
+	for entry, err := doc.Next(); err == nil; entry, err = doc.Next() {
+		if len(entry.Path) == 0 {
+			streams[entry.Name] = entry
+		}
+	}
+	if _, ok := streams["EncryptedPackage"]; ok {
+		// password protected ooxml
+		eresult.Format = "ooxml"
+		eresult.Encrypted = true
+		eresult.Method = "encrypted package"
+		if info, ok := streams["EncryptionInfo"]; ok {
+			var version = make([]byte, 4)
+			if _, err := io.ReadFull(info, version); err == nil {
+				major := binary.LittleEndian.Uint16(version[0:2])
+				minor := binary.LittleEndian.Uint16(version[2:4])
+				eresult.Details = map[string]any{"version": fmt.Sprintf("%d.%d", major, minor)}
+				switch {
+				case major == 4 && minor == 4:
+					eresult.Method = "agile"
+				case minor == 2 && (major == 2 || major == 3 || major == 4):
+					eresult.Method = "standard"
+				case minor == 3 && (major == 3 || major == 4):
+					eresult.Method = "extensible"
+				}
+			}
+		}
+		return eresult, nil
+	}
+	if word, ok := streams["WordDocument"]; ok {
+		eresult.Format = "doc"
+		var fib = make([]byte, 12)
+		if _, err := io.ReadFull(word, fib); err == nil {
+			flags := binary.LittleEndian.Uint16(fib[10:12])
+			if flags&0x0100 != 0 {
+				eresult.Encrypted = true
+				eresult.Method = "rc4"
+				if flags&0x8000 != 0 {
+					eresult.Method = "xor obfuscation"
+				}
+			}
+		}
+		return eresult, nil
+	}
+	if book, ok := streams["Workbook"]; ok {
+		eresult.Format = "xls"
+		eresult.Encrypted, eresult.Method = checkXLSFilePass(book)
+		return eresult, nil
+	}
+	if book, ok := streams["Book"]; ok {
+		eresult.Format = "xls"
+		eresult.Encrypted, eresult.Method = checkXLSFilePass(book)
+		return eresult, nil
+	}
+	if _, ok := streams["PowerPoint Document"]; ok {
+		eresult.Format = "ppt"
+		if _, ok := streams["EncryptedSummary"]; ok {
+			eresult.Encrypted = true
+			eresult.Method = "cryptoapi"
+		}
+		return eresult, nil
+	}
+	return eresult, nil
+}
+
+// checkXLSFilePass looks for a FILEPASS record in the workbook globals substream
+func checkXLSFilePass(r io.Reader) (bool, string) {
+	br := bufio.NewReader(r)
+	var hdr = make([]byte, 4)
+	for i := 0; i < 1000; i++ {
+		if _, err := io.ReadFull(br, hdr); err != nil {
+			return false, ""
+		}
+		recType := binary.LittleEndian.Uint16(hdr[0:2])
+		recLen := int(binary.LittleEndian.Uint16(hdr[2:4]))
+		switch recType {
+		case 0x002f:
+			var data = make([]byte, 2)
+			if _, err := io.ReadFull(br, data); err != nil {
+				return true, ""
+			}
+			if binary.LittleEndian.Uint16(data) == 0 {
+				return true, "xor obfuscation"
+			}
+			return true, "rc4"
+		case 0x000a:
+			// end of globals
+			return false, ""
+		}
+		if _, err := br.Discard(recLen); err != nil {
+			return false, ""
+		}
+	}
+	return false, ""
+}
+
+func check7zEncryption(ra io.ReaderAt, size int64) (*EncryptionResult, error) {
+	eresult := &EncryptionResult{Format: "7z"}
+	var hdr = make([]byte, 32)
+	if _, err := ra.ReadAt(hdr, 0); err != nil {
+		return nil, errors.Wrap(err, "cannot read 7z start header")
+	}
+	nextOffset := int64(binary.LittleEndian.Uint64(hdr[12:20]))
+	nextSize := int64(binary.LittleEndian.Uint64(hdr[20:28]))
+	if nextOffset < 0 || nextSize <= 0 || 32+nextOffset+nextSize > size || nextSize > 64*1024*1024 {
+		eresult.Details = map[string]any{"error": "invalid next header"}
+		return eresult, nil
+	}
+	var next = make([]byte, nextSize)
+	if _, err := ra.ReadAt(next, 32+nextOffset); err != nil {
+		return nil, errors.Wrap(err, "cannot read 7z next header")
+	}
+	hasAES := bytes.Contains(next, aes7zCoder)
+	switch next[0] {
+	case 0x17:
+		// encoded header: the coder list of the header stream tells us
+		// whether the file list itself is encrypted
+		if hasAES {
+			eresult.Encrypted = true
+			eresult.Method = "aes-256"
+			eresult.Details = map[string]any{"headers": true}
+		}
+	case 0x01:
+		if hasAES {
+			eresult.Encrypted = true
+			eresult.Method = "aes-256"
+			eresult.Details = map[string]any{"headers": false}
+		}
+	}
+	return eresult, nil
+}
+
+// readVInt reads a rar5 variable length integer
+func readVInt(br io.ByteReader) (uint64, error) {
+	var result uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		result |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return result, nil
+		}
+	}
+	return 0, errors.New("invalid vint")
+}
+
+func checkRAREncryption(r *io.SectionReader) (*EncryptionResult, error) {
+	var sig = make([]byte, 8)
+	if _, err := io.ReadFull(r, sig); err != nil {
+		return nil, errors.Wrap(err, "cannot read rar signature")
+	}
+	if bytes.HasPrefix(sig, magicRAR5) {
+		return checkRAR5Encryption(r)
+	}
+	if _, err := r.Seek(int64(len(magicRAR4)), io.SeekStart); err != nil {
+		return nil, errors.Wrap(err, "cannot seek")
+	}
+	return checkRAR4Encryption(r)
+}
+
+func checkRAR4Encryption(r *io.SectionReader) (*EncryptionResult, error) {
+	eresult := &EncryptionResult{Format: "rar4"}
+	var files, encrypted int
+	var hdr = make([]byte, 7)
+	for i := 0; i < 10000; i++ {
+		pos, _ := r.Seek(0, io.SeekCurrent)
+		if _, err := io.ReadFull(r, hdr); err != nil {
+			break
+		}
+		headType := hdr[2]
+		flags := binary.LittleEndian.Uint16(hdr[3:5])
+		headSize := int64(binary.LittleEndian.Uint16(hdr[5:7]))
+		if headSize < 7 {
+			break
+		}
+		var addSize int64
+		if flags&0x8000 != 0 || headType == 0x74 {
+			var add = make([]byte, 4)
+			if _, err := io.ReadFull(r, add); err != nil {
+				break
+			}
+			addSize = int64(binary.LittleEndian.Uint32(add))
+		}
+		switch headType {
+		case 0x73:
+			if flags&0x0080 != 0 {
+				eresult.Encrypted = true
+				eresult.Method = "aes-128"
+				eresult.Details = map[string]any{"headers": true}
+				return eresult, nil
+			}
+		case 0x74:
+			files++
+			if flags&0x0004 != 0 {
+				encrypted++
+			}
+		case 0x7b:
+			i = 10000
+		}
+		if _, err := r.Seek(pos+headSize+addSize, io.SeekStart); err != nil {
+			break
+		}
+	}
+	eresult.Details = map[string]any{"headers": false, "entries": files, "encrypted": encrypted}
+	if encrypted > 0 {
+		eresult.Encrypted = true
+		eresult.Method = "aes-128"
+	}
+	return eresult, nil
+}
+
+func checkRAR5Encryption(r *io.SectionReader) (*EncryptionResult, error) {
+	eresult := &EncryptionResult{Format: "rar5"}
+	var files, encrypted int
+	for i := 0; i < 10000; i++ {
+		if _, err := r.Seek(4, io.SeekCurrent); err != nil {
+			break
+		}
+		br := bufio.NewReader(r)
+		start, _ := r.Seek(0, io.SeekCurrent)
+		headSize, err := readVInt(br)
+		if err != nil || headSize == 0 || headSize > 2*1024*1024 {
+			break
+		}
+		var head = make([]byte, headSize)
+		if _, err := io.ReadFull(br, head); err != nil {
+			break
+		}
+		hr := bytes.NewReader(head)
+		headType, _ := readVInt(hr)
+		headFlags, _ := readVInt(hr)
+		var extraSize, dataSize uint64
+		if headFlags&0x01 != 0 {
+			extraSize, _ = readVInt(hr)
+		}
+		if headFlags&0x02 != 0 {
+			dataSize, _ = readVInt(hr)
+		}
+		switch headType {
+		case 4:
+			eresult.Encrypted = true
+			eresult.Method = "aes-256"
+			eresult.Details = map[string]any{"headers": true}
+			return eresult, nil
+		case 2:
+			files++
+			if extraSize > 0 && extraSize <= headSize {
+				if rar5HasEncryptionRecord(head[headSize-extraSize:]) {
+					encrypted++
+				}
+			}
+		case 5:
+			i = 10000
+		}
+		// position after header: start + size of vint + header size
+		var vintLen int64 = 1
+		for v := headSize; v >= 0x80; v >>= 7 {
+			vintLen++
+		}
+		if _, err := r.Seek(start+vintLen+int64(headSize)+int64(dataSize), io.SeekStart); err != nil {
+			break
+		}
+	}
+	eresult.Details = map[string]any{"headers": false, "entries": files, "encrypted": encrypted}
+	if encrypted > 0 {
+		eresult.Encrypted = true
+		eresult.Method = "aes-256"
+	}
+	return eresult, nil
+}
+
+func rar5HasEncryptionRecord(extra []byte) bool {
+	er := bytes.NewReader(extra)
+	for er.Len() > 0 {
+		size, err := readVInt(er)
+		if err != nil || size == 0 || size > uint64(er.Len()) {
+			return false
+		}
+		start := er.Len()
+		recType, err := readVInt(er)
+		if err != nil {
+			return false
+		}
+		if recType == 0x01 {
+			return true
+		}
+		if _, err := er.Seek(int64(size)-int64(start-er.Len()), io.SeekCurrent); err != nil {
+			return false
+		}
+	}
+	return false
+}
+
+var (
+	_ Action = &ActionEncryption{}
+)
@@ -0,0 +1,205 @@
+package indexer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+// cfbFile builds a compound file with the streams in the root storage.
+// every stream is padded to 4096 bytes, so no mini stream is needed
+func cfbFile(names []string, streams map[string][]byte) []byte {
+	const sector = 512
+	const endOfChain, freeSect, noStream = 0xfffffffe, 0xffffffff, 0xffffffff
+	var data = make([]byte, sector)
+	copy(data, []byte{0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1})
+	binary.LittleEndian.PutUint16(data[24:], 0x3e)
+	binary.LittleEndian.PutUint16(data[26:], 3)
+	binary.LittleEndian.PutUint16(data[28:], 0xfffe)
+	binary.LittleEndian.PutUint16(data[30:], 9)
+	binary.LittleEndian.PutUint16(data[32:], 6)
+	binary.LittleEndian.PutUint32(data[44:], 1)          // fat sectors
+	binary.LittleEndian.PutUint32(data[48:], 1)          // first directory sector
+	binary.LittleEndian.PutUint32(data[56:], 4096)       // mini stream cutoff
+	binary.LittleEndian.PutUint32(data[60:], endOfChain) // no mini fat
+	binary.LittleEndian.PutUint32(data[68:], endOfChain) // no difat
+	for i := 0; i < 109; i++ {
+		binary.LittleEndian.PutUint32(data[76+4*i:], freeSect)
+	}
+	binary.LittleEndian.PutUint32(data[76:], 0)
+
+	var fat = make([]byte, sector)
+	for i := 0; i < sector/4; i++ {
+		binary.LittleEndian.PutUint32(fat[4*i:], freeSect)
+	}
+	binary.LittleEndian.PutUint32(fat[0:], 0xfffffffd)
+	binary.LittleEndian.PutUint32(fat[4:], endOfChain)
+
+	var dir = make([]byte, sector)
+	entry := func(i int, name string, typ byte, start uint32, size uint64, right, child uint32) {
+		e := dir[i*128 : (i+1)*128]
+		u := utf16.Encode([]rune(name))
+		for j, c := range u {
+			binary.LittleEndian.PutUint16(e[2*j:], c)
+		}
+		binary.LittleEndian.PutUint16(e[64:], uint16(2*len(u)+2))
+		e[66], e[67] = typ, 1
+		binary.LittleEndian.PutUint32(e[68:], noStream)
+		binary.LittleEndian.PutUint32(e[72:], right)
+		binary.LittleEndian.PutUint32(e[76:], child)
+		binary.LittleEndian.PutUint32(e[116:], start)
+		binary.LittleEndian.PutUint64(e[120:], size)
+	}
+	entry(0, "Root Entry", 5, endOfChain, 0, noStream, 1)
+	var content []byte
+	next := uint32(2)
+	for i, name := range names {
+		stream := make([]byte, 4096)
+		copy(stream, streams[name])
+		right := uint32(noStream)
+		if i < len(names)-1 {
+			right = uint32(i + 2)
+		}
+		entry(i+1, name, 2, next, uint64(len(stream)), right, noStream)
+		for s := uint32(0); s < 8; s++ {
+			link := next + s + 1
+			if s == 7 {
+				link = endOfChain
+			}
+			binary.LittleEndian.PutUint32(fat[4*(next+s):], link)
+		}
+		next += 8
+		content = append(content, stream...)
+	}
+	for i := len(names) + 1; i < 4; i++ {
+		e := dir[i*128:]
+		binary.LittleEndian.PutUint32(e[68:], noStream)
+		binary.LittleEndian.PutUint32(e[72:], noStream)
+		binary.LittleEndian.PutUint32(e[76:], noStream)
+	}
+	data = append(data, fat...)
+	data = append(data, dir...)
+	return append(data, content...)
+}
+
+func zipFile(t *testing.T, flags uint16, files map[string]string) []byte {
+	var buf = &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, content := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Flags: flags})
+		if err != nil {
+			t.Fatalf("cannot create zip entry: %v", err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("cannot close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func sevenZipFile(next []byte) []byte {
+	var data = make([]byte, 32)
+	copy(data, []byte{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c, 0, 4})
+	binary.LittleEndian.PutUint64(data[12:], 0)
+	binary.LittleEndian.PutUint64(data[20:], uint64(len(next)))
+	return append(data, next...)
+}
+
+// rar4File has an archive header and one file header with the given flags
+func rar4File(archiveFlags, fileFlags uint16) []byte {
+	var data = []byte("Rar!\x1a\x07\x00")
+	var archive = make([]byte, 13)
+	archive[2] = 0x73
+	binary.LittleEndian.PutUint16(archive[3:], archiveFlags)
+	binary.LittleEndian.PutUint16(archive[5:], 13)
+	data = append(data, archive...)
+	var file = make([]byte, 32)
+	file[2] = 0x74
+	binary.LittleEndian.PutUint16(file[3:], fileFlags|0x8000)
+	binary.LittleEndian.PutUint16(file[5:], 32)
+	data = append(data, file...)
+	var end = make([]byte, 7)
+	end[2] = 0x7b
+	binary.LittleEndian.PutUint16(end[5:], 7)
+	return append(data, end...)
+}
+
+// rar5File has a main header and one file header with the extra record type
+func rar5File(headerType byte, extraRecord byte) []byte {
+	var data = []byte("Rar!\x1a\x07\x01\x00")
+	header := func(head []byte) {
+		data = append(data, 0, 0, 0, 0, byte(len(head)))
+		data = append(data, head...)
+	}
+	header([]byte{1, 0, 0})
+	if headerType == 4 {
+		header([]byte{4, 0, 0, 0})
+	}
+	// type 2, flags: extra area, extra size 3, file fields, extra record
+	header([]byte{2, 0x01, 3, 0, 0, 0, 2, extraRecord, 0})
+	header([]byte{5, 0, 0})
+	return data
+}
+
+func TestActionEncryption(t *testing.T) {
+	ae := &ActionEncryption{name: NameEncryption, tempDir: t.TempDir()}
+	pgpEncrypted := []byte{0xc1, 0x0c, 3, 0, 1, 2, 3, 4, 5, 6, 7, 8, 1, 0}
+	pgpLiteral := []byte{0xcb, 0x0c, 'b', 0, 0, 0, 0, 0, 't', 'e', 's', 't', '\n', 0}
+	armor := func(packet []byte) []byte {
+		return []byte("-----BEGIN PGP MESSAGE-----\n\n" + base64.StdEncoding.EncodeToString(packet) + "\n-----END PGP MESSAGE-----\n")
+	}
+	var tests = []struct {
+		name      string
+		data      []byte
+		encrypted bool
+		format    string
+		method    string
+	}{
+		{"pdf plain", []byte("%PDF-1.4\n1 0 obj\n<< /Length 44 >>\nstream\nBT (see /Encrypt 2 0 R and /Filter /Standard) Tj ET\nendstream\nendobj\ntrailer\n<< /Root 1 0 R /Size 2 >>\nstartxref\n0\n%%EOF\n"), false, "pdf", ""},
+		{"pdf encrypted", []byte("%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\n2 0 obj\n<< /Filter /Standard /V 4 /R 4 /Length 128 /CF << /StdCF << /CFM /AESV2 >> >> >>\nendobj\ntrailer\n<< /Root 1 0 R /Encrypt 2 0 R /Size 3 >>\nstartxref\n0\n%%EOF\n"), true, "pdf", "AES-128"},
+		{"pdf xref stream", []byte("%PDF-1.5\n2 0 obj\n<< /Filter /Standard /V 5 /R 6 >>\nendobj\n3 0 obj\n<< /Type /XRef /Size 4 /Encrypt 2 0 R /W [1 2 1] >>\nstream\nendstream\nendobj\nstartxref\n0\n%%EOF\n"), true, "pdf", "AES-256"},
+		{"zip plain", zipFile(t, 0, map[string]string{"test.txt": "indexer"}), false, "zip", ""},
+		{"zip zipcrypto", zipFile(t, 0x1, map[string]string{"test.txt": "indexer"}), true, "zip", "zipcrypto"},
+		{"odf encrypted", zipFile(t, 0, map[string]string{"mimetype": "application/vnd.oasis.opendocument.text", "META-INF/manifest.xml": "<manifest:encryption-data/>"}), true, "odf", "odf manifest encryption"},
+		{"ooxml encrypted", cfbFile([]string{"EncryptionInfo", "EncryptedPackage"}, map[string][]byte{"EncryptionInfo": {4, 0, 4, 0}}), true, "ooxml", "agile"},
+		{"doc plain", cfbFile([]string{"WordDocument"}, map[string][]byte{"WordDocument": {0xec, 0xa5}}), false, "doc", ""},
+		{"doc encrypted", cfbFile([]string{"WordDocument"}, map[string][]byte{"WordDocument": {0xec, 0xa5, 0, 0, 0, 0, 0, 0, 0, 0, 0x00, 0x01}}), true, "doc", "rc4"},
+		{"7z plain", sevenZipFile([]byte{0x01, 0x04, 0x06, 0x00}), false, "7z", ""},
+		{"7z encrypted headers", sevenZipFile([]byte{0x17, 0x06, 0xf1, 0x07, 0x01, 0x00}), true, "7z", "aes-256"},
+		{"rar4 plain", rar4File(0, 0), false, "rar4", ""},
+		{"rar4 encrypted file", rar4File(0, 0x0004), true, "rar4", "aes-128"},
+		{"rar4 encrypted headers", rar4File(0x0080, 0), true, "rar4", "aes-128"},
+		{"rar5 plain", rar5File(0, 2), false, "rar5", ""},
+		{"rar5 encrypted file", rar5File(0, 1), true, "rar5", "aes-256"},
+		{"rar5 encrypted headers", rar5File(4, 2), true, "rar5", "aes-256"},
+		{"pgp binary", pgpEncrypted, true, "openpgp", "public-key encrypted session key"},
+		{"pgp armor", armor(pgpEncrypted), true, "openpgp", "public-key encrypted session key"},
+		{"pgp armor plain", armor(pgpLiteral), false, "openpgp", ""},
+		{"age", []byte("age-encryption.org/v1\n-> X25519 abc\ndef\n--- mac\n"), true, "age", "age-encryption.org/v1"},
+	}
+	for _, test := range tests {
+		result, err := ae.Stream("", bytes.NewReader(test.data), "test.bin")
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if result == nil {
+			t.Errorf("%s: no result", test.name)
+			continue
+		}
+		eresult := result.Metadata[NameEncryption].(*EncryptionResult)
+		if eresult.Encrypted != test.encrypted || result.Encrypted != test.encrypted || eresult.Format != test.format || eresult.Method != test.method {
+			t.Errorf("%s: wrong result %+v", test.name, eresult)
+		}
+	}
+
+	// binary data without session key packet is not reported
+	result, err := ae.Stream("", bytes.NewReader(pgpLiteral), "test.bin")
+	if err != nil || result != nil {
+		t.Errorf("pgp literal: unexpected result %v, %v", result, err)
+	}
+}
@@ -19,15 +19,17 @@
 package indexer
 
 import (
+	"bytes"
 	"emperror.dev/errors"
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -37,16 +39,22 @@ type ExternalActionCalltype uint
 const (
 	EACTURL      ExternalActionCalltype = 1 << iota // url with placehoder for full path
 	EACTJSONPOST                                    // send json struct via post
+	EACTSTREAM                                      // send file content via post
+	EACTCOMMAND                                     // run local executable with json result on stdout
 )
 
 var EACTString map[ExternalActionCalltype]string = map[ExternalActionCalltype]string{
 	EACTURL:      "EACTURL",
 	EACTJSONPOST: "EACTJSONPOST",
+	EACTSTREAM:   "EACTSTREAM",
+	EACTCOMMAND:  "EACTCOMMAND",
 }
 
 var EACTAction map[string]ExternalActionCalltype = map[string]ExternalActionCalltype{
 	"EACTURL":      EACTURL,
 	"EACTJSONPOST": EACTJSONPOST,
+	"EACTSTREAM":   EACTSTREAM,
+	"EACTCOMMAND":  EACTCOMMAND,
 }
 
 // for toml decoding
@@ -59,6 +67,41 @@ func (a *ExternalActionCalltype) UnmarshalText(text []byte) error {
 	return nil
 }
 
+// ExternalRequest is the body of EACTJSONPOST calls
+type ExternalRequest struct {
+	URL       string            `json:"url,omitempty"`
+	Filename  string            `json:"filename,omitempty"`
+	Mimetype  string            `json:"mimetype,omitempty"`
+	Checksums map[string]string `json:"checksums,omitempty"`
+}
+
+// ExternalResponseMapping contains dot separated paths into the json reply (i.e. "result.formats.0.mime").
+// an empty path is not mapped. Metadata selects the part of the reply, which is stored as metadata
+type ExternalResponseMapping struct {
+	Mimetypes string
+	Pronoms   string
+	Type      string
+	Subtype   string
+	Width     string
+	Height    string
+	Duration  string
+	Metadata  string
+}
+
+// DefaultExternalTimeout is used, if no timeout is configured for an external action
+const DefaultExternalTimeout = 60 * time.Second
+
+type ExternalActionOptions struct {
+	Headers     map[string]string
+	BearerToken string
+	Timeout     time.Duration
+	Retries     int
+	RetryDelay  time.Duration
+	Response    *ExternalResponseMapping
+	Args        []string // EACTCOMMAND arguments
+	TempDir     string   // EACTCOMMAND spool folder for [[PATH]]
+}
+
 type ActionExternal struct {
 	name       string
 	url        string
@@ -66,28 +109,107 @@ type ActionExternal struct {
 	callType   ExternalActionCalltype
 	server     *Server
 	mimetype   *regexp.Regexp
-}
-
-func (as *ActionExternal) DoV2(filename string) (*ResultV2, error) {
-	//TODO implement me
-	panic("implement me")
+	options    *ExternalActionOptions
+	client     *http.Client
 }
 
 func (as *ActionExternal) CanHandle(contentType string, filename string) bool {
-	return true
+	return contentType == "" || as.mimetype.MatchString(contentType)
 }
 
 func (as *ActionExternal) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
-	return nil, errors.New("external actions does not support streaming")
+	if as.callType == EACTCOMMAND {
+		data, err := as.runCommand(reader, "", &ExternalRequest{Filename: filename, Mimetype: contentType})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return as.resultFragment(data)
+	}
+	if as.callType != EACTSTREAM {
+		return nil, errors.Errorf("external action %s with calltype %s does not support streaming", as.name, EACTString[as.callType])
+	}
+	// a stream cannot be sent twice, so there are no retries
+	data, err := as.request(func() (io.Reader, error) { return reader, nil }, contentType, filename, false)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return as.resultV2(data)
 }
 
-func NewActionExternal(name, address string, capability ActionCapability, callType ExternalActionCalltype, mimetype string, server *Server, ad *ActionDispatcher) Action {
+func (as *ActionExternal) DoV2(filename string) (*ResultV2, error) {
+	var body func() (io.Reader, error)
+	var contentType string
+	switch as.callType {
+	case EACTCOMMAND:
+		data, err := as.runCommand(nil, filename, &ExternalRequest{
+			URL:      "file:///" + strings.TrimLeft(filepath.ToSlash(filename), "/"),
+			Filename: filename,
+		})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return as.resultFragment(data)
+	case EACTURL:
+	case EACTJSONPOST:
+		data, err := json.Marshal(&ExternalRequest{
+			URL:      "file:///" + strings.TrimLeft(filepath.ToSlash(filename), "/"),
+			Filename: filename,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot marshal request")
+		}
+		contentType = "application/json"
+		body = func() (io.Reader, error) { return bytes.NewReader(data), nil }
+	case EACTSTREAM:
+		var fp *os.File
+		defer func() {
+			if fp != nil {
+				fp.Close()
+			}
+		}()
+		body = func() (io.Reader, error) {
+			if fp != nil {
+				fp.Close()
+			}
+			var err error
+			fp, err = os.Open(filename)
+			return fp, errors.Wrapf(err, "cannot open '%s'", filename)
+		}
+	default:
+		return nil, errors.Errorf("unknown calltype %v", as.callType)
+	}
+	data, err := as.request(body, contentType, filename, true)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return as.resultV2(data)
+}
+
+func NewActionExternal(name, address string, capability ActionCapability, callType ExternalActionCalltype, mimetype string, options *ExternalActionOptions, server *Server, ad *ActionDispatcher) Action {
+	// the defaults must not change the options of the caller, which may be shared
+	var opts ExternalActionOptions
+	if options != nil {
+		opts = *options
+	}
+	options = &opts
+	if options.RetryDelay <= 0 {
+		options.RetryDelay = time.Second
+	}
+	// a hanging service must not block the indexer forever
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultExternalTimeout
+	}
+	if callType == EACTSTREAM || callType == EACTCOMMAND {
+		capability |= ACTSTREAM
+	}
 	ae := &ActionExternal{
 		name:       name,
 		url:        address,
 		capability: capability,
 		callType:   callType,
 		mimetype:   regexp.MustCompile(mimetype),
+		options:    options,
+		client:     &http.Client{Timeout: options.Timeout},
 		server:     server,
 	}
 	ad.RegisterAction(ae)
@@ -106,6 +228,170 @@ func (as *ActionExternal) GetName() string {
 	return as.name
 }
 
+// request sends the body to the external service. without body, a GET request with [[PATH]] replacement is sent.
+// network errors, 429 and 5xx are retried, if the body can be recreated
+func (as *ActionExternal) request(body func() (io.Reader, error), contentType, filename string, retry bool) ([]byte, error) {
+	var retries = 0
+	if retry {
+		retries = as.options.Retries
+	}
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * as.options.RetryDelay)
+		}
+		var req *http.Request
+		var err error
+		if body == nil {
+			urlstring := strings.Replace(as.url, "[[PATH]]", strings.Replace(url.PathEscape(filepath.ToSlash(filename)), "+", "%20", -1), -1)
+			req, err = http.NewRequest(http.MethodGet, urlstring, nil)
+		} else {
+			var reader io.Reader
+			if reader, err = body(); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			req, err = http.NewRequest(http.MethodPost, as.url, reader)
+			if err == nil {
+				if contentType == "" {
+					contentType = "application/octet-stream"
+				}
+				req.Header.Set("Content-Type", contentType)
+				if as.callType == EACTSTREAM {
+					req.Header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filepath.Base(filename)))
+				}
+			}
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot create request for %s", as.name)
+		}
+		req.Header.Set("Accept", "application/json")
+		for key, val := range as.options.Headers {
+			req.Header.Set(key, val)
+		}
+		if as.options.BearerToken != "" {
+			req.Header.Set("Authorization", "Bearer "+as.options.BearerToken)
+		}
+		resp, err := as.client.Do(req)
+		if err != nil {
+			lastErr = errors.Wrapf(err, "cannot query %v - %v", as.name, req.URL.String())
+			continue
+		}
+		bodyBytes, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = errors.Wrapf(err, "error reading body")
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			lastErr = errors.New(fmt.Sprintf("status not ok - %v: %s", resp.Status, string(bodyBytes)))
+			if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+				continue
+			}
+			return nil, lastErr
+		}
+		return bodyBytes, nil
+	}
+	return nil, lastErr
+}
+
+// externalPath resolves a dot separated path in a json structure
+func externalPath(data any, path string) (any, bool) {
+	if path == "" || path == "." {
+		return data, true
+	}
+	for _, part := range strings.Split(path, ".") {
+		switch val := data.(type) {
+		case map[string]any:
+			var ok bool
+			if data, ok = val[part]; !ok {
+				return nil, false
+			}
+		case []any:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(val) {
+				return nil, false
+			}
+			data = val[idx]
+		default:
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+func externalStrings(data any) []string {
+	switch val := data.(type) {
+	case string:
+		if val == "" {
+			return nil
+		}
+		return []string{val}
+	case []any:
+		var result []string
+		for _, v := range val {
+			result = append(result, externalStrings(v)...)
+		}
+		return result
+	}
+	return nil
+}
+
+func externalUint(data any) uint {
+	switch val := data.(type) {
+	case float64:
+		if val > 0 {
+			return uint(val)
+		}
+	case string:
+		i, _ := strconv.ParseUint(val, 10, 64)
+		return uint(i)
+	}
+	return 0
+}
+
+// resultV2 maps the json reply to the result
+func (as *ActionExternal) resultV2(data []byte) (*ResultV2, error) {
+	var reply any
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return nil, errors.Wrapf(err, "error decoding json - %v", string(data))
+	}
+	var result = NewResultV2()
+	mapping := as.options.Response
+	if mapping == nil {
+		result.Metadata[as.name] = reply
+		return result, nil
+	}
+	if val, ok := externalPath(reply, mapping.Metadata); ok {
+		result.Metadata[as.name] = val
+	}
+	if val, ok := externalPath(reply, mapping.Mimetypes); ok && mapping.Mimetypes != "" {
+		result.Mimetypes = externalStrings(val)
+	}
+	if val, ok := externalPath(reply, mapping.Pronoms); ok && mapping.Pronoms != "" {
+		result.Pronoms = externalStrings(val)
+	}
+	if val, ok := externalPath(reply, mapping.Type); ok && mapping.Type != "" {
+		if str := externalStrings(val); len(str) > 0 {
+			result.Type = str[0]
+		}
+	}
+	if val, ok := externalPath(reply, mapping.Subtype); ok && mapping.Subtype != "" {
+		if str := externalStrings(val); len(str) > 0 {
+			result.Subtype = str[0]
+		}
+	}
+	if val, ok := externalPath(reply, mapping.Width); ok && mapping.Width != "" {
+		result.Width = externalUint(val)
+	}
+	if val, ok := externalPath(reply, mapping.Height); ok && mapping.Height != "" {
+		result.Height = externalUint(val)
+	}
+	if val, ok := externalPath(reply, mapping.Duration); ok && mapping.Duration != "" {
+		result.Duration = externalUint(val)
+	}
+	return result, nil
+}
+
 func (as *ActionExternal) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
 	switch uri.Scheme {
 	case "file":
@@ -126,39 +412,87 @@ func (as *ActionExternal) Do(uri *url.URL, contentType string, width *uint, heig
 		return nil, nil, nil, ErrMimeNotApplicable
 	}
 
-	var resp *http.Response
-	if as.callType == EACTURL {
-		filename, err := as.server.fm.Get(uri)
-		if err != nil {
+	var filename string
+	if uri.Scheme == "file" {
+		var err error
+		if filename, err = as.server.fm.Get(uri); err != nil {
 			return nil, nil, nil, errors.Wrapf(err, "no file url")
 		}
-		urlstring := strings.Replace(as.url, "[[PATH]]", strings.Replace(url.PathEscape(filepath.ToSlash(filename)), "+", "%20", -1), -1)
-
-		resp, err = http.Get(urlstring)
+	}
+	var body func() (io.Reader, error)
+	var bodyType string
+	var data []byte
+	var err error
+	switch as.callType {
+	case EACTCOMMAND:
+		if filename == "" {
+			return nil, nil, nil, errors.Errorf("no file url")
+		}
+		if data, err = as.runCommand(nil, filename, &ExternalRequest{
+			URL:       uri.String(),
+			Filename:  filename,
+			Mimetype:  contentType,
+			Checksums: checksums,
+		}); err != nil {
+			return nil, nil, nil, errors.WithStack(err)
+		}
+	case EACTURL:
+		if filename == "" {
+			return nil, nil, nil, errors.Errorf("no file url")
+		}
+	case EACTJSONPOST:
+		data, err := json.Marshal(&ExternalRequest{
+			URL:       uri.String(),
+			Filename:  filename,
+			Mimetype:  contentType,
+			Checksums: checksums,
+		})
 		if err != nil {
-			return nil, nil, nil, errors.Wrapf(err, "cannot query %v - %v", as.name, urlstring)
+			return nil, nil, nil, errors.Wrap(err, "cannot marshal request")
 		}
-	} else if as.callType == EACTJSONPOST {
-		return nil, nil, nil, fmt.Errorf("JSONPOST CallType not implemented")
-	} else {
+		bodyType = "application/json"
+		body = func() (io.Reader, error) { return bytes.NewReader(data), nil }
+	case EACTSTREAM:
+		if filename == "" {
+			return nil, nil, nil, errors.Errorf("streaming of %s not supported", uri.String())
+		}
+		bodyType = contentType
+		var fp *os.File
+		defer func() {
+			if fp != nil {
+				fp.Close()
+			}
+		}()
+		body = func() (io.Reader, error) {
+			if fp != nil {
+				fp.Close()
+			}
+			var err error
+			fp, err = os.Open(filename)
+			return fp, errors.Wrapf(err, "cannot open '%s'", filename)
+		}
+	default:
 		return nil, nil, nil, fmt.Errorf("unknown calltype")
 	}
-	defer resp.Body.Close()
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	var result *ResultV2
+	if as.callType == EACTCOMMAND {
+		result, err = as.resultFragment(data)
+	} else {
+		if data, err = as.request(body, bodyType, filename, true); err != nil {
+			return nil, nil, nil, errors.WithStack(err)
+		}
+		result, err = as.resultV2(data)
+	}
 	if err != nil {
-		return nil, nil, nil, errors.Wrapf(err, "error reading body")
+		return nil, nil, nil, errors.WithStack(err)
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, nil, nil, errors.New(fmt.Sprintf("status not ok - %v: %s", resp.Status, string(bodyBytes)))
+	if result.Width > *width {
+		*width = result.Width
 	}
-
-	var result interface{}
-	err = json.Unmarshal(bodyBytes, &result)
-	if err != nil {
-		return nil, nil, nil, errors.Wrapf(err, "error decoding json - %v", string(bodyBytes))
+	if result.Height > *height {
+		*height = result.Height
 	}
-	return result, nil, nil, nil
+	return result.Metadata[as.name], result.Mimetypes, result.Pronoms, nil
 }
 
 var (
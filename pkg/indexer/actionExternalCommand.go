@@ -0,0 +1,105 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indexer
+
+import (
+	"bytes"
+	"context"
+	"emperror.dev/errors"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// ExternalRequestEnv is the environment variable with the json request for EACTCOMMAND plugins
+const ExternalRequestEnv = "INDEXER_REQUEST"
+
+// runCommand executes the plugin of an EACTCOMMAND action. the address is the executable, the arguments
+// may contain [[PATH]] and [[REQUEST]] placeholders. without [[PATH]], the data is sent to stdin.
+// stdout must contain a json ResultV2 fragment or json, which is mapped with the response mapping
+func (as *ActionExternal) runCommand(reader io.Reader, filename string, req *ExternalRequest) ([]byte, error) {
+	usePath := slices.ContainsFunc(as.options.Args, func(arg string) bool { return strings.Contains(arg, "[[PATH]]") })
+	if usePath && filename == "" {
+		fp, err := spoolTempFile(reader, as.options.TempDir)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot spool data")
+		}
+		defer func() {
+			fp.Close()
+			os.Remove(fp.Name())
+		}()
+		filename = fp.Name()
+	}
+	if !usePath && reader == nil {
+		fp, err := os.Open(filename)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot open '%s'", filename)
+		}
+		defer fp.Close()
+		reader = fp
+	}
+	reqData, err := json.Marshal(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal request")
+	}
+
+	var args []string
+	for _, arg := range as.options.Args {
+		arg = strings.ReplaceAll(arg, "[[PATH]]", filename)
+		arg = strings.ReplaceAll(arg, "[[REQUEST]]", string(reqData))
+		args = append(args, arg)
+	}
+	ctx := context.Background()
+	if as.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, as.options.Timeout)
+		defer cancel()
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, as.url, args...)
+	cmd.Env = append(os.Environ(), ExternalRequestEnv+"="+string(reqData))
+	if !usePath {
+		cmd.Stdin = reader
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "error executing (%s %v) for file '%s': %s", as.url, args, filename, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
+
+// resultFragment decodes a ResultV2 fragment. the metadata of the fragment is stored with the action name
+func (as *ActionExternal) resultFragment(data []byte) (*ResultV2, error) {
+	if as.options.Response != nil {
+		return as.resultV2(data)
+	}
+	var fragment struct {
+		ResultV2
+		Metadata any `json:"metadata"`
+	}
+	fragment.ResultV2 = *NewResultV2()
+	if err := json.Unmarshal(data, &fragment); err != nil {
+		return nil, errors.Wrapf(err, "error decoding json - %v", string(data))
+	}
+	var result = &fragment.ResultV2
+	result.Metadata = map[string]any{}
+	if fragment.Metadata != nil {
+		result.Metadata[as.name] = fragment.Metadata
+	}
+	return result, nil
+}
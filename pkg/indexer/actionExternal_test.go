@@ -0,0 +1,185 @@
+package indexer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestActionExternal(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("X-Client") != "indexer" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var reply = map[string]any{
+			"format": map[string]any{"mimetypes": []string{"video/mp4"}, "pronom": "fmt/199"},
+			"video":  map[string]any{"width": 1920, "height": "1080"},
+		}
+		switch r.URL.Path {
+		case "/json":
+			var req ExternalRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			reply["report"] = req
+		case "/stream":
+			// first call fails
+			if calls.Add(1) == 1 {
+				http.Error(w, "busy", http.StatusServiceUnavailable)
+				return
+			}
+			data, _ := io.ReadAll(r.Body)
+			reply["report"] = map[string]any{"size": len(data), "type": r.Header.Get("Content-Type")}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(reply)
+	}))
+	defer srv.Close()
+
+	options := &ExternalActionOptions{
+		Headers:     map[string]string{"X-Client": "indexer"},
+		BearerToken: "secret",
+		Timeout:     5 * time.Second,
+		Retries:     1,
+		RetryDelay:  time.Millisecond,
+		Response: &ExternalResponseMapping{
+			Mimetypes: "format.mimetypes",
+			Pronoms:   "format.pronom",
+			Width:     "video.width",
+			Height:    "video.height",
+			Metadata:  "report",
+		},
+	}
+	filename := filepath.Join(t.TempDir(), "test.mp4")
+	if err := os.WriteFile(filename, []byte("0123456789"), 0644); err != nil {
+		t.Fatalf("cannot write file: %v", err)
+	}
+	ad := NewActionDispatcher(nil)
+
+	ae := NewActionExternal("json", srv.URL+"/json", ACTFILE, EACTJSONPOST, "^video/", options, nil, ad)
+	result, err := ae.DoV2(filename)
+	if err != nil {
+		t.Fatalf("cannot post json: %v", err)
+	}
+	if len(result.Mimetypes) != 1 || result.Mimetypes[0] != "video/mp4" || result.Pronoms[0] != "fmt/199" ||
+		result.Width != 1920 || result.Height != 1080 {
+		t.Errorf("wrong mapping: %+v", result)
+	}
+	if report := result.Metadata["json"].(map[string]any); report["filename"] != filename {
+		t.Errorf("wrong request: %v", report)
+	}
+
+	// streaming retries only files, not streams
+	ae = NewActionExternal("stream", srv.URL+"/stream", ACTFILE, EACTSTREAM, "^video/", options, nil, ad)
+	if ae.GetCaps()&ACTSTREAM == 0 {
+		t.Errorf("stream action without ACTSTREAM")
+	}
+	result, err = ae.DoV2(filename)
+	if err != nil {
+		t.Fatalf("cannot stream file: %v", err)
+	}
+	if report := result.Metadata["stream"].(map[string]any); report["size"] != float64(10) {
+		t.Errorf("wrong upload: %v", report)
+	}
+	calls.Store(0)
+	if _, err := ae.Stream("video/mp4", bytes.NewReader([]byte("0123")), "test.mp4"); err == nil {
+		t.Errorf("stream has been retried")
+	}
+	result, err = ae.Stream("video/mp4", bytes.NewReader([]byte("0123")), "test.mp4")
+	if err != nil {
+		t.Fatalf("cannot stream: %v", err)
+	}
+	if report := result.Metadata["stream"].(map[string]any); report["size"] != float64(4) || report["type"] != "video/mp4" {
+		t.Errorf("wrong upload: %v", report)
+	}
+
+	ae = NewActionExternal("noauth", srv.URL+"/json", ACTFILE, EACTJSONPOST, "", nil, nil, ad)
+	if _, err := ae.DoV2(filename); err == nil {
+		t.Errorf("request without token succeeded")
+	}
+}
+
+// fakePlugin reports the size of stdin or of the file argument and echoes the request
+const fakePlugin = `#!/bin/sh
+if [ -n "$1" ]; then
+	size=$(wc -c < "$1")
+else
+	size=$(wc -c)
+fi
+printf '{"mimetypes": ["application/x-test"], "pronoms": ["x-fmt/1"], "width": 10, "metadata": {"size": %d, "request": %s}}' $size "$INDEXER_REQUEST"
+`
+
+func TestActionExternalCommand(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no shell available")
+	}
+	plugin := filepath.Join(t.TempDir(), "plugin")
+	if err := os.WriteFile(plugin, []byte(fakePlugin), 0755); err != nil {
+		t.Fatalf("cannot write plugin: %v", err)
+	}
+	filename := filepath.Join(t.TempDir(), "test.bin")
+	if err := os.WriteFile(filename, []byte("0123456789"), 0644); err != nil {
+		t.Fatalf("cannot write file: %v", err)
+	}
+	ad := NewActionDispatcher(nil)
+
+	// stdin
+	ae := NewActionExternal("plugin", plugin, ACTFILE, EACTCOMMAND, "", &ExternalActionOptions{Timeout: 5 * time.Second}, nil, ad)
+	if ae.GetCaps()&ACTSTREAM == 0 {
+		t.Errorf("command action without ACTSTREAM")
+	}
+	result, err := ae.Stream("application/octet-stream", bytes.NewReader([]byte("0123")), "test.bin")
+	if err != nil {
+		t.Fatalf("cannot stream: %v", err)
+	}
+	meta := result.Metadata["plugin"].(map[string]any)
+	if meta["size"] != float64(4) || meta["request"].(map[string]any)["mimetype"] != "application/octet-stream" {
+		t.Errorf("wrong metadata: %v", meta)
+	}
+	if result.Mimetypes[0] != "application/x-test" || result.Pronoms[0] != "x-fmt/1" || result.Width != 10 {
+		t.Errorf("wrong fragment: %+v", result)
+	}
+
+	// spooled path
+	ae = NewActionExternal("plugin", plugin, ACTFILE, EACTCOMMAND, "", &ExternalActionOptions{Args: []string{"[[PATH]]"}, TempDir: t.TempDir()}, nil, ad)
+	result, err = ae.Stream("application/octet-stream", bytes.NewReader([]byte("012345")), "test.bin")
+	if err != nil {
+		t.Fatalf("cannot stream: %v", err)
+	}
+	if meta := result.Metadata["plugin"].(map[string]any); meta["size"] != float64(6) {
+		t.Errorf("wrong spooled size: %v", meta)
+	}
+	result, err = ae.DoV2(filename)
+	if err != nil {
+		t.Fatalf("cannot run plugin: %v", err)
+	}
+	meta = result.Metadata["plugin"].(map[string]any)
+	if meta["size"] != float64(10) || meta["request"].(map[string]any)["filename"] != filename {
+		t.Errorf("wrong metadata: %v", meta)
+	}
+}
+
+func TestActionExternalDefaultTimeout(t *testing.T) {
+	ae := NewActionExternal("service", "http://localhost/", ACTFILE, EACTSTREAM, "", nil, nil, NewActionDispatcher(nil)).(*ActionExternal)
+	if ae.client.Timeout != DefaultExternalTimeout || ae.options.Timeout != DefaultExternalTimeout {
+		t.Errorf("no default timeout: %v", ae.client.Timeout)
+	}
+
+	options := &ExternalActionOptions{Retries: 2}
+	ae = NewActionExternal("service", "http://localhost/", ACTFILE, EACTSTREAM, "", options, nil, NewActionDispatcher(nil)).(*ActionExternal)
+	if options.Timeout != 0 || options.RetryDelay != 0 || ae.options.Retries != 2 || ae.options.RetryDelay != time.Second {
+		t.Errorf("options of the caller changed: %+v", options)
+	}
+}
@@ -0,0 +1,336 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indexer
+
+import (
+	"bufio"
+	"context"
+	"emperror.dev/errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DecodePass    = "pass"
+	DecodeFail    = "fail"
+	DecodeTimeout = "timeout"
+)
+
+type DecodeError struct {
+	TimeMS  int64  `json:"timems"`
+	Stream  int    `json:"stream"` // -1 if unknown
+	Codec   string `json:"codec,omitempty"`
+	Message string `json:"message"`
+}
+
+type DecodeSegment struct {
+	StartMS    int64 `json:"startms"`
+	DurationMS int64 `json:"durationms,omitempty"`
+}
+
+// DecodeResult is the verdict of a full or sampled decode with ffmpeg
+type DecodeResult struct {
+	Status     string           `json:"status"`
+	Mode       string           `json:"mode"`
+	ErrorCount int              `json:"errorcount"`
+	Errors     []*DecodeError   `json:"errors,omitempty"`
+	Segments   []*DecodeSegment `json:"segments,omitempty"`
+	DecodedMS  int64            `json:"decodedms"`
+	Runtime    string           `json:"runtime"`
+}
+
+// ActionFFMPEGValidate decodes audio and video streams with ffmpeg to the null muxer.
+// files larger than sampleSize are only decoded at the start, in the middle and at the end
+type ActionFFMPEGValidate struct {
+	name           string
+	ffmpeg         string
+	ffprobe        string
+	wsl            bool
+	timeout        time.Duration
+	sampleSize     int64
+	sampleDuration time.Duration
+	maxErrors      int
+	tempDir        string
+	server         *Server
+}
+
+func (av *ActionFFMPEGValidate) CanHandle(contentType string, filename string) bool {
+	return (&ActionFFProbe{}).CanHandle(contentType, filename)
+}
+
+func NewActionFFMPEGValidate(name, ffmpeg, ffprobe string, wsl bool, timeout time.Duration, sampleSize int64, sampleDuration time.Duration, maxErrors int, tempDir string, server *Server, ad *ActionDispatcher) Action {
+	if sampleDuration <= 0 {
+		sampleDuration = 30 * time.Second
+	}
+	if maxErrors <= 0 {
+		maxErrors = 100
+	}
+	if timeout <= 0 {
+		timeout = time.Hour
+	}
+	av := &ActionFFMPEGValidate{
+		name:           name,
+		ffmpeg:         ffmpeg,
+		ffprobe:        ffprobe,
+		wsl:            wsl,
+		timeout:        timeout,
+		sampleSize:     sampleSize,
+		sampleDuration: sampleDuration,
+		maxErrors:      maxErrors,
+		tempDir:        tempDir,
+		server:         server,
+	}
+	ad.RegisterAction(av)
+	return av
+}
+
+func (av *ActionFFMPEGValidate) GetWeight() uint {
+	return 200
+}
+
+func (av *ActionFFMPEGValidate) GetCaps() ActionCapability {
+	return ACTFILEFULL | ACTSTREAM
+}
+
+func (av *ActionFFMPEGValidate) GetName() string {
+	return av.name
+}
+
+// [h264 @ 0x55d5c8a0e600] error while decoding MB 10 20
+// [vist#0:1/h264 @ 0x55d5c8a0e600] Error while decoding stream
+var regexpFFMPEGError = regexp.MustCompile(`^\[(?:[a-z]*ist#\d+:(\d+)/)?([A-Za-z0-9_\-]+) @ 0x[0-9a-f]+\] (.*)$`)
+
+func (av *ActionFFMPEGValidate) command(ctx context.Context, params []string) *exec.Cmd {
+	cmdfile := av.ffmpeg
+	if av.wsl {
+		params = append([]string{cmdfile}, params...)
+		cmdfile = "wsl"
+	}
+	return exec.CommandContext(ctx, cmdfile, params...)
+}
+
+// decode runs one ffmpeg decode. errors get the timestamp of the last progress report plus offset
+func (av *ActionFFMPEGValidate) decode(ctx context.Context, input string, stdin io.Reader, seek, duration time.Duration, result *DecodeResult) error {
+	var params = []string{"-hide_banner", "-nostats", "-v", "error", "-progress", "pipe:1"}
+	if seek > 0 {
+		params = append(params, "-ss", fmt.Sprintf("%.3f", seek.Seconds()))
+	}
+	if duration > 0 {
+		params = append(params, "-t", fmt.Sprintf("%.3f", duration.Seconds()))
+	}
+	params = append(params, "-i", input, "-map", "0:v?", "-map", "0:a?", "-f", "null", "-")
+	cmd := av.command(ctx, params)
+	cmd.Stdin = stdin
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return errors.Wrap(err, "cannot get stdout")
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return errors.Wrap(err, "cannot get stderr")
+	}
+	if err := cmd.Start(); err != nil {
+		return errors.Wrapf(err, "cannot start %s", av.ffmpeg)
+	}
+
+	var lock sync.Mutex
+	var currentMS int64
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		sc := bufio.NewScanner(stdout)
+		for sc.Scan() {
+			key, val, ok := strings.Cut(sc.Text(), "=")
+			// out_time_ms is in microseconds
+			if !ok || (key != "out_time_us" && key != "out_time_ms") {
+				continue
+			}
+			if us, err := strconv.ParseInt(val, 10, 64); err == nil && us >= 0 {
+				lock.Lock()
+				currentMS = us / 1000
+				lock.Unlock()
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		sc := bufio.NewScanner(stderr)
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" {
+				continue
+			}
+			lock.Lock()
+			de := &DecodeError{TimeMS: seek.Milliseconds() + currentMS, Stream: -1, Message: line}
+			lock.Unlock()
+			if matches := regexpFFMPEGError.FindStringSubmatch(line); matches != nil {
+				if matches[1] != "" {
+					de.Stream, _ = strconv.Atoi(matches[1])
+				}
+				de.Codec = matches[2]
+				de.Message = matches[3]
+			}
+			lock.Lock()
+			result.ErrorCount++
+			if len(result.Errors) < av.maxErrors {
+				result.Errors = append(result.Errors, de)
+			}
+			lock.Unlock()
+		}
+	}()
+	wg.Wait()
+	err = cmd.Wait()
+	result.DecodedMS += currentMS
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil && result.ErrorCount == 0 {
+		// ffmpeg failed without error message
+		result.ErrorCount++
+		result.Errors = append(result.Errors, &DecodeError{TimeMS: seek.Milliseconds() + currentMS, Stream: -1, Message: err.Error()})
+	}
+	return nil
+}
+
+// probeDuration returns the container duration using ffprobe
+func (av *ActionFFMPEGValidate) probeDuration(ctx context.Context, filename string) (time.Duration, error) {
+	params := []string{"-v", "quiet", "-show_entries", "format=duration", "-of", "csv=p=0", filename}
+	cmdfile := av.ffprobe
+	if av.wsl {
+		params = append([]string{cmdfile}, params...)
+		cmdfile = "wsl"
+	}
+	out, err := exec.CommandContext(ctx, cmdfile, params...).Output()
+	if err != nil {
+		return 0, errors.Wrapf(err, "cannot execute %s", av.ffprobe)
+	}
+	return time.Duration(secondsToMS(strings.TrimSpace(string(out)))) * time.Millisecond, nil
+}
+
+func (av *ActionFFMPEGValidate) validateFile(filename string, size int64) (*ResultV2, error) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), av.timeout)
+	defer cancel()
+	dresult := &DecodeResult{Mode: "full", Status: DecodePass}
+
+	var segments []*DecodeSegment
+	if av.sampleSize > 0 && size > av.sampleSize && av.ffprobe != "" {
+		duration, err := av.probeDuration(ctx, filename)
+		if err == nil && duration > 3*av.sampleDuration {
+			seg := av.sampleDuration.Milliseconds()
+			segments = []*DecodeSegment{
+				{StartMS: 0, DurationMS: seg},
+				{StartMS: (duration.Milliseconds() - seg) / 2, DurationMS: seg},
+				{StartMS: duration.Milliseconds() - seg, DurationMS: seg},
+			}
+			dresult.Mode = "sampled"
+		}
+	}
+	if len(segments) == 0 {
+		segments = []*DecodeSegment{{}}
+	}
+	var err error
+	for _, seg := range segments {
+		if err = av.decode(ctx, filename, nil,
+			time.Duration(seg.StartMS)*time.Millisecond,
+			time.Duration(seg.DurationMS)*time.Millisecond,
+			dresult); err != nil {
+			break
+		}
+	}
+	if dresult.Mode == "sampled" {
+		dresult.Segments = segments
+	}
+	return av.result(dresult, err, start)
+}
+
+func (av *ActionFFMPEGValidate) result(dresult *DecodeResult, err error, start time.Time) (*ResultV2, error) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		dresult.Status = DecodeTimeout
+	case err != nil:
+		return nil, errors.WithStack(err)
+	case dresult.ErrorCount > 0:
+		dresult.Status = DecodeFail
+	}
+	dresult.Runtime = time.Since(start).Round(time.Millisecond).String()
+	var result = NewResultV2()
+	result.Metadata[av.GetName()] = dresult
+	return result, nil
+}
+
+func (av *ActionFFMPEGValidate) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
+	if av.sampleSize <= 0 {
+		// full decode needs no random access
+		start := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), av.timeout)
+		defer cancel()
+		dresult := &DecodeResult{Mode: "full", Status: DecodePass}
+		err := av.decode(ctx, "pipe:0", reader, 0, 0, dresult)
+		return av.result(dresult, err, start)
+	}
+	fp, err := spoolTempFile(reader, av.tempDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot spool '%s'", filename)
+	}
+	defer func() {
+		fp.Close()
+		os.Remove(fp.Name())
+	}()
+	stat, err := fp.Stat()
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot stat '%s'", fp.Name())
+	}
+	name := fp.Name()
+	if av.wsl {
+		name = pathToWSL(name)
+	}
+	return av.validateFile(name, stat.Size())
+}
+
+func (av *ActionFFMPEGValidate) DoV2(filename string) (*ResultV2, error) {
+	stat, err := os.Stat(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot stat '%s'", filename)
+	}
+	if av.wsl {
+		filename = pathToWSL(filename)
+	}
+	return av.validateFile(filename, stat.Size())
+}
+
+func (av *ActionFFMPEGValidate) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
+	filename, err := av.server.fm.Get(uri)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "no file url")
+	}
+	result, err := av.DoV2(filename)
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+	return result.Metadata[av.GetName()], nil, nil, nil
+}
+
+var (
+	_ Action = &ActionFFMPEGValidate{}
+)
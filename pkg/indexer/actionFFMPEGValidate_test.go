@@ -0,0 +1,72 @@
+package indexer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeFFMPEG reports progress on stdout and decode errors on stderr like ffmpeg -progress pipe:1 -v error
+const fakeFFMPEG = `#!/bin/sh
+cat > /dev/null
+echo "out_time_us=1500000"
+echo "progress=continue"
+sleep 0.1
+echo "[h264 @ 0x55d5c8a0e600] error while decoding MB 10 20, bytestream -5" >&2
+sleep 0.1
+echo "out_time_us=4000000"
+sleep 0.1
+echo "[aist#0:1/aac @ 0x55d5c8a0e700] Error submitting packet to decoder: Invalid data found when processing input" >&2
+sleep 0.1
+echo "out_time_us=5000000"
+echo "progress=end"
+`
+
+func TestActionFFMPEGValidate(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no shell available")
+	}
+	ffmpeg := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(ffmpeg, []byte(fakeFFMPEG), 0755); err != nil {
+		t.Fatalf("cannot write fake ffmpeg: %v", err)
+	}
+	ad := NewActionDispatcher(nil)
+	av := NewActionFFMPEGValidate(NameFFMPEGValidate, ffmpeg, "", false, 10*time.Second, 0, 0, 1, t.TempDir(), nil, ad)
+	result, err := av.Stream("video/mp4", bytes.NewReader(make([]byte, 1024)), "test.mp4")
+	if err != nil {
+		t.Fatalf("cannot validate: %v", err)
+	}
+	dresult := result.Metadata[NameFFMPEGValidate].(*DecodeResult)
+	if dresult.Status != DecodeFail || dresult.Mode != "full" || dresult.ErrorCount != 2 || dresult.DecodedMS != 5000 {
+		t.Fatalf("wrong result: %+v", dresult)
+	}
+	// maxErrors limits the listed errors, not the count
+	if len(dresult.Errors) != 1 {
+		t.Fatalf("expected 1 listed error, got %d", len(dresult.Errors))
+	}
+	if de := dresult.Errors[0]; de.Codec != "h264" || de.Stream != -1 || de.TimeMS != 1500 || de.Message != "error while decoding MB 10 20, bytestream -5" {
+		t.Errorf("wrong error: %+v", de)
+	}
+
+	av = NewActionFFMPEGValidate(NameFFMPEGValidate, ffmpeg, "", false, 10*time.Second, 0, 0, 0, t.TempDir(), nil, ad)
+	result, err = av.Stream("video/mp4", bytes.NewReader(make([]byte, 1024)), "test.mp4")
+	if err != nil {
+		t.Fatalf("cannot validate: %v", err)
+	}
+	dresult = result.Metadata[NameFFMPEGValidate].(*DecodeResult)
+	if de := dresult.Errors[1]; de.Codec != "aac" || de.Stream != 1 || de.TimeMS != 4000 {
+		t.Errorf("wrong error: %+v", de)
+	}
+
+	// time budget
+	av = NewActionFFMPEGValidate(NameFFMPEGValidate, ffmpeg, "", false, 50*time.Millisecond, 0, 0, 0, t.TempDir(), nil, ad)
+	result, err = av.Stream("video/mp4", bytes.NewReader(make([]byte, 1024)), "test.mp4")
+	if err != nil {
+		t.Fatalf("cannot validate: %v", err)
+	}
+	if dresult := result.Metadata[NameFFMPEGValidate].(*DecodeResult); dresult.Status != DecodeTimeout {
+		t.Errorf("expected timeout: %+v", dresult)
+	}
+}
@@ -19,9 +19,9 @@ import (
 	"emperror.dev/errors"
 	"encoding/json"
 	"fmt"
-	ffmpeg_models "github.com/je4/goffmpeg/models"
 	"golang.org/x/exp/slices"
 	"io"
+	"math"
 	"net/url"
 	"os/exec"
 	"path/filepath"
@@ -126,7 +126,20 @@ func (as *ActionFFProbe) Stream(contentType string, reader io.Reader, filename s
 	if slices.Contains([]string{"image", "pdf"}, contentType) {
 		return nil, nil
 	}
-	cmdparam := []string{"-i", "-", "-v", "quiet", "-print_format", "json", "-show_format", "-show_streams", "-show_error"}
+	return as.probe("-", reader, filename)
+}
+
+func (as *ActionFFProbe) DoV2(filename string) (*ResultV2, error) {
+	input := filename
+	if as.wsl {
+		input = pathToWSL(filename)
+	}
+	return as.probe(input, nil, filename)
+}
+
+// probe runs ffprobe on input, which is a filename or "-" for reader
+func (as *ActionFFProbe) probe(input string, reader io.Reader, filename string) (*ResultV2, error) {
+	cmdparam := []string{"-i", input, "-v", "quiet", "-print_format", "json", "-show_format", "-show_streams", "-show_chapters", "-show_error"}
 	cmdfile := as.ffprobe
 	if as.wsl {
 		cmdparam = append([]string{cmdfile}, cmdparam...)
@@ -146,38 +159,32 @@ func (as *ActionFFProbe) Stream(contentType string, reader io.Reader, filename s
 		return nil, errors.Wrapf(err, "error executing (%s %s) for file '%s': %v", cmdfile, cmdparam, filename, out.String())
 	}
 
-	var metadata ffmpeg_models.Metadata
-	if err := json.Unmarshal([]byte(out.String()), &metadata); err != nil {
+	var probe ffprobeOutput
+	if err := json.Unmarshal(out.Bytes(), &probe); err != nil {
 		return nil, errors.Wrapf(err, "cannot unmarshall metadata: %s", out.String())
 	}
+	av := probe.normalize()
 
-	// calculate duration and dimension
 	var result = NewResultV2()
-	d, _ := strconv.ParseFloat(metadata.Format.Duration, 64)
-	result.Duration = uint(d) // time.Duration(d * float64(time.Second))
+	result.Duration = uint(math.Round(float64(av.DurationMS) / 1000))
 	var hasAudio, hasVideo bool
-	for _, stream := range metadata.Streams {
-		if stream.Width > 0 || stream.Height > 0 {
-			result.Width = uint(stream.Width)
-			result.Height = uint(stream.Height)
-		}
-		if stream.CodecType == "audio" {
+	if video := av.MainVideo(); video != nil {
+		hasVideo = true
+		result.Width = uint(video.Width)
+		result.Height = uint(video.Height)
+	}
+	for _, stream := range av.Streams {
+		if stream.Type == "audio" {
 			hasAudio = true
 		}
-		if stream.CodecType == "video" {
-			hasVideo = true
-		}
-		if stream.CodecType == "data" {
-			//hasData = true
-		}
 	}
 
 	for _, m := range as.mime {
-		if m.Audio == hasAudio && m.Video == hasVideo && m.Format == metadata.Format.FormatName {
+		if m.Audio == hasAudio && m.Video == hasVideo && m.Format == av.Format {
 			result.Mimetypes = append(result.Mimetypes, m.Mime)
 		}
 	}
-	result.Metadata[as.GetName()] = metadata
+	result.Metadata[as.GetName()] = av
 	if hasVideo {
 		result.Type = "video"
 	} else {
@@ -185,71 +192,14 @@ func (as *ActionFFProbe) Stream(contentType string, reader io.Reader, filename s
 			result.Type = "audio"
 		}
 	}
-	result.Subtype = metadata.Format.FormatName
+	result.Subtype = av.Format
 	return result, nil
 }
 
-func (as *ActionFFProbe) DoV2(filename string) (*ResultV2, error) {
-	cmdparam := []string{"-i", filename, "-v", "quiet", "-print_format", "json", "-show_format", "-show_streams", "-show_error"}
-	cmdfile := as.ffprobe
-	if as.wsl {
-		cmdparam = append([]string{cmdfile}, cmdparam...)
-		cmdfile = "wsl"
-	}
-
-	var out bytes.Buffer
-	out.Grow(1024 * 1024) // 1MB size
-	ctx, cancel := context.WithTimeout(context.Background(), as.timeout)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, cmdfile, cmdparam...)
-	cmd.Stdout = &out
-
-	if err := cmd.Run(); err != nil {
-		return nil, errors.Wrapf(err, "error executing (%s %s) for file '%s': %v", cmdfile, cmdparam, filename, out.String())
-	}
-
-	var metadata ffmpeg_models.Metadata
-	if err := json.Unmarshal([]byte(out.String()), &metadata); err != nil {
-		return nil, errors.Wrapf(err, "cannot unmarshall metadata: %s", out.String())
-	}
-
-	// calculate duration and dimension
-	var result = NewResultV2()
-	d, _ := strconv.ParseFloat(metadata.Format.Duration, 64)
-	result.Duration = uint(d) // time.Duration(d * float64(time.Second))
-	var hasAudio, hasVideo bool
-	for _, stream := range metadata.Streams {
-		if stream.Width > 0 || stream.Height > 0 {
-			result.Width = uint(stream.Width)
-			result.Height = uint(stream.Height)
-		}
-		if stream.CodecType == "audio" {
-			hasAudio = true
-		}
-		if stream.CodecType == "video" {
-			hasVideo = true
-		}
-		if stream.CodecType == "data" {
-			//hasData = true
-		}
-	}
-
-	for _, m := range as.mime {
-		if m.Audio == hasAudio && m.Video == hasVideo && m.Format == metadata.Format.FormatName {
-			result.Mimetypes = append(result.Mimetypes, m.Mime)
-		}
-	}
-	result.Metadata[as.GetName()] = metadata
-	return result, nil
-
-}
-
 func (as *ActionFFProbe) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
 	if !as.CanHandle(contentType, uri.String()) {
 		return nil, nil, nil, nil
 	}
-	var metadata ffmpeg_models.Metadata
 	var filename string
 	var err error
 
@@ -266,55 +216,17 @@ func (as *ActionFFProbe) Do(uri *url.URL, contentType string, width *uint, heigh
 		filename = uri.String()
 	}
 
-	cmdparam := []string{"-i", filename, "-v", "quiet", "-print_format", "json", "-show_format", "-show_streams", "-show_error"}
-	cmdfile := as.ffprobe
-	if as.wsl {
-		cmdparam = append([]string{cmdfile}, cmdparam...)
-		cmdfile = "wsl"
-	}
-
-	var out bytes.Buffer
-	ctx, cancel := context.WithTimeout(context.Background(), as.timeout)
-	defer cancel()
-	cmd := exec.CommandContext(ctx, cmdfile, cmdparam...)
-	cmd.Stdout = &out
-
-	err = cmd.Run()
+	result, err := as.probe(filename, nil, filename)
 	if err != nil {
-		return nil, nil, nil, errors.Wrapf(err, "error executing (%s %s): %v", cmdfile, cmdparam, out.String())
-	}
-
-	if err = json.Unmarshal([]byte(out.String()), &metadata); err != nil {
-		return nil, nil, nil, errors.Wrapf(err, "cannot unmarshall metadata: %s", out.String())
-	}
-
-	// calculate duration and dimension
-	d, _ := strconv.ParseFloat(metadata.Format.Duration, 64)
-	*duration = time.Duration(d * float64(time.Second))
-	var hasAudio, hasVideo bool
-	for _, stream := range metadata.Streams {
-		if stream.Width > 0 || stream.Height > 0 {
-			*width = uint(stream.Width)
-			*height = uint(stream.Height)
-		}
-		if stream.CodecType == "audio" {
-			hasAudio = true
-		}
-		if stream.CodecType == "video" {
-			hasVideo = true
-		}
-		if stream.CodecType == "data" {
-			//hasData = true
-		}
+		return nil, nil, nil, errors.WithStack(err)
 	}
-
-	mimetypes := []string{}
-	for _, m := range as.mime {
-		if m.Audio == hasAudio && m.Video == hasVideo && m.Format == metadata.Format.FormatName {
-			mimetypes = append(mimetypes, m.Mime)
-		}
+	av := result.Metadata[as.GetName()].(*AVMetadata)
+	*duration = time.Duration(av.DurationMS) * time.Millisecond
+	if result.Width > 0 || result.Height > 0 {
+		*width = result.Width
+		*height = result.Height
 	}
-	return metadata, mimetypes, nil, nil
+	return av, result.Mimetypes, nil, nil
 }
 
 var (
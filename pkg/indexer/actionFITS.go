@@ -0,0 +1,284 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indexer
+
+import (
+	"bufio"
+	"emperror.dev/errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const fitsBlockSize = 2880
+
+// maxFITSHDUs limits the number of header data units, which are reported
+const maxFITSHDUs = 100
+
+type FITSHDU struct {
+	Type   string            `json:"type"` // primary or the XTENSION value, i.e. IMAGE, BINTABLE
+	BitPix int               `json:"bitpix"`
+	Axes   []int             `json:"axes,omitempty"`
+	Cards  map[string]string `json:"cards"`
+}
+
+type FITSResult struct {
+	HDUs  []*FITSHDU `json:"hdus"`
+	Error string     `json:"error,omitempty"`
+}
+
+type ActionFITS struct {
+	name   string
+	server *Server
+}
+
+func (af *ActionFITS) CanHandle(contentType string, filename string) bool {
+	if slices.Contains([]string{"image/fits", "application/fits"}, contentType) {
+		return true
+	}
+	return slices.Contains([]string{".fits", ".fit", ".fts"}, strings.ToLower(filepath.Ext(filename)))
+}
+
+func NewActionFITS(name string, server *Server, ad *ActionDispatcher) Action {
+	af := &ActionFITS{name: name, server: server}
+	ad.RegisterAction(af)
+	return af
+}
+
+func (af *ActionFITS) GetWeight() uint {
+	return 50
+}
+
+func (af *ActionFITS) GetCaps() ActionCapability {
+	return ACTFILEHEAD | ACTSTREAM
+}
+
+func (af *ActionFITS) GetName() string {
+	return af.name
+}
+
+// fitsValue decodes the value of a header card. strings are unquoted, comments removed
+func fitsValue(card string) (string, bool) {
+	if len(card) < 10 || card[8:10] != "= " {
+		return "", false
+	}
+	value := strings.TrimSpace(card[10:])
+	if strings.HasPrefix(value, "'") {
+		var sb strings.Builder
+		for i := 1; i < len(value); i++ {
+			if value[i] == '\'' {
+				if i+1 < len(value) && value[i+1] == '\'' {
+					sb.WriteByte('\'')
+					i++
+					continue
+				}
+				break
+			}
+			sb.WriteByte(value[i])
+		}
+		return strings.TrimRight(sb.String(), " "), true
+	}
+	if i := strings.Index(value, "/"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value), true
+}
+
+// readHeader reads the cards of a header until END
+func (af *ActionFITS) readHeader(r io.Reader) (*FITSHDU, error) {
+	hdu := &FITSHDU{Cards: map[string]string{}}
+	block := make([]byte, fitsBlockSize)
+	for {
+		if _, err := io.ReadFull(r, block); err != nil {
+			return nil, err
+		}
+		for i := 0; i < fitsBlockSize; i += 80 {
+			card := string(block[i : i+80])
+			keyword := strings.TrimSpace(card[:8])
+			if keyword == "END" {
+				return hdu, nil
+			}
+			// commentary cards are not reported
+			if keyword == "" || keyword == "COMMENT" || keyword == "HISTORY" {
+				continue
+			}
+			if value, ok := fitsValue(card); ok {
+				hdu.Cards[keyword] = value
+			}
+		}
+	}
+}
+
+// dataSize is the size of the data following the header, padded to blocks
+func (hdu *FITSHDU) dataSize() (int64, error) {
+	if len(hdu.Axes) == 0 {
+		return 0, nil
+	}
+	pcount, gcount := int64(0), int64(1)
+	if v, err := strconv.ParseInt(hdu.Cards["PCOUNT"], 10, 64); err == nil {
+		pcount = v
+	}
+	if v, err := strconv.ParseInt(hdu.Cards["GCOUNT"], 10, 64); err == nil {
+		gcount = v
+	}
+	size := int64(1)
+	for i, axis := range hdu.Axes {
+		// random groups have NAXIS1 = 0
+		if i == 0 && axis == 0 && hdu.Cards["GROUPS"] == "T" {
+			continue
+		}
+		size *= int64(axis)
+	}
+	bytesPerValue := int64(hdu.BitPix) / 8
+	if bytesPerValue < 0 {
+		bytesPerValue = -bytesPerValue
+	}
+	size = bytesPerValue * gcount * (pcount + size)
+	if size < 0 {
+		return 0, errors.Errorf("invalid data size %d", size)
+	}
+	return (size + fitsBlockSize - 1) / fitsBlockSize * fitsBlockSize, nil
+}
+
+var fitsOldDateRegexp = regexp.MustCompile(`^(\d{2})/(\d{2})/(\d{2})$`)
+
+// fitsDate converts DATE-OBS to YYYY-MM-DD. the old format is DD/MM/YY of the 20th century
+func fitsDate(date string) string {
+	if found := fitsOldDateRegexp.FindStringSubmatch(date); found != nil {
+		return fmt.Sprintf("19%s-%s-%s", found[3], found[2], found[1])
+	}
+	if len(date) >= 10 && date[4] == '-' && date[7] == '-' {
+		return date[:10]
+	}
+	return date
+}
+
+func (af *ActionFITS) analyze(reader io.Reader) (*ResultV2, error) {
+	br := bufio.NewReaderSize(reader, fitsBlockSize)
+	head, _ := br.Peek(30)
+	if len(head) < 30 || string(head[:9]) != "SIMPLE  =" || strings.TrimSpace(string(head[10:30])) != "T" {
+		return nil, nil
+	}
+	fresult := &FITSResult{}
+	var result = NewResultV2()
+	result.Mimetype = "image/fits"
+	result.Mimetypes = []string{result.Mimetype}
+	result.Metadata[af.GetName()] = fresult
+
+	for len(fresult.HDUs) < maxFITSHDUs {
+		if _, err := br.Peek(1); err == io.EOF {
+			break
+		}
+		hdu, err := af.readHeader(br)
+		if err != nil {
+			// padding at the end of the file is allowed
+			if err != io.EOF && err != io.ErrUnexpectedEOF || len(fresult.HDUs) == 0 {
+				fresult.Error = fmt.Sprintf("cannot read header: %v", err)
+			}
+			break
+		}
+		hdu.Type = hdu.Cards["XTENSION"]
+		if len(fresult.HDUs) == 0 {
+			hdu.Type = "primary"
+		}
+		if hdu.Type == "" {
+			// no extension but trailing data
+			break
+		}
+		hdu.BitPix, _ = strconv.Atoi(hdu.Cards["BITPIX"])
+		naxis, _ := strconv.Atoi(hdu.Cards["NAXIS"])
+		for i := 1; i <= naxis && i <= 999; i++ {
+			axis, _ := strconv.Atoi(hdu.Cards[fmt.Sprintf("NAXIS%d", i)])
+			hdu.Axes = append(hdu.Axes, axis)
+		}
+		fresult.HDUs = append(fresult.HDUs, hdu)
+		size, err := hdu.dataSize()
+		if err != nil {
+			fresult.Error = err.Error()
+			break
+		}
+		if _, err := io.CopyN(io.Discard, br, size); err != nil {
+			fresult.Error = fmt.Sprintf("truncated data of hdu %d", len(fresult.HDUs)-1)
+			break
+		}
+	}
+
+	// the first image defines the normalized values
+	for _, hdu := range fresult.HDUs {
+		if hdu.Type != "primary" && hdu.Type != "IMAGE" || len(hdu.Axes) < 2 {
+			continue
+		}
+		result.Width = uint(hdu.Axes[0])
+		result.Height = uint(hdu.Axes[1])
+		result.BitDepth = uint(max(hdu.BitPix, -hdu.BitPix))
+		break
+	}
+	for _, hdu := range fresult.HDUs {
+		if result.Instrument == "" {
+			result.Instrument = hdu.Cards["INSTRUME"]
+			if result.Instrument == "" {
+				result.Instrument = hdu.Cards["TELESCOP"]
+			}
+		}
+		if result.Date == "" && hdu.Cards["DATE-OBS"] != "" {
+			result.Date = fitsDate(hdu.Cards["DATE-OBS"])
+		}
+	}
+	return result, nil
+}
+
+func (af *ActionFITS) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
+	return af.analyze(reader)
+}
+
+func (af *ActionFITS) DoV2(filename string) (*ResultV2, error) {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open file '%s'", filename)
+	}
+	defer fp.Close()
+	return af.analyze(fp)
+}
+
+func (af *ActionFITS) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
+	filename, err := af.server.fm.Get(uri)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "no file url")
+	}
+	result, err := af.DoV2(filename)
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+	if result == nil {
+		return nil, nil, nil, ErrMimeNotApplicable
+	}
+	if result.Width > *width {
+		*width = result.Width
+	}
+	if result.Height > *height {
+		*height = result.Height
+	}
+	return result.Metadata[af.GetName()], result.Mimetypes, nil, nil
+}
+
+var (
+	_ Action = (*ActionFITS)(nil)
+)
@@ -0,0 +1,78 @@
+package indexer
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildTestFITSHeader pads the cards to a header block
+func buildTestFITSHeader(cards ...string) []byte {
+	var buf bytes.Buffer
+	for _, card := range append(cards, "END") {
+		buf.WriteString(fmt.Sprintf("%-80s", card))
+	}
+	buf.WriteString(strings.Repeat(" ", (fitsBlockSize-buf.Len()%fitsBlockSize)%fitsBlockSize))
+	return buf.Bytes()
+}
+
+func TestActionFITS(t *testing.T) {
+	var data bytes.Buffer
+	data.Write(buildTestFITSHeader(
+		"SIMPLE  =                    T / conforms to FITS standard",
+		"BITPIX  =                  -32",
+		"NAXIS   =                    2",
+		"NAXIS1  =                   40",
+		"NAXIS2  =                   30",
+		"EXTEND  =                    T",
+		"TELESCOP= 'ESO-VLT-U1'",
+		"INSTRUME= 'FORS1   '           / instrument",
+		"OBJECT  = 'Barnard''s star'",
+		"DATE-OBS= '21/03/94'",
+		"COMMENT  some comment",
+	))
+	data.Write(make([]byte, 2*fitsBlockSize)) // 40*30*4 bytes
+	data.Write(buildTestFITSHeader(
+		"XTENSION= 'BINTABLE'",
+		"BITPIX  =                    8",
+		"NAXIS   =                    2",
+		"NAXIS1  =                   10",
+		"NAXIS2  =                    5",
+		"PCOUNT  =                    0",
+		"GCOUNT  =                    1",
+	))
+	data.Write(make([]byte, fitsBlockSize))
+
+	ad := NewActionDispatcher(nil)
+	af := NewActionFITS(NameFITS, nil, ad)
+	if !af.CanHandle("", "image.FITS") || af.CanHandle("", "image.png") {
+		t.Errorf("wrong CanHandle")
+	}
+	result, err := af.Stream("", bytes.NewReader(data.Bytes()), "test.fits")
+	if err != nil {
+		t.Fatalf("cannot analyze: %v", err)
+	}
+	fr := result.Metadata[NameFITS].(*FITSResult)
+	if fr.Error != "" || len(fr.HDUs) != 2 {
+		t.Fatalf("wrong result: %+v", fr)
+	}
+	if fr.HDUs[0].Type != "primary" || fr.HDUs[1].Type != "BINTABLE" || fr.HDUs[0].Cards["OBJECT"] != "Barnard's star" {
+		t.Errorf("wrong hdus: %+v, %+v", fr.HDUs[0], fr.HDUs[1])
+	}
+	if _, ok := fr.HDUs[0].Cards["COMMENT"]; ok {
+		t.Errorf("comment card reported")
+	}
+	if result.Width != 40 || result.Height != 30 || result.BitDepth != 32 || result.Instrument != "FORS1" || result.Date != "1994-03-21" {
+		t.Errorf("wrong normalized values: %dx%d %d %s %s", result.Width, result.Height, result.BitDepth, result.Instrument, result.Date)
+	}
+
+	// truncated data
+	result, err = af.Stream("", bytes.NewReader(data.Bytes()[:fitsBlockSize+100]), "test.fits")
+	if err != nil {
+		t.Fatalf("cannot analyze: %v", err)
+	}
+	if fr := result.Metadata[NameFITS].(*FITSResult); fr.Error == "" || len(fr.HDUs) != 1 {
+		t.Errorf("truncation not detected: %+v", fr)
+	}
+}
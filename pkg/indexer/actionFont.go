@@ -0,0 +1,676 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indexer
+
+import (
+	"bytes"
+	"compress/zlib"
+	"emperror.dev/errors"
+	"encoding/binary"
+	"fmt"
+	"github.com/andybalholm/brotli"
+	"golang.org/x/image/font/sfnt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxFontSize limits the size of fonts, which are loaded into memory
+const maxFontSize = 64 * 1024 * 1024
+
+type FontInfo struct {
+	Family         string    `json:"family,omitempty"`
+	Subfamily      string    `json:"subfamily,omitempty"`
+	FullName       string    `json:"fullname,omitempty"`
+	PostScriptName string    `json:"postscriptname,omitempty"`
+	Version        string    `json:"version,omitempty"`
+	FontRevision   string    `json:"fontrevision,omitempty"`
+	Copyright      string    `json:"copyright,omitempty"`
+	Trademark      string    `json:"trademark,omitempty"`
+	Manufacturer   string    `json:"manufacturer,omitempty"`
+	Designer       string    `json:"designer,omitempty"`
+	DesignerURL    string    `json:"designerurl,omitempty"`
+	Vendor         string    `json:"vendor,omitempty"` // OS/2 achVendID
+	VendorURL      string    `json:"vendorurl,omitempty"`
+	License        string    `json:"license,omitempty"`
+	LicenseURL     string    `json:"licenseurl,omitempty"`
+	FsType         uint16    `json:"fstype"`
+	Embedding      string    `json:"embedding"` // installable, restricted, preview&print, editable
+	EmbeddingFlags []string  `json:"embeddingflags,omitempty"`
+	Outlines       string    `json:"outlines"` // truetype or cff
+	Weight         uint16    `json:"weight,omitempty"`
+	Width          uint16    `json:"width,omitempty"`
+	Italic         bool      `json:"italic,omitempty"`
+	Bold           bool      `json:"bold,omitempty"`
+	FixedPitch     bool      `json:"fixedpitch,omitempty"`
+	Glyphs         int       `json:"glyphs"`
+	UnitsPerEm     int       `json:"unitsperem,omitempty"`
+	Created        time.Time `json:"created,omitempty"`
+	Modified       time.Time `json:"modified,omitempty"`
+	UnicodeRanges  []string  `json:"unicoderanges,omitempty"`
+}
+
+type FontResult struct {
+	Container string      `json:"container"` // truetype, opentype, collection, woff or woff2
+	Flavor    string      `json:"flavor,omitempty"`
+	Fonts     []*FontInfo `json:"fonts,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+type ActionFont struct {
+	name   string
+	server *Server
+}
+
+func (af *ActionFont) CanHandle(contentType string, filename string) bool {
+	if strings.HasPrefix(contentType, "font/") || slices.Contains([]string{"application/font-woff", "application/vnd.ms-opentype", "application/x-font-ttf"}, contentType) {
+		return true
+	}
+	return slices.Contains([]string{".ttf", ".otf", ".ttc", ".otc", ".woff", ".woff2"}, strings.ToLower(filepath.Ext(filename)))
+}
+
+func NewActionFont(name string, server *Server, ad *ActionDispatcher) Action {
+	af := &ActionFont{name: name, server: server}
+	ad.RegisterAction(af)
+	return af
+}
+
+func (af *ActionFont) GetWeight() uint {
+	return 50
+}
+
+func (af *ActionFont) GetCaps() ActionCapability {
+	return ACTFILEFULL | ACTSTREAM
+}
+
+func (af *ActionFont) GetName() string {
+	return af.name
+}
+
+// fontContainer returns the container type and the mimetype for the signature
+func fontContainer(head []byte) (string, string) {
+	if len(head) < 4 {
+		return "", ""
+	}
+	switch string(head[:4]) {
+	case "\x00\x01\x00\x00", "true":
+		return "truetype", "font/ttf"
+	case "OTTO":
+		return "opentype", "font/otf"
+	case "ttcf":
+		return "collection", "font/collection"
+	case "wOFF":
+		return "woff", "font/woff"
+	case "wOF2":
+		return "woff2", "font/woff2"
+	}
+	return "", ""
+}
+
+// fontTables reads the table directory of a sfnt font at offset. offsets of tables are absolute
+func fontTables(data []byte, offset int) (map[string][]byte, error) {
+	if offset+12 > len(data) {
+		return nil, errors.New("truncated table directory")
+	}
+	num := int(binary.BigEndian.Uint16(data[offset+4:]))
+	tables := map[string][]byte{}
+	for i := 0; i < num; i++ {
+		rec := offset + 12 + i*16
+		if rec+16 > len(data) {
+			return nil, errors.New("truncated table directory")
+		}
+		start := int(binary.BigEndian.Uint32(data[rec+8:]))
+		length := int(binary.BigEndian.Uint32(data[rec+12:]))
+		if start+length > len(data) || start+length < start {
+			return nil, errors.Errorf("table '%s' outside of file", string(data[rec:rec+4]))
+		}
+		tables[string(data[rec:rec+4])] = data[start : start+length]
+	}
+	return tables, nil
+}
+
+type fontTable struct {
+	tag  []byte
+	data []byte
+}
+
+// buildSFNT writes the table directory of every font followed by the table data.
+// fonts contains the table indices of each font, more than one font results in a collection.
+// returns the offsets of the table directories
+func buildSFNT(flavors [][]byte, fonts [][]int, tables []fontTable) ([]byte, []int) {
+	var offsets []int
+	offset := 0
+	if len(fonts) > 1 {
+		offset = 12 + 4*len(fonts)
+	}
+	for _, font := range fonts {
+		offsets = append(offsets, offset)
+		offset += 12 + 16*len(font)
+	}
+	var tableOffsets []int
+	for _, t := range tables {
+		tableOffsets = append(tableOffsets, offset)
+		offset += (len(t.data) + 3) &^ 3
+	}
+	var buf = bytes.NewBuffer(make([]byte, 0, offset))
+	if len(fonts) > 1 {
+		buf.WriteString("ttcf")
+		_ = binary.Write(buf, binary.BigEndian, []uint32{0x00010000, uint32(len(fonts))})
+		for _, o := range offsets {
+			_ = binary.Write(buf, binary.BigEndian, uint32(o))
+		}
+	}
+	for i, font := range fonts {
+		num := len(font)
+		buf.Write(flavors[i])
+		var entrySelector uint16
+		for 1<<(entrySelector+1) <= num {
+			entrySelector++
+		}
+		searchRange := uint16(16 << entrySelector)
+		_ = binary.Write(buf, binary.BigEndian, []uint16{uint16(num), searchRange, entrySelector, uint16(num*16) - searchRange})
+		for _, idx := range font {
+			buf.Write(tables[idx].tag)
+			_ = binary.Write(buf, binary.BigEndian, []uint32{0, uint32(tableOffsets[idx]), uint32(len(tables[idx].data))})
+		}
+	}
+	for _, t := range tables {
+		buf.Write(t.data)
+		buf.Write(make([]byte, (4-len(t.data)%4)%4))
+	}
+	return buf.Bytes(), offsets
+}
+
+// woffToSFNT decompresses the tables of a woff 1.0 font into a sfnt font
+func woffToSFNT(data []byte) ([]byte, error) {
+	if len(data) < 44 {
+		return nil, errors.New("truncated woff header")
+	}
+	num := int(binary.BigEndian.Uint16(data[12:]))
+	var tables []fontTable
+	var font []int
+	var size int
+	for i := 0; i < num; i++ {
+		rec := 44 + i*20
+		if rec+20 > len(data) {
+			return nil, errors.New("truncated woff table directory")
+		}
+		offset := int(binary.BigEndian.Uint32(data[rec+4:]))
+		compLength := int(binary.BigEndian.Uint32(data[rec+8:]))
+		origLength := int(binary.BigEndian.Uint32(data[rec+12:]))
+		if offset+compLength > len(data) || offset+compLength < offset {
+			return nil, errors.Errorf("woff table '%s' outside of file", string(data[rec:rec+4]))
+		}
+		if size += origLength; size > maxFontSize {
+			return nil, errors.Errorf("decompressed woff font larger than %d bytes", maxFontSize)
+		}
+		tdata := data[offset : offset+compLength]
+		if compLength < origLength {
+			zr, err := zlib.NewReader(bytes.NewReader(tdata))
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot decompress woff table '%s'", string(data[rec:rec+4]))
+			}
+			tdata, err = io.ReadAll(io.LimitReader(zr, int64(origLength)))
+			zr.Close()
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot decompress woff table '%s'", string(data[rec:rec+4]))
+			}
+		}
+		font = append(font, len(tables))
+		tables = append(tables, fontTable{tag: data[rec : rec+4], data: tdata})
+	}
+	sfntData, _ := buildSFNT([][]byte{data[4:8]}, [][]int{font}, tables)
+	return sfntData, nil
+}
+
+// woff2KnownTags are the table tags of the woff2 table directory flags
+var woff2KnownTags = []string{
+	"cmap", "head", "hhea", "hmtx", "maxp", "name", "OS/2", "post", "cvt ", "fpgm", "glyf", "loca", "prep", "CFF ", "VORG", "EBDT",
+	"EBLC", "gasp", "hdmx", "kern", "LTSH", "PCLT", "VDMX", "vhea", "vmtx", "BASE", "GDEF", "GPOS", "GSUB", "EBSC", "JSTF", "MATH",
+	"CBDT", "CBLC", "COLR", "CPAL", "SVG ", "sbix", "acnt", "avar", "bdat", "bloc", "bsln", "cvar", "fdsc", "feat", "fmtx", "fvar",
+	"gvar", "hsty", "just", "lcar", "mort", "morx", "opbd", "prop", "trak", "Zapf", "Silf", "Glat", "Gloc", "Feat", "Sill",
+}
+
+// woff2Reader reads the variable length integers of the woff2 directories
+type woff2Reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *woff2Reader) bytes(n int) []byte {
+	if r.err != nil || n > len(r.data)-r.pos {
+		r.err = errors.New("truncated woff2 directory")
+		return make([]byte, n)
+	}
+	r.pos += n
+	return r.data[r.pos-n : r.pos]
+}
+
+func (r *woff2Reader) uint16() uint16 { return binary.BigEndian.Uint16(r.bytes(2)) }
+
+func (r *woff2Reader) uint32() uint32 { return binary.BigEndian.Uint32(r.bytes(4)) }
+
+func (r *woff2Reader) base128() uint32 {
+	var value uint32
+	for i := 0; i < 5; i++ {
+		b := r.bytes(1)[0]
+		if (i == 0 && b == 0x80) || value&0xfe000000 != 0 {
+			r.err = errors.New("invalid UIntBase128 value")
+			return 0
+		}
+		value = value<<7 | uint32(b&0x7f)
+		if b&0x80 == 0 {
+			return value
+		}
+	}
+	r.err = errors.New("invalid UIntBase128 value")
+	return 0
+}
+
+func (r *woff2Reader) uint255() uint16 {
+	switch code := r.bytes(1)[0]; code {
+	case 253:
+		return r.uint16()
+	case 254:
+		return uint16(r.bytes(1)[0]) + 506
+	case 255:
+		return uint16(r.bytes(1)[0]) + 253
+	default:
+		return uint16(code)
+	}
+}
+
+// woff2ToSFNT decompresses a woff2 font or collection into a sfnt font or collection.
+// transformed glyf and loca tables are replaced by empty glyphs, only the metadata of the font is kept.
+// returns the offsets of the table directories
+func woff2ToSFNT(data []byte) ([]byte, []int, error) {
+	if len(data) < 48 {
+		return nil, nil, errors.New("truncated woff2 header")
+	}
+	r := &woff2Reader{data: data, pos: 48}
+	num := int(binary.BigEndian.Uint16(data[12:]))
+	compressedSize := int(binary.BigEndian.Uint32(data[20:]))
+	var tables = make([]fontTable, num)
+	var lengths = make([]int, num)
+	var transformed = make([]bool, num)
+	var size int
+	for i := 0; i < num; i++ {
+		flags := r.bytes(1)[0]
+		if flags&0x3f == 0x3f {
+			tables[i].tag = r.bytes(4)
+		} else if int(flags&0x3f) < len(woff2KnownTags) {
+			tables[i].tag = []byte(woff2KnownTags[flags&0x3f])
+		} else {
+			return nil, nil, errors.Errorf("invalid woff2 table tag index %d", flags&0x3f)
+		}
+		lengths[i] = int(r.base128())
+		version := flags >> 6
+		if tag := string(tables[i].tag); tag == "glyf" || tag == "loca" {
+			transformed[i] = version != 3
+		} else {
+			transformed[i] = version != 0
+		}
+		if size += lengths[i]; size > maxFontSize {
+			return nil, nil, errors.Errorf("decompressed woff2 font larger than %d bytes", maxFontSize)
+		}
+		if transformed[i] {
+			lengths[i] = int(r.base128())
+		}
+	}
+	var flavors [][]byte
+	var fonts [][]int
+	if string(data[4:8]) == "ttcf" {
+		r.uint32() // version
+		numFonts := int(r.uint255())
+		for i := 0; i < numFonts && r.err == nil; i++ {
+			numTables := int(r.uint255())
+			flavors = append(flavors, r.bytes(4))
+			var font []int
+			for j := 0; j < numTables; j++ {
+				idx := int(r.uint255())
+				if idx >= num {
+					return nil, nil, errors.Errorf("invalid woff2 table index %d", idx)
+				}
+				font = append(font, idx)
+			}
+			fonts = append(fonts, font)
+		}
+	} else {
+		var font []int
+		for i := 0; i < num; i++ {
+			font = append(font, i)
+		}
+		flavors, fonts = [][]byte{data[4:8]}, [][]int{font}
+	}
+	if r.err != nil {
+		return nil, nil, r.err
+	}
+	if compressedSize > len(data)-r.pos {
+		return nil, nil, errors.New("truncated woff2 table data")
+	}
+	decompressed, err := io.ReadAll(io.LimitReader(brotli.NewReader(bytes.NewReader(data[r.pos:r.pos+compressedSize])), maxFontSize+1))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot decompress woff2 table data")
+	}
+	if len(decompressed) > maxFontSize {
+		return nil, nil, errors.Errorf("decompressed woff2 font larger than %d bytes", maxFontSize)
+	}
+	var offset int
+	for i := range tables {
+		if lengths[i] > len(decompressed)-offset {
+			return nil, nil, errors.Errorf("woff2 table '%s' outside of table data", string(tables[i].tag))
+		}
+		tables[i].data = decompressed[offset : offset+lengths[i]]
+		offset += lengths[i]
+	}
+
+	for _, font := range fonts {
+		var byTag = map[string]int{}
+		for _, idx := range font {
+			byTag[string(tables[idx].tag)] = idx
+		}
+		glyf, okGlyf := byTag["glyf"]
+		loca, okLoca := byTag["loca"]
+		if okGlyf && okLoca && transformed[glyf] {
+			// the transformed glyf table starts with numGlyphs and indexFormat
+			header := tables[glyf].data
+			if len(header) < 8 {
+				return nil, nil, errors.New("truncated transformed glyf table")
+			}
+			numGlyphs, indexFormat := int(binary.BigEndian.Uint16(header[4:])), binary.BigEndian.Uint16(header[6:])
+			locaSize := 2
+			if indexFormat != 0 {
+				locaSize = 4
+			}
+			tables[loca].data = make([]byte, (numGlyphs+1)*locaSize)
+			tables[glyf].data = nil
+			// tables may be shared by the fonts of a collection
+			transformed[glyf], transformed[loca] = false, false
+		}
+		if hmtx, ok := byTag["hmtx"]; ok && transformed[hmtx] {
+			hhea, okHhea := byTag["hhea"]
+			maxp, okMaxp := byTag["maxp"]
+			if !okHhea || !okMaxp || len(tables[hhea].data) < 36 || len(tables[maxp].data) < 6 {
+				return nil, nil, errors.New("transformed hmtx table without hhea or maxp")
+			}
+			numHMetrics := int(binary.BigEndian.Uint16(tables[hhea].data[34:]))
+			numGlyphs := int(binary.BigEndian.Uint16(tables[maxp].data[4:]))
+			if tables[hmtx].data, err = woff2Hmtx(tables[hmtx].data, numHMetrics, numGlyphs); err != nil {
+				return nil, nil, errors.WithStack(err)
+			}
+			transformed[hmtx] = false
+		}
+	}
+	sfntData, offsets := buildSFNT(flavors, fonts, tables)
+	return sfntData, offsets, nil
+}
+
+// woff2Hmtx reconstructs a transformed hmtx table. omitted left side bearings are set to zero
+func woff2Hmtx(data []byte, numHMetrics, numGlyphs int) ([]byte, error) {
+	if len(data) < 1 || numHMetrics > numGlyphs {
+		return nil, errors.New("invalid transformed hmtx table")
+	}
+	flags := data[0]
+	r := &woff2Reader{data: data, pos: 1}
+	advances := r.bytes(2 * numHMetrics)
+	var lsb, extraLsb []byte
+	if flags&0x01 == 0 {
+		lsb = r.bytes(2 * numHMetrics)
+	}
+	if flags&0x02 == 0 {
+		extraLsb = r.bytes(2 * (numGlyphs - numHMetrics))
+	}
+	if r.err != nil {
+		return nil, errors.New("truncated transformed hmtx table")
+	}
+	var hmtx = make([]byte, 4*numHMetrics+2*(numGlyphs-numHMetrics))
+	for i := 0; i < numHMetrics; i++ {
+		copy(hmtx[4*i:], advances[2*i:2*i+2])
+		if lsb != nil {
+			copy(hmtx[4*i+2:], lsb[2*i:2*i+2])
+		}
+	}
+	copy(hmtx[4*numHMetrics:], extraLsb)
+	return hmtx, nil
+}
+
+// fontEpoch is the start of LONGDATETIME in the head table
+var fontEpoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func fontDate(data []byte) time.Time {
+	secs := int64(binary.BigEndian.Uint64(data))
+	if secs <= 0 {
+		return time.Time{}
+	}
+	return fontEpoch.Add(time.Duration(secs) * time.Second).UTC()
+}
+
+func fontEmbedding(fsType uint16) (string, []string) {
+	var embedding string
+	switch {
+	case fsType&0x0002 != 0:
+		embedding = "restricted"
+	case fsType&0x0004 != 0:
+		embedding = "preview&print"
+	case fsType&0x0008 != 0:
+		embedding = "editable"
+	default:
+		embedding = "installable"
+	}
+	var flags []string
+	if fsType&0x0100 != 0 {
+		flags = append(flags, "no-subsetting")
+	}
+	if fsType&0x0200 != 0 {
+		flags = append(flags, "bitmap-only")
+	}
+	return embedding, flags
+}
+
+// fontInfo collects the name, OS/2, head and post information of a single font
+func fontInfo(f *sfnt.Font, tables map[string][]byte) *FontInfo {
+	var b sfnt.Buffer
+	name := func(id sfnt.NameID) string {
+		str, _ := f.Name(&b, id)
+		return strings.TrimSpace(str)
+	}
+	info := &FontInfo{
+		Family:         name(sfnt.NameIDFamily),
+		Subfamily:      name(sfnt.NameIDSubfamily),
+		FullName:       name(sfnt.NameIDFull),
+		PostScriptName: name(sfnt.NameIDPostScript),
+		Version:        name(sfnt.NameIDVersion),
+		Copyright:      name(sfnt.NameIDCopyright),
+		Trademark:      name(sfnt.NameIDTrademark),
+		Manufacturer:   name(sfnt.NameIDManufacturer),
+		Designer:       name(sfnt.NameIDDesigner),
+		DesignerURL:    name(sfnt.NameIDDesignerURL),
+		VendorURL:      name(sfnt.NameIDVendorURL),
+		License:        name(sfnt.NameIDLicense),
+		LicenseURL:     name(sfnt.NameIDLicenseURL),
+		Glyphs:         f.NumGlyphs(),
+		UnitsPerEm:     int(f.UnitsPerEm()),
+		Outlines:       "truetype",
+	}
+	if typo := name(sfnt.NameIDTypographicFamily); typo != "" {
+		info.Family = typo
+	}
+	if _, ok := tables["CFF "]; ok {
+		info.Outlines = "cff"
+	} else if _, ok := tables["CFF2"]; ok {
+		info.Outlines = "cff"
+	}
+	if post := f.PostTable(); post != nil {
+		info.FixedPitch = post.IsFixedPitch
+	}
+	if head := tables["head"]; len(head) >= 46 {
+		rev := binary.BigEndian.Uint32(head[4:])
+		info.FontRevision = fmt.Sprintf("%.3f", float64(rev)/65536)
+		info.Created = fontDate(head[20:])
+		info.Modified = fontDate(head[28:])
+		macStyle := binary.BigEndian.Uint16(head[44:])
+		info.Bold = macStyle&0x01 != 0
+		info.Italic = macStyle&0x02 != 0
+	}
+	info.Embedding, info.EmbeddingFlags = fontEmbedding(0)
+	if os2 := tables["OS/2"]; len(os2) >= 68 {
+		info.Weight = binary.BigEndian.Uint16(os2[4:])
+		info.Width = binary.BigEndian.Uint16(os2[6:])
+		info.FsType = binary.BigEndian.Uint16(os2[8:])
+		info.Embedding, info.EmbeddingFlags = fontEmbedding(info.FsType)
+		for i := 0; i < 4; i++ {
+			bits := binary.BigEndian.Uint32(os2[42+i*4:])
+			for bit := 0; bit < 32; bit++ {
+				if bits&(1<<bit) != 0 && i*32+bit < len(fontUnicodeRanges) {
+					info.UnicodeRanges = append(info.UnicodeRanges, fontUnicodeRanges[i*32+bit])
+				}
+			}
+		}
+		info.Vendor = strings.TrimRight(string(os2[58:62]), " \x00")
+		fsSelection := binary.BigEndian.Uint16(os2[62:])
+		info.Italic = info.Italic || fsSelection&0x01 != 0
+		info.Bold = info.Bold || fsSelection&0x20 != 0
+	}
+	return info
+}
+
+func (af *ActionFont) analyze(reader io.Reader, filename string) (*ResultV2, error) {
+	data, err := io.ReadAll(io.LimitReader(reader, maxFontSize+1))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read '%s'", filename)
+	}
+	container, mimetype := fontContainer(data)
+	if container == "" {
+		return nil, nil
+	}
+	var result = NewResultV2()
+	result.Mimetype = mimetype
+	result.Mimetypes = []string{mimetype}
+	fresult := &FontResult{Container: container}
+	result.Metadata[af.GetName()] = fresult
+	if len(data) > maxFontSize {
+		fresult.Error = fmt.Sprintf("font larger than %d bytes", maxFontSize)
+		return result, nil
+	}
+
+	var offsets []int
+	switch container {
+	case "woff2":
+		fresult.Flavor, _ = fontContainer(data[4:8])
+		if data, offsets, err = woff2ToSFNT(data); err != nil {
+			fresult.Error = err.Error()
+			return result, nil
+		}
+	case "woff":
+		fresult.Flavor, _ = fontContainer(data[4:8])
+		if data, err = woffToSFNT(data); err != nil {
+			fresult.Error = err.Error()
+			return result, nil
+		}
+		offsets = []int{0}
+	case "collection":
+		if len(data) < 12 {
+			fresult.Error = "truncated collection header"
+			return result, nil
+		}
+		num := int(binary.BigEndian.Uint32(data[8:]))
+		for i := 0; i < num && 12+i*4+4 <= len(data); i++ {
+			offsets = append(offsets, int(binary.BigEndian.Uint32(data[12+i*4:])))
+		}
+	default:
+		offsets = []int{0}
+	}
+	collection, err := sfnt.ParseCollection(data)
+	if err != nil {
+		fresult.Error = err.Error()
+		return result, nil
+	}
+	for i := 0; i < collection.NumFonts() && i < len(offsets); i++ {
+		f, err := collection.Font(i)
+		if err != nil {
+			fresult.Error = err.Error()
+			continue
+		}
+		tables, err := fontTables(data, offsets[i])
+		if err != nil {
+			fresult.Error = err.Error()
+			continue
+		}
+		fresult.Fonts = append(fresult.Fonts, fontInfo(f, tables))
+	}
+	return result, nil
+}
+
+func (af *ActionFont) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
+	return af.analyze(reader, filename)
+}
+
+func (af *ActionFont) DoV2(filename string) (*ResultV2, error) {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open file '%s'", filename)
+	}
+	defer fp.Close()
+	return af.analyze(fp, filename)
+}
+
+func (af *ActionFont) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
+	filename, err := af.server.fm.Get(uri)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "no file url")
+	}
+	result, err := af.DoV2(filename)
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+	if result == nil {
+		return nil, nil, nil, ErrMimeNotApplicable
+	}
+	return result.Metadata[af.GetName()], result.Mimetypes, nil, nil
+}
+
+// fontUnicodeRanges are the names of the ulUnicodeRange bits of the OS/2 table
+var fontUnicodeRanges = []string{
+	"Basic Latin", "Latin-1 Supplement", "Latin Extended-A", "Latin Extended-B", "IPA Extensions",
+	"Spacing Modifier Letters", "Combining Diacritical Marks", "Greek and Coptic", "Coptic", "Cyrillic",
+	"Armenian", "Hebrew", "Vai", "Arabic", "NKo", "Devanagari", "Bengali", "Gurmukhi", "Gujarati", "Oriya",
+	"Tamil", "Telugu", "Kannada", "Malayalam", "Thai", "Lao", "Georgian", "Balinese", "Hangul Jamo",
+	"Latin Extended Additional", "Greek Extended", "General Punctuation", "Superscripts And Subscripts",
+	"Currency Symbols", "Combining Diacritical Marks For Symbols", "Letterlike Symbols", "Number Forms",
+	"Arrows", "Mathematical Operators", "Miscellaneous Technical", "Control Pictures",
+	"Optical Character Recognition", "Enclosed Alphanumerics", "Box Drawing", "Block Elements",
+	"Geometric Shapes", "Miscellaneous Symbols", "Dingbats", "CJK Symbols And Punctuation", "Hiragana",
+	"Katakana", "Bopomofo", "Hangul Compatibility Jamo", "Phags-pa", "Enclosed CJK Letters And Months",
+	"CJK Compatibility", "Hangul Syllables", "Non-Plane 0", "Phoenician", "CJK Unified Ideographs",
+	"Private Use Area (plane 0)", "CJK Strokes", "Alphabetic Presentation Forms", "Arabic Presentation Forms-A",
+	"Combining Half Marks", "Vertical Forms", "Small Form Variants", "Arabic Presentation Forms-B",
+	"Halfwidth And Fullwidth Forms", "Specials", "Tibetan", "Syriac", "Thaana", "Sinhala", "Myanmar",
+	"Ethiopic", "Cherokee", "Unified Canadian Aboriginal Syllabics", "Ogham", "Runic", "Khmer", "Mongolian",
+	"Braille Patterns", "Yi Syllables", "Tagalog", "Old Italic", "Gothic", "Deseret",
+	"Byzantine Musical Symbols", "Mathematical Alphanumeric Symbols", "Private Use (plane 15)",
+	"Variation Selectors", "Tags", "Limbu", "Tai Le", "New Tai Lue", "Buginese", "Glagolitic", "Tifinagh",
+	"Yijing Hexagram Symbols", "Syloti Nagri", "Linear B Syllabary", "Ancient Greek Numbers", "Ugaritic",
+	"Old Persian", "Shavian", "Osmanya", "Cypriot Syllabary", "Kharoshthi", "Tai Xuan Jing Symbols",
+	"Cuneiform", "Counting Rod Numerals", "Sundanese", "Lepcha", "Ol Chiki", "Saurashtra", "Kayah Li",
+	"Rejang", "Cham", "Ancient Symbols", "Phaistos Disc", "Carian", "Domino Tiles",
+}
+
+var (
+	_ Action = (*ActionFont)(nil)
+)
@@ -0,0 +1,209 @@
+package indexer
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// buildTestWOFF wraps the tables of a sfnt font into a woff 1.0 container with compressed tables
+func buildTestWOFF(t *testing.T, font []byte) []byte {
+	num := int(binary.BigEndian.Uint16(font[4:]))
+	var dir, data bytes.Buffer
+	offset := 44 + num*20
+	for i := 0; i < num; i++ {
+		rec := font[12+i*16 : 12+i*16+16]
+		start, length := binary.BigEndian.Uint32(rec[8:]), binary.BigEndian.Uint32(rec[12:])
+		var comp bytes.Buffer
+		zw := zlib.NewWriter(&comp)
+		if _, err := zw.Write(font[start : start+length]); err != nil {
+			t.Fatalf("cannot compress: %v", err)
+		}
+		zw.Close()
+		tdata := comp.Bytes()
+		if len(tdata) >= int(length) {
+			tdata = font[start : start+length]
+		}
+		dir.Write(rec[:4])
+		_ = binary.Write(&dir, binary.BigEndian, []uint32{uint32(offset + data.Len()), uint32(len(tdata)), length, binary.BigEndian.Uint32(rec[4:])})
+		data.Write(tdata)
+		data.Write(make([]byte, (4-len(tdata)%4)%4))
+	}
+	var woff bytes.Buffer
+	woff.WriteString("wOFF")
+	woff.Write(font[:4])
+	_ = binary.Write(&woff, binary.BigEndian, []uint32{uint32(offset + data.Len())})
+	_ = binary.Write(&woff, binary.BigEndian, []uint16{uint16(num), 0})
+	_ = binary.Write(&woff, binary.BigEndian, []uint32{uint32(len(font))})
+	_ = binary.Write(&woff, binary.BigEndian, []uint16{1, 0})
+	_ = binary.Write(&woff, binary.BigEndian, []uint32{0, 0, 0, 0, 0})
+	woff.Write(dir.Bytes())
+	woff.Write(data.Bytes())
+	return woff.Bytes()
+}
+
+func woff2Base128(value int) []byte {
+	var data = []byte{byte(value & 0x7f)}
+	for value >>= 7; value > 0; value >>= 7 {
+		data = append([]byte{byte(value&0x7f) | 0x80}, data...)
+	}
+	return data
+}
+
+// buildTestWOFF2 puts the tables of a sfnt font into a woff2 container. with transform glyf, loca and hmtx are
+// transformed, only the glyf header and the advance widths are kept. fonts > 1 creates a collection of the same font
+func buildTestWOFF2(t *testing.T, font []byte, transform bool, fonts int) []byte {
+	num := int(binary.BigEndian.Uint16(font[4:]))
+	table := func(tag string) []byte {
+		for i := 0; i < num; i++ {
+			rec := font[12+i*16 : 12+i*16+16]
+			if string(rec[:4]) == tag {
+				start, length := binary.BigEndian.Uint32(rec[8:]), binary.BigEndian.Uint32(rec[12:])
+				return font[start : start+length]
+			}
+		}
+		t.Fatalf("no table %s", tag)
+		return nil
+	}
+	var dir, data bytes.Buffer
+	for i := 0; i < num; i++ {
+		tag := string(font[12+i*16 : 12+i*16+4])
+		tdata := table(tag)
+		flags := byte(slices.Index(woff2KnownTags, tag))
+		if flags == 0xff {
+			flags = 0x3f
+		}
+		var transformed []byte
+		switch {
+		case (tag == "glyf" || tag == "loca") && !transform:
+			flags |= 0xc0
+		case tag == "glyf":
+			transformed = make([]byte, 36)
+			copy(transformed[4:], table("maxp")[4:6])
+			copy(transformed[6:], table("head")[50:52])
+		case tag == "loca":
+			transformed = []byte{}
+		case tag == "hmtx" && transform:
+			flags |= 0x40
+			numHMetrics := int(binary.BigEndian.Uint16(table("hhea")[34:]))
+			transformed = []byte{0x03}
+			for j := 0; j < numHMetrics; j++ {
+				transformed = append(transformed, tdata[4*j:4*j+2]...)
+			}
+		}
+		dir.WriteByte(flags)
+		if flags&0x3f == 0x3f {
+			dir.WriteString(tag)
+		}
+		dir.Write(woff2Base128(len(tdata)))
+		if transformed != nil {
+			dir.Write(woff2Base128(len(transformed)))
+			tdata = transformed
+		}
+		data.Write(tdata)
+	}
+	flavor := font[:4]
+	if fonts > 1 {
+		flavor = []byte("ttcf")
+		_ = binary.Write(&dir, binary.BigEndian, uint32(0x00010000))
+		dir.WriteByte(byte(fonts))
+		for i := 0; i < fonts; i++ {
+			dir.WriteByte(byte(num))
+			dir.Write(font[:4])
+			for j := 0; j < num; j++ {
+				dir.WriteByte(byte(j))
+			}
+		}
+	}
+	var comp bytes.Buffer
+	bw := brotli.NewWriter(&comp)
+	if _, err := bw.Write(data.Bytes()); err != nil {
+		t.Fatalf("cannot compress: %v", err)
+	}
+	bw.Close()
+	var woff2 bytes.Buffer
+	woff2.WriteString("wOF2")
+	woff2.Write(flavor)
+	_ = binary.Write(&woff2, binary.BigEndian, []uint32{uint32(48 + dir.Len() + comp.Len())})
+	_ = binary.Write(&woff2, binary.BigEndian, []uint16{uint16(num), 0})
+	_ = binary.Write(&woff2, binary.BigEndian, []uint32{uint32(len(font)), uint32(comp.Len())})
+	_ = binary.Write(&woff2, binary.BigEndian, []uint16{1, 0})
+	_ = binary.Write(&woff2, binary.BigEndian, []uint32{0, 0, 0, 0, 0})
+	woff2.Write(dir.Bytes())
+	woff2.Write(comp.Bytes())
+	return woff2.Bytes()
+}
+
+func TestActionFont(t *testing.T) {
+	ad := NewActionDispatcher(nil)
+	af := NewActionFont(NameFont, nil, ad)
+
+	check := func(name string, data []byte, container, mimetype string) {
+		result, err := af.Stream("", bytes.NewReader(data), name)
+		if err != nil {
+			t.Fatalf("cannot analyze %s: %v", name, err)
+		}
+		fr := result.Metadata[NameFont].(*FontResult)
+		if fr.Container != container || result.Mimetype != mimetype || fr.Error != "" || len(fr.Fonts) != 1 {
+			t.Fatalf("wrong result for %s: %+v", name, fr)
+		}
+		info := fr.Fonts[0]
+		if info.Family != "Go" || info.Subfamily != "Regular" || info.Glyphs == 0 || info.Outlines != "truetype" ||
+			info.Embedding != "installable" || info.Weight != 400 || info.Created.IsZero() || info.FontRevision == "" ||
+			!slices.Contains(info.UnicodeRanges, "Basic Latin") || !slices.Contains(info.UnicodeRanges, "Cyrillic") {
+			t.Errorf("wrong font info for %s: %+v", name, info)
+		}
+	}
+	check("go.ttf", goregular.TTF, "truetype", "font/ttf")
+	check("go.woff", buildTestWOFF(t, goregular.TTF), "woff", "font/woff")
+
+	check("go.woff2", buildTestWOFF2(t, goregular.TTF, false, 1), "woff2", "font/woff2")
+	check("go-transformed.woff2", buildTestWOFF2(t, goregular.TTF, true, 1), "woff2", "font/woff2")
+
+	result, err := af.Stream("", bytes.NewReader(buildTestWOFF2(t, goregular.TTF, true, 2)), "go.woff2")
+	if err != nil {
+		t.Fatalf("cannot analyze woff2 collection: %v", err)
+	}
+	if fr := result.Metadata[NameFont].(*FontResult); fr.Container != "woff2" || fr.Flavor != "collection" || fr.Error != "" ||
+		len(fr.Fonts) != 2 || fr.Fonts[1].Family != "Go" || fr.Fonts[1].Glyphs != fr.Fonts[0].Glyphs {
+		t.Errorf("wrong woff2 collection result: %+v", fr)
+	}
+
+	// decompressed size is limited
+	woff := buildTestWOFF(t, goregular.TTF)
+	binary.BigEndian.PutUint32(woff[44+12:], maxFontSize+1)
+	woff2 := buildTestWOFF2(t, goregular.TTF, false, 1)
+	first := goregular.TTF[12:28]
+	pos := 49
+	if !slices.Contains(woff2KnownTags, string(first[:4])) {
+		pos += 4
+	}
+	woff2 = slices.Concat(woff2[:pos], woff2Base128(maxFontSize+1), woff2[pos+len(woff2Base128(int(binary.BigEndian.Uint32(first[12:])))):])
+	for name, data := range map[string][]byte{"large.woff": woff, "large.woff2": woff2, "truncated.woff2": []byte("wOF2\x00\x01\x00\x00")} {
+		result, err := af.Stream("", bytes.NewReader(data), name)
+		if err != nil {
+			t.Fatalf("cannot analyze %s: %v", name, err)
+		}
+		fr := result.Metadata[NameFont].(*FontResult)
+		if fr.Flavor != "truetype" || fr.Error == "" || len(fr.Fonts) != 0 {
+			t.Errorf("wrong result for %s: %+v", name, fr)
+		}
+		if strings.HasPrefix(name, "large") && !strings.Contains(fr.Error, "larger than") {
+			t.Errorf("size limit not reported for %s: %s", name, fr.Error)
+		}
+	}
+	if result, err := af.Stream("", bytes.NewReader([]byte("no font")), "test.ttf"); err != nil || result != nil {
+		t.Errorf("no font must be ignored: %v / %v", result, err)
+	}
+
+	embedding, flags := fontEmbedding(0x0204)
+	if embedding != "preview&print" || !slices.Equal(flags, []string{"bitmap-only"}) {
+		t.Errorf("wrong embedding: %s %v", embedding, flags)
+	}
+}
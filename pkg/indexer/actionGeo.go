@@ -0,0 +1,785 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indexer
+
+import (
+	"bufio"
+	"bytes"
+	"emperror.dev/errors"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/je4/indexer/v3/pkg/sqlite"
+	"github.com/je4/indexer/v3/pkg/tiff"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// GeoLayer is a content table of a geopackage
+type GeoLayer struct {
+	Name         string    `json:"name"`
+	DataType     string    `json:"datatype"` // features, tiles, attributes
+	CRS          string    `json:"crs,omitempty"`
+	BBox         []float64 `json:"bbox,omitempty"`
+	Count        int64     `json:"count"`
+	GeometryType string    `json:"geometrytype,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+type GeoResult struct {
+	Format        string            `json:"format"`        // geotiff, shapefile, projection, geopackage or geojson
+	CRS           string            `json:"crs,omitempty"` // i.e. EPSG:4326
+	CRSName       string            `json:"crsname,omitempty"`
+	BBox          []float64         `json:"bbox,omitempty"` // minx, miny, maxx, maxy in crs units
+	Features      int64             `json:"features,omitempty"`
+	Rasters       int               `json:"rasters,omitempty"`
+	GeometryTypes []string          `json:"geometrytypes,omitempty"`
+	GeoKeys       map[string]string `json:"geokeys,omitempty"`
+	Layers        []*GeoLayer       `json:"layers,omitempty"`
+	Error         string            `json:"error,omitempty"`
+}
+
+type ActionGeo struct {
+	name    string
+	tempDir string
+	server  *Server
+}
+
+func (ag *ActionGeo) CanHandle(contentType string, filename string) bool {
+	if slices.Contains([]string{"image/tiff", "application/geo+json", "application/geopackage+sqlite3", "application/x-shapefile"}, contentType) {
+		return true
+	}
+	return slices.Contains([]string{".tif", ".tiff", ".shp", ".prj", ".gpkg", ".geojson", ".json"}, strings.ToLower(filepath.Ext(filename)))
+}
+
+func NewActionGeo(name string, tempDir string, server *Server, ad *ActionDispatcher) Action {
+	ag := &ActionGeo{name: name, tempDir: tempDir, server: server}
+	ad.RegisterAction(ag)
+	return ag
+}
+
+func (ag *ActionGeo) GetWeight() uint {
+	return 50
+}
+
+func (ag *ActionGeo) GetCaps() ActionCapability {
+	return ACTFILEFULL | ACTSTREAM
+}
+
+func (ag *ActionGeo) GetName() string {
+	return ag.name
+}
+
+// geoBBox collects the extent of coordinates
+type geoBBox struct {
+	minX, minY, maxX, maxY float64
+	valid                  bool
+}
+
+func (b *geoBBox) add(x, y float64) {
+	if math.IsNaN(x) || math.IsNaN(y) {
+		return
+	}
+	if !b.valid {
+		b.minX, b.minY, b.maxX, b.maxY, b.valid = x, y, x, y, true
+		return
+	}
+	b.minX, b.minY = min(b.minX, x), min(b.minY, y)
+	b.maxX, b.maxY = max(b.maxX, x), max(b.maxY, y)
+}
+
+func (b *geoBBox) slice() []float64 {
+	if !b.valid {
+		return nil
+	}
+	return []float64{b.minX, b.minY, b.maxX, b.maxY}
+}
+
+// geoHeadSize is read ahead to detect the format without spooling the file
+const geoHeadSize = 64 * 1024
+
+// geoPackageIDs are the sqlite application ids of geopackage 1.0, 1.1 and 1.2+
+var geoPackageIDs = []uint32{0x47503130, 0x47503131, 0x47504B47}
+
+// geoTIFFHead reports whether the first ifd in head has a geo key directory.
+// if the ifd is not inside of head, the file has to be inspected
+func geoTIFFHead(head []byte) bool {
+	f, _ := tiff.Open(bytes.NewReader(head), int64(len(head)))
+	if f == nil {
+		return false
+	}
+	if len(f.IFDs) == 0 {
+		return true
+	}
+	return f.IFDs[0].Entry(tiff.TagGeoKeyDirectory) != nil
+}
+
+// detect returns the geo format based on the magic or the file extension.
+// tiff files without geo keys and sqlite databases without geopackage application id are ignored
+func (ag *ActionGeo) detect(head []byte, contentType, filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	switch {
+	case isTIFFHeader(head):
+		if !geoTIFFHead(head) {
+			return ""
+		}
+		return "geotiff"
+	case bytes.HasPrefix(head, []byte(sqlite.Magic)):
+		if len(head) < 72 || !slices.Contains(geoPackageIDs, binary.BigEndian.Uint32(head[68:])) {
+			return ""
+		}
+		return "geopackage"
+	case len(head) >= 4 && binary.BigEndian.Uint32(head) == 9994:
+		return "shapefile"
+	case ext == ".prj":
+		return "projection"
+	case ext == ".geojson" || ext == ".json" || contentType == "application/geo+json":
+		if trimmed := bytes.TrimLeft(head, " \t\r\n\ufeff"); len(trimmed) > 0 && trimmed[0] == '{' {
+			return "geojson"
+		}
+	}
+	return ""
+}
+
+func (ag *ActionGeo) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
+	br := bufio.NewReaderSize(reader, geoHeadSize)
+	head, _ := br.Peek(geoHeadSize)
+	format := ag.detect(head, contentType, filename)
+	switch format {
+	case "":
+		return nil, nil
+	case "geotiff", "geopackage":
+		// directories and b-tree pages need random access
+		fp, err := spoolTempFile(br, ag.tempDir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot spool '%s'", filename)
+		}
+		defer func() {
+			fp.Close()
+			os.Remove(fp.Name())
+		}()
+		return ag.inspect(fp, format, filename)
+	}
+	gresult, err := ag.read(br, format)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read '%s'", filename)
+	}
+	return ag.result(gresult), nil
+}
+
+func (ag *ActionGeo) DoV2(filename string) (*ResultV2, error) {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open file '%s'", filename)
+	}
+	defer fp.Close()
+	head := make([]byte, geoHeadSize)
+	n, _ := io.ReadFull(fp, head)
+	format := ag.detect(head[:n], "", filename)
+	switch format {
+	case "":
+		return nil, nil
+	case "geotiff", "geopackage":
+		return ag.inspect(fp, format, filename)
+	}
+	if _, err := fp.Seek(0, io.SeekStart); err != nil {
+		return nil, errors.Wrapf(err, "cannot seek '%s'", filename)
+	}
+	gresult, err := ag.read(fp, format)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read '%s'", filename)
+	}
+	// the projection of a shapefile is stored in the .prj sidecar
+	if gresult != nil && format == "shapefile" {
+		base := strings.TrimSuffix(filename, filepath.Ext(filename))
+		for _, ext := range []string{".prj", ".PRJ"} {
+			prj, err := os.Open(base + ext)
+			if err != nil {
+				continue
+			}
+			wkt, err := io.ReadAll(io.LimitReader(prj, 64*1024))
+			prj.Close()
+			if err == nil {
+				gresult.CRS, gresult.CRSName = geoWKT(string(wkt))
+			}
+			break
+		}
+	}
+	return ag.result(gresult), nil
+}
+
+// inspect handles the formats, which need random access
+func (ag *ActionGeo) inspect(fp *os.File, format, filename string) (*ResultV2, error) {
+	stat, err := fp.Stat()
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot stat '%s'", filename)
+	}
+	var gresult *GeoResult
+	switch format {
+	case "geotiff":
+		gresult = ag.geoTIFF(fp, stat.Size())
+	case "geopackage":
+		if gresult, err = ag.geoPackage(fp, stat.Size()); err != nil {
+			return nil, errors.Wrapf(err, "cannot open geopackage '%s'", filename)
+		}
+	}
+	return ag.result(gresult), nil
+}
+
+// read handles the formats, which can be streamed
+func (ag *ActionGeo) read(reader io.Reader, format string) (*GeoResult, error) {
+	switch format {
+	case "shapefile":
+		return ag.shapefile(reader)
+	case "projection":
+		wkt, err := io.ReadAll(io.LimitReader(reader, 64*1024))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		crs, name := geoWKT(string(wkt))
+		if name == "" {
+			return nil, nil
+		}
+		return &GeoResult{Format: format, CRS: crs, CRSName: name}, nil
+	case "geojson":
+		return ag.geoJSON(reader)
+	}
+	return nil, nil
+}
+
+func (ag *ActionGeo) result(gresult *GeoResult) *ResultV2 {
+	if gresult == nil {
+		return nil
+	}
+	var result = NewResultV2()
+	switch gresult.Format {
+	case "shapefile":
+		result.Mimetype = "application/x-shapefile"
+	case "geopackage":
+		result.Mimetype = "application/geopackage+sqlite3"
+	case "geojson":
+		result.Mimetype = "application/geo+json"
+	}
+	if result.Mimetype != "" {
+		result.Mimetypes = []string{result.Mimetype}
+	}
+	result.Metadata[ag.GetName()] = gresult
+	return result
+}
+
+// geo keys of GeoTIFF 1.1
+var geoKeyNames = map[uint64]string{
+	1024: "GTModelType",
+	1025: "GTRasterType",
+	1026: "GTCitation",
+	2048: "GeodeticCRS",
+	2049: "GeodeticCitation",
+	2050: "GeodeticDatum",
+	2054: "GeogAngularUnits",
+	3072: "ProjectedCRS",
+	3073: "ProjectedCitation",
+	3074: "Projection",
+	3076: "ProjLinearUnits",
+	4096: "VerticalCRS",
+	4097: "VerticalCitation",
+	4099: "VerticalUnits",
+}
+
+// geoTIFF reads the geo keys of the first image. tiffs without geo keys are ignored
+func (ag *ActionGeo) geoTIFF(r io.ReaderAt, size int64) *GeoResult {
+	f, _ := tiff.Open(r, size)
+	if f == nil || len(f.IFDs) == 0 {
+		return nil
+	}
+	ifd := f.IFDs[0]
+	entry := ifd.Entry(tiff.TagGeoKeyDirectory)
+	if entry == nil {
+		return nil
+	}
+	gresult := &GeoResult{Format: "geotiff", GeoKeys: map[string]string{}}
+	for _, ifd := range f.IFDs {
+		// reduced resolution images are no separate rasters
+		if subfile, err := f.Uint(ifd, tiff.TagNewSubfileType, 0); err == nil && subfile&1 == 0 {
+			gresult.Rasters++
+		}
+	}
+	keys, err := f.Uints(entry)
+	if err != nil || len(keys) < 4 {
+		gresult.Error = fmt.Sprintf("invalid geo key directory: %v", err)
+		return gresult
+	}
+	var doubles []float64
+	if e := ifd.Entry(tiff.TagGeoDoubleParams); e != nil {
+		doubles, _ = f.Floats(e)
+	}
+	var ascii string
+	if e := ifd.Entry(tiff.TagGeoASCIIParams); e != nil {
+		ascii, _ = f.ASCII(e)
+	}
+	shorts := map[uint64]uint64{}
+	for i := uint64(0); i < keys[3] && 7+4*i < uint64(len(keys)); i++ {
+		key := keys[4+4*i : 8+4*i]
+		id, location, count, value := key[0], key[1], key[2], key[3]
+		name, ok := geoKeyNames[id]
+		if !ok {
+			name = fmt.Sprintf("Key%d", id)
+		}
+		switch location {
+		case 0:
+			shorts[id] = value
+			gresult.GeoKeys[name] = fmt.Sprintf("%d", value)
+		case tiff.TagGeoDoubleParams:
+			if value+count <= uint64(len(doubles)) {
+				gresult.GeoKeys[name] = strings.Trim(fmt.Sprint(doubles[value:value+count]), "[]")
+			}
+		case tiff.TagGeoASCIIParams:
+			if value+count <= uint64(len(ascii)) {
+				gresult.GeoKeys[name] = strings.TrimRight(ascii[value:value+count], "|\x00")
+			}
+		}
+	}
+	// 32767 is user defined
+	for _, id := range []uint64{3072, 2048} {
+		if code, ok := shorts[id]; ok && code > 0 && code != 32767 {
+			gresult.CRS = fmt.Sprintf("EPSG:%d", code)
+			break
+		}
+	}
+	for _, name := range []string{"ProjectedCitation", "GTCitation", "GeodeticCitation"} {
+		if gresult.GeoKeys[name] != "" {
+			gresult.CRSName = gresult.GeoKeys[name]
+			break
+		}
+	}
+
+	width, _ := f.Uint(ifd, tiff.TagImageWidth, 0)
+	height, _ := f.Uint(ifd, tiff.TagImageLength, 0)
+	corners := [][2]float64{{0, 0}, {float64(width), 0}, {0, float64(height)}, {float64(width), float64(height)}}
+	bbox := &geoBBox{}
+	if e := ifd.Entry(tiff.TagModelTransformation); e != nil {
+		if m, err := f.Floats(e); err == nil && len(m) >= 8 {
+			for _, c := range corners {
+				bbox.add(m[0]*c[0]+m[1]*c[1]+m[3], m[4]*c[0]+m[5]*c[1]+m[7])
+			}
+		}
+	} else if te, se := ifd.Entry(tiff.TagModelTiepoint), ifd.Entry(tiff.TagModelPixelScale); te != nil && se != nil {
+		tie, err1 := f.Floats(te)
+		scale, err2 := f.Floats(se)
+		if err1 == nil && err2 == nil && len(tie) >= 6 && len(scale) >= 2 {
+			for _, c := range corners {
+				bbox.add(tie[3]+(c[0]-tie[0])*scale[0], tie[4]-(c[1]-tie[1])*scale[1])
+			}
+		}
+	}
+	gresult.BBox = bbox.slice()
+	return gresult
+}
+
+var shapeTypes = map[uint32]string{
+	0:  "Null",
+	1:  "Point",
+	3:  "PolyLine",
+	5:  "Polygon",
+	8:  "MultiPoint",
+	11: "PointZ",
+	13: "PolyLineZ",
+	15: "PolygonZ",
+	18: "MultiPointZ",
+	21: "PointM",
+	23: "PolyLineM",
+	25: "PolygonM",
+	28: "MultiPointM",
+	31: "MultiPatch",
+}
+
+// shapefile reads the main file header and counts the records
+func (ag *ActionGeo) shapefile(reader io.Reader) (*GeoResult, error) {
+	head := make([]byte, 100)
+	if _, err := io.ReadFull(reader, head); err != nil {
+		return nil, nil
+	}
+	if binary.LittleEndian.Uint32(head[28:]) != 1000 {
+		return nil, nil
+	}
+	gresult := &GeoResult{Format: "shapefile"}
+	shapeType := binary.LittleEndian.Uint32(head[32:])
+	if name, ok := shapeTypes[shapeType]; ok {
+		gresult.GeometryTypes = []string{name}
+	} else {
+		gresult.GeometryTypes = []string{fmt.Sprintf("unknown (%d)", shapeType)}
+	}
+	bbox := &geoBBox{}
+	bbox.add(math.Float64frombits(binary.LittleEndian.Uint64(head[36:])), math.Float64frombits(binary.LittleEndian.Uint64(head[44:])))
+	bbox.add(math.Float64frombits(binary.LittleEndian.Uint64(head[52:])), math.Float64frombits(binary.LittleEndian.Uint64(head[60:])))
+	// an empty shapefile has a zero box
+	if gresult.BBox = bbox.slice(); slices.Equal(gresult.BBox, []float64{0, 0, 0, 0}) {
+		gresult.BBox = nil
+	}
+	// file length is in 16-bit words
+	fileLength := int64(binary.BigEndian.Uint32(head[24:])) * 2
+	var record = make([]byte, 8)
+	for offset := int64(100); offset < fileLength; {
+		if _, err := io.ReadFull(reader, record); err != nil {
+			gresult.Error = fmt.Sprintf("truncated at record %d", gresult.Features+1)
+			break
+		}
+		length := int64(binary.BigEndian.Uint32(record[4:])) * 2
+		if _, err := io.CopyN(io.Discard, reader, length); err != nil {
+			gresult.Error = fmt.Sprintf("truncated at record %d", gresult.Features+1)
+			break
+		}
+		gresult.Features++
+		offset += 8 + length
+	}
+	return gresult, nil
+}
+
+var geoWKTNameRegexp = regexp.MustCompile(`^\s*(?:PROJCS|GEOGCS|GEOCCS|COMPD_CS|VERT_CS|LOCAL_CS|PROJCRS|GEOGCRS|GEODCRS|COMPOUNDCRS|VERTCRS|ENGCRS|BOUNDCRS)\s*[\[(]\s*"([^"]*)"`)
+
+// the authority of the crs is the last element of the outermost node
+var geoWKTAuthorityRegexp = regexp.MustCompile(`(?:AUTHORITY|ID)\s*[\[(]\s*"([^"]+)"\s*,\s*"?(\d+)"?\s*[\])]\s*[\])]\s*$`)
+
+// geoWKT extracts the authority code and the name of a wkt crs definition
+func geoWKT(wkt string) (string, string) {
+	found := geoWKTNameRegexp.FindStringSubmatch(wkt)
+	if found == nil {
+		return "", ""
+	}
+	if auth := geoWKTAuthorityRegexp.FindStringSubmatch(wkt); auth != nil {
+		return strings.ToUpper(auth[1]) + ":" + auth[2], found[1]
+	}
+	return "", found[1]
+}
+
+// geoRows passes the rows of a table as column map. an INTEGER PRIMARY KEY column is filled with the rowid
+func geoRows(db *sqlite.DB, entry *sqlite.SchemaEntry, fn func(row map[string]any)) error {
+	columns := sqlite.Columns(entry.SQL)
+	pk := strings.ToLower(sqlite.IntegerPrimaryKey(entry.SQL))
+	return db.Scan(entry.RootPage, func(rowid int64, values []any) error {
+		row := map[string]any{}
+		for i, column := range columns {
+			if i < len(values) {
+				row[strings.ToLower(column)] = values[i]
+			}
+		}
+		if pk != "" && row[pk] == nil {
+			row[pk] = rowid
+		}
+		fn(row)
+		return nil
+	})
+}
+
+func geoFloat(v any) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case int64:
+		return float64(val), true
+	}
+	return 0, false
+}
+
+func geoString(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case int64:
+		return fmt.Sprintf("%d", val)
+	}
+	return ""
+}
+
+// geoPackage reads the gpkg_contents with the referenced spatial reference systems and geometry columns
+func (ag *ActionGeo) geoPackage(r io.ReaderAt, size int64) (*GeoResult, error) {
+	db, err := sqlite.Open(r, size)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	schema, err := db.Schema()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	tables := map[string]*sqlite.SchemaEntry{}
+	for _, entry := range schema {
+		if entry.Type == "table" {
+			tables[strings.ToLower(entry.Name)] = &entry
+		}
+	}
+	contents, ok := tables["gpkg_contents"]
+	if !ok {
+		// plain sqlite database
+		return nil, nil
+	}
+	gresult := &GeoResult{Format: "geopackage", Layers: []*GeoLayer{}}
+
+	type srs struct{ crs, name string }
+	srsIDs := map[string]srs{}
+	if entry, ok := tables["gpkg_spatial_ref_sys"]; ok {
+		if err := geoRows(db, entry, func(row map[string]any) {
+			s := srs{name: geoString(row["srs_name"])}
+			if org, code := geoString(row["organization"]), geoString(row["organization_coordsys_id"]); org != "" && strings.ToUpper(org) != "NONE" {
+				s.crs = strings.ToUpper(org) + ":" + code
+			}
+			srsIDs[geoString(row["srs_id"])] = s
+		}); err != nil {
+			gresult.Error = fmt.Sprintf("cannot read gpkg_spatial_ref_sys: %v", err)
+		}
+	}
+	geometryTypes := map[string]string{}
+	if entry, ok := tables["gpkg_geometry_columns"]; ok {
+		if err := geoRows(db, entry, func(row map[string]any) {
+			geometryTypes[strings.ToLower(geoString(row["table_name"]))] = strings.ToUpper(geoString(row["geometry_type_name"]))
+		}); err != nil {
+			gresult.Error = fmt.Sprintf("cannot read gpkg_geometry_columns: %v", err)
+		}
+	}
+	if err := geoRows(db, contents, func(row map[string]any) {
+		layer := &GeoLayer{
+			Name:     geoString(row["table_name"]),
+			DataType: geoString(row["data_type"]),
+		}
+		if s, ok := srsIDs[geoString(row["srs_id"])]; ok {
+			layer.CRS = s.crs
+			if layer.CRS == "" {
+				layer.CRS = s.name
+			}
+		}
+		minX, ok1 := geoFloat(row["min_x"])
+		minY, ok2 := geoFloat(row["min_y"])
+		maxX, ok3 := geoFloat(row["max_x"])
+		maxY, ok4 := geoFloat(row["max_y"])
+		if ok1 && ok2 && ok3 && ok4 {
+			layer.BBox = []float64{minX, minY, maxX, maxY}
+		}
+		layer.GeometryType = geometryTypes[strings.ToLower(layer.Name)]
+		if table, ok := tables[strings.ToLower(layer.Name)]; ok && table.RootPage > 0 {
+			var err error
+			if layer.Count, err = db.CountRows(table.RootPage); err != nil {
+				layer.Count = -1
+				layer.Error = err.Error()
+			}
+		}
+		gresult.Layers = append(gresult.Layers, layer)
+	}); err != nil {
+		gresult.Error = fmt.Sprintf("cannot read gpkg_contents: %v", err)
+	}
+
+	// layer values are summarized, if all layers share the crs
+	var crs []string
+	bbox := &geoBBox{}
+	for _, layer := range gresult.Layers {
+		switch layer.DataType {
+		case "features":
+			gresult.Features += max(layer.Count, 0)
+		case "tiles", "2d-gridded-coverage":
+			gresult.Rasters++
+		}
+		if layer.GeometryType != "" && !slices.Contains(gresult.GeometryTypes, layer.GeometryType) {
+			gresult.GeometryTypes = append(gresult.GeometryTypes, layer.GeometryType)
+		}
+		if !slices.Contains(crs, layer.CRS) {
+			crs = append(crs, layer.CRS)
+		}
+		if len(layer.BBox) == 4 {
+			bbox.add(layer.BBox[0], layer.BBox[1])
+			bbox.add(layer.BBox[2], layer.BBox[3])
+		}
+	}
+	slices.Sort(gresult.GeometryTypes)
+	if len(crs) == 1 {
+		gresult.CRS = crs[0]
+		gresult.BBox = bbox.slice()
+		for _, s := range srsIDs {
+			if s.crs == gresult.CRS {
+				gresult.CRSName = s.name
+				break
+			}
+		}
+	}
+	return gresult, nil
+}
+
+type geoJSONGeometry struct {
+	Type        string             `json:"type"`
+	Coordinates json.RawMessage    `json:"coordinates"`
+	Geometries  []*geoJSONGeometry `json:"geometries"`
+}
+
+type geoJSONFeature struct {
+	Type     string           `json:"type"`
+	Geometry *geoJSONGeometry `json:"geometry"`
+}
+
+type geoJSONCRS struct {
+	Type       string `json:"type"`
+	Properties struct {
+		Name string `json:"name"`
+	} `json:"properties"`
+}
+
+var geoJSONGeometryTypes = []string{"Point", "MultiPoint", "LineString", "MultiLineString", "Polygon", "MultiPolygon", "GeometryCollection"}
+
+// geoURNRegexp matches crs urns like urn:ogc:def:crs:EPSG::3857 or urn:ogc:def:crs:OGC:1.3:CRS84
+var geoURNRegexp = regexp.MustCompile(`(?i)^urn:ogc:def:crs:(\w+):[^:]*:(\w+)$`)
+
+// geoPositions adds all positions of nested coordinate arrays to the bounding box
+func geoPositions(coordinates any, bbox *geoBBox) {
+	arr, ok := coordinates.([]any)
+	if !ok || len(arr) == 0 {
+		return
+	}
+	if x, ok := arr[0].(float64); ok {
+		if len(arr) >= 2 {
+			if y, ok := arr[1].(float64); ok {
+				bbox.add(x, y)
+			}
+		}
+		return
+	}
+	for _, a := range arr {
+		geoPositions(a, bbox)
+	}
+}
+
+func (ag *ActionGeo) addGeoJSONGeometry(gresult *GeoResult, geometry *geoJSONGeometry, bbox *geoBBox, depth int) {
+	if geometry == nil || depth > 16 {
+		return
+	}
+	if geometry.Type != "" && !slices.Contains(gresult.GeometryTypes, geometry.Type) {
+		gresult.GeometryTypes = append(gresult.GeometryTypes, geometry.Type)
+	}
+	if len(geometry.Coordinates) > 0 {
+		var coordinates any
+		if err := json.Unmarshal(geometry.Coordinates, &coordinates); err == nil {
+			geoPositions(coordinates, bbox)
+		}
+	}
+	for _, g := range geometry.Geometries {
+		ag.addGeoJSONGeometry(gresult, g, bbox, depth+1)
+	}
+}
+
+// geoJSON decodes the features one by one, so that large collections need not be held in memory
+func (ag *ActionGeo) geoJSON(reader io.Reader) (*GeoResult, error) {
+	dec := json.NewDecoder(reader)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, nil
+	}
+	gresult := &GeoResult{Format: "geojson"}
+	bbox := &geoBBox{}
+	var typ string
+	var explicitBBox []float64
+	var crs *geoJSONCRS
+	var geometry = &geoJSONGeometry{}
+	var feature = &geoJSONFeature{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil
+		}
+		key, _ := tok.(string)
+		switch key {
+		case "type":
+			err = dec.Decode(&typ)
+		case "bbox":
+			err = dec.Decode(&explicitBBox)
+		case "crs":
+			err = dec.Decode(&crs)
+		case "coordinates":
+			err = dec.Decode(&geometry.Coordinates)
+		case "geometries":
+			err = dec.Decode(&geometry.Geometries)
+		case "geometry":
+			err = dec.Decode(&feature.Geometry)
+		case "features":
+			if tok, err = dec.Token(); err != nil || tok != json.Delim('[') {
+				return nil, nil
+			}
+			for dec.More() {
+				var f = &geoJSONFeature{}
+				if err = dec.Decode(f); err != nil {
+					break
+				}
+				gresult.Features++
+				ag.addGeoJSONGeometry(gresult, f.Geometry, bbox, 0)
+			}
+			if err == nil {
+				_, err = dec.Token()
+			}
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			if typ == "" {
+				return nil, nil
+			}
+			gresult.Error = err.Error()
+			break
+		}
+	}
+	switch {
+	case typ == "FeatureCollection":
+	case typ == "Feature":
+		gresult.Features = 1
+		ag.addGeoJSONGeometry(gresult, feature.Geometry, bbox, 0)
+	case slices.Contains(geoJSONGeometryTypes, typ):
+		geometry.Type = typ
+		ag.addGeoJSONGeometry(gresult, geometry, bbox, 0)
+	default:
+		// no geojson
+		return nil, nil
+	}
+	slices.Sort(gresult.GeometryTypes)
+	gresult.BBox = bbox.slice()
+	if n := len(explicitBBox); n == 4 || n == 6 {
+		gresult.BBox = []float64{explicitBBox[0], explicitBBox[1], explicitBBox[n/2], explicitBBox[n/2+1]}
+	}
+	// rfc 7946 uses wgs 84 longitude, latitude. the crs member is from the 2008 specification
+	gresult.CRS = "OGC:CRS84"
+	if crs != nil && crs.Properties.Name != "" {
+		gresult.CRS = crs.Properties.Name
+		if found := geoURNRegexp.FindStringSubmatch(crs.Properties.Name); found != nil {
+			gresult.CRS = strings.ToUpper(found[1]) + ":" + found[2]
+		}
+	}
+	return gresult, nil
+}
+
+func (ag *ActionGeo) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
+	filename, err := ag.server.fm.Get(uri)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "no file url")
+	}
+	result, err := ag.DoV2(filename)
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+	if result == nil {
+		return nil, nil, nil, ErrMimeNotApplicable
+	}
+	return result.Metadata[ag.GetName()], result.Mimetypes, nil, nil
+}
+
+var (
+	_ Action = (*ActionGeo)(nil)
+)
@@ -0,0 +1,157 @@
+package indexer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/je4/indexer/v3/pkg/tiff"
+)
+
+func testTIFFDoubles(values ...float64) []uint32 {
+	var result []uint32
+	for _, v := range values {
+		bits := math.Float64bits(v)
+		result = append(result, uint32(bits), uint32(bits>>32))
+	}
+	return result
+}
+
+// buildTestShapefile creates a point shapefile
+func buildTestShapefile(points [][2]float64) []byte {
+	var records bytes.Buffer
+	for i, p := range points {
+		_ = binary.Write(&records, binary.BigEndian, []uint32{uint32(i + 1), 10})
+		_ = binary.Write(&records, binary.LittleEndian, uint32(1))
+		_ = binary.Write(&records, binary.LittleEndian, p[:])
+	}
+	head := make([]byte, 100)
+	binary.BigEndian.PutUint32(head, 9994)
+	binary.BigEndian.PutUint32(head[24:], uint32(100+records.Len())/2)
+	binary.LittleEndian.PutUint32(head[28:], 1000)
+	binary.LittleEndian.PutUint32(head[32:], 1)
+	for i, v := range []float64{7.5, 46.9, 7.6, 47.6} {
+		binary.LittleEndian.PutUint64(head[36+8*i:], math.Float64bits(v))
+	}
+	return append(head, records.Bytes()...)
+}
+
+func checkGeoResult(t *testing.T, result *ResultV2, format, crs string, bbox []float64, features int64, geometryTypes []string) *GeoResult {
+	t.Helper()
+	if result == nil {
+		t.Fatalf("%s not detected", format)
+	}
+	gr := result.Metadata[NameGeo].(*GeoResult)
+	if gr.Format != format || gr.CRS != crs || gr.Features != features || gr.Error != "" {
+		t.Errorf("wrong %s result: %+v", format, gr)
+	}
+	if !slices.Equal(gr.BBox, bbox) || !slices.Equal(gr.GeometryTypes, geometryTypes) {
+		t.Errorf("wrong %s extent: %v %v", format, gr.BBox, gr.GeometryTypes)
+	}
+	return gr
+}
+
+func TestActionGeo(t *testing.T) {
+	ad := NewActionDispatcher(nil)
+	ag := NewActionGeo(NameGeo, t.TempDir(), nil, ad)
+
+	// geotiff with tiepoint and pixel scale in swiss lv95
+	entries := append(testTIFFEntries([]uint32{8}, []uint32{12}, 2),
+		testTIFFEntry{tiff.TagModelPixelScale, tiff.TypeDouble, testTIFFDoubles(10, 10, 0)},
+		testTIFFEntry{tiff.TagModelTiepoint, tiff.TypeDouble, testTIFFDoubles(0, 0, 0, 2600000, 1200000, 0)},
+		testTIFFEntry{tiff.TagGeoKeyDirectory, tiff.TypeShort, []uint32{1, 1, 0, 3, 1024, 0, 1, 1, 1025, 0, 1, 1, 3072, 0, 1, 2056}},
+	)
+	result, err := ag.Stream("", bytes.NewReader(buildTestTIFF(entries, make([]byte, 12))), "test.tif")
+	if err != nil {
+		t.Fatalf("cannot analyze geotiff: %v", err)
+	}
+	gr := checkGeoResult(t, result, "geotiff", "EPSG:2056", []float64{2600000, 1199980, 2600020, 1200000}, 0, nil)
+	if gr.Rasters != 1 || gr.GeoKeys["GTModelType"] != "1" {
+		t.Errorf("wrong geotiff result: %+v", gr)
+	}
+	// plain tiff
+	result, err = ag.Stream("", bytes.NewReader(buildTestTIFF(testTIFFEntries([]uint32{8}, []uint32{12}, 2), make([]byte, 12))), "test.tif")
+	if err != nil || result != nil {
+		t.Errorf("plain tiff: %v, %v", result, err)
+	}
+
+	// shapefile with projection sidecar
+	dir := t.TempDir()
+	shp := filepath.Join(dir, "points.shp")
+	if err := os.WriteFile(shp, buildTestShapefile([][2]float64{{7.5, 47.6}, {7.6, 46.9}}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wkt := `GEOGCS["GCS_WGS_1984",DATUM["D_WGS_1984",SPHEROID["WGS_1984",6378137,298.257223563]],PRIMEM["Greenwich",0],UNIT["Degree",0.0174532925199433],AUTHORITY["EPSG","4326"]]`
+	if err := os.WriteFile(filepath.Join(dir, "points.prj"), []byte(wkt), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err = ag.DoV2(shp)
+	if err != nil {
+		t.Fatalf("cannot analyze shapefile: %v", err)
+	}
+	gr = checkGeoResult(t, result, "shapefile", "EPSG:4326", []float64{7.5, 46.9, 7.6, 47.6}, 2, []string{"Point"})
+	if gr.CRSName != "GCS_WGS_1984" {
+		t.Errorf("wrong crs name '%s'", gr.CRSName)
+	}
+
+	// geopackage
+	data, err := os.ReadFile("testdata/test.gpkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = ag.Stream("", bytes.NewReader(data), "test.gpkg")
+	if err != nil {
+		t.Fatalf("cannot analyze geopackage: %v", err)
+	}
+	gr = checkGeoResult(t, result, "geopackage", "EPSG:4326", []float64{5.9, 45.8, 10.5, 47.8}, 4, []string{"LINESTRING", "POINT"})
+	if len(gr.Layers) != 2 || gr.Layers[0].Name != "cities" || gr.Layers[0].Count != 3 || gr.CRSName != "WGS 84 geodetic" || result.Mimetype != "application/geopackage+sqlite3" {
+		t.Errorf("wrong geopackage result: %+v, %+v", gr, gr.Layers[0])
+	}
+
+	// plain tiff and sqlite are rejected before spooling
+	noSpool := NewActionGeo(NameGeo, filepath.Join(t.TempDir(), "missing"), nil, NewActionDispatcher(nil))
+	plainDB := slices.Clone(data)
+	binary.BigEndian.PutUint32(plainDB[68:], 0)
+	for name, data := range map[string][]byte{
+		"test.tif": buildTestTIFF(testTIFFEntries([]uint32{8}, []uint32{12}, 2), make([]byte, 12)),
+		"test.db":  plainDB,
+	} {
+		if result, err := noSpool.Stream("", bytes.NewReader(data), name); err != nil || result != nil {
+			t.Errorf("%s: %v, %v", name, result, err)
+		}
+	}
+	if _, err := noSpool.Stream("", bytes.NewReader(data), "test.gpkg"); err == nil {
+		t.Errorf("geopackage not spooled")
+	}
+
+	// geojson
+	geojson := `{"type": "FeatureCollection", "name": "test", "features": [
+		{"type": "Feature", "properties": {"name": "Basel"}, "geometry": {"type": "Point", "coordinates": [7.59, 47.56]}},
+		{"type": "Feature", "properties": {}, "geometry": {"type": "GeometryCollection", "geometries": [
+			{"type": "LineString", "coordinates": [[7.44, 46.95], [8.54, 47.37]]}]}},
+		{"type": "Feature", "properties": {}, "geometry": null}
+	]}`
+	result, err = ag.Stream("", strings.NewReader(geojson), "test.geojson")
+	if err != nil {
+		t.Fatalf("cannot analyze geojson: %v", err)
+	}
+	checkGeoResult(t, result, "geojson", "OGC:CRS84", []float64{7.44, 46.95, 8.54, 47.56}, 3, []string{"GeometryCollection", "LineString", "Point"})
+
+	geojson = `{"crs": {"type": "name", "properties": {"name": "urn:ogc:def:crs:EPSG::2056"}}, "type": "Polygon", "coordinates": [[[2600000, 1200000], [2601000, 1200000], [2600000, 1201000], [2600000, 1200000]]]}`
+	result, err = ag.Stream("", strings.NewReader(geojson), "test.json")
+	if err != nil {
+		t.Fatalf("cannot analyze geojson: %v", err)
+	}
+	checkGeoResult(t, result, "geojson", "EPSG:2056", []float64{2600000, 1200000, 2601000, 1201000}, 0, []string{"Polygon"})
+
+	// other json
+	result, err = ag.Stream("", strings.NewReader(`{"name": "test", "type": "module"}`), "package.json")
+	if err != nil || result != nil {
+		t.Errorf("no geojson: %v, %v", result, err)
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indexer
+
+import (
+	"emperror.dev/errors"
+	badger "github.com/dgraph-io/badger/v4"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HashSetMatch is a hit of a file digest in a named hash set
+type HashSetMatch struct {
+	Set      string              `json:"set"`
+	Digest   string              `json:"digest"`
+	Checksum string              `json:"checksum"`
+	Labels   []map[string]string `json:"labels,omitempty"`
+}
+
+type HashSetResult struct {
+	Matched bool            `json:"matched"`
+	Sets    []string        `json:"sets,omitempty"`
+	Matches []*HashSetMatch `json:"matches,omitempty"`
+}
+
+type hashSet struct {
+	name    string
+	db      *badger.DB
+	digests []string
+}
+
+// ActionHashSet matches file digests against named hash sets.
+// the sets are badger databases with the key layout of nsrl2badger ("<digest>-<checksum>")
+type ActionHashSet struct {
+	name    string
+	server  *Server
+	sets    []*hashSet
+	digests []string
+}
+
+func (ah *ActionHashSet) CanHandle(contentType string, filename string) bool {
+	return true
+}
+
+func NewActionHashSet(name string, sets map[string]*badger.DB, server *Server, ad *ActionDispatcher) Action {
+	ah := &ActionHashSet{name: name, server: server}
+	for setName, db := range sets {
+		hs := &hashSet{name: setName, db: db, digests: badgerDigests(db)}
+		ah.sets = append(ah.sets, hs)
+		for _, digest := range hs.digests {
+			if !slices.Contains(ah.digests, digest) {
+				ah.digests = append(ah.digests, digest)
+			}
+		}
+	}
+	sort.Slice(ah.sets, func(i, j int) bool {
+		return ah.sets[i].name < ah.sets[j].name
+	})
+	ad.RegisterAction(ah)
+	return ah
+}
+
+func (ah *ActionHashSet) GetWeight() uint {
+	return 100
+}
+
+func (ah *ActionHashSet) GetCaps() ActionCapability {
+	return ACTFILEFULL | ACTSTREAM
+}
+
+func (ah *ActionHashSet) GetName() string {
+	return ah.name
+}
+
+func (ah *ActionHashSet) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
+	checksums, err := digestStream(reader, ah.digests)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read '%s'", filename)
+	}
+	hresult, err := ah.lookup(checksums)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot lookup '%s'", filename)
+	}
+	var result = NewResultV2()
+	result.Metadata[ah.GetName()] = hresult
+	return result, nil
+}
+
+// lookup checks the checksums against all sets. the keys of checksums
+// are normalized with normalizeNSRLDigest
+func (ah *ActionHashSet) lookup(checksums map[string]string) (*HashSetResult, error) {
+	var sums = map[string]string{}
+	for name, val := range checksums {
+		if digest := normalizeNSRLDigest(name); digest != "" {
+			sums[digest] = strings.ToUpper(val)
+		}
+	}
+	var hresult = &HashSetResult{}
+	for _, hs := range ah.sets {
+		for _, digest := range hs.digests {
+			sum, ok := sums[digest]
+			if !ok {
+				continue
+			}
+			var labels []map[string]string
+			if err := hs.db.View(func(txn *badger.Txn) error {
+				var err error
+				labels, err = getStringMap(txn, digest+"-"+sum)
+				return err
+			}); err != nil {
+				return nil, errors.Wrapf(err, "cannot query hash set '%s'", hs.name)
+			}
+			if labels == nil {
+				continue
+			}
+			hresult.Matched = true
+			hresult.Sets = append(hresult.Sets, hs.name)
+			hresult.Matches = append(hresult.Matches, &HashSetMatch{
+				Set:      hs.name,
+				Digest:   digest,
+				Checksum: sum,
+				Labels:   labels,
+			})
+			// one match per set is enough
+			break
+		}
+	}
+	return hresult, nil
+}
+
+func (ah *ActionHashSet) DoV2(filename string) (*ResultV2, error) {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open file '%s'", filename)
+	}
+	defer fp.Close()
+	return ah.Stream("", fp, filename)
+}
+
+func (ah *ActionHashSet) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
+	// supplied checksums are used if they cover all digests of the sets
+	var covered = 0
+	for _, digest := range ah.digests {
+		for name := range checksums {
+			if normalizeNSRLDigest(name) == digest {
+				covered++
+				break
+			}
+		}
+	}
+	if covered > 0 && covered == len(ah.digests) {
+		hresult, err := ah.lookup(checksums)
+		if err != nil {
+			return nil, nil, nil, errors.WithStack(err)
+		}
+		return hresult, nil, nil, nil
+	}
+	filename, err := ah.server.fm.Get(uri)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "no file url")
+	}
+	result, err := ah.DoV2(filename)
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+	return result.Metadata[ah.GetName()], nil, nil, nil
+}
+
+var (
+	_ Action = &ActionHashSet{}
+)
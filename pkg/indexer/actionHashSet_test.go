@@ -0,0 +1,83 @@
+package indexer
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/golang/snappy"
+)
+
+func hashSetTestDB(t *testing.T, entries map[string]map[string]string) *badger.DB {
+	bconfig := badger.DefaultOptions(t.TempDir())
+	bconfig.Logger = nil
+	db, err := badger.Open(bconfig)
+	if err != nil {
+		t.Fatalf("cannot open badger: %v", err)
+	}
+	if err := db.Update(func(txn *badger.Txn) error {
+		for key, val := range entries {
+			data, _ := json.Marshal([]map[string]string{val})
+			if err := txn.Set([]byte(key), snappy.Encode(nil, data)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("cannot write badger: %v", err)
+	}
+	return db
+}
+
+func TestActionHashSet(t *testing.T) {
+	data := []byte("0123456789")
+	sha := sha256.Sum256(data)
+	md := md5.Sum(data)
+	malware := hashSetTestDB(t, map[string]map[string]string{
+		"SHA-256-" + strings.ToUpper(hex.EncodeToString(sha[:])): {"name": "sample", "family": "test"},
+	})
+	defer malware.Close()
+	deaccessioned := hashSetTestDB(t, map[string]map[string]string{
+		"MD5-" + strings.ToUpper(hex.EncodeToString(md[:])): {"signature": "A-123"},
+	})
+	defer deaccessioned.Close()
+	other := hashSetTestDB(t, map[string]map[string]string{
+		"MD5-00000000000000000000000000000000": {"collection": "other"},
+	})
+	defer other.Close()
+
+	ad := NewActionDispatcher(nil)
+	ah := NewActionHashSet(NameHashSet, map[string]*badger.DB{
+		"malware":       malware,
+		"deaccessioned": deaccessioned,
+		"other":         other,
+	}, nil, ad)
+
+	result, err := ah.Stream("", bytes.NewReader(data), "test.txt")
+	if err != nil {
+		t.Fatalf("cannot stream: %v", err)
+	}
+	hresult := result.Metadata[NameHashSet].(*HashSetResult)
+	if !hresult.Matched || strings.Join(hresult.Sets, ",") != "deaccessioned,malware" {
+		t.Fatalf("wrong matches: %+v", hresult)
+	}
+	if hresult.Matches[0].Digest != "MD5" || hresult.Matches[0].Labels[0]["signature"] != "A-123" {
+		t.Errorf("wrong deaccessioned match: %+v", hresult.Matches[0])
+	}
+	if hresult.Matches[1].Digest != "SHA-256" || hresult.Matches[1].Labels[0]["family"] != "test" {
+		t.Errorf("wrong malware match: %+v", hresult.Matches[1])
+	}
+
+	result, err = ah.Stream("", bytes.NewReader([]byte("unknown")), "unknown.txt")
+	if err != nil {
+		t.Fatalf("cannot stream: %v", err)
+	}
+	if hresult := result.Metadata[NameHashSet].(*HashSetResult); hresult.Matched {
+		t.Errorf("unknown file matched: %+v", hresult)
+	}
+}
@@ -43,6 +43,8 @@ type ActionIdentifyV2 struct {
 	server       *Server
 	mimeMap      map[string]string
 	extensionMap map[*regexp.Regexp]string
+	validate     bool
+	policyDir    string
 }
 
 func (ai *ActionIdentifyV2) CanHandle(contentType string, filename string) bool {
@@ -97,29 +99,31 @@ func NewActionIdentifyV2(name, identify, convert string, wsl bool, timeout time.
 	return ai
 }
 
-func (ai *ActionIdentifyV2) GetWeight() uint {
-	return 50
-}
-
-func (ai *ActionIdentifyV2) GetCaps() ActionCapability {
-	return ACTFILEHEAD | ACTSTREAM
+// SetValidation enables the validation mode. decoder messages, dimensions, uniform images and profiles
+// are reported. if policy is not nil, the resource limits are applied with a generated policy.xml
+func (ai *ActionIdentifyV2) SetValidation(policy *MagickPolicy, tempDir string) error {
+	ai.validate = true
+	if policy == nil {
+		return nil
+	}
+	dir, err := policy.writePolicy(tempDir)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	ai.policyDir = dir
+	return nil
 }
 
-func (ai *ActionIdentifyV2) GetName() string {
-	return ai.name
+// Close removes the generated policy
+func (ai *ActionIdentifyV2) Close() error {
+	if ai.policyDir == "" {
+		return nil
+	}
+	return errors.WithStack(os.RemoveAll(ai.policyDir))
 }
 
-func (ai *ActionIdentifyV2) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
-	if slices.Contains([]string{"audio", "video", "pdf"}, contentType) {
-		return nil, nil
-	}
-	infile := "-"
-	for re, t := range ai.extensionMap {
-		if re.MatchString(filename) {
-			infile = t + ":-"
-			break
-		}
-	}
+// convertJSON runs convert infile json:- and adds the validation on demand
+func (ai *ActionIdentifyV2) convertJSON(infile string, stdin io.Reader, filename string) (*ResultV2, *FullMagickResult, error) {
 	cmdparam := []string{infile, "json:-"}
 	cmdfile := ai.convert
 	if ai.wsl {
@@ -127,36 +131,54 @@ func (ai *ActionIdentifyV2) Stream(contentType string, reader io.Reader, filenam
 		cmdfile = "wsl"
 	}
 
-	var out bytes.Buffer
+	var out, stderr bytes.Buffer
 	out.Grow(1024 * 1024) // 1MB size
 	ctx, cancel := context.WithTimeout(context.Background(), ai.timeout)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, cmdfile, cmdparam...)
-	cmd.Stdin = reader
+	cmd.Stdin = stdin
 	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if ai.policyDir != "" {
+		policyDir := ai.policyDir
+		if ai.wsl {
+			policyDir = pathToWSL(policyDir)
+		}
+		cmd.Env = append(os.Environ(), "MAGICK_CONFIGURE_PATH="+policyDir, "WSLENV=MAGICK_CONFIGURE_PATH")
+	}
 
-	if err := cmd.Run(); err != nil {
-		return nil, errors.Wrapf(err, "error executing (%s %s) for file '%s': %v", cmdfile, cmdparam, filename, out.String())
+	runErr := cmd.Run()
+	if runErr != nil && !ai.validate {
+		return nil, nil, errors.Wrapf(runErr, "error executing (%s %s) for file '%s': %v", cmdfile, cmdparam, filename, stderr.String())
 	}
 
 	var meta = []*MagickResult{}
-	if err := json.Unmarshal([]byte(out.String()), &meta); err != nil {
-		return nil, errors.Wrapf(err, "cannot unmarshall metadata: %s", out.String())
+	if out.Len() > 0 || !ai.validate {
+		if err := json.Unmarshal(out.Bytes(), &meta); err != nil && !ai.validate {
+			return nil, nil, errors.Wrapf(err, "cannot unmarshall metadata: %s", out.String())
+		}
 	}
-	if len(meta) == 0 {
-		return nil, errors.New("no metadata from imagemagick found")
+	if len(meta) == 0 && !ai.validate {
+		return nil, nil, errors.New("no metadata from imagemagick found")
 	}
 
-	var metadata = FullMagickResult{
+	var metadata = &FullMagickResult{
 		Frames: []*Geometry{},
 	}
+	if ai.validate {
+		metadata.Validation = newMagickValidation(meta, stderr.Bytes(), runErr)
+	}
+	var result = NewResultV2()
+	if len(meta) == 0 {
+		result.Metadata[ai.GetName()] = *metadata
+		return result, metadata, nil
+	}
 
 	metadata.Magick = meta[0]
 	if metadata.Magick.Image != nil {
 		metadata.Magick.Image.Name = filename
 	}
-	var result = NewResultV2()
 	mimetypes := []string{}
 	for _, m := range meta {
 		if m.Image == nil {
@@ -177,7 +199,40 @@ func (ai *ActionIdentifyV2) Stream(contentType string, reader io.Reader, filenam
 	}
 	slices.Sort(mimetypes)
 	result.Mimetypes = slices.Compact(mimetypes)
-	result.Metadata[ai.GetName()] = metadata
+	result.Metadata[ai.GetName()] = *metadata
+	return result, metadata, nil
+}
+
+func (ai *ActionIdentifyV2) GetWeight() uint {
+	return 50
+}
+
+func (ai *ActionIdentifyV2) GetCaps() ActionCapability {
+	return ACTFILEHEAD | ACTSTREAM
+}
+
+func (ai *ActionIdentifyV2) GetName() string {
+	return ai.name
+}
+
+func (ai *ActionIdentifyV2) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
+	if slices.Contains([]string{"audio", "video", "pdf"}, contentType) {
+		return nil, nil
+	}
+	infile := "-"
+	for re, t := range ai.extensionMap {
+		if re.MatchString(filename) {
+			infile = t + ":-"
+			break
+		}
+	}
+	result, metadata, err := ai.convertJSON(infile, reader, filename)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if metadata.Magick == nil || metadata.Magick.Image == nil {
+		return result, nil
+	}
 	result.Type = "image"
 	result.Subtype = metadata.Magick.Image.Format
 	if result.Subtype == "PDF" {
@@ -195,64 +250,10 @@ func (ai *ActionIdentifyV2) DoV2(filename string) (*ResultV2, error) {
 			break
 		}
 	}
-	cmdparam := []string{infile, "json:-"}
-	cmdfile := ai.convert
-	if ai.wsl {
-		cmdparam = append([]string{cmdfile}, cmdparam...)
-		cmdfile = "wsl"
-	}
-
-	var out bytes.Buffer
-	out.Grow(1024 * 1024) // 1MB size
-	ctx, cancel := context.WithTimeout(context.Background(), ai.timeout)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, cmdfile, cmdparam...)
-	cmd.Stdout = &out
-
-	if err := cmd.Run(); err != nil {
-		return nil, errors.Wrapf(err, "error executing (%s %s) for file '%s': %v", cmdfile, cmdparam, filename, out.String())
-	}
-
-	var meta = []*MagickResult{}
-	if err := json.Unmarshal([]byte(out.String()), &meta); err != nil {
-		return nil, errors.Wrapf(err, "cannot unmarshall metadata: %s", out.String())
-	}
-	if len(meta) == 0 {
-		return nil, errors.New("no metadata from imagemagick found")
-	}
-
-	var metadata = FullMagickResult{
-		Frames: []*Geometry{},
-	}
-
-	metadata.Magick = meta[0]
-	if metadata.Magick.Image != nil {
-		metadata.Magick.Image.Name = filename
-	}
-	var result = NewResultV2()
-	mimetypes := []string{}
-	for _, m := range meta {
-		if m.Image == nil {
-			continue
-		}
-		if m.Image.MimeType != "" {
-			mimetypes = append(mimetypes, m.Image.MimeType)
-		}
-		if m.Image.Geometry != nil {
-			metadata.Frames = append(metadata.Frames, m.Image.Geometry)
-			if uint(m.Image.Geometry.Width+m.Image.Geometry.X) > result.Width {
-				result.Width = uint(m.Image.Geometry.Width + m.Image.Geometry.X)
-			}
-			if uint(m.Image.Geometry.Height+m.Image.Geometry.Y) > result.Height {
-				result.Height = uint(m.Image.Geometry.Height + m.Image.Geometry.Y)
-			}
-		}
+	result, _, err := ai.convertJSON(infile, nil, filename)
+	if err != nil {
+		return nil, errors.WithStack(err)
 	}
-	slices.Sort(mimetypes)
-	result.Mimetypes = slices.Compact(mimetypes)
-	result.Metadata[ai.GetName()] = metadata
-
 	return result, nil
 }
 
@@ -353,5 +354,6 @@ func (ai *ActionIdentifyV2) Do(uri *url.URL, contentType string, width *uint, he
 }
 
 var (
-	_ Action = (*ActionIdentifyV2)(nil)
+	_ Action    = (*ActionIdentifyV2)(nil)
+	_ io.Closer = (*ActionIdentifyV2)(nil)
 )
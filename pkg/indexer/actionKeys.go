@@ -0,0 +1,635 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indexer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/dsa"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"emperror.dev/errors"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"golang.org/x/crypto/pkcs12"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxKeyFileSize limits the data, which is read for key material
+const maxKeyFileSize = 1 << 20
+
+// KeyObject is a certificate, key or openpgp packet sequence
+type KeyObject struct {
+	Type        string     `json:"type"`     // certificate, csr, crl, private key, public key, pkcs12, pgp public key, pgp private key, pgp signature, pgp message
+	Encoding    string     `json:"encoding"` // pem, der, openssh, armor or binary
+	Subject     string     `json:"subject,omitempty"`
+	Issuer      string     `json:"issuer,omitempty"`
+	Serial      string     `json:"serial,omitempty"`
+	NotBefore   *time.Time `json:"notbefore,omitempty"`
+	NotAfter    *time.Time `json:"notafter,omitempty"`
+	KeyType     string     `json:"keytype,omitempty"` // i.e. RSA-2048, ECDSA-P-256, Ed25519
+	Fingerprint string     `json:"fingerprint,omitempty"`
+	UserIDs     []string   `json:"userids,omitempty"`
+	Private     bool       `json:"private,omitempty"`
+	Encrypted   bool       `json:"encrypted,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+type KeysResult struct {
+	Objects []*KeyObject `json:"objects"`
+	// Sensitive is set, if private key material was found. encrypted keys are sensitive as well
+	Sensitive bool `json:"sensitive"`
+}
+
+type ActionKeys struct {
+	name   string
+	server *Server
+}
+
+func (ak *ActionKeys) CanHandle(contentType string, filename string) bool {
+	return true
+}
+
+func NewActionKeys(name string, server *Server, ad *ActionDispatcher) Action {
+	ak := &ActionKeys{name: name, server: server}
+	ad.RegisterAction(ak)
+	return ak
+}
+
+func (ak *ActionKeys) GetWeight() uint {
+	return 20
+}
+
+func (ak *ActionKeys) GetCaps() ActionCapability {
+	return ACTFILEFULL | ACTSTREAM
+}
+
+func (ak *ActionKeys) GetName() string {
+	return ak.name
+}
+
+var (
+	pemBegin      = []byte("-----BEGIN ")
+	pgpArmorBegin = []byte("-----BEGIN PGP ")
+	sshPublicKeys = []string{"ssh-rsa ", "ssh-dss ", "ssh-ed25519 ", "ecdsa-sha2-", "sk-ssh-ed25519@openssh.com ", "sk-ecdsa-sha2-"}
+)
+
+func hasSSHPublicKey(head []byte) bool {
+	for _, prefix := range sshPublicKeys {
+		if bytes.HasPrefix(head, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyDescription returns algorithm and size of a public or private key
+func keyDescription(key any) string {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA-%d", k.N.BitLen())
+	case *rsa.PrivateKey:
+		return fmt.Sprintf("RSA-%d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA-" + k.Curve.Params().Name
+	case *ecdsa.PrivateKey:
+		return "ECDSA-" + k.Curve.Params().Name
+	case ed25519.PublicKey, ed25519.PrivateKey, *ed25519.PrivateKey:
+		return "Ed25519"
+	case *ecdh.PublicKey:
+		return fmt.Sprintf("ECDH-%v", k.Curve())
+	case *ecdh.PrivateKey:
+		return fmt.Sprintf("ECDH-%v", k.Curve())
+	case *dsa.PublicKey:
+		return fmt.Sprintf("DSA-%d", k.P.BitLen())
+	case *dsa.PrivateKey:
+		return fmt.Sprintf("DSA-%d", k.P.BitLen())
+	case nil:
+		return ""
+	}
+	return fmt.Sprintf("%T", key)
+}
+
+func (ak *ActionKeys) certificate(der []byte, encoding string) *KeyObject {
+	obj := &KeyObject{Type: "certificate", Encoding: encoding}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		obj.Error = err.Error()
+		return obj
+	}
+	obj.Subject = cert.Subject.String()
+	obj.Issuer = cert.Issuer.String()
+	obj.Serial = cert.SerialNumber.Text(16)
+	obj.NotBefore = &cert.NotBefore
+	obj.NotAfter = &cert.NotAfter
+	obj.KeyType = keyDescription(cert.PublicKey)
+	sum := sha256.Sum256(cert.Raw)
+	obj.Fingerprint = "SHA256:" + hex.EncodeToString(sum[:])
+	return obj
+}
+
+// pemObject interprets a pem block
+func (ak *ActionKeys) pemObject(block *pem.Block) *KeyObject {
+	var err error
+	var key any
+	obj := &KeyObject{Encoding: "pem"}
+	switch block.Type {
+	case "CERTIFICATE", "X509 CERTIFICATE", "TRUSTED CERTIFICATE":
+		return ak.certificate(block.Bytes, "pem")
+	case "CERTIFICATE REQUEST", "NEW CERTIFICATE REQUEST":
+		obj.Type = "csr"
+		var csr *x509.CertificateRequest
+		if csr, err = x509.ParseCertificateRequest(block.Bytes); err == nil {
+			obj.Subject = csr.Subject.String()
+			key = csr.PublicKey
+		}
+	case "X509 CRL":
+		obj.Type = "crl"
+		var crl *x509.RevocationList
+		if crl, err = x509.ParseRevocationList(block.Bytes); err == nil {
+			obj.Issuer = crl.Issuer.String()
+			obj.NotBefore = &crl.ThisUpdate
+			obj.NotAfter = &crl.NextUpdate
+		}
+	case "PRIVATE KEY":
+		obj.Type, obj.Private = "private key", true
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "ENCRYPTED PRIVATE KEY":
+		obj.Type, obj.Private, obj.Encrypted = "private key", true, true
+	case "RSA PRIVATE KEY", "EC PRIVATE KEY", "DSA PRIVATE KEY":
+		obj.Type, obj.Private = "private key", true
+		// legacy openssl encryption
+		if strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED") {
+			obj.Encrypted = true
+			obj.KeyType = strings.TrimSuffix(block.Type, " PRIVATE KEY")
+			break
+		}
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		default:
+			obj.KeyType = "DSA"
+		}
+	case "OPENSSH PRIVATE KEY":
+		obj.Type, obj.Private, obj.Encoding = "private key", true, "openssh"
+		key, err = ssh.ParseRawPrivateKey(pem.EncodeToMemory(block))
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			obj.Encrypted, err = true, nil
+			if missing.PublicKey != nil {
+				obj.Fingerprint = ssh.FingerprintSHA256(missing.PublicKey)
+				if cpk, ok := missing.PublicKey.(ssh.CryptoPublicKey); ok {
+					key = cpk.CryptoPublicKey()
+				}
+			}
+		}
+	case "PUBLIC KEY":
+		obj.Type = "public key"
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		obj.Type = "public key"
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil
+	}
+	if err != nil {
+		obj.Error = err.Error()
+		return obj
+	}
+	if obj.KeyType == "" {
+		obj.KeyType = keyDescription(key)
+	}
+	return obj
+}
+
+// derObject tries the der structures. everything else is ignored
+func (ak *ActionKeys) derObject(der []byte) []*KeyObject {
+	if _, err := x509.ParseCertificate(der); err == nil {
+		return []*KeyObject{ak.certificate(der, "der")}
+	}
+	for _, typ := range []string{"CERTIFICATE REQUEST", "X509 CRL", "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY", "PUBLIC KEY"} {
+		if obj := ak.pemObject(&pem.Block{Type: typ, Bytes: der}); obj != nil && obj.Error == "" {
+			obj.Encoding = "der"
+			return []*KeyObject{obj}
+		}
+	}
+	return ak.pkcs12(der)
+}
+
+// pkcs12 recognizes the pfx structure and decodes the content, if there is no password
+func (ak *ActionKeys) pkcs12(der []byte) []*KeyObject {
+	var pfx struct {
+		Version  int
+		AuthSafe struct {
+			ContentType asn1.ObjectIdentifier
+			Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+		}
+		MacData asn1.RawValue `asn1:"optional"`
+	}
+	if rest, err := asn1.Unmarshal(der, &pfx); err != nil || len(rest) > 0 || pfx.Version != 3 ||
+		!pfx.AuthSafe.ContentType.Equal(asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}) {
+		return nil
+	}
+	// a pkcs#12 container is regarded as private, because it usually holds the key of its certificate
+	obj := &KeyObject{Type: "pkcs12", Encoding: "der", Private: true}
+	blocks, err := pkcs12.ToPEM(der, "")
+	if err != nil {
+		if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+			obj.Encrypted = true
+		} else {
+			obj.Error = err.Error()
+		}
+		return []*KeyObject{obj}
+	}
+	var objects = []*KeyObject{obj}
+	for _, block := range blocks {
+		if content := ak.pemObject(block); content != nil {
+			content.Encoding = "pkcs12"
+			objects = append(objects, content)
+		}
+	}
+	return objects
+}
+
+// pgpNextPacket returns tag and body of the first openpgp packet and the remaining data
+func pgpNextPacket(data []byte) (byte, []byte, []byte, bool) {
+	if len(data) < 2 || data[0]&0x80 == 0 {
+		return 0, nil, nil, false
+	}
+	var tag byte
+	var length, offset int
+	if data[0]&0x40 != 0 {
+		tag = data[0] & 0x3f
+		switch l := int(data[1]); {
+		case l < 192:
+			length, offset = l, 2
+		case l < 224:
+			if len(data) < 3 {
+				return 0, nil, nil, false
+			}
+			length, offset = (l-192)<<8+int(data[2])+192, 3
+		case l == 255:
+			if len(data) < 6 {
+				return 0, nil, nil, false
+			}
+			length, offset = int(binary.BigEndian.Uint32(data[2:])), 6
+		default:
+			// partial body lengths are only used for data packets
+			length, offset = 1<<(l&0x1f), 2
+		}
+	} else {
+		tag = (data[0] >> 2) & 0x0f
+		switch data[0] & 0x03 {
+		case 0:
+			length, offset = int(data[1]), 2
+		case 1:
+			if len(data) < 3 {
+				return 0, nil, nil, false
+			}
+			length, offset = int(binary.BigEndian.Uint16(data[1:])), 3
+		case 2:
+			if len(data) < 5 {
+				return 0, nil, nil, false
+			}
+			length, offset = int(binary.BigEndian.Uint32(data[1:])), 5
+		default:
+			length, offset = len(data)-1, 1
+		}
+	}
+	if length < 0 || offset+length > len(data) {
+		return tag, data[offset:], nil, true
+	}
+	return tag, data[offset : offset+length], data[offset+length:], true
+}
+
+var pgpAlgorithms = map[byte]string{
+	1: "RSA", 2: "RSA", 3: "RSA", 16: "Elgamal", 17: "DSA", 18: "ECDH", 19: "ECDSA", 22: "EdDSA",
+	25: "X25519", 26: "X448", 27: "Ed25519", 28: "Ed448",
+}
+
+// pgpCurves are the curve oids of ecc keys
+var pgpCurves = map[string]string{
+	"2b06010401da470f01":   "Ed25519",
+	"2b060104019755010501": "Curve25519",
+	"2a8648ce3d030107":     "P-256",
+	"2b81040022":           "P-384",
+	"2b81040023":           "P-521",
+	"2b2403030208010107":   "brainpoolP256r1",
+	"2b240303020801010d":   "brainpoolP512r1",
+}
+
+// pgpMPIs is the number of multiprecision integers of the public key material
+var pgpMPIs = map[byte]int{1: 2, 2: 2, 3: 2, 16: 3, 17: 4, 18: 1, 19: 1, 22: 1}
+
+// pgpNativeKeySize is the public key size of the algorithms without mpi
+var pgpNativeKeySize = map[byte]int{25: 32, 26: 56, 27: 32, 28: 57}
+
+// pgpKey reads the public part of a key packet and the s2k usage of secret keys
+func pgpKey(obj *KeyObject, body []byte, secret bool) {
+	if len(body) < 6 {
+		obj.Error = "key packet too short"
+		return
+	}
+	version := body[0]
+	created := time.Unix(int64(binary.BigEndian.Uint32(body[1:])), 0).UTC()
+	obj.NotBefore = &created
+	pos := 5
+	if version <= 3 {
+		// validity in days
+		if days := binary.BigEndian.Uint16(body[5:]); days > 0 {
+			expires := created.AddDate(0, 0, int(days))
+			obj.NotAfter = &expires
+		}
+		pos += 2
+	}
+	if pos >= len(body) {
+		obj.Error = "key packet too short"
+		return
+	}
+	algorithm := body[pos]
+	pos++
+	obj.KeyType = pgpAlgorithms[algorithm]
+	if obj.KeyType == "" {
+		obj.KeyType = fmt.Sprintf("algorithm %d", algorithm)
+	}
+	end := -1
+	if version >= 5 {
+		// key material has an explicit length
+		if pos+4 <= len(body) {
+			end = pos + 4 + int(binary.BigEndian.Uint32(body[pos:]))
+			pos += 4
+		}
+	}
+	switch algorithm {
+	case 18, 19, 22:
+		if pos < len(body) && pos+1+int(body[pos]) <= len(body) {
+			oid := hex.EncodeToString(body[pos+1 : pos+1+int(body[pos])])
+			if curve, ok := pgpCurves[oid]; ok {
+				obj.KeyType += "-" + curve
+			}
+			pos += 1 + int(body[pos])
+		}
+	}
+	for i := 0; i < pgpMPIs[algorithm] && pos+2 <= len(body); i++ {
+		bits := int(binary.BigEndian.Uint16(body[pos:]))
+		if i == 0 && pgpMPIs[algorithm] > 1 {
+			obj.KeyType += fmt.Sprintf("-%d", bits)
+		}
+		pos += 2 + (bits+7)/8
+	}
+	if algorithm == 18 && pos < len(body) {
+		// kdf parameters
+		pos += 1 + int(body[pos])
+	}
+	pos += pgpNativeKeySize[algorithm]
+	if end >= 0 {
+		pos = end
+	}
+	if secret && pos < len(body) {
+		// s2k usage 0 means unprotected key material
+		obj.Encrypted = body[pos] != 0
+	}
+}
+
+// pgpObjects interprets a sequence of openpgp packets. a transferable key starts with a key packet
+func (ak *ActionKeys) pgpObjects(data []byte, encoding string) []*KeyObject {
+	var objects []*KeyObject
+	var current *KeyObject
+	for i := 0; i < 10000 && len(data) > 0; i++ {
+		tag, body, rest, ok := pgpNextPacket(data)
+		if !ok {
+			break
+		}
+		data = rest
+		switch tag {
+		case 5, 6:
+			current = &KeyObject{Type: "pgp public key", Encoding: encoding}
+			if tag == 5 {
+				current.Type, current.Private = "pgp private key", true
+			}
+			pgpKey(current, body, tag == 5)
+			objects = append(objects, current)
+		case 13:
+			if current != nil && len(current.UserIDs) < 100 {
+				current.UserIDs = append(current.UserIDs, string(body))
+			}
+		case 2:
+			if current == nil {
+				objects = append(objects, &KeyObject{Type: "pgp signature", Encoding: encoding})
+				return objects
+			}
+		case 1, 3, 8, 11:
+			if current == nil {
+				objects = append(objects, &KeyObject{Type: "pgp message", Encoding: encoding})
+				return objects
+			}
+		}
+	}
+	return objects
+}
+
+// pgpDearmor decodes the first armored block
+func pgpDearmor(data []byte) []byte {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), maxKeyFileSize)
+	var state int // 0 before begin, 1 headers, 2 data
+	var b64 strings.Builder
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch state {
+		case 0:
+			if strings.HasPrefix(line, string(pgpArmorBegin)) {
+				state = 1
+			}
+		case 1:
+			if line == "" {
+				state = 2
+			}
+		case 2:
+			if strings.HasPrefix(line, "=") || strings.HasPrefix(line, "-----") {
+				packets, _ := base64.StdEncoding.DecodeString(b64.String())
+				return packets
+			}
+			b64.WriteString(line)
+		}
+	}
+	return nil
+}
+
+func (ak *ActionKeys) analyze(reader io.Reader) (*ResultV2, error) {
+	br := bufio.NewReaderSize(reader, 4096)
+	head, _ := br.Peek(4096)
+	var objects []*KeyObject
+	var mimetype string
+	switch {
+	case bytes.Contains(head, pemBegin):
+		data, err := io.ReadAll(io.LimitReader(br, maxKeyFileSize))
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read pem data")
+		}
+		mimetype = "application/x-pem-file"
+		for rest := data; len(rest) > 0; {
+			start := bytes.Index(rest, pemBegin)
+			if start < 0 {
+				break
+			}
+			rest = rest[start:]
+			if bytes.HasPrefix(rest, pgpArmorBegin) {
+				if bytes.HasPrefix(rest, []byte("-----BEGIN PGP SIGNED MESSAGE-----")) {
+					// the signature follows the cleartext
+					rest = rest[len(pemBegin):]
+					continue
+				}
+				objects = append(objects, ak.pgpObjects(pgpDearmor(rest), "armor")...)
+				mimetype = "application/pgp-keys"
+				if end := bytes.Index(rest, []byte("-----END PGP ")); end >= 0 {
+					rest = rest[end+1:]
+					continue
+				}
+				break
+			}
+			block, next := pem.Decode(rest)
+			if block == nil {
+				rest = rest[len(pemBegin):]
+				continue
+			}
+			rest = next
+			if obj := ak.pemObject(block); obj != nil {
+				objects = append(objects, obj)
+			}
+		}
+		if len(objects) > 0 && objects[0].Type == "pgp signature" {
+			mimetype = "application/pgp-signature"
+		}
+	case hasSSHPublicKey(head):
+		data, err := io.ReadAll(io.LimitReader(br, maxKeyFileSize))
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read ssh public keys")
+		}
+		for rest := data; len(rest) > 0; {
+			pub, comment, _, next, err := ssh.ParseAuthorizedKey(rest)
+			if err != nil {
+				break
+			}
+			obj := &KeyObject{Type: "public key", Encoding: "openssh", Subject: comment, Fingerprint: ssh.FingerprintSHA256(pub), KeyType: pub.Type()}
+			if cpk, ok := pub.(ssh.CryptoPublicKey); ok {
+				obj.KeyType = keyDescription(cpk.CryptoPublicKey())
+			}
+			objects = append(objects, obj)
+			rest = next
+		}
+	case len(head) >= 4 && head[0] == 0x30 && (head[1]&0x80 != 0 || int(head[1])+2 == len(head)):
+		// der sequence with long form length or a short structure like an ed25519 key
+		data, err := io.ReadAll(io.LimitReader(br, maxKeyFileSize))
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read der data")
+		}
+		objects = ak.derObject(data)
+		if len(objects) > 0 {
+			mimetype = map[string]string{
+				"certificate": "application/pkix-cert",
+				"csr":         "application/pkcs10",
+				"crl":         "application/pkix-crl",
+				"private key": "application/pkcs8",
+				"pkcs12":      "application/x-pkcs12",
+			}[objects[0].Type]
+		}
+	default:
+		// binary openpgp keys and detached signatures. messages are handled by the encryption action
+		tag, body, _, ok := pgpNextPacket(head)
+		if !ok || len(body) == 0 {
+			return nil, nil
+		}
+		switch {
+		case (tag == 5 || tag == 6) && body[0] >= 3 && body[0] <= 6:
+		case tag == 2 && body[0] == 4 && len(body) > 3 && body[1] <= 0x01 && pgpAlgorithms[body[2]] != "" && body[3] >= 1 && body[3] <= 14:
+			// v4 signature of a binary or text document
+		default:
+			return nil, nil
+		}
+		data, err := io.ReadAll(io.LimitReader(br, maxKeyFileSize))
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read openpgp data")
+		}
+		objects = ak.pgpObjects(data, "binary")
+		mimetype = "application/pgp-keys"
+		if len(objects) > 0 && objects[0].Type == "pgp signature" {
+			mimetype = "application/pgp-signature"
+		}
+	}
+	if len(objects) == 0 {
+		return nil, nil
+	}
+	kresult := &KeysResult{Objects: objects}
+	for _, obj := range objects {
+		if obj.Private {
+			kresult.Sensitive = true
+		}
+	}
+	var result = NewResultV2()
+	result.Sensitive = kresult.Sensitive
+	if mimetype != "" {
+		result.Mimetype = mimetype
+		result.Mimetypes = []string{mimetype}
+	}
+	result.Metadata[ak.GetName()] = kresult
+	return result, nil
+}
+
+func (ak *ActionKeys) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
+	return ak.analyze(reader)
+}
+
+func (ak *ActionKeys) DoV2(filename string) (*ResultV2, error) {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open file '%s'", filename)
+	}
+	defer fp.Close()
+	return ak.analyze(fp)
+}
+
+func (ak *ActionKeys) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
+	filename, err := ak.server.fm.Get(uri)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "no file url")
+	}
+	result, err := ak.DoV2(filename)
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+	if result == nil {
+		return nil, nil, nil, ErrMimeNotApplicable
+	}
+	return result.Metadata[ak.GetName()], result.Mimetypes, nil, nil
+}
+
+var (
+	_ Action = (*ActionKeys)(nil)
+)
@@ -0,0 +1,347 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indexer
+
+import (
+	"emperror.dev/errors"
+	"golang.org/x/net/html"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MarkupResource is an external resource, which is needed for rendering
+type MarkupResource struct {
+	Kind      string `json:"kind"` // image, font, stylesheet, script, media, frame, object, reference, link
+	Element   string `json:"element"`
+	Attribute string `json:"attribute,omitempty"`
+	URL       string `json:"url"`
+}
+
+type MarkupScript struct {
+	Inline bool   `json:"inline"`
+	Src    string `json:"src,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Size   int    `json:"size,omitempty"`
+}
+
+type MarkupResult struct {
+	Kind           string            `json:"kind"` // svg or html
+	Title          string            `json:"title,omitempty"`
+	Width          string            `json:"width,omitempty"`
+	Height         string            `json:"height,omitempty"`
+	ViewBox        string            `json:"viewbox,omitempty"`
+	Resources      []*MarkupResource `json:"resources,omitempty"`
+	DataURIs       int               `json:"datauris,omitempty"`
+	Scripts        []*MarkupScript   `json:"scripts,omitempty"`
+	EventHandlers  []string          `json:"eventhandlers,omitempty"` // element@attribute
+	JavaScriptURLs int               `json:"javascripturls,omitempty"`
+	ForeignObjects int               `json:"foreignobjects,omitempty"`
+	ActiveContent  bool              `json:"activecontent"`
+}
+
+type ActionMarkup struct {
+	name   string
+	server *Server
+}
+
+var markupMimes = []string{"image/svg+xml", "text/html", "application/xhtml+xml"}
+
+func (am *ActionMarkup) CanHandle(contentType string, filename string) bool {
+	if slices.Contains([]string{".svg", ".html", ".htm", ".xhtml"}, strings.ToLower(filepath.Ext(filename))) {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return slices.Contains(markupMimes, mediaType)
+}
+
+func NewActionMarkup(name string, server *Server, ad *ActionDispatcher) Action {
+	am := &ActionMarkup{name: name, server: server}
+	ad.RegisterAction(am)
+	return am
+}
+
+func (am *ActionMarkup) GetWeight() uint {
+	return 50
+}
+
+func (am *ActionMarkup) GetCaps() ActionCapability {
+	return ACTFILEFULL | ACTSTREAM
+}
+
+func (am *ActionMarkup) GetName() string {
+	return am.name
+}
+
+// markupAttributes are the attributes, which reference resources
+var markupAttributes = []string{"src", "href", "xlink:href", "srcset", "poster", "data", "background"}
+
+// markupCSSURLRegexp finds url() and @import, plain strings are only relevant after @import
+var markupCSSURLRegexp = regexp.MustCompile(`(?i)(@import\s+)?(?:url\(\s*(?:"([^"]*)"|'([^']*)'|([^)'"\s]*))\s*\)|"([^"]*)"|'([^']*)')`)
+var markupFontRegexp = regexp.MustCompile(`(?i)\.(woff2?|ttf|otf|eot)([?#].*)?$`)
+var markupLengthRegexp = regexp.MustCompile(`^\s*([0-9]+(?:\.[0-9]+)?)\s*(px)?\s*$`)
+
+func markupLocal(name string) string {
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+func markupResourceKind(elem string, attrs map[string]string) string {
+	switch elem {
+	case "img", "image", "feimage", "picture":
+		return "image"
+	case "script":
+		return "script"
+	case "link":
+		rel := strings.ToLower(attrs["rel"])
+		switch {
+		case strings.Contains(rel, "stylesheet"):
+			return "stylesheet"
+		case strings.Contains(rel, "icon"):
+			return "image"
+		case attrs["as"] == "font" || markupFontRegexp.MatchString(attrs["href"]):
+			return "font"
+		}
+		return "link"
+	case "video", "audio", "source", "track":
+		return "media"
+	case "iframe", "frame":
+		return "frame"
+	case "object", "embed", "applet":
+		return "object"
+	case "use", "tref", "pattern", "lineargradient", "radialgradient", "filter":
+		return "reference"
+	case "font-face-uri":
+		return "font"
+	}
+	return "link"
+}
+
+func (am *ActionMarkup) addURL(mresult *MarkupResult, kind, elem, attr, value string) {
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "", strings.HasPrefix(value, "#"):
+		return
+	case strings.HasPrefix(strings.ToLower(value), "data:"):
+		mresult.DataURIs++
+		return
+	case strings.HasPrefix(strings.ToLower(value), "javascript:"):
+		mresult.JavaScriptURLs++
+		return
+	}
+	mresult.Resources = append(mresult.Resources, &MarkupResource{Kind: kind, Element: elem, Attribute: attr, URL: value})
+}
+
+func (am *ActionMarkup) addCSS(mresult *MarkupResult, elem, attr, css string) {
+	for _, found := range markupCSSURLRegexp.FindAllStringSubmatch(css, -1) {
+		isURL := strings.HasPrefix(strings.ToLower(strings.TrimPrefix(found[0], found[1])), "url(")
+		if found[1] == "" && !isURL {
+			continue
+		}
+		u := strings.Join(found[2:], "")
+		switch {
+		case found[1] != "":
+			am.addURL(mresult, "stylesheet", elem, attr, u)
+		case markupFontRegexp.MatchString(u):
+			am.addURL(mresult, "font", elem, attr, u)
+		default:
+			am.addURL(mresult, "image", elem, attr, u)
+		}
+	}
+}
+
+func (am *ActionMarkup) analyze(reader io.Reader, contentType, filename string) (*ResultV2, error) {
+	mresult := &MarkupResult{}
+	isHTML := am.CanHandle(contentType, filename) && !strings.Contains(contentType, "svg") && strings.ToLower(filepath.Ext(filename)) != ".svg"
+	z := html.NewTokenizer(reader)
+	var current string // element with text content
+	var script *MarkupScript
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if err := z.Err(); err != io.EOF {
+				return nil, errors.Wrapf(err, "cannot parse '%s'", filename)
+			}
+			break
+		}
+		switch tt {
+		case html.DoctypeToken:
+			if strings.HasPrefix(strings.ToLower(string(z.Token().Data)), "html") && mresult.Kind == "" {
+				mresult.Kind = "html"
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := z.Token()
+			elem := strings.ToLower(markupLocal(token.Data))
+			attrs := map[string]string{}
+			for _, attr := range token.Attr {
+				attrs[strings.ToLower(attr.Key)] = attr.Val
+			}
+			if mresult.Kind == "" {
+				switch {
+				case elem == "svg":
+					mresult.Kind = "svg"
+				case elem == "html" || elem == "head" || elem == "body" || isHTML:
+					mresult.Kind = "html"
+				default:
+					// neither svg nor html
+					return nil, nil
+				}
+			}
+			if elem == "svg" && mresult.Width == "" && mresult.Height == "" && mresult.ViewBox == "" {
+				mresult.Width = attrs["width"]
+				mresult.Height = attrs["height"]
+				mresult.ViewBox = attrs["viewbox"]
+			}
+			for key := range attrs {
+				if strings.HasPrefix(key, "on") {
+					mresult.EventHandlers = append(mresult.EventHandlers, elem+"@"+key)
+				}
+			}
+			switch elem {
+			case "script":
+				src := attrs["src"]
+				if src == "" {
+					src = attrs["xlink:href"]
+				}
+				script = &MarkupScript{Src: src, Type: attrs["type"], Inline: src == ""}
+				mresult.Scripts = append(mresult.Scripts, script)
+				if tt == html.StartTagToken {
+					current = elem
+				}
+			case "foreignobject":
+				mresult.ForeignObjects++
+			case "title", "style":
+				if tt == html.StartTagToken {
+					current = elem
+				}
+			}
+			for _, attr := range markupAttributes {
+				val, ok := attrs[attr]
+				if !ok {
+					continue
+				}
+				if attr == "srcset" {
+					for _, candidate := range strings.Split(val, ",") {
+						if fields := strings.Fields(candidate); len(fields) > 0 {
+							am.addURL(mresult, "image", elem, attr, fields[0])
+						}
+					}
+					continue
+				}
+				if (attr == "href" || attr == "xlink:href") && elem == "a" {
+					am.addURL(mresult, "link", elem, attr, val)
+					continue
+				}
+				am.addURL(mresult, markupResourceKind(elem, attrs), elem, attr, val)
+			}
+			if style, ok := attrs["style"]; ok {
+				am.addCSS(mresult, elem, "style", style)
+			}
+		case html.TextToken:
+			switch current {
+			case "title":
+				if mresult.Title == "" {
+					mresult.Title = strings.TrimSpace(string(z.Text()))
+				}
+			case "style":
+				am.addCSS(mresult, "style", "", string(z.Text()))
+			case "script":
+				if script != nil && script.Inline {
+					script.Size += len(strings.TrimSpace(string(z.Text())))
+				}
+			}
+		case html.EndTagToken:
+			current = ""
+		}
+	}
+	if mresult.Kind == "" {
+		return nil, nil
+	}
+	slices.Sort(mresult.EventHandlers)
+	mresult.EventHandlers = slices.Compact(mresult.EventHandlers)
+	mresult.ActiveContent = len(mresult.Scripts) > 0 || len(mresult.EventHandlers) > 0 || mresult.JavaScriptURLs > 0 || mresult.ForeignObjects > 0
+
+	var result = NewResultV2()
+	if mresult.Kind == "svg" {
+		result.Mimetype = "image/svg+xml"
+		if found := markupLengthRegexp.FindStringSubmatch(mresult.Width); found != nil {
+			w, _ := strconv.ParseFloat(found[1], 64)
+			result.Width = uint(w)
+		}
+		if found := markupLengthRegexp.FindStringSubmatch(mresult.Height); found != nil {
+			h, _ := strconv.ParseFloat(found[1], 64)
+			result.Height = uint(h)
+		}
+	} else {
+		result.Mimetype = "text/html"
+	}
+	result.Mimetypes = []string{result.Mimetype}
+	result.Metadata[am.GetName()] = mresult
+	return result, nil
+}
+
+func (am *ActionMarkup) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
+	return am.analyze(reader, contentType, filename)
+}
+
+func (am *ActionMarkup) DoV2(filename string) (*ResultV2, error) {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open file '%s'", filename)
+	}
+	defer fp.Close()
+	return am.analyze(fp, "", filename)
+}
+
+func (am *ActionMarkup) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
+	filename, err := am.server.fm.Get(uri)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "no file url")
+	}
+	fp, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "cannot open file '%s'", filename)
+	}
+	defer fp.Close()
+	result, err := am.analyze(fp, contentType, filename)
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+	if result == nil {
+		return nil, nil, nil, ErrMimeNotApplicable
+	}
+	if result.Width > *width {
+		*width = result.Width
+	}
+	if result.Height > *height {
+		*height = result.Height
+	}
+	return result.Metadata[am.GetName()], result.Mimetypes, nil, nil
+}
+
+var (
+	_ Action = (*ActionMarkup)(nil)
+)
@@ -0,0 +1,81 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testSVG = `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="200px" height="100" viewBox="0 0 200 100" onload="init()">
+	<title>Test Drawing</title>
+	<style>@import url("fonts.css"); text { font-family: x; src: url(font.woff2) }</style>
+	<image xlink:href="https://example.com/photo.jpg" width="10" height="10"/>
+	<image href="data:image/png;base64,AAAA"/>
+	<use xlink:href="#local"/>
+	<a xlink:href="javascript:alert(1)"><rect width="10" height="10"/></a>
+	<script type="text/ecmascript"><![CDATA[ function init() { alert(1) } ]]></script>
+	<foreignObject width="100" height="50"><div xmlns="http://www.w3.org/1999/xhtml" onclick="x()">html</div></foreignObject>
+</svg>`
+
+const testHTML = `<!DOCTYPE html>
+<html><head><title>Portal</title>
+<link rel="stylesheet" href="/css/main.css"><script src="https://cdn.example.com/lib.js"></script>
+</head><body style="background: url('bg.png')">
+<img srcset="a.jpg 1x, b.jpg 2x" src="a.jpg"><iframe src="https://example.com/embed"></iframe>
+<p>unclosed <b>tags
+</body></html>`
+
+func TestActionMarkup(t *testing.T) {
+	ad := NewActionDispatcher(nil)
+	am := NewActionMarkup(NameMarkup, nil, ad)
+
+	result, err := am.Stream("image/svg+xml", strings.NewReader(testSVG), "test.svg")
+	if err != nil {
+		t.Fatalf("cannot analyze svg: %v", err)
+	}
+	mr := result.Metadata[NameMarkup].(*MarkupResult)
+	if mr.Kind != "svg" || mr.Title != "Test Drawing" || mr.ViewBox != "0 0 200 100" || result.Width != 200 || result.Height != 100 ||
+		result.Mimetype != "image/svg+xml" {
+		t.Errorf("wrong svg result: %+v / %+v", result, mr)
+	}
+	if !mr.ActiveContent || len(mr.Scripts) != 1 || !mr.Scripts[0].Inline || mr.Scripts[0].Size == 0 ||
+		!slices.Equal(mr.EventHandlers, []string{"div@onclick", "svg@onload"}) || mr.JavaScriptURLs != 1 || mr.ForeignObjects != 1 || mr.DataURIs != 1 {
+		t.Errorf("wrong active content: %+v", mr)
+	}
+	var kinds []string
+	for _, r := range mr.Resources {
+		kinds = append(kinds, r.Kind+":"+r.URL)
+	}
+	if !slices.Equal(kinds, []string{"stylesheet:fonts.css", "font:font.woff2", "image:https://example.com/photo.jpg"}) {
+		t.Errorf("wrong svg resources: %v", kinds)
+	}
+
+	filename := filepath.Join(t.TempDir(), "test.html")
+	if err := os.WriteFile(filename, []byte(testHTML), 0644); err != nil {
+		t.Fatalf("cannot write file: %v", err)
+	}
+	result, err = am.DoV2(filename)
+	if err != nil {
+		t.Fatalf("cannot analyze html: %v", err)
+	}
+	mr = result.Metadata[NameMarkup].(*MarkupResult)
+	kinds = nil
+	for _, r := range mr.Resources {
+		kinds = append(kinds, r.Kind+":"+r.URL)
+	}
+	if mr.Kind != "html" || mr.Title != "Portal" || result.Mimetype != "text/html" || !mr.ActiveContent ||
+		len(mr.Scripts) != 1 || mr.Scripts[0].Inline || mr.Scripts[0].Src != "https://cdn.example.com/lib.js" {
+		t.Errorf("wrong html result: %+v", mr)
+	}
+	if !slices.Equal(kinds, []string{"stylesheet:/css/main.css", "script:https://cdn.example.com/lib.js", "image:bg.png",
+		"image:a.jpg", "image:a.jpg", "image:b.jpg", "frame:https://example.com/embed"}) {
+		t.Errorf("wrong html resources: %v", kinds)
+	}
+
+	if result, err := am.Stream("application/xml", strings.NewReader("<rss><channel/></rss>"), "feed.xml"); err != nil || result != nil {
+		t.Errorf("rss must be ignored: %v / %v", result, err)
+	}
+}
@@ -14,43 +14,55 @@
 package indexer
 
 import (
+	"crypto/md5"
 	"crypto/sha1"
+	"crypto/sha256"
 	"emperror.dev/errors"
+	"encoding/hex"
 	"encoding/json"
-	"fmt"
 	badger "github.com/dgraph-io/badger/v4"
 	"github.com/golang/snappy"
+	"hash"
 	"io"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 )
 
 const NSRL_OS = "OpSystemCode-"
 const NSRL_PROD = "ProductCode-"
-const NSRL_MFG = "MFgCode-"
+const NSRL_MFG = "MfgCode-"
 const NSRL_File = "SHA-1-"
 
-type ActionNSRL struct {
-	name   string
-	caps   ActionCapability
-	server *Server
-	nsrldb *badger.DB
+// NSRL_Version holds the version of the imported reference data set
+const NSRL_Version = "NSRLVersion"
+
+// digests which can be used as key of the file records, strongest first
+var nsrlDigests = []string{"SHA-256", "SHA-1", "MD5"}
+
+// NSRLResult is the verdict of the nsrl lookup
+type NSRLResult struct {
+	Known    bool             `json:"known"`
+	Digest   string           `json:"digest,omitempty"`
+	Checksum string           `json:"checksum,omitempty"`
+	Version  string           `json:"version,omitempty"`
+	Files    []ActionNSRLMeta `json:"files,omitempty"`
 }
 
-func (aNSRL *ActionNSRL) DoV2(filename string) (*ResultV2, error) {
-	//TODO implement me
-	panic("implement me")
+type ActionNSRL struct {
+	name    string
+	caps    ActionCapability
+	server  *Server
+	nsrldb  *badger.DB
+	digests []string
+	version string
 }
 
 func (aNSRL *ActionNSRL) CanHandle(contentType string, filename string) bool {
 	return true
 }
 
-func (aNSRL *ActionNSRL) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
-	return nil, errors.New("nsrl actions does not support streaming")
-}
-
 type ActionNSRLMeta struct {
 	File    map[string]string
 	FileMfG map[string]string
@@ -60,8 +72,23 @@ type ActionNSRLMeta struct {
 	ProdMfg map[string]string
 }
 
+// NewActionNSRL creates the nsrl lookup action. the digests used as keys are
+// detected from the database content
 func NewActionNSRL(name string, nsrldb *badger.DB, server *Server, ad *ActionDispatcher) Action {
-	an := &ActionNSRL{name: name, nsrldb: nsrldb, server: server, caps: ACTFILE}
+	an := &ActionNSRL{name: name, nsrldb: nsrldb, server: server, caps: ACTFILEFULL | ACTSTREAM}
+	an.digests = badgerDigests(nsrldb)
+	_ = nsrldb.View(func(txn *badger.Txn) error {
+		if item, err := txn.Get([]byte(NSRL_Version)); err == nil {
+			_ = item.Value(func(val []byte) error {
+				an.version = string(val)
+				return nil
+			})
+		}
+		return nil
+	})
+	if len(an.digests) == 0 {
+		an.digests = []string{"SHA-1"}
+	}
 	ad.RegisterAction(an)
 	return an
 }
@@ -70,6 +97,129 @@ func (aNSRL *ActionNSRL) GetWeight() uint {
 	return 100
 }
 
+// badgerDigests detects the digests used as key prefixes of a hash database
+func badgerDigests(db *badger.DB) []string {
+	var digests []string
+	_ = db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		for _, digest := range nsrlDigests {
+			opts.Prefix = []byte(digest + "-")
+			it := txn.NewIterator(opts)
+			it.Rewind()
+			if it.Valid() {
+				digests = append(digests, digest)
+			}
+			it.Close()
+		}
+		return nil
+	})
+	return digests
+}
+
+func newNSRLHash(digest string) hash.Hash {
+	switch digest {
+	case "MD5":
+		return md5.New()
+	case "SHA-256":
+		return sha256.New()
+	default:
+		return sha1.New()
+	}
+}
+
+// normalizeNSRLDigest maps checksum names like "sha256" or "SHA-1" to the nsrl key prefixes
+func normalizeNSRLDigest(name string) string {
+	switch strings.ToUpper(strings.ReplaceAll(name, "-", "")) {
+	case "MD5":
+		return "MD5"
+	case "SHA1":
+		return "SHA-1"
+	case "SHA256":
+		return "SHA-256"
+	default:
+		return ""
+	}
+}
+
+// digestStream computes the given digests of the data in reader
+func digestStream(reader io.Reader, digests []string) (map[string]string, error) {
+	var hashes = map[string]hash.Hash{}
+	var writers []io.Writer
+	for _, digest := range digests {
+		h := newNSRLHash(digest)
+		hashes[digest] = h
+		writers = append(writers, h)
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), reader); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var checksums = map[string]string{}
+	for digest, h := range hashes {
+		checksums[digest] = hex.EncodeToString(h.Sum(nil))
+	}
+	return checksums, nil
+}
+
+func (aNSRL *ActionNSRL) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
+	checksums, err := digestStream(reader, aNSRL.digests)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read '%s'", filename)
+	}
+	nresult, err := aNSRL.lookup(checksums)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot lookup '%s'", filename)
+	}
+	var result = NewResultV2()
+	result.Metadata[aNSRL.GetName()] = nresult
+	return result, nil
+}
+
+// lookup checks the given checksums against the database. the keys of checksums
+// are normalized with normalizeNSRLDigest
+func (aNSRL *ActionNSRL) lookup(checksums map[string]string) (*NSRLResult, error) {
+	var nresult = &NSRLResult{Version: aNSRL.version}
+	for _, digest := range aNSRL.digests {
+		var sum string
+		for name, val := range checksums {
+			if normalizeNSRLDigest(name) == digest {
+				sum = strings.ToUpper(val)
+				break
+			}
+		}
+		if sum == "" {
+			continue
+		}
+		if nresult.Digest == "" {
+			nresult.Digest = digest
+			nresult.Checksum = sum
+		}
+		files, err := aNSRL.getNSRL(digest, sum)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if len(files) > 0 {
+			nresult.Known = true
+			nresult.Digest = digest
+			nresult.Checksum = sum
+			nresult.Files = files
+			break
+		}
+	}
+	if nresult.Digest == "" {
+		return nil, errors.Errorf("no checksum of %v given to check nsrl", aNSRL.digests)
+	}
+	return nresult, nil
+}
+
+func (aNSRL *ActionNSRL) DoV2(filename string) (*ResultV2, error) {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open file '%s'", filename)
+	}
+	defer fp.Close()
+	return aNSRL.Stream("", fp, filename)
+}
 func getStringMap(txn *badger.Txn, key string) ([]map[string]string, error) {
 	var result []map[string]string
 	item, err := txn.Get([]byte(key))
@@ -94,25 +244,13 @@ func getStringMap(txn *badger.Txn, key string) ([]map[string]string, error) {
 	return result, nil
 }
 
-func (aNSRL *ActionNSRL) getNSRL(sha1sum string) (interface{}, []string, []string, error) {
+func (aNSRL *ActionNSRL) getNSRL(digest, sum string) ([]ActionNSRLMeta, error) {
 	var result []ActionNSRLMeta
-	aNSRL.nsrldb.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(NSRL_File + sha1sum))
+	key := digest + "-" + sum
+	if err := aNSRL.nsrldb.View(func(txn *badger.Txn) error {
+		fileData, err := getStringMap(txn, key)
 		if err != nil {
-			return errors.Wrapf(err, "cannot get os %s", NSRL_File+sha1sum)
-		}
-		var fileData []map[string]string
-		if err := item.Value(func(val []byte) error {
-			jsonStr, err := snappy.Decode(nil, val)
-			if err != nil {
-				return errors.Wrapf(err, "cannot decompress snappy of %s", NSRL_File+sha1sum)
-			}
-			if err := json.Unmarshal([]byte(jsonStr), &fileData); err != nil {
-				return errors.Wrapf(err, "cannot unmarshal %s for %s", jsonStr, NSRL_File+sha1sum)
-			}
-			return nil
-		}); err != nil {
-			return errors.Wrapf(err, "cannot get value of %s", NSRL_File+sha1sum)
+			return errors.Wrapf(err, "cannot get file %s", key)
 		}
 		if len(fileData) > 10 {
 			fileData = fileData[0:10]
@@ -121,15 +259,14 @@ func (aNSRL *ActionNSRL) getNSRL(sha1sum string) (interface{}, []string, []strin
 			var am ActionNSRLMeta
 			am.File = file
 			if am.File["MfgCode"] != "" {
-				r, _ := getStringMap(txn, NSRL_MFG+am.Prod["MfgCode"])
+				r, _ := getStringMap(txn, NSRL_MFG+am.File["MfgCode"])
 				if len(r) > 0 {
 					am.FileMfG = r[0]
 				}
 			}
 			r, err := getStringMap(txn, NSRL_PROD+file["ProductCode"])
 			if err != nil {
-				aNSRL.server.log.Errorf("cannot get data of %s: %v", NSRL_PROD+file["ProductCode"], err)
-				// return errors.Wrapf(err, "cannot get data of %s", NSRL_PROD+file["ProductCode"])
+				aNSRL.logError("cannot get data of %s: %v", NSRL_PROD+file["ProductCode"], err)
 			}
 			if len(r) > 0 {
 				am.Prod = r[0]
@@ -142,14 +279,13 @@ func (aNSRL *ActionNSRL) getNSRL(sha1sum string) (interface{}, []string, []strin
 			}
 			r, err = getStringMap(txn, NSRL_OS+file["OpSystemCode"])
 			if err != nil {
-				aNSRL.server.log.Errorf("cannot get data of %s: %v", NSRL_OS+file["OpSystemCode"], err)
-				// return errors.Wrapf(err, "cannot get data of %s", NSRL_PROD+file["ProductCode"])
+				aNSRL.logError("cannot get data of %s: %v", NSRL_OS+file["OpSystemCode"], err)
 			}
 			if len(r) > 0 {
 				am.OS = r[0]
 			}
 			if am.OS["MfgCode"] != "" {
-				r, _ = getStringMap(txn, NSRL_MFG+am.Prod["MfgCode"])
+				r, _ = getStringMap(txn, NSRL_MFG+am.OS["MfgCode"])
 				if len(r) > 0 {
 					am.OSMfg = r[0]
 				}
@@ -157,8 +293,16 @@ func (aNSRL *ActionNSRL) getNSRL(sha1sum string) (interface{}, []string, []strin
 			result = append(result, am)
 		}
 		return nil
-	})
-	return result, []string{}, nil, nil
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (aNSRL *ActionNSRL) logError(format string, args ...any) {
+	if aNSRL.server != nil {
+		aNSRL.server.log.Errorf(format, args...)
+	}
 }
 
 func (aNSRL *ActionNSRL) GetCaps() ActionCapability {
@@ -170,59 +314,27 @@ func (aNSRL *ActionNSRL) GetName() string {
 }
 
 func (aNSRL *ActionNSRL) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
-	if checksums == nil {
-		checksums = make(map[string]string)
-	}
-	if _, ok := checksums["SHA-1"]; !ok {
-		filename, err := aNSRL.server.fm.Get(uri)
-		if err != nil {
-			return nil, nil, nil, errors.Wrapf(err, "no file url")
-		}
-		fp, err := os.Open(filename)
-		if err != nil {
-			return nil, nil, nil, errors.Wrapf(err, "cannot open file %s", filename)
-		}
-		defer fp.Close()
-		hash := sha1.New()
-		if _, err := io.Copy(hash, fp); err != nil {
-			return nil, nil, nil, errors.Wrapf(err, "cannot read %s", filename)
-		}
-		sum := hash.Sum(nil)
-		sumStr := fmt.Sprintf("%X", sum)
-		checksums = make(map[string]string)
-		checksums["SHA-1"] = sumStr
-		//return nil, fmt.Errorf("need checksum (md5) to check nsrl")
-	}
-
-	SHA1sumStr, ok := checksums["SHA-1"]
-	if !ok {
-		return nil, nil, nil, fmt.Errorf("no SHA-1 checksum given to check nsrl")
-	}
-	/*
-		result := make([]map[string]interface{}, 0)
-		if err := aNSRL.nsrldb.View(func(txn *badger.Txn) error {
-			item, err := txn.Get([]byte("SHA-1-"+SHA1sumStr))
-			if err == badger.ErrKeyNotFound {
-				return nil
-			} else if err != nil {
-				return errors.Wrapf(err, "cannot lookup checksum SHA-1-%s", SHA1sumStr)
-			}
-			if err := item.Value(func(val []byte) error {
-				if err := json.Unmarshal(val, &result); err != nil {
-					return errors.Wrapf(err, "cannot unmarshal %s", string(val))
+	// reuse supplied checksums if possible
+	for name := range checksums {
+		for _, digest := range aNSRL.digests {
+			if normalizeNSRLDigest(name) == digest {
+				nresult, err := aNSRL.lookup(checksums)
+				if err != nil {
+					return nil, nil, nil, errors.WithStack(err)
 				}
-				return nil
-			}); err != nil {
-				return errors.Wrapf(err, "cannot get value of nsrl from SHA-1-%s", SHA1sumStr)
+				return nresult, []string{}, nil, nil
 			}
-			return nil
-		}); err != nil {
-			return nil, errors.Wrapf(err, "cannot get entry for SHA-1-%s", sumStr)
 		}
-	*/
-
-	aNSRL.server.log.Infof("NSRL of %s", SHA1sumStr)
-	return aNSRL.getNSRL(SHA1sumStr)
+	}
+	filename, err := aNSRL.server.fm.Get(uri)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "no file url")
+	}
+	result, err := aNSRL.DoV2(filename)
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+	return result.Metadata[aNSRL.GetName()], []string{}, nil, nil
 }
 
 var (
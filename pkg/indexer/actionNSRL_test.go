@@ -0,0 +1,87 @@
+package indexer
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/golang/snappy"
+)
+
+func nsrlTestDB(t *testing.T, digest string, sum string) *badger.DB {
+	bconfig := badger.DefaultOptions(t.TempDir())
+	bconfig.Logger = nil
+	db, err := badger.Open(bconfig)
+	if err != nil {
+		t.Fatalf("cannot open badger: %v", err)
+	}
+	records := map[string]map[string]string{
+		digest + "-" + sum:    {"FileName": "test.txt", "FileSize": "10", "ProductCode": "1", "OpSystemCode": "2"},
+		NSRL_PROD + "1":       {"ProductCode": "1", "ProductName": "Indexer", "MfgCode": "3"},
+		NSRL_OS + "2":         {"OpSystemCode": "2", "OpSystemName": "Linux", "MfgCode": "3"},
+		NSRL_MFG + "3":        {"MfgCode": "3", "MfgName": "info-age"},
+		"MD5-" + "0000000000": {"FileName": "dummy"},
+	}
+	if err := db.Update(func(txn *badger.Txn) error {
+		for key, val := range records {
+			data, _ := json.Marshal([]map[string]string{val})
+			if err := txn.Set([]byte(key), snappy.Encode(nil, data)); err != nil {
+				return err
+			}
+		}
+		return txn.Set([]byte(NSRL_Version), []byte("2024.12.1"))
+	}); err != nil {
+		t.Fatalf("cannot write badger: %v", err)
+	}
+	return db
+}
+
+func TestActionNSRL(t *testing.T) {
+	data := []byte("0123456789")
+	sha := sha256.Sum256(data)
+	db := nsrlTestDB(t, "SHA-256", strings.ToUpper(hex.EncodeToString(sha[:])))
+	defer db.Close()
+
+	ad := NewActionDispatcher(nil)
+	an := NewActionNSRL(NameNSRL, db, nil, ad).(*ActionNSRL)
+	if strings.Join(an.digests, ",") != "SHA-256,MD5" {
+		t.Errorf("wrong digests detected: %v", an.digests)
+	}
+
+	result, err := an.Stream("", bytes.NewReader(data), "test.txt")
+	if err != nil {
+		t.Fatalf("cannot stream: %v", err)
+	}
+	nresult := result.Metadata[NameNSRL].(*NSRLResult)
+	if !nresult.Known || nresult.Digest != "SHA-256" || nresult.Version != "2024.12.1" {
+		t.Fatalf("file not known: %+v", nresult)
+	}
+	meta := nresult.Files[0]
+	if meta.Prod["ProductName"] != "Indexer" || meta.OS["OpSystemName"] != "Linux" ||
+		meta.ProdMfg["MfgName"] != "info-age" || meta.OSMfg["MfgName"] != "info-age" {
+		t.Errorf("incomplete records: %+v", meta)
+	}
+
+	result, err = an.Stream("", bytes.NewReader([]byte("unknown")), "unknown.txt")
+	if err != nil {
+		t.Fatalf("cannot stream: %v", err)
+	}
+	if nresult := result.Metadata[NameNSRL].(*NSRLResult); nresult.Known {
+		t.Errorf("unknown file is known: %+v", nresult)
+	}
+
+	// supplied checksums are used without reading the file
+	md := md5.Sum([]byte("unknown"))
+	iresult, _, _, err := an.Do(nil, "", nil, nil, nil, map[string]string{"md5": hex.EncodeToString(md[:])})
+	if err != nil {
+		t.Fatalf("cannot lookup checksum: %v", err)
+	}
+	if nresult := iresult.(*NSRLResult); nresult.Known || nresult.Digest != "MD5" {
+		t.Errorf("wrong md5 lookup: %+v", nresult)
+	}
+}
@@ -0,0 +1,400 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indexer
+
+import (
+	"emperror.dev/errors"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// PIIFinding reports the number of matches of a detector. the values are never reported
+type PIIFinding struct {
+	Count   int64   `json:"count"`
+	Offsets []int64 `json:"offsets"` // byte offsets of the first matches within the text
+}
+
+type PIIResult struct {
+	Source    string                 `json:"source"` // content or the action, which extracted the text
+	Size      int64                  `json:"size"`   // bytes scanned
+	Truncated bool                   `json:"truncated,omitempty"`
+	Findings  map[string]*PIIFinding `json:"findings"`
+}
+
+type piiDetector struct {
+	name     string
+	regexp   *regexp.Regexp
+	validate func(match []byte) bool
+}
+
+// piiDigits returns the digits of a match
+func piiDigits(match []byte) []byte {
+	var digits []byte
+	for _, c := range match {
+		if c >= '0' && c <= '9' {
+			digits = append(digits, c)
+		}
+	}
+	return digits
+}
+
+// piiLuhn validates the check digit of credit card numbers
+func piiLuhn(match []byte) bool {
+	digits := piiDigits(match)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	// major issuer identification numbers only, to reduce false positives
+	switch {
+	case digits[0] == '4', digits[0] == '5' && digits[1] >= '1' && digits[1] <= '5', digits[0] == '2' && digits[1] >= '2' && digits[1] <= '7',
+		digits[0] == '3' && (digits[1] == '4' || digits[1] == '7'), digits[0] == '6':
+	default:
+		return false
+	}
+	var sum int
+	for i := range digits {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// piiIBAN validates the iso 13616 mod 97 checksum
+func piiIBAN(match []byte) bool {
+	iban := strings.ReplaceAll(string(match), " ", "")
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	var remainder int
+	for _, c := range iban[4:] + iban[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			remainder = (remainder*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			// letters are replaced by two digits, A = 10
+			remainder = (remainder*100 + int(c-'A'+10)) % 97
+		default:
+			return false
+		}
+	}
+	return remainder == 1
+}
+
+// piiAHV validates the ean-13 check digit of the swiss social security number
+func piiAHV(match []byte) bool {
+	digits := piiDigits(match)
+	if len(digits) != 13 {
+		return false
+	}
+	var sum int
+	for i, c := range digits[:12] {
+		d := int(c - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return (10-sum%10)%10 == int(digits[12]-'0')
+}
+
+// piiPhone accepts the number of digits of e.164 numbers
+func piiPhone(match []byte) bool {
+	digits := len(piiDigits(match))
+	return digits >= 9 && digits <= 15
+}
+
+var piiDetectors = map[string]*piiDetector{
+	"email": {
+		regexp: regexp.MustCompile(`(?i)\b[a-z0-9._%+\-]+@[a-z0-9\-]+(?:\.[a-z0-9\-]+)*\.[a-z]{2,}\b`),
+	},
+	"phone": {
+		regexp:   regexp.MustCompile(`(?:\+|\b00)[1-9]\d{0,2}[ \-./]?(?:\(0\)[ ]?)?\d{1,4}(?:[ \-./]?\d{2,4}){2,4}\b|\b0\d{2}[ \-./]?\d{3}[ \-./]?\d{2}[ \-./]?\d{2}\b`),
+		validate: piiPhone,
+	},
+	"iban": {
+		regexp:   regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`),
+		validate: piiIBAN,
+	},
+	"creditcard": {
+		regexp:   regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`),
+		validate: piiLuhn,
+	},
+	"ahv": {
+		regexp:   regexp.MustCompile(`\b756[. ]?\d{4}[. ]?\d{4}[. ]?\d{2}\b`),
+		validate: piiAHV,
+	},
+}
+
+type ActionPII struct {
+	name       string
+	detectors  []*piiDetector
+	sources    []string
+	maxSamples int
+	maxSize    int64
+	server     *Server
+	ad         *ActionDispatcher
+}
+
+func (ap *ActionPII) CanHandle(contentType string, filename string) bool {
+	return strings.HasPrefix(contentType, "text/")
+}
+
+// NewActionPII creates the scanner with the named builtin detectors (all, if empty) and custom regular expressions.
+// sources are the actions, whose extracted text is scanned, i.e. the tika fulltext
+func NewActionPII(name string, detectors []string, custom map[string]string, sources []string, maxSamples int, maxSize int64, server *Server, ad *ActionDispatcher) (*ActionPII, error) {
+	ap := &ActionPII{name: name, sources: sources, maxSamples: maxSamples, maxSize: maxSize, server: server, ad: ad}
+	if len(detectors) == 0 {
+		for detector := range piiDetectors {
+			detectors = append(detectors, detector)
+		}
+		slices.Sort(detectors)
+	}
+	for _, detector := range detectors {
+		d, ok := piiDetectors[strings.ToLower(detector)]
+		if !ok {
+			return nil, errors.Errorf("unknown pii detector '%s'", detector)
+		}
+		ap.detectors = append(ap.detectors, &piiDetector{name: strings.ToLower(detector), regexp: d.regexp, validate: d.validate})
+	}
+	for cname, expr := range custom {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid regexp of pii detector '%s'", cname)
+		}
+		ap.detectors = append(ap.detectors, &piiDetector{name: cname, regexp: re})
+	}
+	ad.RegisterAction(ap)
+	return ap, nil
+}
+
+func (ap *ActionPII) GetWeight() uint {
+	return 50
+}
+
+func (ap *ActionPII) GetCaps() ActionCapability {
+	return ACTFILEFULL | ACTSTREAM
+}
+
+func (ap *ActionPII) GetName() string {
+	return ap.name
+}
+
+const (
+	piiChunkSize = 64 * 1024
+	// piiOverlap is kept from the previous chunk, so that matches at the border are found
+	piiOverlap = 256
+)
+
+// scan runs the detectors chunkwise. a match is counted in the chunk, where it starts
+func (ap *ActionPII) scan(reader io.Reader, source string) (*PIIResult, error) {
+	presult := &PIIResult{Source: source, Findings: map[string]*PIIFinding{}}
+	// end of the last counted match per detector
+	var nextStart = make([]int64, len(ap.detectors))
+	var buf = make([]byte, 0, piiChunkSize+piiOverlap)
+	var chunk = make([]byte, piiChunkSize)
+	var base int64 // offset of buf[0]
+	for {
+		n, err := io.ReadFull(reader, chunk)
+		buf = append(buf, chunk[:n]...)
+		presult.Size += int64(n)
+		final := err != nil
+		if !final && ap.maxSize > 0 && presult.Size >= ap.maxSize {
+			presult.Truncated, final = true, true
+		}
+		limit := len(buf) - piiOverlap
+		if final || limit < 0 {
+			limit = len(buf)
+		}
+		for i, d := range ap.detectors {
+			for _, loc := range d.regexp.FindAllIndex(buf, -1) {
+				start := base + int64(loc[0])
+				if loc[0] >= limit || start < nextStart[i] {
+					continue
+				}
+				if d.validate != nil && !d.validate(buf[loc[0]:loc[1]]) {
+					continue
+				}
+				nextStart[i] = base + int64(loc[1])
+				finding, ok := presult.Findings[d.name]
+				if !ok {
+					finding = &PIIFinding{Offsets: []int64{}}
+					presult.Findings[d.name] = finding
+				}
+				finding.Count++
+				if len(finding.Offsets) < ap.maxSamples {
+					finding.Offsets = append(finding.Offsets, start)
+				}
+			}
+		}
+		if final {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				err = nil
+			}
+			return presult, errors.WithStack(err)
+		}
+		base += int64(limit)
+		buf = append(buf[:0], buf[limit:]...)
+	}
+}
+
+func (ap *ActionPII) result(presult *PIIResult) *ResultV2 {
+	var result = NewResultV2()
+	result.PII = map[string]int64{}
+	for name, finding := range presult.Findings {
+		result.PII[name] = finding.Count
+	}
+	result.Metadata[ap.GetName()] = presult
+	return result
+}
+
+func (ap *ActionPII) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
+	if !ap.CanHandle(contentType, filename) {
+		return nil, nil
+	}
+	presult, err := ap.scan(reader, "content")
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot scan '%s'", filename)
+	}
+	return ap.result(presult), nil
+}
+
+// sourceText returns the text of the first source action in metadata
+func (ap *ActionPII) sourceText(metadata map[string]any) (string, string) {
+	for _, source := range ap.sources {
+		field := ap.sourceField(source)
+		val, ok := metadata[source]
+		if !ok && field != "" {
+			// embedded resources of tika are keyed by the field
+			val = metadata[field]
+		}
+		if text := piiText(val, field); text != "" {
+			return text, source
+		}
+	}
+	return "", ""
+}
+
+// sourceField returns the configured field of a tika source action
+func (ap *ActionPII) sourceField(source string) string {
+	if ap.ad == nil {
+		return ""
+	}
+	action, ok := ap.ad.GetAction(source)
+	if !ok {
+		return ""
+	}
+	if at, ok := action.(*ActionTika); ok {
+		return at.field
+	}
+	return ""
+}
+
+// piiText extracts the text of a string, a list of strings or the field of a tika metadata list
+func piiText(val any, field string) string {
+	var parts []string
+	switch v := val.(type) {
+	case string:
+		return v
+	case []any:
+		for _, elem := range v {
+			if text := piiText(elem, field); text != "" {
+				parts = append(parts, text)
+			}
+		}
+	case []map[string]any:
+		for _, doc := range v {
+			if text := piiText(doc, field); text != "" {
+				parts = append(parts, text)
+			}
+		}
+	case map[string]any:
+		if field != "" {
+			return piiText(v[field], "")
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// ProcessResult scans the text of the source actions, if the content itself was not scanned.
+// the text of embedded resources is scanned as well, the findings are added to their metadata
+func (ap *ActionPII) ProcessResult(result *ResultV2) error {
+	if _, ok := result.Metadata[ap.GetName()]; !ok {
+		if text, source := ap.sourceText(result.Metadata); text != "" {
+			presult, err := ap.scan(strings.NewReader(text), source)
+			if err != nil {
+				return errors.Wrapf(err, "cannot scan text of '%s'", source)
+			}
+			result.Merge(ap.result(presult))
+		}
+	}
+	for _, embedded := range result.Embedded {
+		if _, ok := embedded.Metadata[ap.GetName()]; ok {
+			continue
+		}
+		text, source := ap.sourceText(embedded.Metadata)
+		if text == "" {
+			continue
+		}
+		presult, err := ap.scan(strings.NewReader(text), source)
+		if err != nil {
+			return errors.Wrapf(err, "cannot scan text of '%s' in '%s'", source, embedded.Path)
+		}
+		embedded.Metadata[ap.GetName()] = presult
+	}
+	return nil
+}
+
+func (ap *ActionPII) DoV2(filename string) (*ResultV2, error) {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open file '%s'", filename)
+	}
+	defer fp.Close()
+	presult, err := ap.scan(fp, "content")
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot scan '%s'", filename)
+	}
+	return ap.result(presult), nil
+}
+
+func (ap *ActionPII) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
+	if !ap.CanHandle(contentType, uri.String()) {
+		return nil, nil, nil, ErrMimeNotApplicable
+	}
+	filename, err := ap.server.fm.Get(uri)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "no file url")
+	}
+	result, err := ap.DoV2(filename)
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+	return result.Metadata[ap.GetName()], nil, nil, nil
+}
+
+var (
+	_ Action          = (*ActionPII)(nil)
+	_ ResultProcessor = (*ActionPII)(nil)
+)
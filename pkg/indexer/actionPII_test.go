@@ -0,0 +1,121 @@
+package indexer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/je4/utils/v2/pkg/zLogger"
+	"github.com/rs/zerolog"
+)
+
+func TestActionPII(t *testing.T) {
+	ad := NewActionDispatcher(nil)
+	NewActionTika(NameFullText, "http://localhost:9998/rmeta/text", time.Second, "", "", "X-TIKA:content", false, nil, ad)
+	ap, err := NewActionPII(NamePII, nil, map[string]string{"matrikel": `\b\d{2}-\d{3}-\d{3}\b`}, []string{NameFullText}, 2, 0, nil, ad)
+	if err != nil {
+		t.Fatalf("cannot create action: %v", err)
+	}
+	text := `Contact: jane.doe@example.com, phone +41 61 267 31 11 or 061 267 31 11.
+Payment to CH93 0076 2011 6238 5295 7 or CH9300762011623852958 (invalid), card 4111 1111 1111 1111, not 4111 1111 1111 1112.
+AHV 756.1234.5678.97, invalid 756.1234.5678.98, student 12-345-678.`
+	result, err := ap.Stream("text/plain", strings.NewReader(text), "test.txt")
+	if err != nil {
+		t.Fatalf("cannot scan: %v", err)
+	}
+	for detector, count := range map[string]int64{"email": 1, "phone": 2, "iban": 1, "creditcard": 1, "ahv": 1, "matrikel": 1} {
+		if result.PII[detector] != count {
+			t.Errorf("%s: %d findings, expected %d", detector, result.PII[detector], count)
+		}
+	}
+	pr := result.Metadata[NamePII].(*PIIResult)
+	if pr.Findings["email"].Offsets[0] != int64(strings.Index(text, "jane")) || len(pr.Findings["phone"].Offsets) != 2 {
+		t.Errorf("wrong offsets: %+v", pr.Findings)
+	}
+	if data, _ := json.Marshal(pr); strings.Contains(string(data), "jane") {
+		t.Errorf("value reported")
+	}
+
+	// match across the chunk border is counted once
+	long := strings.Repeat("x ", piiChunkSize/2-5) + "jane.doe@example.com " + strings.Repeat("y ", 1000)
+	result, err = ap.Stream("text/plain", strings.NewReader(long), "test.txt")
+	if err != nil {
+		t.Fatalf("cannot scan: %v", err)
+	}
+	if result.PII["email"] != 1 || result.Metadata[NamePII].(*PIIResult).Findings["email"].Offsets[0] != int64(strings.Index(long, "jane")) {
+		t.Errorf("wrong border handling: %+v", result.PII)
+	}
+
+	// text extracted by tika
+	merged := NewResultV2()
+	merged.Metadata[NameFullText] = "mail to john@example.org"
+	if err := ap.ProcessResult(merged); err != nil {
+		t.Fatalf("cannot process result: %v", err)
+	}
+	if merged.PII["email"] != 1 || merged.Metadata[NamePII].(*PIIResult).Source != NameFullText {
+		t.Errorf("fulltext not scanned: %+v", merged.PII)
+	}
+
+	// metadata list of the legacy tika action
+	legacy := NewResultV2()
+	legacy.Metadata[NameFullText] = []map[string]any{{"X-TIKA:content": "mail to john@example.org"}, {"X-TIKA:content": "AHV 756.1234.5678.97"}}
+	if err := ap.ProcessResult(legacy); err != nil {
+		t.Fatalf("cannot process result: %v", err)
+	}
+	if legacy.PII["email"] != 1 || legacy.PII["ahv"] != 1 {
+		t.Errorf("field of metadata list not scanned: %+v", legacy.PII)
+	}
+
+	// text of embedded resources, which were not scanned before
+	scanned := &PIIResult{Source: "content"}
+	merged.Embedded = []*EmbeddedResource{
+		{Path: "/readme.txt", Metadata: map[string]any{"X-TIKA:content": "AHV 756.1234.5678.97"}},
+		{Path: "/scanned.txt", Metadata: map[string]any{"X-TIKA:content": "jane.doe@example.com", NamePII: scanned}},
+		{Path: "/image.png", Metadata: map[string]any{}},
+	}
+	if err := ap.ProcessResult(merged); err != nil {
+		t.Fatalf("cannot process result: %v", err)
+	}
+	if pr, ok := merged.Embedded[0].Metadata[NamePII].(*PIIResult); !ok || pr.Findings["ahv"] == nil || pr.Source != NameFullText {
+		t.Errorf("embedded text not scanned: %+v", merged.Embedded[0].Metadata)
+	}
+	if merged.Embedded[1].Metadata[NamePII] != scanned || merged.Embedded[2].Metadata[NamePII] != nil || merged.PII["email"] != 1 {
+		t.Errorf("wrong embedded scan: %+v, %+v", merged.Embedded[1:], merged.PII)
+	}
+
+	if _, err := NewActionPII(NamePII, []string{"unknown"}, nil, nil, 2, 0, nil, ad); err == nil {
+		t.Errorf("unknown detector accepted")
+	}
+}
+
+func TestActionPIIServer(t *testing.T) {
+	tika := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"Content-Type": "application/pdf", "X-TIKA:content": "mail to john@example.org"})
+	}))
+	defer tika.Close()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "test.pdf"), []byte("%PDF-1.4"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	logger := zerolog.Nop()
+	ad := NewActionDispatcher(nil)
+	srv := &Server{fm: NewFileMapper(map[string]string{"test": dir}), log: zLogger.NewZWrapper(&logger), actions: ad}
+	NewActionTika(NameFullText, tika.URL, 5*time.Second, "", "", "X-TIKA:content", false, srv, ad)
+	if _, err := NewActionPII(NamePII, nil, nil, []string{NameFullText}, 2, 0, srv, ad); err != nil {
+		t.Fatalf("cannot create action: %v", err)
+	}
+	result, err := srv.doIndex(ActionParam{Url: "file://test/test.pdf", Actions: []string{NameFullText, NamePII}}, "v2")
+	if err != nil {
+		t.Fatalf("cannot index: %v", err)
+	}
+	rv2 := result.(ResultV2)
+	if _, ok := rv2.Metadata[NameFullText].([]map[string]any); !ok || rv2.PII["email"] != 1 || rv2.Metadata[NamePII] == nil {
+		t.Errorf("extracted text not scanned: %+v", rv2)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indexer
+
+import (
+	"bufio"
+	"bytes"
+	"emperror.dev/errors"
+	"github.com/je4/indexer/v3/pkg/sqlite"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+type SQLiteTable struct {
+	Name     string `json:"name"`
+	RootPage uint32 `json:"rootpage"`
+	Rows     int64  `json:"rows"`
+	Error    string `json:"error,omitempty"`
+}
+
+type SQLiteIndex struct {
+	Name  string `json:"name"`
+	Table string `json:"table"`
+}
+
+type SQLiteResult struct {
+	PageSize      uint32         `json:"pagesize"`
+	PageCount     uint32         `json:"pagecount"`
+	FreePages     uint32         `json:"freepages"`
+	Encoding      string         `json:"encoding"`
+	SchemaVersion uint32         `json:"schemaversion"`
+	SchemaFormat  uint32         `json:"schemaformat"`
+	UserVersion   uint32         `json:"userversion"`
+	ApplicationID uint32         `json:"applicationid,omitempty"`
+	Application   string         `json:"application,omitempty"`
+	SQLiteVersion string         `json:"sqliteversion"`
+	WAL           bool           `json:"wal"`
+	AutoVacuum    bool           `json:"autovacuum"`
+	Tables        []*SQLiteTable `json:"tables"`
+	Indexes       []*SQLiteIndex `json:"indexes,omitempty"`
+	Views         []string       `json:"views,omitempty"`
+	Triggers      []string       `json:"triggers,omitempty"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// well known application ids (PRAGMA application_id)
+var sqliteApplicationIDs = map[uint32]string{
+	0x47504b47: "GeoPackage",
+	0x47503130: "GeoPackage 1.0",
+	0x4d504258: "MBTiles",
+	0x0f055112: "Fossil",
+}
+
+type ActionSQLite struct {
+	name    string
+	tempDir string
+	server  *Server
+}
+
+func (as *ActionSQLite) CanHandle(contentType string, filename string) bool {
+	if slices.Contains([]string{"application/vnd.sqlite3", "application/x-sqlite3", "application/geopackage+sqlite3"}, contentType) {
+		return true
+	}
+	return slices.Contains([]string{".sqlite", ".sqlite3", ".db", ".db3", ".gpkg", ".mbtiles"}, strings.ToLower(filepath.Ext(filename)))
+}
+
+func NewActionSQLite(name string, tempDir string, server *Server, ad *ActionDispatcher) Action {
+	as := &ActionSQLite{name: name, tempDir: tempDir, server: server}
+	ad.RegisterAction(as)
+	return as
+}
+
+func (as *ActionSQLite) GetWeight() uint {
+	return 50
+}
+
+func (as *ActionSQLite) GetCaps() ActionCapability {
+	return ACTFILEFULL | ACTSTREAM
+}
+
+func (as *ActionSQLite) GetName() string {
+	return as.name
+}
+
+func (as *ActionSQLite) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
+	br := bufio.NewReader(reader)
+	head, _ := br.Peek(len(sqlite.Magic))
+	if !bytes.Equal(head, []byte(sqlite.Magic)) {
+		return nil, nil
+	}
+	// b-tree pages need random access
+	fp, err := spoolTempFile(br, as.tempDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot spool '%s'", filename)
+	}
+	defer func() {
+		fp.Close()
+		os.Remove(fp.Name())
+	}()
+	return as.inspect(fp, filename)
+}
+
+func (as *ActionSQLite) inspect(fp *os.File, filename string) (*ResultV2, error) {
+	stat, err := fp.Stat()
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot stat '%s'", filename)
+	}
+	db, err := sqlite.Open(fp, stat.Size())
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open sqlite database '%s'", filename)
+	}
+	h := db.Header
+	sresult := &SQLiteResult{
+		PageSize:      h.PageSize,
+		PageCount:     h.PageCount,
+		FreePages:     h.FreelistCount,
+		Encoding:      sqlite.EncodingString[h.TextEncoding],
+		SchemaVersion: h.SchemaCookie,
+		SchemaFormat:  h.SchemaFormat,
+		UserVersion:   h.UserVersion,
+		ApplicationID: h.ApplicationID,
+		Application:   sqliteApplicationIDs[h.ApplicationID],
+		SQLiteVersion: h.Version(),
+		WAL:           h.WAL(),
+		AutoVacuum:    h.AutoVacuum != 0,
+		Tables:        []*SQLiteTable{},
+	}
+	var result = NewResultV2()
+	result.Mimetypes = append(result.Mimetypes, "application/vnd.sqlite3")
+	result.Metadata[as.GetName()] = sresult
+
+	schema, err := db.Schema()
+	if err != nil {
+		// header information is still valid
+		sresult.Error = err.Error()
+		return result, nil
+	}
+	for _, entry := range schema {
+		switch entry.Type {
+		case "table":
+			table := &SQLiteTable{Name: entry.Name, RootPage: entry.RootPage}
+			// virtual tables have no b-tree
+			if entry.RootPage > 0 {
+				if table.Rows, err = db.CountRows(entry.RootPage); err != nil {
+					table.Rows = -1
+					table.Error = err.Error()
+				}
+			}
+			sresult.Tables = append(sresult.Tables, table)
+		case "index":
+			sresult.Indexes = append(sresult.Indexes, &SQLiteIndex{Name: entry.Name, Table: entry.TblName})
+		case "view":
+			sresult.Views = append(sresult.Views, entry.Name)
+		case "trigger":
+			sresult.Triggers = append(sresult.Triggers, entry.Name)
+		}
+	}
+	return result, nil
+}
+
+func (as *ActionSQLite) DoV2(filename string) (*ResultV2, error) {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open file '%s'", filename)
+	}
+	defer fp.Close()
+	head := make([]byte, len(sqlite.Magic))
+	if _, err := io.ReadFull(fp, head); err != nil || !bytes.Equal(head, []byte(sqlite.Magic)) {
+		return nil, nil
+	}
+	return as.inspect(fp, filename)
+}
+
+func (as *ActionSQLite) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
+	filename, err := as.server.fm.Get(uri)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "no file url")
+	}
+	result, err := as.DoV2(filename)
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+	if result == nil {
+		return nil, nil, nil, ErrMimeNotApplicable
+	}
+	return result.Metadata[as.GetName()], result.Mimetypes, result.Pronoms, nil
+}
+
+var (
+	_ Action = &ActionSQLite{}
+)
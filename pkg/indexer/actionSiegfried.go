@@ -17,18 +17,31 @@ import (
 	"bytes"
 	"emperror.dev/errors"
 	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/pkg/core"
 	"github.com/richardlehane/siegfried/pkg/pronom"
 	"io"
 	"log"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
+// SiegfriedSignature is a named siegfried signature file
+type SiegfriedSignature struct {
+	Name string
+	Data []byte
+}
+
+type siegfriedInstance struct {
+	name string
+	sf   *siegfried.Siegfried
+}
+
 type ActionSiegfried struct {
 	name    string
-	sf      *siegfried.Siegfried
+	sfs     []*siegfriedInstance
 	mimeMap map[string]string
 	server  *Server
 	typeMap map[string]TypeSubtype
@@ -39,15 +52,30 @@ func (as *ActionSiegfried) CanHandle(contentType string, filename string) bool {
 }
 
 func NewActionSiegfried(name string, signatureData []byte, mimeMap map[string]string, typeMap map[string]TypeSubtype, server *Server, ad *ActionDispatcher) Action {
-	sf, err := siegfried.LoadReader(bytes.NewBuffer(signatureData))
+	as, err := NewActionSiegfriedSignatures(name, []*SiegfriedSignature{{Data: signatureData}}, mimeMap, typeMap, server, ad)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	as := &ActionSiegfried{name: name, sf: sf, mimeMap: mimeMap, typeMap: typeMap, server: server}
-	ad.RegisterAction(as)
 	return as
 }
 
+// NewActionSiegfriedSignatures loads all signature files. every file is identified with each of them
+func NewActionSiegfriedSignatures(name string, signatures []*SiegfriedSignature, mimeMap map[string]string, typeMap map[string]TypeSubtype, server *Server, ad *ActionDispatcher) (*ActionSiegfried, error) {
+	if len(signatures) == 0 {
+		return nil, errors.New("no siegfried signature")
+	}
+	as := &ActionSiegfried{name: name, mimeMap: mimeMap, typeMap: typeMap, server: server}
+	for _, sig := range signatures {
+		sf, err := siegfried.LoadReader(bytes.NewBuffer(sig.Data))
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot load siegfried signature '%s'", sig.Name)
+		}
+		as.sfs = append(as.sfs, &siegfriedInstance{name: sig.Name, sf: sf})
+	}
+	ad.RegisterAction(as)
+	return as, nil
+}
+
 func (as *ActionSiegfried) GetWeight() uint {
 	return 10
 }
@@ -60,69 +88,101 @@ func (as *ActionSiegfried) GetName() string {
 	return as.name
 }
 
-func (as *ActionSiegfried) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
-	ident, err := as.sf.Identify(reader, filepath.Base(filename), "")
-	if err != nil {
-		return nil, errors.Wrapf(err, "cannot identify file %s", filename)
+// Identifiers returns the identifier names and details of all signatures
+func (as *ActionSiegfried) Identifiers() [][2]string {
+	var ids [][2]string
+	for _, inst := range as.sfs {
+		ids = append(ids, inst.sf.Identifiers()...)
 	}
-	var result = NewResultV2()
-	for _, id := range ident {
-		if pid, ok := id.(pronom.Identification); ok {
-			if pid.MIME != "" {
-				result.Mimetypes = append(result.Mimetypes, pid.MIME)
-			}
-			if pid.ID != "" {
-				result.Pronoms = append(result.Pronoms, pid.ID)
-				if t, ok := as.typeMap[pid.ID]; ok {
-					result.Type = t.Type
-					result.Subtype = t.Subtype
-				}
-				if mime, ok := as.mimeMap[pid.ID]; ok {
-					if mime != "" {
-						result.Mimetypes = append(result.Mimetypes, mime)
-					}
-				}
-			}
+	return ids
+}
 
+func (as *ActionSiegfried) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
+	// the buffer keeps the whole stream and can be read by every signature
+	buffer, err := as.sfs[0].sf.Buffer(reader)
+	defer as.sfs[0].sf.Put(buffer)
+	var result = NewResultV2()
+	var idents []core.Identification
+	for _, inst := range as.sfs {
+		ident, err := inst.sf.IdentifyBuffer(buffer, err, filepath.Base(filename), "")
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot identify file %s", filename)
 		}
+		for _, id := range ident {
+			as.addIdentification(result, inst, id)
+		}
+		idents = append(idents, ident...)
 	}
-	result.Metadata[as.GetName()] = ident
+	result.Metadata[as.GetName()] = idents
 	return result, nil
 }
 
-func (as *ActionSiegfried) DoV2(filename string) (*ResultV2, error) {
-	reader, err := os.Open(filename)
-	if err != nil {
-		return nil, errors.Wrapf(err, "cannot open file '%s'", filename)
+func (as *ActionSiegfried) addIdentification(result *ResultV2, inst *siegfriedInstance, id core.Identification) {
+	fi := &FormatIdentification{
+		Source:    as.name,
+		Signature: inst.name,
+		Created:   inst.sf.C,
+		Known:     id.Known(),
 	}
-	defer reader.Close()
-	ident, err := as.sf.Identify(reader, filepath.Base(filename), "")
-	if err != nil {
-		return nil, errors.Wrapf(err, "cannot identify file %s", filename)
+	for _, field := range inst.sf.Label(id) {
+		switch field[0] {
+		case "namespace":
+			fi.Identifier = field[1]
+		case "id":
+			fi.ID = field[1]
+		case "format":
+			fi.Format = field[1]
+		case "version":
+			fi.Version = field[1]
+		case "mime":
+			fi.Mimetype = field[1]
+		case "class":
+			fi.Class = field[1]
+		case "basis":
+			if field[1] != "" {
+				fi.Basis = strings.Split(field[1], "; ")
+			}
+		case "warning":
+			fi.Warning = field[1]
+		}
 	}
-	var result = NewResultV2()
-	for _, id := range ident {
-		if pid, ok := id.(pronom.Identification); ok {
-			if pid.MIME != "" {
-				result.Mimetypes = append(result.Mimetypes, pid.MIME)
+	for _, identifier := range inst.sf.Identifiers() {
+		if identifier[0] == fi.Identifier {
+			fi.Details = identifier[1]
+		}
+	}
+	result.Identifications = append(result.Identifications, fi)
+
+	if pid, ok := id.(pronom.Identification); ok {
+		if pid.MIME != "" {
+			result.Mimetypes = append(result.Mimetypes, pid.MIME)
+		}
+		if pid.ID != "" {
+			result.Pronoms = append(result.Pronoms, pid.ID)
+			if t, ok := as.typeMap[pid.ID]; ok {
+				result.Type = t.Type
+				result.Subtype = t.Subtype
 			}
-			if pid.ID != "" {
-				result.Pronoms = append(result.Pronoms, pid.ID)
-				if t, ok := as.typeMap[pid.ID]; ok {
-					result.Type = t.Type
-					result.Subtype = t.Subtype
-				}
-				if mime, ok := as.mimeMap[pid.ID]; ok {
-					if mime != "" {
-						result.Mimetypes = append(result.Mimetypes, mime)
-					}
+			if mime, ok := as.mimeMap[pid.ID]; ok {
+				if mime != "" {
+					result.Mimetypes = append(result.Mimetypes, mime)
 				}
 			}
 		}
+		return
+	}
+	if fi.Known && fi.Mimetype != "" {
+		result.Mimetypes = append(result.Mimetypes, fi.Mimetype)
 	}
-	result.Metadata[as.GetName()] = ident
+}
 
-	return result, nil
+func (as *ActionSiegfried) DoV2(filename string) (*ResultV2, error) {
+	reader, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open file '%s'", filename)
+	}
+	defer reader.Close()
+	return as.Stream("", reader, filename)
 }
 
 func (as *ActionSiegfried) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
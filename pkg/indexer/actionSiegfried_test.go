@@ -0,0 +1,41 @@
+package indexer
+
+import (
+	"bytes"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestActionSiegfriedSignatures(t *testing.T) {
+	data, err := os.ReadFile("../../data/siegfried/default.sig")
+	if err != nil {
+		t.Skipf("no signature file: %v", err)
+	}
+	ad := NewActionDispatcher(nil)
+	as, err := NewActionSiegfriedSignatures(NameSiegfried, []*SiegfriedSignature{
+		{Name: "default.sig", Data: data},
+		{Name: "second.sig", Data: data},
+	}, map[string]string{"fmt/11": "image/x-png"}, nil, nil, ad)
+	if err != nil {
+		t.Fatalf("cannot load signatures: %v", err)
+	}
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x02\x00\x00\x00\x90\x77\x53\xde\x00\x00\x00\x00IEND\xae\x42\x60\x82")
+	result, err := as.Stream("", bytes.NewReader(png), "test.png")
+	if err != nil {
+		t.Fatalf("cannot identify: %v", err)
+	}
+	if len(result.Identifications) != 2 {
+		t.Fatalf("expected one identification per signature: %+v", result.Identifications)
+	}
+	for i, sig := range []string{"default.sig", "second.sig"} {
+		fi := result.Identifications[i]
+		if fi.Signature != sig || fi.Identifier != "pronom" || fi.Details == "" || fi.Created.IsZero() ||
+			!fi.Known || fi.ID != "fmt/11" || fi.Mimetype != "image/png" || len(fi.Basis) == 0 {
+			t.Errorf("wrong identification: %+v", fi)
+		}
+	}
+	if !slices.Contains(result.Pronoms, "fmt/11") || !slices.Contains(result.Mimetypes, "image/x-png") {
+		t.Errorf("wrong result: %v / %v", result.Pronoms, result.Mimetypes)
+	}
+}
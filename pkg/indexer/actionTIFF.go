@@ -0,0 +1,413 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indexer
+
+import (
+	"bufio"
+	"emperror.dev/errors"
+	"fmt"
+	"github.com/je4/indexer/v3/pkg/tiff"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TIFFProfile restricts compression, bits per sample and photometric interpretation of archival masters.
+// empty lists allow everything
+type TIFFProfile struct {
+	Name          string
+	Compression   []string // names from tiff.CompressionNames, i.e. "none", "lzw"
+	BitsPerSample []int
+	Photometric   []string // names from tiff.PhotometricNames, i.e. "rgb", "blackiszero"
+	AllowTiles    bool
+	AllowBigTIFF  bool
+}
+
+type TIFFPage struct {
+	Offset          int64    `json:"offset"`
+	Width           uint64   `json:"width"`
+	Height          uint64   `json:"height"`
+	Compression     string   `json:"compression"`
+	Photometric     string   `json:"photometric"`
+	BitsPerSample   []uint64 `json:"bitspersample"`
+	SamplesPerPixel uint64   `json:"samplesperpixel"`
+	Tiled           bool     `json:"tiled,omitempty"`
+	Thumbnail       bool     `json:"thumbnail,omitempty"`
+	Tags            []string `json:"tags"`
+	Missing         []string `json:"missing,omitempty"`
+	Errors          []string `json:"errors,omitempty"`
+	Warnings        []string `json:"warnings,omitempty"`
+	Baseline        bool     `json:"baseline"`
+	Profile         []string `json:"profileviolations,omitempty"`
+}
+
+type TIFFResult struct {
+	ByteOrder    string      `json:"byteorder"`
+	BigTIFF      bool        `json:"bigtiff"`
+	Pages        int         `json:"pages"`
+	Valid        bool        `json:"valid"`
+	Baseline     bool        `json:"baseline"`
+	Profile      string      `json:"profile,omitempty"`
+	ProfileValid bool        `json:"profilevalid,omitempty"`
+	Errors       []string    `json:"errors,omitempty"`
+	IFDs         []*TIFFPage `json:"ifds"`
+}
+
+// tags which are required by TIFF 6.0 baseline and have no default value
+var tiffRequiredTags = [][]uint16{
+	{tiff.TagImageWidth},
+	{tiff.TagImageLength},
+	{tiff.TagPhotometricInterpretation},
+	{tiff.TagStripOffsets, tiff.TagTileOffsets},
+	{tiff.TagStripByteCounts, tiff.TagTileByteCounts},
+	{tiff.TagXResolution},
+	{tiff.TagYResolution},
+}
+
+var tiffBaselineCompression = []uint64{1, 2, 32773}
+
+type ActionTIFF struct {
+	name    string
+	profile *TIFFProfile
+	tempDir string
+	server  *Server
+}
+
+func (at *ActionTIFF) CanHandle(contentType string, filename string) bool {
+	if contentType == "image/tiff" {
+		return true
+	}
+	return slices.Contains([]string{".tif", ".tiff"}, strings.ToLower(filepath.Ext(filename)))
+}
+
+func NewActionTIFF(name string, profile *TIFFProfile, tempDir string, server *Server, ad *ActionDispatcher) Action {
+	at := &ActionTIFF{name: name, profile: profile, tempDir: tempDir, server: server}
+	ad.RegisterAction(at)
+	return at
+}
+
+func (at *ActionTIFF) GetWeight() uint {
+	return 50
+}
+
+func (at *ActionTIFF) GetCaps() ActionCapability {
+	return ACTFILEFULL | ACTSTREAM
+}
+
+func (at *ActionTIFF) GetName() string {
+	return at.name
+}
+
+func isTIFFHeader(head []byte) bool {
+	if len(head) < 4 {
+		return false
+	}
+	switch string(head[:4]) {
+	case "II*\x00", "MM\x00*", "II+\x00", "MM\x00+":
+		return true
+	}
+	return false
+}
+
+func (at *ActionTIFF) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
+	br := bufio.NewReader(reader)
+	head, _ := br.Peek(4)
+	if !isTIFFHeader(head) {
+		return nil, nil
+	}
+	// ifds need random access
+	fp, err := spoolTempFile(br, at.tempDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot spool '%s'", filename)
+	}
+	defer func() {
+		fp.Close()
+		os.Remove(fp.Name())
+	}()
+	return at.validate(fp, filename)
+}
+
+func (at *ActionTIFF) DoV2(filename string) (*ResultV2, error) {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open file '%s'", filename)
+	}
+	defer fp.Close()
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(fp, head); err != nil || !isTIFFHeader(head) {
+		return nil, nil
+	}
+	return at.validate(fp, filename)
+}
+
+func (at *ActionTIFF) validate(fp *os.File, filename string) (*ResultV2, error) {
+	stat, err := fp.Stat()
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot stat '%s'", filename)
+	}
+	f, err := tiff.Open(fp, stat.Size())
+	if f == nil {
+		return nil, errors.Wrapf(err, "cannot open tiff '%s'", filename)
+	}
+	tresult := &TIFFResult{
+		ByteOrder: "big-endian",
+		BigTIFF:   f.BigTIFF,
+		IFDs:      []*TIFFPage{},
+	}
+	if f.ByteOrder.String() == "LittleEndian" {
+		tresult.ByteOrder = "little-endian"
+	}
+	if err != nil {
+		tresult.Errors = append(tresult.Errors, err.Error())
+	}
+	var result = NewResultV2()
+	result.Mimetypes = append(result.Mimetypes, "image/tiff")
+	result.Type = "image"
+	result.Subtype = "TIFF"
+	result.Metadata[at.GetName()] = tresult
+
+	tresult.Valid = len(tresult.Errors) == 0
+	tresult.Baseline = !f.BigTIFF
+	if at.profile != nil {
+		tresult.Profile = at.profile.Name
+		tresult.ProfileValid = true
+	}
+	for _, ifd := range f.IFDs {
+		page := at.validateIFD(f, ifd)
+		tresult.IFDs = append(tresult.IFDs, page)
+		if !page.Thumbnail {
+			tresult.Pages++
+		}
+		if len(page.Errors) > 0 || len(page.Missing) > 0 {
+			tresult.Valid = false
+		}
+		if !page.Baseline {
+			tresult.Baseline = false
+		}
+		if len(page.Profile) > 0 {
+			tresult.ProfileValid = false
+		}
+		if page.Width > uint64(result.Width) && !page.Thumbnail {
+			result.Width = uint(page.Width)
+			result.Height = uint(page.Height)
+		}
+	}
+	if at.profile != nil && f.BigTIFF && !at.profile.AllowBigTIFF {
+		tresult.ProfileValid = false
+	}
+	if !tresult.Valid {
+		tresult.Baseline = false
+		tresult.ProfileValid = false
+	}
+	return result, nil
+}
+
+type tiffSegment struct {
+	offset, length uint64
+}
+
+func (at *ActionTIFF) validateIFD(f *tiff.File, ifd *tiff.IFD) *TIFFPage {
+	page := &TIFFPage{Offset: ifd.Offset, Tags: []string{}}
+	errorf := func(format string, args ...any) {
+		page.Errors = append(page.Errors, fmt.Sprintf(format, args...))
+	}
+	if ifd.Offset%2 != 0 {
+		page.Warnings = append(page.Warnings, fmt.Sprintf("ifd at odd offset %d", ifd.Offset))
+	}
+	var last uint16
+	for i, e := range ifd.Entries {
+		page.Tags = append(page.Tags, tiff.TagName(e.Tag))
+		if i > 0 && e.Tag <= last {
+			errorf("tag %s not in ascending order", tiff.TagName(e.Tag))
+		}
+		last = e.Tag
+		if e.Size() == 0 && e.Count > 0 {
+			page.Warnings = append(page.Warnings, fmt.Sprintf("unknown type %d of tag %s", e.Type, tiff.TagName(e.Tag)))
+			continue
+		}
+		if !f.InFile(e) {
+			errorf("value of tag %s at offset %d out of file", tiff.TagName(e.Tag), e.Offset)
+		}
+	}
+	for _, tags := range tiffRequiredTags {
+		if !slices.ContainsFunc(tags, func(tag uint16) bool { return ifd.Entry(tag) != nil }) {
+			page.Missing = append(page.Missing, tiff.TagName(tags[0]))
+		}
+	}
+
+	uintValue := func(tag uint16, def uint64) uint64 {
+		val, err := f.Uint(ifd, tag, def)
+		if err != nil {
+			errorf("%v", err)
+		}
+		return val
+	}
+	subfile := uintValue(tiff.TagNewSubfileType, 0)
+	page.Thumbnail = subfile&1 != 0
+	page.Width = uintValue(tiff.TagImageWidth, 0)
+	page.Height = uintValue(tiff.TagImageLength, 0)
+	compression := uintValue(tiff.TagCompression, 1)
+	page.Compression = tiff.CompressionNames[compression]
+	if page.Compression == "" {
+		page.Compression = fmt.Sprintf("unknown-%d", compression)
+	}
+	var photometric uint64 = 0xffff
+	if ifd.Entry(tiff.TagPhotometricInterpretation) != nil {
+		photometric = uintValue(tiff.TagPhotometricInterpretation, 0)
+		page.Photometric = tiff.PhotometricNames[photometric]
+		if page.Photometric == "" {
+			page.Photometric = fmt.Sprintf("unknown-%d", photometric)
+		}
+	}
+	page.SamplesPerPixel = uintValue(tiff.TagSamplesPerPixel, 1)
+	page.BitsPerSample = []uint64{1}
+	if e := ifd.Entry(tiff.TagBitsPerSample); e != nil {
+		if bps, err := f.Uints(e); err != nil {
+			errorf("%v", err)
+		} else {
+			page.BitsPerSample = bps
+		}
+	}
+	if len(page.BitsPerSample) != 1 && uint64(len(page.BitsPerSample)) != page.SamplesPerPixel {
+		errorf("%d BitsPerSample values for %d samples per pixel", len(page.BitsPerSample), page.SamplesPerPixel)
+	}
+	if page.Width == 0 || page.Height == 0 {
+		errorf("invalid dimension %dx%d", page.Width, page.Height)
+	}
+
+	// strips or tiles
+	offsetTag, countTag := uint16(tiff.TagStripOffsets), uint16(tiff.TagStripByteCounts)
+	if ifd.Entry(tiff.TagTileOffsets) != nil {
+		page.Tiled = true
+		offsetTag, countTag = tiff.TagTileOffsets, tiff.TagTileByteCounts
+	}
+	var offsets, counts []uint64
+	if e := ifd.Entry(offsetTag); e != nil {
+		var err error
+		if offsets, err = f.Uints(e); err != nil {
+			errorf("%v", err)
+		}
+	}
+	if e := ifd.Entry(countTag); e != nil {
+		var err error
+		if counts, err = f.Uints(e); err != nil {
+			errorf("%v", err)
+		}
+	}
+	if offsets != nil && counts != nil {
+		if len(offsets) != len(counts) {
+			errorf("%d %s but %d %s", len(offsets), tiff.TagName(offsetTag), len(counts), tiff.TagName(countTag))
+		}
+		var segments []tiffSegment
+		size := uint64(f.Size())
+		for i := 0; i < len(offsets) && i < len(counts); i++ {
+			if counts[i] == 0 {
+				continue
+			}
+			// offset + count can overflow for bigtiff
+			if counts[i] > size || offsets[i] > size-counts[i] {
+				errorf("segment %d at offset %d with %d bytes out of file", i, offsets[i], counts[i])
+				continue
+			}
+			segments = append(segments, tiffSegment{offset: offsets[i], length: counts[i]})
+		}
+		sort.Slice(segments, func(i, j int) bool { return segments[i].offset < segments[j].offset })
+		for i := 1; i < len(segments); i++ {
+			if segments[i-1].offset+segments[i-1].length > segments[i].offset {
+				errorf("overlapping segments at offset %d and %d", segments[i-1].offset, segments[i].offset)
+			}
+		}
+		if !page.Tiled && page.Height > 0 {
+			rows := uintValue(tiff.TagRowsPerStrip, 1<<32-1)
+			if rows > 0 {
+				planes := uint64(1)
+				if uintValue(tiff.TagPlanarConfiguration, 1) == 2 {
+					planes = page.SamplesPerPixel
+				}
+				if expected := (page.Height + rows - 1) / rows * planes; expected != uint64(len(offsets)) {
+					errorf("%d strips expected, %d found", expected, len(offsets))
+				}
+			}
+		}
+	}
+
+	// tiff 6.0 baseline
+	page.Baseline = !page.Tiled && slices.Contains(tiffBaselineCompression, compression) && photometric <= 3
+	if compression == 2 && photometric > 1 {
+		page.Baseline = false
+	}
+	for _, bps := range page.BitsPerSample {
+		switch {
+		case photometric <= 1 && (bps == 1 || bps == 4 || bps == 8):
+		case photometric == 3 && (bps == 4 || bps == 8):
+		case photometric == 2 && bps == 8:
+		default:
+			page.Baseline = false
+		}
+	}
+	if photometric == 3 && ifd.Entry(tiff.TagColorMap) == nil {
+		page.Missing = append(page.Missing, tiff.TagName(tiff.TagColorMap))
+	}
+
+	if at.profile != nil {
+		if len(at.profile.Compression) > 0 && !slices.Contains(at.profile.Compression, page.Compression) {
+			page.Profile = append(page.Profile, fmt.Sprintf("compression %s not allowed", page.Compression))
+		}
+		if len(at.profile.Photometric) > 0 && !slices.Contains(at.profile.Photometric, page.Photometric) {
+			page.Profile = append(page.Profile, fmt.Sprintf("photometric interpretation %s not allowed", page.Photometric))
+		}
+		if len(at.profile.BitsPerSample) > 0 {
+			for _, bps := range page.BitsPerSample {
+				if !slices.Contains(at.profile.BitsPerSample, int(bps)) {
+					page.Profile = append(page.Profile, fmt.Sprintf("%d bits per sample not allowed", bps))
+					break
+				}
+			}
+		}
+		if page.Tiled && !at.profile.AllowTiles {
+			page.Profile = append(page.Profile, "tiles not allowed")
+		}
+	}
+	return page
+}
+
+func (at *ActionTIFF) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
+	filename, err := at.server.fm.Get(uri)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "no file url")
+	}
+	result, err := at.DoV2(filename)
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+	if result == nil {
+		return nil, nil, nil, nil
+	}
+	if result.Width > *width {
+		*width = result.Width
+	}
+	if result.Height > *height {
+		*height = result.Height
+	}
+	return result.Metadata[at.GetName()], result.Mimetypes, nil, nil
+}
+
+var (
+	_ Action = (*ActionTIFF)(nil)
+)
@@ -0,0 +1,180 @@
+package indexer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/je4/indexer/v3/pkg/tiff"
+)
+
+type testTIFFEntry struct {
+	tag    uint16
+	typ    uint16
+	values []uint32 // rationals are num, den pairs, doubles low, high pairs
+}
+
+// buildTestTIFF writes a little endian tiff with image data at offset 8 followed by a single ifd
+func buildTestTIFF(entries []testTIFFEntry, data []byte) []byte {
+	le := binary.LittleEndian
+	ifdOffset := 8 + len(data) + len(data)%2
+	extraOffset := ifdOffset + 2 + len(entries)*12 + 4
+	var extra []byte
+	var buf = []byte("II*\x00\x00\x00\x00\x00")
+	le.PutUint32(buf[4:], uint32(ifdOffset))
+	buf = append(buf, data...)
+	if len(data)%2 != 0 {
+		buf = append(buf, 0)
+	}
+	buf = le.AppendUint16(buf, uint16(len(entries)))
+	for _, e := range entries {
+		var value []byte
+		count := len(e.values)
+		for _, v := range e.values {
+			if e.typ == tiff.TypeShort {
+				value = le.AppendUint16(value, uint16(v))
+			} else {
+				value = le.AppendUint32(value, v)
+			}
+		}
+		if e.typ == tiff.TypeRational || e.typ == tiff.TypeDouble {
+			count /= 2
+		}
+		buf = le.AppendUint16(buf, e.tag)
+		buf = le.AppendUint16(buf, e.typ)
+		buf = le.AppendUint32(buf, uint32(count))
+		if len(value) <= 4 {
+			buf = append(buf, append(value, make([]byte, 4-len(value))...)...)
+		} else {
+			buf = le.AppendUint32(buf, uint32(extraOffset+len(extra)))
+			extra = append(extra, value...)
+		}
+	}
+	buf = le.AppendUint32(buf, 0)
+	return append(buf, extra...)
+}
+
+func testTIFFEntries(stripOffsets, stripCounts []uint32, rowsPerStrip uint32) []testTIFFEntry {
+	return []testTIFFEntry{
+		{tiff.TagImageWidth, tiff.TypeShort, []uint32{2}},
+		{tiff.TagImageLength, tiff.TypeShort, []uint32{2}},
+		{tiff.TagBitsPerSample, tiff.TypeShort, []uint32{8, 8, 8}},
+		{tiff.TagCompression, tiff.TypeShort, []uint32{1}},
+		{tiff.TagPhotometricInterpretation, tiff.TypeShort, []uint32{2}},
+		{tiff.TagStripOffsets, tiff.TypeLong, stripOffsets},
+		{tiff.TagSamplesPerPixel, tiff.TypeShort, []uint32{3}},
+		{tiff.TagRowsPerStrip, tiff.TypeShort, []uint32{rowsPerStrip}},
+		{tiff.TagStripByteCounts, tiff.TypeLong, stripCounts},
+		{tiff.TagXResolution, tiff.TypeRational, []uint32{300, 1}},
+		{tiff.TagYResolution, tiff.TypeRational, []uint32{300, 1}},
+		{tiff.TagResolutionUnit, tiff.TypeShort, []uint32{2}},
+	}
+}
+
+func TestActionTIFF(t *testing.T) {
+	ad := NewActionDispatcher(nil)
+	at := NewActionTIFF(NameTIFF, &TIFFProfile{
+		Name:          "master",
+		Compression:   []string{"none", "lzw"},
+		BitsPerSample: []int{8, 16},
+		Photometric:   []string{"rgb"},
+	}, t.TempDir(), nil, ad)
+
+	valid := buildTestTIFF(testTIFFEntries([]uint32{8}, []uint32{12}, 2), make([]byte, 12))
+	result, err := at.Stream("image/tiff", bytes.NewReader(valid), "valid.tif")
+	if err != nil {
+		t.Fatalf("cannot validate: %v", err)
+	}
+	tresult := result.Metadata[NameTIFF].(*TIFFResult)
+	if !tresult.Valid || !tresult.Baseline || !tresult.ProfileValid || tresult.Pages != 1 || tresult.ByteOrder != "little-endian" || tresult.BigTIFF {
+		t.Fatalf("wrong result: %+v", tresult)
+	}
+	page := tresult.IFDs[0]
+	if page.Compression != "none" || page.Photometric != "rgb" || !slices.Equal(page.BitsPerSample, []uint64{8, 8, 8}) ||
+		len(page.Tags) != 12 || page.Tags[0] != "ImageWidth" || result.Width != 2 || result.Height != 2 {
+		t.Errorf("wrong page: %+v", page)
+	}
+
+	// two strips, which overlap, without resolution
+	entries := testTIFFEntries([]uint32{8, 10}, []uint32{6, 6}, 1)
+	entries = slices.DeleteFunc(entries, func(e testTIFFEntry) bool { return e.tag == tiff.TagXResolution })
+	broken := buildTestTIFF(entries, make([]byte, 12))
+	filename := filepath.Join(t.TempDir(), "broken.tif")
+	if err := os.WriteFile(filename, broken, 0644); err != nil {
+		t.Fatalf("cannot write file: %v", err)
+	}
+	result, err = at.DoV2(filename)
+	if err != nil {
+		t.Fatalf("cannot validate: %v", err)
+	}
+	tresult = result.Metadata[NameTIFF].(*TIFFResult)
+	if tresult.Valid || tresult.Baseline {
+		t.Errorf("broken tiff is valid: %+v", tresult)
+	}
+	page = tresult.IFDs[0]
+	if !slices.Equal(page.Missing, []string{"XResolution"}) || len(page.Errors) != 1 {
+		t.Errorf("wrong errors: %v / %v", page.Missing, page.Errors)
+	}
+
+	// 16 bit lzw is no baseline tiff, but conforms to the profile
+	entries = testTIFFEntries([]uint32{8}, []uint32{12}, 2)
+	entries[2].values = []uint32{16, 16, 16}
+	entries[3].values = []uint32{5}
+	result, err = at.Stream("image/tiff", bytes.NewReader(buildTestTIFF(entries, make([]byte, 12))), "lzw.tif")
+	if err != nil {
+		t.Fatalf("cannot validate: %v", err)
+	}
+	tresult = result.Metadata[NameTIFF].(*TIFFResult)
+	if !tresult.Valid || tresult.Baseline || !tresult.ProfileValid {
+		t.Errorf("16 bit lzw must be valid, not baseline and conform to profile: %+v", tresult)
+	}
+
+	// packbits is baseline, but violates the profile
+	entries[2].values = []uint32{8, 8, 8}
+	entries[3].values = []uint32{32773}
+	result, err = at.Stream("image/tiff", bytes.NewReader(buildTestTIFF(entries, make([]byte, 12))), "packbits.tif")
+	if err != nil {
+		t.Fatalf("cannot validate: %v", err)
+	}
+	tresult = result.Metadata[NameTIFF].(*TIFFResult)
+	if !tresult.Valid || !tresult.Baseline || tresult.ProfileValid || len(tresult.IFDs[0].Profile) != 1 {
+		t.Errorf("packbits must be baseline and violate the profile: %+v", tresult)
+	}
+
+	// offset + count of a bigtiff strip overflows
+	le := binary.LittleEndian
+	big := le.AppendUint64([]byte("II+\x00\x08\x00\x00\x00"), 16)
+	big = le.AppendUint64(big, 7)
+	for _, e := range [][3]uint64{
+		{tiff.TagImageWidth, tiff.TypeShort, 2},
+		{tiff.TagImageLength, tiff.TypeShort, 2},
+		{tiff.TagBitsPerSample, tiff.TypeShort, 8},
+		{tiff.TagPhotometricInterpretation, tiff.TypeShort, 1},
+		{tiff.TagStripOffsets, tiff.TypeLong8, 1<<64 - 16},
+		{tiff.TagRowsPerStrip, tiff.TypeShort, 2},
+		{tiff.TagStripByteCounts, tiff.TypeLong8, 32},
+	} {
+		big = le.AppendUint16(big, uint16(e[0]))
+		big = le.AppendUint16(big, uint16(e[1]))
+		big = le.AppendUint64(big, 1)
+		big = le.AppendUint64(big, e[2])
+	}
+	big = le.AppendUint64(big, 0)
+	result, err = at.Stream("image/tiff", bytes.NewReader(big), "big.tif")
+	if err != nil {
+		t.Fatalf("cannot validate: %v", err)
+	}
+	tresult = result.Metadata[NameTIFF].(*TIFFResult)
+	if !tresult.BigTIFF || tresult.Valid || !slices.ContainsFunc(tresult.IFDs[0].Errors, func(e string) bool { return strings.Contains(e, "out of file") }) {
+		t.Errorf("strip out of file not detected: %+v / %v", tresult, tresult.IFDs[0].Errors)
+	}
+
+	result, err = at.Stream("image/tiff", bytes.NewReader([]byte("no tiff")), "test.tif")
+	if err != nil || result != nil {
+		t.Errorf("no tiff must be ignored: %v / %v", result, err)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -39,6 +40,7 @@ type ActionTika struct {
 	caps          ActionCapability
 	server        *Server
 	field         string
+	rmeta         bool
 }
 
 func (at *ActionTika) CanHandle(contentType string, filename string) bool {
@@ -74,6 +76,12 @@ func NewActionTika(name, uri string, timeout time.Duration, regexpMime, regexpMi
 	return at
 }
 
+// SetRMeta marks the url as recursive metadata endpoint (/rmeta), which returns the
+// embedded documents as additional list entries
+func (at *ActionTika) SetRMeta(rmeta bool) {
+	at.rmeta = rmeta
+}
+
 func (at *ActionTika) GetWeight() uint {
 	return 50
 }
@@ -123,33 +131,7 @@ func (at *ActionTika) Stream(contentType string, reader io.Reader, filename stri
 	if err != nil {
 		return nil, errors.Wrapf(err, "error decoding json - %v", string(bodyBytes))
 	}
-	var result = NewResultV2()
-	if at.field != "" {
-		if len(meta) > 0 {
-			fls, ok := meta[0][at.field]
-			if ok {
-				result.Metadata[at.GetName()] = fls
-			}
-		}
-	} else {
-		result.Metadata[at.GetName()] = meta
-	}
-
-	if len(meta) > 0 {
-		if mtype, ok := meta[0]["Content-Type"]; ok {
-			if mTypeString, ok := mtype.(string); ok {
-				result.Mimetypes = append(result.Mimetypes, mTypeString)
-			}
-		}
-		if durationAny, ok := meta[0]["xmpDM:duration"]; ok {
-			if durationStr, ok := durationAny.(string); ok {
-				if durationFloat, err := strconv.ParseFloat(durationStr, 64); err == nil {
-					result.Duration = uint(math.Floor(durationFloat))
-				}
-			}
-		}
-	}
-	return result, nil
+	return at.resultV2(meta), nil
 }
 
 func (at *ActionTika) DoV2(filename string) (*ResultV2, error) {
@@ -191,26 +173,70 @@ func (at *ActionTika) DoV2(filename string) (*ResultV2, error) {
 	if err != nil {
 		return nil, errors.Wrapf(err, "error decoding json - %v", string(bodyBytes))
 	}
+	return at.resultV2(meta), nil
+}
+
+// tikaString returns the first value of a single or multivalued tika field
+func tikaString(val any) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case []interface{}:
+		if len(v) > 0 {
+			if str, ok := v[0].(string); ok {
+				return str
+			}
+		}
+	}
+	return ""
+}
+
+func (at *ActionTika) resultV2(meta []map[string]interface{}) *ResultV2 {
 	var result = NewResultV2()
-	if at.field != "" {
-		if len(meta) > 0 {
-			fls, ok := meta[0][at.field]
-			if ok {
-				result.Metadata[at.GetName()] = fls
+	if len(meta) == 0 {
+		return result
+	}
+	var doc any = meta
+	if at.rmeta {
+		// first entry is the container itself
+		doc = meta[0]
+		for _, m := range meta[1:] {
+			embedded := &EmbeddedResource{
+				Path:     tikaString(m["X-TIKA:embedded_resource_path"]),
+				Name:     tikaString(m["resourceName"]),
+				Mimetype: strings.TrimSpace(strings.Split(tikaString(m["Content-Type"]), ";")[0]),
+				Source:   at.GetName(),
+				Metadata: m,
+			}
+			if depth, err := strconv.Atoi(tikaString(m["X-TIKA:embedded_depth"])); err == nil {
+				embedded.Depth = depth
 			}
+			if size, err := strconv.ParseUint(tikaString(m["Content-Length"]), 10, 64); err == nil {
+				embedded.Size = size
+			}
+			if at.field != "" {
+				embedded.Metadata = map[string]any{at.field: m[at.field]}
+			}
+			result.Embedded = append(result.Embedded, embedded)
+		}
+	}
+	if at.field != "" {
+		if fls, ok := meta[0][at.field]; ok {
+			result.Metadata[at.GetName()] = fls
 		}
 	} else {
-		result.Metadata[at.GetName()] = meta
+		result.Metadata[at.GetName()] = doc
 	}
 
-	if len(meta) > 0 {
-		if mtype, ok := meta[0]["Content-Type"]; ok {
-			if mTypeString, ok := mtype.(string); ok {
-				result.Mimetypes = append(result.Mimetypes, mTypeString)
-			}
+	if mTypeString := tikaString(meta[0]["Content-Type"]); mTypeString != "" {
+		result.Mimetypes = append(result.Mimetypes, mTypeString)
+	}
+	if durationStr := tikaString(meta[0]["xmpDM:duration"]); durationStr != "" {
+		if durationFloat, err := strconv.ParseFloat(durationStr, 64); err == nil {
+			result.Duration = uint(math.Floor(durationFloat))
 		}
 	}
-	return result, nil
+	return result
 }
 
 func (at *ActionTika) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
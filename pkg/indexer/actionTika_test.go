@@ -0,0 +1,132 @@
+package indexer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeTika answers /meta with the container only and /rmeta with the container and its embedded documents
+func fakeTika(t *testing.T) *httptest.Server {
+	container := map[string]any{
+		"Content-Type":   "application/pdf",
+		"resourceName":   "test.pdf",
+		"X-TIKA:content": "container text",
+	}
+	embedded := []map[string]any{
+		{
+			"Content-Type":                  "image/png",
+			"resourceName":                  "image0.png",
+			"Content-Length":                "1234",
+			"X-TIKA:embedded_depth":         "1",
+			"X-TIKA:embedded_resource_path": "/image0.png",
+		},
+		{
+			"Content-Type":                  "text/plain; charset=UTF-8",
+			"resourceName":                  []any{"readme.txt"},
+			"X-TIKA:embedded_depth":         "2",
+			"X-TIKA:embedded_resource_path": "/attachment.zip/readme.txt",
+			"X-TIKA:content":                "embedded text",
+		},
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		_, _ = io.Copy(io.Discard, r.Body)
+		var data any
+		switch r.URL.Path {
+		case "/meta":
+			data = container
+		case "/rmeta", "/rmeta/text":
+			data = append([]map[string]any{container}, embedded...)
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(data); err != nil {
+			t.Errorf("cannot encode tika response: %v", err)
+		}
+	}))
+}
+
+func TestActionTikaRMeta(t *testing.T) {
+	srv := fakeTika(t)
+	defer srv.Close()
+	ad := NewActionDispatcher(nil)
+
+	meta := NewActionTika(NameTika, srv.URL+"/meta", 5*time.Second, "", "", "", false, nil, ad)
+	result, err := meta.Stream("application/pdf", bytes.NewReader([]byte("%PDF-1.4")), "test.pdf")
+	if err != nil {
+		t.Fatalf("cannot stream to /meta: %v", err)
+	}
+	if len(result.Embedded) != 0 {
+		t.Errorf("/meta returned embedded resources: %v", result.Embedded)
+	}
+	if _, ok := result.Metadata[NameTika].([]map[string]interface{}); !ok {
+		t.Errorf("/meta result is not a list: %T", result.Metadata[NameTika])
+	}
+
+	rmeta := NewActionTika(NameTikaRMeta, srv.URL+"/rmeta", 5*time.Second, "", "", "", false, nil, ad).(*ActionTika)
+	rmeta.SetRMeta(true)
+	result, err = rmeta.Stream("application/pdf", bytes.NewReader([]byte("%PDF-1.4")), "test.pdf")
+	if err != nil {
+		t.Fatalf("cannot stream to /rmeta: %v", err)
+	}
+	if result.Mimetypes[0] != "application/pdf" {
+		t.Errorf("wrong container mimetype: %v", result.Mimetypes)
+	}
+	if len(result.Embedded) != 2 {
+		t.Fatalf("expected 2 embedded resources, got %d", len(result.Embedded))
+	}
+	img, txt := result.Embedded[0], result.Embedded[1]
+	if img.Path != "/image0.png" || img.Mimetype != "image/png" || img.Size != 1234 || img.Depth != 1 || img.Source != NameTikaRMeta {
+		t.Errorf("wrong image entry: %+v", img)
+	}
+	if txt.Path != "/attachment.zip/readme.txt" || txt.Name != "readme.txt" || txt.Mimetype != "text/plain" || txt.Depth != 2 {
+		t.Errorf("wrong text entry: %+v", txt)
+	}
+
+	// fulltext mode keeps only the content field of every entry
+	fulltext := NewActionTika(NameFullText, srv.URL+"/rmeta/text", 5*time.Second, "", "", "X-TIKA:content", false, nil, ad).(*ActionTika)
+	fulltext.SetRMeta(true)
+	result, err = fulltext.Stream("application/pdf", bytes.NewReader([]byte("%PDF-1.4")), "test.pdf")
+	if err != nil {
+		t.Fatalf("cannot stream to /rmeta/text: %v", err)
+	}
+	if result.Metadata[NameFullText] != "container text" {
+		t.Errorf("wrong container text: %v", result.Metadata[NameFullText])
+	}
+	if len(result.Embedded) != 2 || result.Embedded[1].Metadata["X-TIKA:content"] != "embedded text" {
+		t.Errorf("wrong embedded text: %+v", result.Embedded)
+	}
+
+	// merged results keep the embedded resources, resources with the same path are combined
+	merged := NewResultV2()
+	merged.Merge(result)
+	if len(merged.Embedded) != 2 {
+		t.Errorf("embedded resources lost in merge")
+	}
+	other := NewResultV2()
+	other.Embedded = []*EmbeddedResource{{Path: "/image0.png", Source: NameEmail, Metadata: map[string]any{NameChecksum: "sum"}}}
+	merged.Merge(other)
+	if len(merged.Embedded) != 2 || merged.Embedded[0].Source != NameFullText+","+NameEmail || merged.Embedded[0].Metadata[NameChecksum] != "sum" {
+		t.Errorf("wrong merge of embedded resource: %+v", merged.Embedded[0])
+	}
+
+	// the recursive mode is not derived from the url
+	plain := NewActionTika(NameTika, srv.URL+"/rmeta", 5*time.Second, "", "", "", false, nil, ad)
+	result, err = plain.Stream("application/pdf", bytes.NewReader([]byte("%PDF-1.4")), "test.pdf")
+	if err != nil {
+		t.Fatalf("cannot stream to /rmeta: %v", err)
+	}
+	if len(result.Embedded) != 0 {
+		t.Errorf("embedded resources without rmeta mode: %v", result.Embedded)
+	}
+}
@@ -0,0 +1,651 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indexer
+
+import (
+	"bufio"
+	"bytes"
+	"emperror.dev/errors"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	TruncationComplete     = "complete"
+	TruncationTruncated    = "truncated"
+	TruncationTrailingData = "trailing-data"
+	TruncationCorrupt      = "corrupt"
+)
+
+var regexpTruncationMime = regexp.MustCompile("^(image/(jpeg|png|gif|bmp|webp|x-ms-bmp)|application/(pdf|zip|epub\\+zip|java-archive|vnd\\.openxmlformats-.+|vnd\\.oasis\\.opendocument\\..+)|audio/(wav|x-wav|aiff|x-aiff)|video/(avi|x-msvideo))$")
+
+// TruncationResult describes whether a stream ends where its format says it should.
+// All offsets are byte offsets from the start of the stream.
+type TruncationResult struct {
+	Format   string `json:"format"`
+	Status   string `json:"status"`
+	Size     int64  `json:"size"`
+	End      int64  `json:"end,omitempty"`      // offset directly after the end-of-stream marker
+	Expected int64  `json:"expected,omitempty"` // size announced by an internal length field
+	Offset   int64  `json:"offset,omitempty"`   // offset where a problem was detected
+	Message  string `json:"message,omitempty"`
+}
+
+type ActionTruncation struct {
+	name   string
+	server *Server
+}
+
+func (at *ActionTruncation) CanHandle(contentType string, filename string) bool {
+	if regexpTruncationMime.MatchString(contentType) {
+		return true
+	}
+	return slices.Contains(
+		[]string{
+			".jpg", ".jpeg", ".jpe", ".jfif", ".png", ".gif", ".bmp", ".webp",
+			".pdf", ".zip", ".jar", ".epub", ".docx", ".xlsx", ".pptx", ".odt",
+			".ods", ".odp", ".wav", ".avi", ".aif", ".aiff"},
+		strings.ToLower(filepath.Ext(filename)))
+}
+
+func NewActionTruncation(name string, server *Server, ad *ActionDispatcher) Action {
+	at := &ActionTruncation{name: name, server: server}
+	ad.RegisterAction(at)
+	return at
+}
+
+func (at *ActionTruncation) GetWeight() uint {
+	return 20
+}
+
+func (at *ActionTruncation) GetCaps() ActionCapability {
+	return ACTFILEFULL | ACTSTREAM
+}
+
+func (at *ActionTruncation) GetName() string {
+	return at.name
+}
+
+func (at *ActionTruncation) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
+	tr := &truncReader{br: bufio.NewReaderSize(reader, 64*1024)}
+	head, _ := tr.br.Peek(12)
+	var check func(*truncReader) (*TruncationResult, error)
+	switch {
+	case bytes.HasPrefix(head, []byte{0xff, 0xd8, 0xff}):
+		check = checkJPEG
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		check = checkPNG
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		check = checkGIF
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		check = checkPDF
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		check = checkZIP
+	case bytes.HasPrefix(head, []byte("RIFF")), bytes.HasPrefix(head, []byte("FORM")):
+		check = checkRIFF
+	case bytes.HasPrefix(head, []byte("BM")) && len(head) >= 6:
+		check = checkBMP
+	default:
+		return nil, nil
+	}
+	tresult, err := check(tr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot check '%s'", filename)
+	}
+	// count everything that follows the format-defined end
+	n, err := io.Copy(io.Discard, tr.br)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read '%s'", filename)
+	}
+	tr.pos += n
+	tresult.Size = tr.pos
+	if tresult.Status == TruncationComplete && tresult.End > 0 && tresult.End < tresult.Size {
+		tresult.Status = TruncationTrailingData
+		tresult.Offset = tresult.End
+		tresult.Message = fmt.Sprintf("%d bytes after end of %s", tresult.Size-tresult.End, tresult.Format)
+	}
+	if tresult.Expected > 0 && tresult.Status == TruncationComplete {
+		switch {
+		case tresult.Size < tresult.Expected:
+			tresult.Status = TruncationTruncated
+			tresult.Offset = tresult.Size
+			tresult.Message = fmt.Sprintf("%d bytes missing", tresult.Expected-tresult.Size)
+		case tresult.Size > tresult.Expected:
+			tresult.Status = TruncationTrailingData
+			tresult.Offset = tresult.Expected
+			tresult.Message = fmt.Sprintf("%d bytes after end of %s", tresult.Size-tresult.Expected, tresult.Format)
+		}
+	}
+	var result = NewResultV2()
+	result.Metadata[at.GetName()] = tresult
+	return result, nil
+}
+
+func (at *ActionTruncation) DoV2(filename string) (*ResultV2, error) {
+	reader, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open file '%s'", filename)
+	}
+	defer reader.Close()
+	return at.Stream("", reader, filename)
+}
+
+func (at *ActionTruncation) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
+	filename, err := at.server.fm.Get(uri)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "no file url")
+	}
+
+	fp, err := os.OpenFile(filename, os.O_RDONLY, 0644)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "cannot open file %s", filename)
+	}
+	defer fp.Close()
+
+	result, err := at.Stream("", fp, filename)
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+	if result == nil {
+		return nil, nil, nil, ErrMimeNotApplicable
+	}
+	return result.Metadata[at.GetName()], result.Mimetypes, result.Pronoms, nil
+}
+
+// truncReader keeps track of the current stream offset
+type truncReader struct {
+	br  *bufio.Reader
+	pos int64
+}
+
+func (tr *truncReader) readByte() (byte, error) {
+	b, err := tr.br.ReadByte()
+	if err == nil {
+		tr.pos++
+	}
+	return b, err
+}
+
+func (tr *truncReader) readFull(buf []byte) error {
+	n, err := io.ReadFull(tr.br, buf)
+	tr.pos += int64(n)
+	return err
+}
+
+func (tr *truncReader) skip(n int64) error {
+	for n > 0 {
+		chunk := int(min(n, 1<<30))
+		d, err := tr.br.Discard(chunk)
+		tr.pos += int64(d)
+		n -= int64(d)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readTail consumes the whole stream and returns at most the last size bytes
+func (tr *truncReader) readTail(size int) ([]byte, error) {
+	var tail = make([]byte, 0, 2*size)
+	var buf = make([]byte, 32*1024)
+	for {
+		n, err := tr.br.Read(buf)
+		tr.pos += int64(n)
+		tail = append(tail, buf[:n]...)
+		if len(tail) > size {
+			tail = append(tail[:0], tail[len(tail)-size:]...)
+		}
+		if err == io.EOF {
+			return tail, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readLastMarker consumes the whole stream and returns at most size bytes up to and including the last
+// occurrence of marker. end is the offset after the marker and the cutset characters following it.
+// head is nil, if the marker was not found
+func (tr *truncReader) readLastMarker(marker []byte, size int, cutset string) (head []byte, end int64, err error) {
+	var win = make([]byte, 0, 2*size)
+	var winStart int64
+	var buf = make([]byte, 32*1024)
+	var found = int64(-1)
+	var trimming bool
+	for {
+		n, rerr := tr.br.Read(buf)
+		tr.pos += int64(n)
+		win = append(win, buf[:n]...)
+		if idx := bytes.LastIndex(win, marker); idx >= 0 && winStart+int64(idx) > found {
+			found = winStart + int64(idx)
+			head = bytes.Clone(win[max(0, idx+len(marker)-size) : idx+len(marker)])
+			end = found + int64(len(marker))
+			trimming = true
+		}
+		for trimming && end < winStart+int64(len(win)) {
+			if !strings.ContainsRune(cutset, rune(win[end-winStart])) {
+				trimming = false
+				break
+			}
+			end++
+		}
+		// keep enough bytes to find a marker crossing the chunk border
+		if keep := size + len(marker); len(win) > keep {
+			winStart += int64(len(win) - keep)
+			win = append(win[:0], win[len(win)-keep:]...)
+		}
+		if rerr == io.EOF {
+			return head, end, nil
+		}
+		if rerr != nil {
+			return nil, 0, rerr
+		}
+	}
+}
+
+func newTruncatedResult(format string, tr *truncReader, msg string) *TruncationResult {
+	return &TruncationResult{
+		Format:  format,
+		Status:  TruncationTruncated,
+		Offset:  tr.pos,
+		Message: msg,
+	}
+}
+
+func newCorruptResult(format string, offset int64, msg string) *TruncationResult {
+	return &TruncationResult{
+		Format:  format,
+		Status:  TruncationCorrupt,
+		Offset:  offset,
+		Message: msg,
+	}
+}
+
+func isTruncationEOF(err error) bool {
+	return err == io.EOF || err == io.ErrUnexpectedEOF
+}
+
+func checkJPEG(tr *truncReader) (*TruncationResult, error) {
+	const format = "jpeg"
+	var soi = make([]byte, 2)
+	if err := tr.readFull(soi); err != nil {
+		return nil, err
+	}
+	var inScan bool
+	for {
+		b, err := tr.readByte()
+		if err != nil {
+			if isTruncationEOF(err) {
+				return newTruncatedResult(format, tr, "missing EOI marker"), nil
+			}
+			return nil, err
+		}
+		if b != 0xff {
+			if inScan {
+				continue
+			}
+			return newCorruptResult(format, tr.pos-1, fmt.Sprintf("expected marker, found 0x%02x", b)), nil
+		}
+		// skip fill bytes
+		for b == 0xff {
+			if b, err = tr.readByte(); err != nil {
+				if isTruncationEOF(err) {
+					return newTruncatedResult(format, tr, "missing EOI marker"), nil
+				}
+				return nil, err
+			}
+		}
+		switch {
+		case b == 0x00 && inScan:
+			// stuffed byte within entropy coded data
+			continue
+		case b >= 0xd0 && b <= 0xd7, b == 0x01:
+			// restart markers and TEM have no length
+			continue
+		case b == 0xd9:
+			return &TruncationResult{Format: format, Status: TruncationComplete, End: tr.pos}, nil
+		}
+		var lenBuf = make([]byte, 2)
+		if err := tr.readFull(lenBuf); err != nil {
+			if isTruncationEOF(err) {
+				return newTruncatedResult(format, tr, fmt.Sprintf("segment 0x%02x truncated", b)), nil
+			}
+			return nil, err
+		}
+		segLen := int64(binary.BigEndian.Uint16(lenBuf))
+		if segLen < 2 {
+			return newCorruptResult(format, tr.pos-2, fmt.Sprintf("invalid length %d in segment 0x%02x", segLen, b)), nil
+		}
+		if err := tr.skip(segLen - 2); err != nil {
+			if isTruncationEOF(err) {
+				return newTruncatedResult(format, tr, fmt.Sprintf("segment 0x%02x truncated", b)), nil
+			}
+			return nil, err
+		}
+		inScan = b == 0xda
+	}
+}
+
+func checkPNG(tr *truncReader) (*TruncationResult, error) {
+	const format = "png"
+	var sig = make([]byte, 8)
+	if err := tr.readFull(sig); err != nil {
+		return nil, err
+	}
+	var hdr = make([]byte, 8)
+	var crcBuf = make([]byte, 4)
+	for {
+		chunkStart := tr.pos
+		if err := tr.readFull(hdr); err != nil {
+			if isTruncationEOF(err) {
+				return newTruncatedResult(format, tr, "missing IEND chunk"), nil
+			}
+			return nil, err
+		}
+		chunkLen := int64(binary.BigEndian.Uint32(hdr[:4]))
+		chunkType := string(hdr[4:])
+		if chunkLen > 0x7fffffff {
+			return newCorruptResult(format, chunkStart, fmt.Sprintf("invalid length %d of chunk %q", chunkLen, chunkType)), nil
+		}
+		crc := crc32.NewIEEE()
+		crc.Write(hdr[4:])
+		n, err := io.CopyN(crc, tr.br, chunkLen)
+		tr.pos += n
+		if err != nil {
+			if isTruncationEOF(err) {
+				return newTruncatedResult(format, tr, fmt.Sprintf("chunk %q truncated", chunkType)), nil
+			}
+			return nil, err
+		}
+		if err := tr.readFull(crcBuf); err != nil {
+			if isTruncationEOF(err) {
+				return newTruncatedResult(format, tr, fmt.Sprintf("crc of chunk %q truncated", chunkType)), nil
+			}
+			return nil, err
+		}
+		if crc.Sum32() != binary.BigEndian.Uint32(crcBuf) {
+			return newCorruptResult(format, chunkStart, fmt.Sprintf("crc mismatch in chunk %q", chunkType)), nil
+		}
+		if chunkType == "IEND" {
+			return &TruncationResult{Format: format, Status: TruncationComplete, End: tr.pos}, nil
+		}
+	}
+}
+
+// skipGIFSubBlocks skips a sequence of data sub-blocks up to the block terminator
+func skipGIFSubBlocks(tr *truncReader) error {
+	for {
+		size, err := tr.readByte()
+		if err != nil {
+			return err
+		}
+		if size == 0 {
+			return nil
+		}
+		if err := tr.skip(int64(size)); err != nil {
+			return err
+		}
+	}
+}
+
+func checkGIF(tr *truncReader) (*TruncationResult, error) {
+	const format = "gif"
+	// header and logical screen descriptor
+	var hdr = make([]byte, 13)
+	if err := tr.readFull(hdr); err != nil {
+		if isTruncationEOF(err) {
+			return newTruncatedResult(format, tr, "header truncated"), nil
+		}
+		return nil, err
+	}
+	if hdr[10]&0x80 != 0 {
+		if err := tr.skip(3 << ((hdr[10] & 0x07) + 1)); err != nil {
+			if isTruncationEOF(err) {
+				return newTruncatedResult(format, tr, "global color table truncated"), nil
+			}
+			return nil, err
+		}
+	}
+	for {
+		blockStart := tr.pos
+		b, err := tr.readByte()
+		if err != nil {
+			if isTruncationEOF(err) {
+				return newTruncatedResult(format, tr, "missing trailer"), nil
+			}
+			return nil, err
+		}
+		switch b {
+		case 0x3b:
+			return &TruncationResult{Format: format, Status: TruncationComplete, End: tr.pos}, nil
+		case 0x21:
+			if _, err := tr.readByte(); err != nil {
+				if isTruncationEOF(err) {
+					return newTruncatedResult(format, tr, "extension truncated"), nil
+				}
+				return nil, err
+			}
+			if err := skipGIFSubBlocks(tr); err != nil {
+				if isTruncationEOF(err) {
+					return newTruncatedResult(format, tr, "extension truncated"), nil
+				}
+				return nil, err
+			}
+		case 0x2c:
+			var desc = make([]byte, 9)
+			if err := tr.readFull(desc); err != nil {
+				if isTruncationEOF(err) {
+					return newTruncatedResult(format, tr, "image descriptor truncated"), nil
+				}
+				return nil, err
+			}
+			if desc[8]&0x80 != 0 {
+				if err := tr.skip(3 << ((desc[8] & 0x07) + 1)); err != nil {
+					if isTruncationEOF(err) {
+						return newTruncatedResult(format, tr, "local color table truncated"), nil
+					}
+					return nil, err
+				}
+			}
+			// lzw minimum code size
+			if _, err := tr.readByte(); err != nil {
+				if isTruncationEOF(err) {
+					return newTruncatedResult(format, tr, "image data truncated"), nil
+				}
+				return nil, err
+			}
+			if err := skipGIFSubBlocks(tr); err != nil {
+				if isTruncationEOF(err) {
+					return newTruncatedResult(format, tr, "image data truncated"), nil
+				}
+				return nil, err
+			}
+		default:
+			return newCorruptResult(format, blockStart, fmt.Sprintf("invalid block introducer 0x%02x", b)), nil
+		}
+	}
+}
+
+var regexpPDFStartXRef = regexp.MustCompile(`startxref\s+([0-9]+)\s+%%EOF`)
+
+func checkPDF(tr *truncReader) (*TruncationResult, error) {
+	const format = "pdf"
+	const headSize = 4096
+	head, end, err := tr.readLastMarker([]byte("%%EOF"), headSize, "\r\n \t\x00")
+	if err != nil {
+		return nil, err
+	}
+	if head == nil {
+		return newTruncatedResult(format, tr, "missing %%EOF marker"), nil
+	}
+	tresult := &TruncationResult{Format: format, Status: TruncationComplete, End: end}
+	if m := regexpPDFStartXRef.FindSubmatch(head); m != nil {
+		var xref int64
+		fmt.Sscan(string(m[1]), &xref)
+		if xref >= end {
+			return newCorruptResult(format, end, fmt.Sprintf("startxref %d beyond end of file", xref)), nil
+		}
+	}
+	return tresult, nil
+}
+
+// findZIPEOCD returns the position of the end of central directory record within tail.
+// the signature can be part of the archive comment, so the record, whose comment ends exactly at the
+// end of the stream, is preferred. otherwise the last record with a valid comment length is used (trailing data).
+// if no comment fits, the last signature is returned (truncated comment)
+func findZIPEOCD(tail []byte, tailStart int64) int {
+	var fits, last = -1, -1
+	for end := len(tail); ; {
+		idx := bytes.LastIndex(tail[:end], []byte("PK\x05\x06"))
+		if idx < 0 {
+			break
+		}
+		end = idx
+		if len(tail)-idx < 22 {
+			continue
+		}
+		eocd := tail[idx:]
+		cdSize := int64(binary.LittleEndian.Uint32(eocd[12:16]))
+		cdOffset := int64(binary.LittleEndian.Uint32(eocd[16:20]))
+		// central directory must precede the record, zip64 values are checked later
+		if cdOffset != 0xffffffff && cdSize != 0xffffffff && cdOffset+cdSize > tailStart+int64(idx) {
+			continue
+		}
+		if last < 0 {
+			last = idx
+		}
+		commentEnd := idx + 22 + int(binary.LittleEndian.Uint16(eocd[20:22]))
+		if commentEnd == len(tail) {
+			return idx
+		}
+		if commentEnd < len(tail) && fits < 0 {
+			fits = idx
+		}
+	}
+	if fits >= 0 {
+		return fits
+	}
+	return last
+}
+
+func checkZIP(tr *truncReader) (*TruncationResult, error) {
+	const format = "zip"
+	// end of central directory record (22 bytes) plus maximum comment length
+	// plus zip64 locator
+	const tailSize = 22 + 0xffff + 20
+	tail, err := tr.readTail(tailSize)
+	if err != nil {
+		return nil, err
+	}
+	size := tr.pos
+	tailStart := size - int64(len(tail))
+	idx := findZIPEOCD(tail, tailStart)
+	if idx < 0 {
+		return newTruncatedResult(format, tr, "missing end of central directory record"), nil
+	}
+	eocd := tail[idx:]
+	eocdPos := tailStart + int64(idx)
+	commentLen := int64(binary.LittleEndian.Uint16(eocd[20:22]))
+	cdSize := int64(binary.LittleEndian.Uint32(eocd[12:16]))
+	cdOffset := int64(binary.LittleEndian.Uint32(eocd[16:20]))
+	end := eocdPos + 22 + commentLen
+	if end > size {
+		return newTruncatedResult(format, tr, "end of central directory comment truncated"), nil
+	}
+	cdEnd := eocdPos
+	if cdOffset == 0xffffffff || cdSize == 0xffffffff {
+		// zip64: locator directly precedes the end of central directory record
+		if idx < 20 || !bytes.Equal(tail[idx-20:idx-16], []byte("PK\x06\x07")) {
+			return newCorruptResult(format, eocdPos, "missing zip64 end of central directory locator"), nil
+		}
+		zip64Pos := int64(binary.LittleEndian.Uint64(tail[idx-12 : idx-4]))
+		rel := zip64Pos - tailStart
+		if rel < 0 || rel+56 > int64(len(tail)) {
+			// record outside of our tail window, cannot verify further
+			return &TruncationResult{Format: format, Status: TruncationComplete, End: end}, nil
+		}
+		rec := tail[rel:]
+		if !bytes.HasPrefix(rec, []byte("PK\x06\x06")) {
+			return newCorruptResult(format, zip64Pos, "invalid zip64 end of central directory record"), nil
+		}
+		cdSize = int64(binary.LittleEndian.Uint64(rec[40:48]))
+		cdOffset = int64(binary.LittleEndian.Uint64(rec[48:56]))
+		cdEnd = zip64Pos
+	}
+	if cdOffset+cdSize > cdEnd {
+		return newCorruptResult(format, cdOffset, fmt.Sprintf("central directory (offset %d, size %d) overlaps end record at %d", cdOffset, cdSize, cdEnd)), nil
+	}
+	if rel := cdOffset - tailStart; rel >= 0 && rel+4 <= int64(len(tail)) && cdSize > 0 {
+		if !bytes.HasPrefix(tail[rel:], []byte("PK\x01\x02")) {
+			return newCorruptResult(format, cdOffset, "no central directory header at announced offset"), nil
+		}
+	}
+	tresult := &TruncationResult{Format: format, Status: TruncationComplete, End: end}
+	if cdOffset+cdSize < cdEnd {
+		// data between central directory and end record (e.g. prepended stub with wrong offsets)
+		tresult.Status = TruncationCorrupt
+		tresult.Offset = cdOffset + cdSize
+		tresult.Message = fmt.Sprintf("%d unexpected bytes before end of central directory", cdEnd-cdOffset-cdSize)
+	}
+	return tresult, nil
+}
+
+func checkRIFF(tr *truncReader) (*TruncationResult, error) {
+	var hdr = make([]byte, 12)
+	if err := tr.readFull(hdr); err != nil {
+		if isTruncationEOF(err) {
+			return newTruncatedResult("riff", tr, "header truncated"), nil
+		}
+		return nil, err
+	}
+	var format string
+	var chunkSize int64
+	if string(hdr[:4]) == "FORM" {
+		format = "iff/" + strings.TrimSpace(string(hdr[8:12]))
+		chunkSize = int64(binary.BigEndian.Uint32(hdr[4:8]))
+	} else {
+		format = "riff/" + strings.TrimSpace(string(hdr[8:12]))
+		chunkSize = int64(binary.LittleEndian.Uint32(hdr[4:8]))
+	}
+	tresult := &TruncationResult{Format: strings.ToLower(format), Status: TruncationComplete}
+	tresult.Expected = chunkSize + 8
+	return tresult, nil
+}
+
+func checkBMP(tr *truncReader) (*TruncationResult, error) {
+	const format = "bmp"
+	var hdr = make([]byte, 6)
+	if err := tr.readFull(hdr); err != nil {
+		return nil, err
+	}
+	tresult := &TruncationResult{Format: format, Status: TruncationComplete}
+	// some writers leave the size field empty
+	tresult.Expected = int64(binary.LittleEndian.Uint32(hdr[2:6]))
+	return tresult, nil
+}
+
+var (
+	_ Action = &ActionTruncation{}
+)
@@ -0,0 +1,152 @@
+package indexer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func truncationTestFiles(t *testing.T) map[string][]byte {
+	img := image.NewGray(image.Rect(0, 0, 32, 32))
+	files := map[string][]byte{}
+
+	var buf = &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatalf("cannot encode png: %v", err)
+	}
+	files["png"] = buf.Bytes()
+
+	buf = &bytes.Buffer{}
+	if err := jpeg.Encode(buf, img, nil); err != nil {
+		t.Fatalf("cannot encode jpeg: %v", err)
+	}
+	files["jpeg"] = buf.Bytes()
+
+	buf = &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	w, err := zw.Create("test.txt")
+	if err != nil {
+		t.Fatalf("cannot create zip entry: %v", err)
+	}
+	w.Write(bytes.Repeat([]byte("indexer "), 100))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("cannot close zip: %v", err)
+	}
+	files["zip"] = buf.Bytes()
+
+	buf = &bytes.Buffer{}
+	if err := gif.Encode(buf, img, nil); err != nil {
+		t.Fatalf("cannot encode gif: %v", err)
+	}
+	files["gif"] = buf.Bytes()
+
+	// wave file with 64 bytes of silence
+	wav := []byte("RIFF\x00\x00\x00\x00WAVEfmt \x10\x00\x00\x00\x01\x00\x01\x00\x40\x1f\x00\x00\x40\x1f\x00\x00\x01\x00\x08\x00data\x40\x00\x00\x00")
+	wav = append(wav, bytes.Repeat([]byte{0x80}, 64)...)
+	binary.LittleEndian.PutUint32(wav[4:8], uint32(len(wav)-8))
+	files["riff/wave"] = wav
+
+	// 4x4 pixel 24 bit bitmap
+	bmp := make([]byte, 54+4*4*3)
+	copy(bmp, "BM")
+	binary.LittleEndian.PutUint32(bmp[2:6], uint32(len(bmp)))
+	binary.LittleEndian.PutUint32(bmp[10:14], 54)
+	binary.LittleEndian.PutUint32(bmp[14:18], 40)
+	binary.LittleEndian.PutUint32(bmp[18:22], 4)
+	binary.LittleEndian.PutUint32(bmp[22:26], 4)
+	binary.LittleEndian.PutUint16(bmp[26:28], 1)
+	binary.LittleEndian.PutUint16(bmp[28:30], 24)
+	files["bmp"] = bmp
+
+	files["pdf"] = []byte("%PDF-1.4\n1 0 obj\n<<>>\nendobj\nxref\n0 1\ntrailer\n<<>>\nstartxref\n28\n%%EOF\n")
+	return files
+}
+
+func TestActionTruncation(t *testing.T) {
+	at := &ActionTruncation{name: NameTruncation}
+	for format, data := range truncationTestFiles(t) {
+		tests := map[string][]byte{
+			TruncationComplete:     data,
+			TruncationTruncated:    data[:len(data)-10],
+			TruncationTrailingData: append(bytes.Clone(data), []byte("garbage")...),
+		}
+		for status, content := range tests {
+			result, err := at.Stream("", bytes.NewReader(content), "test.bin")
+			if err != nil {
+				t.Errorf("%s/%s: %v", format, status, err)
+				continue
+			}
+			tresult, ok := result.Metadata[NameTruncation].(*TruncationResult)
+			if !ok {
+				t.Errorf("%s/%s: no truncation result", format, status)
+				continue
+			}
+			if tresult.Format != format {
+				t.Errorf("%s/%s: format is %s", format, status, tresult.Format)
+			}
+			if tresult.Status != status {
+				t.Errorf("%s/%s: status is %s (%s)", format, status, tresult.Status, tresult.Message)
+			}
+			if tresult.Size != int64(len(content)) {
+				t.Errorf("%s/%s: size %d != %d", format, status, tresult.Size, len(content))
+			}
+		}
+	}
+}
+
+// the end of central directory signature within the archive comment must not be used
+func TestActionTruncationZIPComment(t *testing.T) {
+	var buf = &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	w, err := zw.Create("test.txt")
+	if err != nil {
+		t.Fatalf("cannot create zip entry: %v", err)
+	}
+	w.Write([]byte("indexer"))
+	if err := zw.SetComment("comment PK\x05\x06 with end of central directory signature and some more text"); err != nil {
+		t.Fatalf("cannot set comment: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("cannot close zip: %v", err)
+	}
+	at := &ActionTruncation{name: NameTruncation}
+	for status, content := range map[string][]byte{
+		TruncationComplete:     buf.Bytes(),
+		TruncationTrailingData: append(bytes.Clone(buf.Bytes()), []byte("garbage")...),
+		TruncationTruncated:    buf.Bytes()[:buf.Len()-10],
+	} {
+		result, err := at.Stream("", bytes.NewReader(content), "test.zip")
+		if err != nil {
+			t.Fatalf("%s: %v", status, err)
+		}
+		tresult := result.Metadata[NameTruncation].(*TruncationResult)
+		if tresult.Status != status {
+			t.Errorf("%s: status is %s (%s)", status, tresult.Status, tresult.Message)
+		}
+		if status == TruncationTrailingData && tresult.Offset != int64(buf.Len()) {
+			t.Errorf("trailing data at %d, expected %d", tresult.Offset, buf.Len())
+		}
+	}
+}
+
+// data of any size after the last %%EOF is trailing data, not a truncation
+func TestActionTruncationPDFTrailingData(t *testing.T) {
+	pdf := truncationTestFiles(t)["pdf"]
+	at := &ActionTruncation{name: NameTruncation}
+	for _, size := range []int{10, 4096, 100 * 1024} {
+		content := append(bytes.Clone(pdf), bytes.Repeat([]byte{'x'}, size)...)
+		result, err := at.Stream("", bytes.NewReader(content), "test.pdf")
+		if err != nil {
+			t.Fatalf("%d: %v", size, err)
+		}
+		tresult := result.Metadata[NameTruncation].(*TruncationResult)
+		if tresult.Status != TruncationTrailingData || tresult.Offset != int64(len(pdf)) || tresult.Size != int64(len(content)) {
+			t.Errorf("%d: status is %s at %d (%s)", size, tresult.Status, tresult.Offset, tresult.Message)
+		}
+	}
+}
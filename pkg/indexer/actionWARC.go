@@ -0,0 +1,464 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indexer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"emperror.dev/errors"
+	"encoding/base32"
+	"encoding/hex"
+	"hash"
+	"io"
+	"mime"
+	"net/http/httputil"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type WARCRecord struct {
+	Type               string `json:"type"`
+	TargetURI          string `json:"targeturi,omitempty"`
+	Date               string `json:"date,omitempty"`
+	Length             int64  `json:"length"`
+	Status             int    `json:"status,omitempty"`
+	ContentType        string `json:"contenttype,omitempty"`
+	PayloadDigest      string `json:"payloaddigest,omitempty"`
+	PayloadDigestValid *bool  `json:"payloaddigestvalid,omitempty"`
+	BlockDigestValid   *bool  `json:"blockdigestvalid,omitempty"`
+	Error              string `json:"error,omitempty"`
+	embedded           *EmbeddedResource
+}
+
+type WARCResult struct {
+	Format          string         `json:"format"`
+	Compressed      bool           `json:"compressed"`
+	RecordCount     int            `json:"recordcount"`
+	RecordTypes     map[string]int `json:"recordtypes"`
+	StatusCodes     map[string]int `json:"statuscodes"`
+	ContentTypes    map[string]int `json:"contenttypes"`
+	DigestsVerified int            `json:"digestsverified"`
+	DigestErrors    int            `json:"digesterrors"`
+	Records         []*WARCRecord  `json:"records"`
+	Error           string         `json:"error,omitempty"`
+	embedded        []*EmbeddedResource
+}
+
+type ActionWARC struct {
+	name             string
+	maxRecords       int
+	identifyPayloads bool
+	actions          []string
+	server           *Server
+	ad               *ActionDispatcher
+}
+
+// CanHandle accepts gzip only with a warc or arc extension, other gzip files are not decompressed
+func (aw *ActionWARC) CanHandle(contentType string, filename string) bool {
+	if slices.Contains([]string{"application/warc", "application/x-internet-archive"}, contentType) {
+		return true
+	}
+	fname := strings.ToLower(filename)
+	for _, ext := range []string{".warc", ".warc.gz", ".arc", ".arc.gz"} {
+		if strings.HasSuffix(fname, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewActionWARC creates an action for WARC/1.0, WARC/1.1 and ARC files.
+// if identifyPayloads is true, payloads are indexed with the given actions of ad
+func NewActionWARC(name string, maxRecords int, identifyPayloads bool, actions []string, server *Server, ad *ActionDispatcher) Action {
+	aw := &ActionWARC{
+		name:             name,
+		maxRecords:       maxRecords,
+		identifyPayloads: identifyPayloads,
+		server:           server,
+		ad:               ad,
+	}
+	for _, a := range actions {
+		if a != name {
+			aw.actions = append(aw.actions, a)
+		}
+	}
+	ad.RegisterAction(aw)
+	return aw
+}
+
+func (aw *ActionWARC) GetWeight() uint {
+	return 50
+}
+
+func (aw *ActionWARC) GetCaps() ActionCapability {
+	return ACTFILEFULL | ACTSTREAM
+}
+
+func (aw *ActionWARC) GetName() string {
+	return aw.name
+}
+
+func (aw *ActionWARC) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
+	br := bufio.NewReaderSize(reader, 64*1024)
+	wresult := &WARCResult{
+		RecordTypes:  map[string]int{},
+		StatusCodes:  map[string]int{},
+		ContentTypes: map[string]int{},
+		Records:      []*WARCRecord{},
+	}
+	if head, _ := br.Peek(2); bytes.Equal(head, []byte{0x1f, 0x8b}) {
+		// gzip reader reads concatenated members (per-record gzip) as one stream
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot open gzip stream of '%s'", filename)
+		}
+		defer gz.Close()
+		br = bufio.NewReaderSize(gz, 64*1024)
+		wresult.Compressed = true
+	}
+	head, _ := br.Peek(11)
+	var err error
+	switch {
+	case bytes.HasPrefix(head, []byte("WARC/")):
+		err = aw.readWARC(br, wresult)
+	case bytes.HasPrefix(head, []byte("filedesc://")):
+		wresult.Format = "arc"
+		err = aw.readARC(br, wresult)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		// keep what we have, a broken record at the end should not hide the rest
+		wresult.Error = err.Error()
+	}
+	var result = NewResultV2()
+	if wresult.Format == "arc" {
+		result.Mimetypes = append(result.Mimetypes, "application/x-internet-archive")
+	} else {
+		result.Mimetypes = append(result.Mimetypes, "application/warc")
+	}
+	result.Embedded = wresult.embedded
+	result.Metadata[aw.GetName()] = wresult
+	return result, nil
+}
+
+func newWARCDigest(algorithm string) hash.Hash {
+	switch strings.ToLower(strings.ReplaceAll(algorithm, "-", "")) {
+	case "sha1":
+		return sha1.New()
+	case "sha256":
+		return sha256.New()
+	case "sha512":
+		return sha512.New()
+	case "md5":
+		return md5.New()
+	}
+	return nil
+}
+
+// warcDigest holds a declared digest and computes the actual value
+type warcDigest struct {
+	declared string
+	value    string
+	hash     hash.Hash
+}
+
+func newWARCDigestCheck(declared string) *warcDigest {
+	alg, value, ok := strings.Cut(declared, ":")
+	if !ok {
+		return nil
+	}
+	h := newWARCDigest(alg)
+	if h == nil {
+		return nil
+	}
+	return &warcDigest{declared: declared, value: value, hash: h}
+}
+
+// valid compares the declared value in base32 or hex representation
+func (wd *warcDigest) valid() bool {
+	sum := wd.hash.Sum(nil)
+	return strings.EqualFold(wd.value, base32.StdEncoding.EncodeToString(sum)) ||
+		strings.EqualFold(wd.value, hex.EncodeToString(sum))
+}
+
+func (aw *ActionWARC) addRecord(wresult *WARCResult, rec *WARCRecord) {
+	wresult.RecordCount++
+	wresult.RecordTypes[rec.Type]++
+	if rec.Status > 0 {
+		wresult.StatusCodes[strconv.Itoa(rec.Status)]++
+	}
+	if rec.ContentType != "" {
+		wresult.ContentTypes[rec.ContentType]++
+	}
+	for _, valid := range []*bool{rec.PayloadDigestValid, rec.BlockDigestValid} {
+		if valid == nil {
+			continue
+		}
+		wresult.DigestsVerified++
+		if !*valid {
+			wresult.DigestErrors++
+		}
+	}
+	if aw.maxRecords <= 0 || len(wresult.Records) < aw.maxRecords {
+		wresult.Records = append(wresult.Records, rec)
+	}
+	if rec.embedded != nil {
+		wresult.embedded = append(wresult.embedded, rec.embedded)
+	}
+}
+
+func (aw *ActionWARC) readWARC(br *bufio.Reader, wresult *WARCResult) error {
+	tp := textproto.NewReader(br)
+	for {
+		line, err := tp.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "cannot read record header")
+		}
+		if line == "" {
+			// record separator
+			continue
+		}
+		if !strings.HasPrefix(line, "WARC/") {
+			return errors.Errorf("invalid warc version line '%s'", line)
+		}
+		if wresult.Format == "" {
+			wresult.Format = strings.ToLower(line)
+		}
+		header, err := tp.ReadMIMEHeader()
+		if err != nil && err != io.EOF {
+			return errors.Wrap(err, "cannot read warc header")
+		}
+		length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "invalid content-length '%s'", header.Get("Content-Length"))
+		}
+		rec := &WARCRecord{
+			Type:      header.Get("WARC-Type"),
+			TargetURI: strings.Trim(header.Get("WARC-Target-URI"), "<>"),
+			Date:      header.Get("WARC-Date"),
+			Length:    length,
+		}
+		content := &countingReader{r: io.LimitReader(br, length)}
+		var block io.Reader = content
+		blockDigest := newWARCDigestCheck(header.Get("WARC-Block-Digest"))
+		if blockDigest != nil {
+			block = io.TeeReader(content, blockDigest.hash)
+		}
+		payloadDigest := newWARCDigestCheck(header.Get("WARC-Payload-Digest"))
+		if payloadDigest != nil {
+			rec.PayloadDigest = payloadDigest.declared
+		}
+		complete := aw.readPayload(rec, header.Get("Content-Type"), block, payloadDigest)
+		if _, err := io.Copy(io.Discard, block); err != nil {
+			return errors.Wrap(err, "cannot read record content")
+		}
+		if content.n < length {
+			rec.Error = "record truncated"
+			aw.addRecord(wresult, rec)
+			return errors.Errorf("record '%s' truncated", rec.TargetURI)
+		}
+		if blockDigest != nil {
+			valid := blockDigest.valid()
+			rec.BlockDigestValid = &valid
+		}
+		// a payload, which could not be parsed, is not verified
+		if payloadDigest != nil && complete {
+			valid := payloadDigest.valid()
+			rec.PayloadDigestValid = &valid
+		} else if payloadDigest != nil && rec.Error == "" {
+			rec.Error = "payload digest not verified, invalid http message"
+		}
+		aw.addRecord(wresult, rec)
+	}
+}
+
+// readPayload parses http messages and feeds the payload to digest and dispatcher.
+// complete is false, if the payload could not be separated from the http message
+func (aw *ActionWARC) readPayload(rec *WARCRecord, contentType string, block io.Reader, payloadDigest *warcDigest) (complete bool) {
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	payload := block
+	var payloadType = mediaType
+	var chunked bool
+	if mediaType == "application/http" {
+		bbr := bufio.NewReader(block)
+		tp := textproto.NewReader(bbr)
+		statusLine, err := tp.ReadLine()
+		if err != nil {
+			return false
+		}
+		if params["msgtype"] == "response" || strings.HasPrefix(statusLine, "HTTP/") {
+			parts := strings.SplitN(statusLine, " ", 3)
+			if len(parts) >= 2 {
+				rec.Status, _ = strconv.Atoi(parts[1])
+			}
+		}
+		httpHeader, err := tp.ReadMIMEHeader()
+		if err != nil && err != io.EOF {
+			return false
+		}
+		if ct := httpHeader.Get("Content-Type"); ct != "" {
+			payloadType, _, _ = mime.ParseMediaType(ct)
+			rec.ContentType = payloadType
+		}
+		payload = bbr
+		// the payload digest is computed over the entity body without transfer encoding
+		if strings.Contains(strings.ToLower(strings.Join(httpHeader.Values("Transfer-Encoding"), ",")), "chunked") {
+			payload = httputil.NewChunkedReader(bbr)
+			chunked = true
+		}
+	} else if rec.Type == "resource" {
+		rec.ContentType = mediaType
+	}
+	if payloadDigest != nil {
+		payload = io.TeeReader(payload, payloadDigest.hash)
+	}
+	if aw.identifyPayloads && aw.ad != nil && len(aw.actions) > 0 && (rec.Type == "response" || rec.Type == "resource") {
+		name := path.Base(rec.TargetURI)
+		if u, err := url.Parse(rec.TargetURI); err == nil {
+			name = path.Base(u.Path)
+		}
+		cr := &countingReader{r: payload}
+		payload = cr
+		embedded := &EmbeddedResource{
+			Path:     rec.TargetURI,
+			Name:     name,
+			Mimetype: payloadType,
+			Depth:    1,
+			Source:   aw.GetName(),
+		}
+		index, err := aw.ad.streamEmbedded(cr, []string{name}, aw.actions)
+		if err != nil {
+			rec.Error = err.Error()
+		} else {
+			if index.Mimetype != "" {
+				embedded.Mimetype = index.Mimetype
+			}
+			embedded.Metadata = index.Metadata
+		}
+		_, _ = io.Copy(io.Discard, payload)
+		embedded.Size = uint64(cr.n)
+		rec.embedded = embedded
+	}
+	// errors of the chunked reader are sticky
+	if _, err := io.Copy(io.Discard, payload); err != nil && chunked {
+		return false
+	}
+	return true
+}
+
+func (aw *ActionWARC) readARC(br *bufio.Reader, wresult *WARCResult) error {
+	for {
+		line, err := br.ReadString('\n')
+		if err == io.EOF && strings.TrimSpace(line) == "" {
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return errors.Wrap(err, "cannot read arc header")
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			return errors.Errorf("invalid arc header line '%s'", line)
+		}
+		length, err := strconv.ParseInt(fields[len(fields)-1], 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "invalid arc length in '%s'", line)
+		}
+		rec := &WARCRecord{
+			Type:      "response",
+			TargetURI: fields[0],
+			Length:    length,
+		}
+		if strings.HasPrefix(rec.TargetURI, "filedesc://") {
+			rec.Type = "filedesc"
+		}
+		if t, err := time.Parse("20060102150405", fields[2]); err == nil {
+			rec.Date = t.Format(time.RFC3339)
+		}
+		content := &countingReader{r: io.LimitReader(br, length)}
+		contentType := fields[3]
+		if rec.Type == "response" {
+			contentType = "application/http; msgtype=response"
+			cbr := bufio.NewReader(content)
+			if head, _ := cbr.Peek(5); !bytes.Equal(head, []byte("HTTP/")) {
+				// dns or other non-http records
+				contentType = fields[3]
+				rec.ContentType = contentType
+			}
+			aw.readPayload(rec, contentType, cbr, nil)
+		}
+		if _, err := io.Copy(io.Discard, content); err != nil {
+			return errors.Wrap(err, "cannot read arc record")
+		}
+		if content.n < length {
+			rec.Error = "record truncated"
+			aw.addRecord(wresult, rec)
+			return errors.Errorf("record '%s' truncated", rec.TargetURI)
+		}
+		aw.addRecord(wresult, rec)
+	}
+}
+
+func (aw *ActionWARC) DoV2(filename string) (*ResultV2, error) {
+	reader, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open file '%s'", filename)
+	}
+	defer reader.Close()
+	return aw.Stream("", reader, filename)
+}
+
+func (aw *ActionWARC) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
+	filename, err := aw.server.fm.Get(uri)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "no file url")
+	}
+
+	fp, err := os.OpenFile(filename, os.O_RDONLY, 0644)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "cannot open file %s", filename)
+	}
+	defer fp.Close()
+
+	result, err := aw.Stream("", fp, filename)
+	if err != nil {
+		return nil, nil, nil, errors.WithStack(err)
+	}
+	if result == nil {
+		return nil, nil, nil, ErrMimeNotApplicable
+	}
+	return result.Metadata[aw.GetName()], result.Mimetypes, result.Pronoms, nil
+}
+
+var (
+	_ Action = &ActionWARC{}
+)
@@ -0,0 +1,141 @@
+package indexer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"testing"
+
+	"github.com/je4/utils/v2/pkg/checksum"
+)
+
+func warcSHA1(data []byte) string {
+	sum := sha1.Sum(data)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+func warcRecord(typ, uri, contentType string, block []byte, payloadDigest string) string {
+	header := fmt.Sprintf("WARC/1.1\r\nWARC-Type: %s\r\nWARC-Target-URI: <%s>\r\nWARC-Date: 2006-01-02T15:04:05Z\r\nContent-Type: %s\r\nWARC-Block-Digest: %s\r\n", typ, uri, contentType, warcSHA1(block))
+	if payloadDigest != "" {
+		header += fmt.Sprintf("WARC-Payload-Digest: %s\r\n", payloadDigest)
+	}
+	return header + fmt.Sprintf("Content-Length: %d\r\n\r\n%s\r\n\r\n", len(block), block)
+}
+
+func testWARC() []byte {
+	payload := []byte("<html><body>indexer</body></html>")
+	response := append([]byte("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n"), payload...)
+	broken := append([]byte("HTTP/1.1 200 OK\r\nbroken header line\r\n\r\n"), payload...)
+	var warc bytes.Buffer
+	warc.WriteString(warcRecord("warcinfo", "", "application/warc-fields", []byte("software: indexer\r\n"), ""))
+	warc.WriteString(warcRecord("response", "http://example.com/index.html", "application/http; msgtype=response", response, warcSHA1(payload)))
+	warc.WriteString(warcRecord("response", "http://example.com/wrong.html", "application/http; msgtype=response", response, warcSHA1([]byte("other"))))
+	warc.WriteString(warcRecord("response", "http://example.com/broken.html", "application/http; msgtype=response", broken, warcSHA1(payload)))
+	return warc.Bytes()
+}
+
+func TestActionWARC(t *testing.T) {
+	ad := NewActionDispatcher(nil)
+	NewActionChecksum(NameChecksum, []checksum.DigestAlgorithm{checksum.DigestSHA256}, nil, ad)
+	aw := NewActionWARC(NameWARC, 0, true, []string{NameChecksum}, nil, ad)
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write(testWARC())
+	gw.Close()
+
+	for name, data := range map[string][]byte{"test.warc": testWARC(), "test.warc.gz": gz.Bytes()} {
+		result, err := aw.Stream("", bytes.NewReader(data), name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		wresult := result.Metadata[NameWARC].(*WARCResult)
+		if wresult.Error != "" || wresult.Format != "warc/1.1" || wresult.Compressed != (name == "test.warc.gz") {
+			t.Fatalf("%s: wrong result %+v", name, wresult)
+		}
+		if wresult.RecordCount != 4 || wresult.RecordTypes["response"] != 3 || wresult.StatusCodes["200"] != 3 {
+			t.Errorf("%s: wrong statistics %+v", name, wresult)
+		}
+		// 4 block digests, 2 payload digests. the payload of the broken http message is not verified
+		if wresult.DigestsVerified != 6 || wresult.DigestErrors != 1 {
+			t.Errorf("%s: %d digests verified, %d errors", name, wresult.DigestsVerified, wresult.DigestErrors)
+		}
+		if rec := wresult.Records[1]; rec.PayloadDigestValid == nil || !*rec.PayloadDigestValid || rec.ContentType != "text/html" {
+			t.Errorf("%s: wrong record %+v", name, rec)
+		}
+		if rec := wresult.Records[2]; rec.PayloadDigestValid == nil || *rec.PayloadDigestValid {
+			t.Errorf("%s: payload digest of %s must be invalid", name, rec.TargetURI)
+		}
+		if rec := wresult.Records[3]; rec.PayloadDigestValid != nil || rec.Error == "" {
+			t.Errorf("%s: payload digest of %s must not be verified", name, rec.TargetURI)
+		}
+		if len(result.Embedded) != 2 || result.Embedded[0].Path != "http://example.com/index.html" || result.Embedded[0].Name != "index.html" {
+			t.Fatalf("%s: wrong embedded resources %+v", name, result.Embedded)
+		}
+		if _, ok := result.Embedded[0].Metadata[NameChecksum]; !ok || result.Embedded[0].Size != 33 {
+			t.Errorf("%s: payload not indexed %+v", name, result.Embedded[0])
+		}
+	}
+}
+
+// the payload digest covers the de-chunked body
+func TestActionWARCChunked(t *testing.T) {
+	ad := NewActionDispatcher(nil)
+	NewActionChecksum(NameChecksum, []checksum.DigestAlgorithm{checksum.DigestSHA256}, nil, ad)
+	aw := NewActionWARC(NameWARC, 0, true, []string{NameChecksum}, nil, ad)
+	payload := []byte("<html><body>indexer</body></html>")
+	response := append([]byte("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nTransfer-Encoding: chunked\r\n\r\n"),
+		fmt.Sprintf("10\r\n%s\r\n%x\r\n%s\r\n0\r\n\r\n", payload[:16], len(payload)-16, payload[16:])...)
+	var warc bytes.Buffer
+	warc.WriteString(warcRecord("response", "http://example.com/index.html", "application/http; msgtype=response", response, warcSHA1(payload)))
+	warc.WriteString(warcRecord("response", "http://example.com/broken.html", "application/http; msgtype=response", response[:len(response)-20], warcSHA1(payload)))
+	result, err := aw.Stream("", &warc, "test.warc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wresult := result.Metadata[NameWARC].(*WARCResult)
+	if rec := wresult.Records[0]; rec.PayloadDigestValid == nil || !*rec.PayloadDigestValid {
+		t.Errorf("wrong record %+v", rec)
+	}
+	if rec := wresult.Records[1]; rec.PayloadDigestValid != nil || rec.Error == "" {
+		t.Errorf("payload digest of broken chunks must not be verified: %+v", rec)
+	}
+	if len(result.Embedded) == 0 || result.Embedded[0].Size != uint64(len(payload)) {
+		t.Errorf("wrong embedded resources %+v", result.Embedded)
+	}
+}
+
+func TestActionWARCARC(t *testing.T) {
+	aw := NewActionWARC(NameWARC, 0, false, nil, nil, NewActionDispatcher(nil))
+	filedesc := "1 0 indexer\nURL IP-address Archive-date Content-type Archive-length\n"
+	response := "HTTP/1.0 404 Not Found\r\nContent-Type: text/html\r\n\r\nnot found"
+	arc := fmt.Sprintf("filedesc://test.arc 0.0.0.0 20060102150405 text/plain %d\n%s\nhttp://example.com/ 1.2.3.4 20060102150405 text/html %d\n%s\n",
+		len(filedesc), filedesc, len(response), response)
+	result, err := aw.Stream("", bytes.NewReader([]byte(arc)), "test.arc")
+	if err != nil {
+		t.Fatalf("cannot read arc: %v", err)
+	}
+	wresult := result.Metadata[NameWARC].(*WARCResult)
+	if wresult.Format != "arc" || wresult.RecordCount != 2 || wresult.RecordTypes["filedesc"] != 1 || wresult.StatusCodes["404"] != 1 {
+		t.Errorf("wrong result %+v", wresult)
+	}
+}
+
+func TestActionWARCCanHandle(t *testing.T) {
+	aw := &ActionWARC{name: NameWARC}
+	for _, test := range []struct {
+		contentType, filename string
+		ok                    bool
+	}{
+		{"application/warc", "test.bin", true},
+		{"application/gzip", "test.warc.gz", true},
+		{"application/gzip", "test.tar.gz", false},
+		{"application/x-gzip", "test.gz", false},
+	} {
+		if aw.CanHandle(test.contentType, test.filename) != test.ok {
+			t.Errorf("CanHandle(%s, %s) != %v", test.contentType, test.filename, test.ok)
+		}
+	}
+}
@@ -1,27 +1,96 @@
 package indexer
 
 import (
-	"bufio"
+	"bytes"
+	"context"
 	"emperror.dev/errors"
+	"encoding/xml"
 	"fmt"
-	xmlparser "github.com/tamerh/xml-stream-parser"
+	"golang.org/x/net/html/charset"
 	"io"
-	"log"
 	"mime"
 	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
 
-type ActionXML struct {
-	server         *Server
+const xmlSchemaInstance = "http://www.w3.org/2001/XMLSchema-instance"
+
+// maxXMLValidationErrors limits the number of reported schema validation errors
+const maxXMLValidationErrors = 100
+
+// XMLMatch is a configured format, which matches the document
+type XMLMatch struct {
+	Format    string `json:"format"`
+	Element   string `json:"element,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Attribute string `json:"attribute,omitempty"`
+	pos       int
+}
+
+type XMLValidationError struct {
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// XMLValidation is the result of the validation against a local schema
+type XMLValidation struct {
+	Format     string                `json:"format"`
+	Schema     string                `json:"schema"`
+	Valid      bool                  `json:"valid"`
+	ErrorCount int                   `json:"errorcount,omitempty"`
+	Errors     []*XMLValidationError `json:"errors,omitempty"`
+	Error      string                `json:"error,omitempty"`
+}
+
+type XMLResult struct {
+	Root            string           `json:"root"`
+	Namespace       string           `json:"namespace,omitempty"`
+	Namespaces      []string         `json:"namespaces,omitempty"`
+	SchemaLocations []string         `json:"schemalocations,omitempty"`
+	PublicID        string           `json:"publicid,omitempty"`
+	SystemID        string           `json:"systemid,omitempty"`
+	WellFormed      bool             `json:"wellformed"`
+	Error           string           `json:"error,omitempty"`
+	Matches         []*XMLMatch      `json:"matches,omitempty"`
+	Validation      []*XMLValidation `json:"validation,omitempty"`
+}
+
+type xmlFormat struct {
+	ConfigXMLFormat
 	name           string
-	format         map[string]ConfigXMLFormat
-	compiledRegexp map[string]map[string]*regexp.Regexp
+	attributes     map[string]string
+	attrRegexp     map[string]*regexp.Regexp
+	schemaLocation *regexp.Regexp
+	publicID       *regexp.Regexp
+	xpath          *xmlPath
+}
+
+func (f *xmlFormat) hasElementCondition() bool {
+	return f.Element != "" || f.Namespace != "" || len(f.attributes) > 0
+}
+
+func (f *xmlFormat) matchValue(re *regexp.Regexp, pattern, value string) bool {
+	if f.Regexp {
+		return re != nil && re.MatchString(value)
+	}
+	return pattern == value
+}
+
+type ActionXML struct {
+	server   *Server
+	name     string
+	formats  []*xmlFormat
+	xmllint  string
+	timeout  time.Duration
+	tempDir  string
+	validate bool
 }
 
 func (as *ActionXML) CanHandle(contentType string, filename string) bool {
@@ -33,41 +102,70 @@ func (as *ActionXML) CanHandle(contentType string, filename string) bool {
 		//log.Printf("cannot parse media type %s", contentType)
 		return false
 	}
-	if slices.Contains([]string{"application/xml", "text/xml", "text/plain"}, mediaType) {
+	if slices.Contains([]string{"application/xml", "text/xml"}, mediaType) || strings.HasSuffix(mediaType, "+xml") {
 		return true
 	}
 	return false
 }
 
-func NewActionXML(name string, format map[string]ConfigXMLFormat, server *Server, ad *ActionDispatcher) Action {
-	as := &ActionXML{name: name, format: map[string]ConfigXMLFormat{}, server: server}
-	// allow old config with element as key
+func NewActionXML(name string, format map[string]ConfigXMLFormat, server *Server, ad *ActionDispatcher) (*ActionXML, error) {
+	as := &ActionXML{name: name, server: server}
 	for key, value := range format {
-		if value.Element == "" {
+		// allow old config with element as key
+		if value.Element == "" && value.XPath == "" && value.SchemaLocation == "" && value.PublicID == "" {
 			value.Element = strings.ToLower(key)
 		}
-		as.format[key] = value
-	}
-	compiledRegexp := map[string]map[string]*regexp.Regexp{}
-	for xmlName, xmlFormat := range as.format {
-		if _, ok := compiledRegexp[xmlName]; !ok {
-			compiledRegexp[xmlName] = map[string]*regexp.Regexp{}
+		f := &xmlFormat{
+			ConfigXMLFormat: value,
+			name:            key,
+			attributes:      map[string]string{},
+			attrRegexp:      map[string]*regexp.Regexp{},
 		}
-		if xmlFormat.Regexp {
-			for attr, val := range xmlFormat.Attributes {
+		for attr, val := range value.Attributes {
+			attr = strings.ToLower(attr)
+			f.attributes[attr] = val
+			if value.Regexp {
 				re, err := regexp.Compile(val)
 				if err != nil {
-					log.Printf("cannot compile regexp %s:%s: %v", xmlName, val, err)
-					continue
+					return nil, errors.Wrapf(err, "cannot compile regexp %s:%s", key, val)
 				}
-				compiledRegexp[xmlName][attr] = re
+				f.attrRegexp[attr] = re
+			}
+		}
+		if value.Regexp {
+			var err error
+			if value.SchemaLocation != "" {
+				if f.schemaLocation, err = regexp.Compile(value.SchemaLocation); err != nil {
+					return nil, errors.Wrapf(err, "cannot compile regexp %s:%s", key, value.SchemaLocation)
+				}
+			}
+			if value.PublicID != "" {
+				if f.publicID, err = regexp.Compile(value.PublicID); err != nil {
+					return nil, errors.Wrapf(err, "cannot compile regexp %s:%s", key, value.PublicID)
+				}
+			}
+		}
+		if value.XPath != "" {
+			xp, err := parseXMLPath(value.XPath, value.Namespaces)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot parse xpath %s:%s", key, value.XPath)
 			}
+			f.xpath = xp
 		}
+		as.formats = append(as.formats, f)
 	}
-	as.compiledRegexp = compiledRegexp
+	slices.SortFunc(as.formats, func(a, b *xmlFormat) int { return strings.Compare(a.name, b.name) })
 
 	ad.RegisterAction(as)
-	return as
+	return as, nil
+}
+
+// SetValidation enables the validation of matching documents against the schema of the format
+func (as *ActionXML) SetValidation(xmllint string, timeout time.Duration, tempDir string) {
+	as.xmllint = xmllint
+	as.timeout = timeout
+	as.tempDir = tempDir
+	as.validate = xmllint != "" && slices.ContainsFunc(as.formats, func(f *xmlFormat) bool { return f.Schema != "" })
 }
 
 func (as *ActionXML) GetWeight() uint {
@@ -82,91 +180,282 @@ func (as *ActionXML) GetName() string {
 	return as.name
 }
 
-func (as *ActionXML) getElement(elem string) map[string]ConfigXMLFormat {
-	elem = strings.ToLower(elem)
-	result := map[string]ConfigXMLFormat{}
-	for name, format := range as.format {
-		if format.Element == elem {
-			result[name] = format
+// xmlScopes resolves namespace uris to the prefixes of the document
+type xmlScopes []map[string]string
+
+func (s xmlScopes) prefix(uri string) string {
+	for i := len(s) - 1; i >= 0; i-- {
+		for prefix, u := range s[i] {
+			if u == uri {
+				return prefix
+			}
 		}
 	}
-	return result
+	return uri
 }
-func (as *ActionXML) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
-	var result = NewResultV2()
-	result.Mimetypes = []string{"application/xml"}
-	result.Mimetype = "application/xml"
-	//elements := maps.Keys(as.format)
-	elements := []string{}
-	for _, value := range as.format {
-		elements = append(elements, strings.ToLower(value.Element))
-	}
-	slices.Sort(elements)
-	elements = slices.Compact(elements)
-	br := bufio.NewReaderSize(reader, 4096*4)
-	parser := xmlparser.NewXMLParser(br, elements...).ParseAttributesOnly(elements...)
-	var found bool
-	for xml := range parser.Stream() {
-		if xml.Err != nil {
-			continue
+
+// attrNames are the local and the prefixed name of an attribute
+func (s xmlScopes) attrNames(attr xml.Attr) []string {
+	if attr.Name.Space == "" {
+		return []string{strings.ToLower(attr.Name.Local)}
+	}
+	return []string{
+		strings.ToLower(attr.Name.Local),
+		strings.ToLower(s.prefix(attr.Name.Space) + ":" + attr.Name.Local),
+	}
+}
+
+var xmlDoctypeRegexp = regexp.MustCompile(`^DOCTYPE\s+\S+\s+(?:PUBLIC\s+(?:"([^"]*)"|'([^']*)')(?:\s+(?:"([^"]*)"|'([^']*)'))?|SYSTEM\s+(?:"([^"]*)"|'([^']*)'))`)
+
+func (as *ActionXML) matchElement(f *xmlFormat, elem *xml.StartElement, scopes xmlScopes, depth int) *XMLMatch {
+	if f.Root && depth != 1 {
+		return nil
+	}
+	if f.Element != "" && !strings.EqualFold(f.Element, elem.Name.Local) {
+		return nil
+	}
+	if f.Namespace != "" && f.Namespace != elem.Name.Space {
+		return nil
+	}
+	match := &XMLMatch{Format: f.name, Element: elem.Name.Local, Namespace: elem.Name.Space}
+	if len(f.attributes) == 0 {
+		return match
+	}
+	for _, attr := range elem.Attr {
+		for _, name := range scopes.attrNames(attr) {
+			val, ok := f.attributes[name]
+			if !ok {
+				continue
+			}
+			if f.matchValue(f.attrRegexp[name], val, attr.Value) {
+				match.Attribute = fmt.Sprintf("%s=%s", name, attr.Value)
+				return match
+			}
 		}
-		formats := as.getElement(xml.Name)
-		if len(formats) == 0 {
-			continue
+	}
+	return nil
+}
+
+// analyze parses the document and checks all formats. filename is needed for validation only
+func (as *ActionXML) analyze(reader io.Reader, filename string) (*ResultV2, error) {
+	xmlResult := &XMLResult{WellFormed: true}
+	decoder := xml.NewDecoder(reader)
+	decoder.CharsetReader = charset.NewReaderLabel
+
+	elementMatches := map[string]*XMLMatch{}
+	xpathMatches := map[string]int{}
+	var stack []xml.StartElement
+	var scopes xmlScopes
+	var pos, rootPos int
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if xmlResult.Root == "" {
+				// no xml at all
+				return nil, nil
+			}
+			xmlResult.WellFormed = false
+			xmlResult.Error = err.Error()
+			break
 		}
-		for formatName, format := range formats {
-			for attr, val := range xml.Attrs {
-				attr = strings.ToLower(attr)
-				if val2, ok := format.Attributes[attr]; !ok {
+		pos++
+		switch t := tok.(type) {
+		case xml.Directive:
+			if found := xmlDoctypeRegexp.FindStringSubmatch(string(t)); found != nil {
+				xmlResult.PublicID = found[1] + found[2]
+				xmlResult.SystemID = found[3] + found[4] + found[5] + found[6]
+			}
+		case xml.StartElement:
+			elem := t.Copy()
+			stack = append(stack, elem)
+			scope := map[string]string{}
+			for _, attr := range elem.Attr {
+				switch {
+				case attr.Name.Space == "xmlns":
+					scope[attr.Name.Local] = attr.Value
+				case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+					scope[""] = attr.Value
+				case attr.Name.Space == xmlSchemaInstance && attr.Name.Local == "schemaLocation":
+					xmlResult.SchemaLocations = append(xmlResult.SchemaLocations, strings.Fields(attr.Value)...)
+				case attr.Name.Space == xmlSchemaInstance && attr.Name.Local == "noNamespaceSchemaLocation":
+					xmlResult.SchemaLocations = append(xmlResult.SchemaLocations, attr.Value)
+				default:
 					continue
-				} else {
-					if format.Regexp {
-						re, ok := as.compiledRegexp[formatName][attr]
-						if !ok {
-							continue
-						}
-						found = re.MatchString(val)
-					} else {
-						found = val == val2
-					}
-					if found {
-						result.Type = format.Type
-						result.Subtype = format.Subtype
-						if format.Mime != "" {
-							result.Mimetypes = append(result.Mimetypes, format.Mime)
-							result.Mimetype = format.Mime
-						}
-						if format.Pronom != "" {
-							result.Pronoms = []string{format.Pronom}
-							result.Pronom = format.Pronom
-						}
-						result.Metadata[as.GetName()] = map[string]string{
-							"element":   xml.Name,
-							"attribute": fmt.Sprintf("%s=%s", attr, val),
-						}
-						found = true
-						break
+				}
+				if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+					xmlResult.Namespaces = append(xmlResult.Namespaces, attr.Value)
+				}
+			}
+			scopes = append(scopes, scope)
+			if len(stack) == 1 {
+				xmlResult.Root = elem.Name.Local
+				xmlResult.Namespace = elem.Name.Space
+				rootPos = pos
+			}
+			for _, f := range as.formats {
+				if _, ok := elementMatches[f.name]; !ok && f.hasElementCondition() {
+					if match := as.matchElement(f, &elem, scopes, len(stack)); match != nil {
+						match.pos = pos
+						elementMatches[f.name] = match
 					}
 				}
-				if found {
-					break
+				if _, ok := xpathMatches[f.name]; !ok && f.xpath != nil && f.xpath.match(stack) {
+					xpathMatches[f.name] = pos
 				}
 			}
-			if found {
-				break
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+				scopes = scopes[:len(scopes)-1]
+			}
+		}
+	}
+	if xmlResult.Root == "" {
+		return nil, nil
+	}
+	slices.Sort(xmlResult.Namespaces)
+	xmlResult.Namespaces = slices.Compact(xmlResult.Namespaces)
+
+	for _, f := range as.formats {
+		// document conditions belong to the root element
+		var match = &XMLMatch{Format: f.name, pos: rootPos}
+		if f.hasElementCondition() {
+			m, ok := elementMatches[f.name]
+			if !ok {
+				continue
+			}
+			match = m
+		}
+		if f.xpath != nil {
+			p, ok := xpathMatches[f.name]
+			if !ok {
+				continue
 			}
+			match.pos = max(match.pos, p)
+		}
+		if f.SchemaLocation != "" && !slices.ContainsFunc(xmlResult.SchemaLocations, func(loc string) bool {
+			return f.matchValue(f.schemaLocation, f.SchemaLocation, loc)
+		}) {
+			continue
+		}
+		if f.PublicID != "" && !f.matchValue(f.publicID, f.PublicID, xmlResult.PublicID) {
+			continue
+		}
+		xmlResult.Matches = append(xmlResult.Matches, match)
+	}
+	// the earliest match in the document wins for each value
+	slices.SortStableFunc(xmlResult.Matches, func(a, b *XMLMatch) int { return a.pos - b.pos })
+
+	var result = NewResultV2()
+	result.Mimetypes = []string{"application/xml"}
+	result.Mimetype = "application/xml"
+	for i := len(xmlResult.Matches) - 1; i >= 0; i-- {
+		f := as.getFormat(xmlResult.Matches[i].Format)
+		if f.Type != "" {
+			result.Type = f.Type
+			result.Subtype = f.Subtype
+		}
+		if f.Mime != "" {
+			result.Mimetypes = append(result.Mimetypes, f.Mime)
+			result.Mimetype = f.Mime
+		}
+		if f.Pronom != "" {
+			result.Pronoms = append(result.Pronoms, f.Pronom)
+			result.Pronom = f.Pronom
 		}
 	}
+	if as.validate && filename != "" && xmlResult.WellFormed {
+		for _, match := range xmlResult.Matches {
+			f := as.getFormat(match.Format)
+			if f.Schema == "" {
+				continue
+			}
+			validation, err := as.validateSchema(filename, f.Schema)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			validation.Format = f.name
+			xmlResult.Validation = append(xmlResult.Validation, validation)
+		}
+	}
+	result.Metadata[as.GetName()] = xmlResult
 	return result, nil
 }
 
+func (as *ActionXML) getFormat(name string) *xmlFormat {
+	for _, f := range as.formats {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+var xmllintErrorRegexp = regexp.MustCompile(`^.*?:(\d+): (.+)$`)
+
+// validateSchema validates the file with xmllint against a local xsd. network access is disabled
+func (as *ActionXML) validateSchema(filename, schema string) (*XMLValidation, error) {
+	ctx := context.Background()
+	if as.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, as.timeout)
+		defer cancel()
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, as.xmllint, "--noout", "--nonet", "--schema", schema, filename)
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	validation := &XMLValidation{Schema: schema, Valid: err == nil}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, errors.Wrapf(err, "cannot execute (%s) for file '%s'", as.xmllint, filename)
+		}
+		// 3 and 4 are validation errors, everything else is a problem with the schema
+		if code := exitErr.ExitCode(); code != 3 && code != 4 {
+			validation.Error = strings.TrimSpace(stderr.String())
+			return validation, nil
+		}
+	}
+	for _, line := range strings.Split(stderr.String(), "\n") {
+		found := xmllintErrorRegexp.FindStringSubmatch(strings.TrimPrefix(line, filename))
+		if found == nil {
+			continue
+		}
+		validation.ErrorCount++
+		if len(validation.Errors) < maxXMLValidationErrors {
+			lineNo, _ := strconv.Atoi(found[1])
+			validation.Errors = append(validation.Errors, &XMLValidationError{Line: lineNo, Message: found[2]})
+		}
+	}
+	return validation, nil
+}
+
+func (as *ActionXML) Stream(contentType string, reader io.Reader, filename string) (*ResultV2, error) {
+	if !as.validate {
+		return as.analyze(reader, "")
+	}
+	fp, err := spoolTempFile(reader, as.tempDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot spool '%s'", filename)
+	}
+	defer func() {
+		fp.Close()
+		os.Remove(fp.Name())
+	}()
+	return as.analyze(fp, fp.Name())
+}
+
 func (as *ActionXML) DoV2(filename string) (*ResultV2, error) {
 	reader, err := os.Open(filename)
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot open file '%s'", filename)
 	}
 	defer reader.Close()
-	return as.Stream("", reader, filename)
+	return as.analyze(reader, filename)
 }
 
 func (as *ActionXML) Do(uri *url.URL, contentType string, width *uint, height *uint, duration *time.Duration, checksums map[string]string) (interface{}, []string, []string, error) {
@@ -181,11 +470,27 @@ func (as *ActionXML) Do(uri *url.URL, contentType string, width *uint, height *u
 	}
 	defer fp.Close()
 
-	result, err := as.Stream("", fp, filename)
+	result, err := as.analyze(fp, filename)
 	if err != nil {
 		return nil, nil, nil, errors.WithStack(err)
 	}
-	return result.Metadata[as.GetName()], result.Mimetypes, result.Pronoms, nil
+	if result == nil {
+		return nil, nil, nil, ErrMimeNotApplicable
+	}
+	// v1 clients get element and attribute of the first match
+	xmlResult, ok := result.Metadata[as.GetName()].(*XMLResult)
+	if !ok || len(xmlResult.Matches) == 0 {
+		return nil, result.Mimetypes, result.Pronoms, nil
+	}
+	match := xmlResult.Matches[0]
+	element := match.Element
+	if element == "" {
+		element = xmlResult.Root
+	}
+	return map[string]string{
+		"element":   element,
+		"attribute": match.Attribute,
+	}, result.Mimetypes, result.Pronoms, nil
 }
 
 var (
@@ -0,0 +1,146 @@
+package indexer
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testMETS = `<?xml version="1.0" encoding="UTF-8"?>
+<m:mets xmlns:m="http://www.loc.gov/METS/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"
+	xsi:schemaLocation="http://www.loc.gov/METS/ http://www.loc.gov/standards/mets/mets.xsd" TYPE="book">
+	<m:dmdSec ID="dmd1"><m:mdWrap><m:xmlData>
+		<mods xmlns="http://www.loc.gov/mods/v3" version="3.7"><titleInfo/></mods>
+	</m:xmlData></m:mdWrap></m:dmdSec>
+	<m:unknown/>
+</m:mets>`
+
+const testEAD = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<!DOCTYPE ead PUBLIC "+//ISBN 1-931666-00-8//DTD ead.dtd (Encoded Archival Description (EAD) Version 2002)//EN" "ead.dtd">
+<ead><eadheader><titleproper>Z` + "\xfc" + `rich</titleproper></eadheader></ead>`
+
+const testMETSSchema = `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" targetNamespace="http://www.loc.gov/METS/" elementFormDefault="qualified">
+<xs:element name="mets"><xs:complexType><xs:sequence>
+	<xs:element name="dmdSec" minOccurs="0" maxOccurs="unbounded"><xs:complexType><xs:sequence><xs:any processContents="skip"/></xs:sequence><xs:anyAttribute processContents="skip"/></xs:complexType></xs:element>
+</xs:sequence><xs:anyAttribute processContents="skip"/></xs:complexType></xs:element>
+</xs:schema>`
+
+func TestActionXML(t *testing.T) {
+	dir := t.TempDir()
+	schema := filepath.Join(dir, "mets.xsd")
+	if err := os.WriteFile(schema, []byte(testMETSSchema), 0644); err != nil {
+		t.Fatalf("cannot write schema: %v", err)
+	}
+	ad := NewActionDispatcher(nil)
+	ax, err := NewActionXML(NameXML, map[string]ConfigXMLFormat{
+		"mets": {
+			Namespace: "http://www.loc.gov/METS/",
+			Root:      true,
+			Mime:      "application/mets+xml",
+			Type:      "text",
+			Subtype:   "mets",
+			Schema:    schema,
+		},
+		"mods": {
+			Root:      true,
+			Namespace: "http://www.loc.gov/mods/v3",
+			Mime:      "application/mods+xml",
+		},
+		"mets-mods": {
+			XPath:      "/mets:mets/mets:dmdSec//mods:mods[@version='3.7']",
+			Namespaces: map[string]string{"mets": "http://www.loc.gov/METS/", "mods": "http://www.loc.gov/mods/v3"},
+		},
+		"mets-location": {
+			SchemaLocation: `^http://www\.loc\.gov/standards/mets/`,
+			Regexp:         true,
+		},
+		"book": {
+			Element:    "mets",
+			Attributes: map[string]string{"type": "book"},
+		},
+		"ead2002": {
+			PublicID: "EAD.*Version 2002",
+			Regexp:   true,
+			Pronom:   "fmt/1234",
+			Type:     "text",
+			Subtype:  "ead",
+		},
+	}, nil, ad)
+	if err != nil {
+		t.Fatalf("cannot create action: %v", err)
+	}
+
+	result, err := ax.Stream("application/xml", strings.NewReader(testMETS), "mets.xml")
+	if err != nil {
+		t.Fatalf("cannot analyze: %v", err)
+	}
+	xr := result.Metadata[NameXML].(*XMLResult)
+	var formats []string
+	for _, m := range xr.Matches {
+		formats = append(formats, m.Format)
+	}
+	if !slices.Equal(formats, []string{"book", "mets", "mets-location", "mets-mods"}) {
+		t.Errorf("wrong matches: %v", formats)
+	}
+	if result.Mimetype != "application/mets+xml" || result.Subtype != "mets" || xr.Root != "mets" ||
+		xr.Namespace != "http://www.loc.gov/METS/" || len(xr.SchemaLocations) != 2 || len(xr.Namespaces) != 3 || !xr.WellFormed {
+		t.Errorf("wrong result: %+v / %+v", result, xr)
+	}
+
+	result, err = ax.Stream("text/xml", bytes.NewReader([]byte(testEAD)), "ead.xml")
+	if err != nil {
+		t.Fatalf("cannot analyze: %v", err)
+	}
+	xr = result.Metadata[NameXML].(*XMLResult)
+	if len(xr.Matches) != 1 || xr.Matches[0].Format != "ead2002" || result.Pronom != "fmt/1234" || xr.SystemID != "ead.dtd" {
+		t.Errorf("wrong ead result: %+v / %+v", result, xr)
+	}
+
+	if result, err := ax.Stream("text/plain", strings.NewReader("no xml"), "test.txt"); err != nil || result != nil {
+		t.Errorf("text must be ignored: %v / %v", result, err)
+	}
+	// v1 result
+	if err := os.WriteFile(filepath.Join(dir, "mets.xml"), []byte(testMETS), 0644); err != nil {
+		t.Fatalf("cannot write file: %v", err)
+	}
+	ax.server = &Server{fm: NewFileMapper(map[string]string{"test": dir})}
+	v1, mimetypes, _, err := ax.Do(&url.URL{Scheme: "file", Host: "test", Path: "/mets.xml"}, "", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("cannot analyze v1: %v", err)
+	}
+	if m, ok := v1.(map[string]string); !ok || m["element"] != "mets" || m["attribute"] != "type=book" || !slices.Contains(mimetypes, "application/mets+xml") {
+		t.Errorf("wrong v1 result: %v / %v", v1, mimetypes)
+	}
+
+	if _, err := NewActionXML(NameXML, map[string]ConfigXMLFormat{"broken": {Element: "a", Attributes: map[string]string{"b": "("}, Regexp: true}}, nil, NewActionDispatcher(nil)); err == nil {
+		t.Errorf("invalid regexp must fail")
+	}
+	if _, err := NewActionXML(NameXML, map[string]ConfigXMLFormat{"broken": {XPath: "/x:a"}}, nil, NewActionDispatcher(nil)); err == nil {
+		t.Errorf("unknown xpath prefix must fail")
+	}
+
+	if ax.CanHandle("text/plain", "test.txt") || !ax.CanHandle("application/mets+xml", "") {
+		t.Errorf("wrong CanHandle")
+	}
+
+	xmllint, err := exec.LookPath("xmllint")
+	if err != nil {
+		t.Skip("no xmllint available")
+	}
+	ax.SetValidation(xmllint, 10*time.Second, dir)
+	result, err = ax.Stream("application/xml", strings.NewReader(testMETS), "mets.xml")
+	if err != nil {
+		t.Fatalf("cannot validate: %v", err)
+	}
+	xr = result.Metadata[NameXML].(*XMLResult)
+	if len(xr.Validation) != 1 || xr.Validation[0].Valid || xr.Validation[0].ErrorCount != 1 ||
+		!strings.Contains(xr.Validation[0].Errors[0].Message, "unknown") || xr.Validation[0].Errors[0].Line != 7 {
+		t.Errorf("wrong validation: %+v", xr.Validation[0])
+	}
+}
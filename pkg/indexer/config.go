@@ -26,6 +26,24 @@ const (
 	NameFFProbe = "ffprobe"
 	NameIdentify = "identify"
 	NameFullText = "fulltext"
+	NameTikaRMeta = "tikarmeta"
+	NameTruncation = "truncation"
+	NameEncryption = "encryption"
+	NameEmail = "email"
+	NameWARC = "warc"
+	NameSQLite = "sqlite"
+	NameClamd = "clamd"
+	NameNSRL = "nsrl"
+	NameHashSet = "hashset"
+	NameFFMPEGValidate = "ffmpegvalidate"
+	NameTIFF = "tiff"
+	NameMarkup = "markup"
+	NameFont = "font"
+	NameDICOM = "dicom"
+	NameFITS = "fits"
+	NameGeo = "geo"
+	NameKeys = "keys"
+	NamePII = "pii"
 )
 
 type duration struct {
@@ -45,6 +63,14 @@ type ConfigClamAV struct {
 	Wsl      bool
 }
 
+type ConfigClamd struct {
+	Enabled   bool
+	Network   string
+	Address   string
+	Timeout   duration
+	ChunkSize int
+}
+
 type TypeSubtype struct {
 	Type    string
 	Subtype string
@@ -54,6 +80,8 @@ type ConfigSiegfried struct {
 	//Address string
 	Enabled       bool
 	SignatureFile string `toml:"signature"`
+	// Signatures are additional signature files, i.e. for loc or tika identifiers
+	Signatures []string
 	MimeMap       map[string]string
 	TypeMap       map[string]TypeSubtype
 }
@@ -61,6 +89,8 @@ type ConfigSiegfried struct {
 type ConfigTika struct {
 	AddressMeta           string
 	AddressFulltext       string
+	AddressRMeta          string
+	FulltextRMeta         bool // AddressFulltext is a recursive /rmeta/text endpoint
 	Timeout               duration
 	RegexpMimeFulltext    string
 	RegexpMimeFulltextNot string
@@ -86,12 +116,36 @@ type ConfigFFMPEG struct {
 	Mime    []FFMPEGMime
 }
 
+// ConfigFFMPEGValidate configures the decode validation. files larger than SampleSize bytes
+// are only decoded in three segments of SampleDuration. SampleSize 0 means full decode
+type ConfigFFMPEGValidate struct {
+	Enabled        bool
+	FFMPEG         string
+	FFProbe        string
+	Wsl            bool
+	Timeout        duration
+	SampleSize     int64
+	SampleDuration duration
+	MaxErrors      int
+}
+
 type ConfigChecksum struct {
 	Name    string
 	Digest  []checksum.DigestAlgorithm
 	Enabled bool
 }
 
+// ConfigMagickPolicy contains ImageMagick resource limits like "256MiB"
+type ConfigMagickPolicy struct {
+	Memory string
+	Map    string
+	Disk   string
+	Area   string
+	Width  string
+	Height string
+	Time   duration
+}
+
 type ConfigImageMagick struct {
 	Identify string
 	Convert  string
@@ -99,6 +153,8 @@ type ConfigImageMagick struct {
 	Timeout  duration
 	Online   bool
 	Enabled  bool
+	Validate bool
+	Policy   *ConfigMagickPolicy
 }
 
 type ConfigXMLFormat struct {
@@ -109,11 +165,27 @@ type ConfigXMLFormat struct {
 	Mime       string
 	Type       string
 	Subtype    string
+	// Namespace is the namespace uri of the element
+	Namespace string
+	// Root matches the root element only
+	Root bool
+	// SchemaLocation matches an entry of xsi:schemaLocation or xsi:noNamespaceSchemaLocation
+	SchemaLocation string
+	// PublicID matches the public id of the DOCTYPE
+	PublicID string
+	// XPath is a simple path with attribute predicates, i.e. /mets:mets//mods:mods[@version]
+	XPath string
+	// Namespaces maps the prefixes of XPath to namespace uris
+	Namespaces map[string]string
+	// Schema is a local xsd for validation
+	Schema string
 }
 
 type ConfigXML struct {
 	Enabled bool
 	Format  map[string]ConfigXMLFormat
+	XMLLint string
+	Timeout duration
 }
 
 type ConfigExternalAction struct {
@@ -122,6 +194,13 @@ type ConfigExternalAction struct {
 	Mimetype string
 	ActionCapabilities []ActionCapability
 	CallType           ExternalActionCalltype
+	Headers            map[string]string
+	BearerToken        string
+	Timeout            duration
+	Retries            int
+	RetryDelay         duration
+	Response           *ExternalResponseMapping
+	Args               []string // EACTCOMMAND: address is the executable
 }
 
 type ConfigFileMap struct {
@@ -140,6 +219,86 @@ type ConfigNSRL struct {
 	Badger  string
 }
 
+type ConfigHashSetDB struct {
+	Name   string
+	Badger string
+}
+
+type ConfigHashSet struct {
+	Enabled bool
+	Sets    []ConfigHashSetDB
+}
+
+type ConfigTruncation struct {
+	Enabled bool
+}
+
+type ConfigEncryption struct {
+	Enabled bool
+}
+
+type ConfigEmail struct {
+	Enabled          bool
+	MaxMessages      int
+	IndexAttachments bool
+	Actions          []string
+}
+
+type ConfigWARC struct {
+	Enabled          bool
+	MaxRecords       int
+	IdentifyPayloads bool
+	Actions          []string
+}
+
+type ConfigSQLite struct {
+	Enabled bool
+}
+
+type ConfigMarkup struct {
+	Enabled bool
+}
+
+type ConfigFont struct {
+	Enabled bool
+}
+
+type ConfigDICOM struct {
+	Enabled     bool
+	KeepPatient bool // report patient, physician and institution identifiers, which are stripped by default
+}
+
+type ConfigFITS struct {
+	Enabled bool
+}
+
+type ConfigGeo struct {
+	Enabled bool
+}
+
+type ConfigKeys struct {
+	Enabled bool
+}
+
+type ConfigPIIPattern struct {
+	Name   string
+	Regexp string
+}
+
+type ConfigPII struct {
+	Enabled    bool
+	Detectors  []string // email, phone, iban, creditcard, ahv. all if empty
+	Custom     []ConfigPIIPattern
+	Sources    []string // actions with extracted text. default is fulltext
+	MaxSamples int      // number of offsets per detector
+	MaxSize    int64    // bytes of text, which are scanned. unlimited if 0
+}
+
+type ConfigTIFF struct {
+	Enabled bool
+	Profile *TIFFProfile // archival profile, optional
+}
+
 type ConfigMimeWeight struct {
 	Regexp string
 	Weight int
@@ -156,6 +315,7 @@ type IndexerConfig struct {
 	Siegfried       ConfigSiegfried
 	Checksum        ConfigChecksum
 	FFMPEG          ConfigFFMPEG
+	FFMPEGValidate  ConfigFFMPEGValidate
 	ImageMagick     ConfigImageMagick
 	Tika            ConfigTika
 	XML             ConfigXML
@@ -163,8 +323,24 @@ type IndexerConfig struct {
 	FileMap         []ConfigFileMap
 	URLRegexp       []string
 	NSRL            ConfigNSRL
+	HashSet         ConfigHashSet
 	Clamav          ConfigClamAV
+	Clamd           ConfigClamd
+	Truncation      ConfigTruncation
+	Encryption      ConfigEncryption
+	Email           ConfigEmail
+	WARC            ConfigWARC
+	SQLite          ConfigSQLite
+	TIFF            ConfigTIFF
+	Markup          ConfigMarkup
+	Font            ConfigFont
+	DICOM           ConfigDICOM
+	FITS            ConfigFITS
+	Geo             ConfigGeo
+	Keys            ConfigKeys
+	PII             ConfigPII
 	MimeRelevance   map[string]ConfigMimeWeight
+	Concurrency     map[string]int // maximum parallel executions per action
 }
 
 func GetDefaultConfig() *IndexerConfig {
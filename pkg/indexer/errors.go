@@ -1,6 +1,8 @@
 package indexer
 
 import (
+	"sync"
+
 	"github.com/je4/indexer/v3/internal"
 	"github.com/je4/utils/v2/pkg/zLogger"
 	archiveerror "github.com/ocfl-archive/error/pkg/error"
@@ -14,7 +16,14 @@ const (
 	IndexerInit = "IndexerInit"
 )
 
+var errorFactoryOnce sync.Once
+
+// configErrorFactory registers the errors once, InitActionDispatcher is called on every reload
 func configErrorFactory(logger zLogger.ZLogger) {
+	errorFactoryOnce.Do(func() { registerErrors(logger) })
+}
+
+func registerErrors(logger zLogger.ZLogger) {
 	var err error
 	const errorsEmbedToml string = "errors.toml"
 	archiveErrs, err := archiveerror.LoadTOMLFileFS(internal.InternalFS, errorsEmbedToml)
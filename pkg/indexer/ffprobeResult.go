@@ -0,0 +1,289 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indexer
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// AVMetadata is the normalized technical metadata of an audio/video container
+type AVMetadata struct {
+	Format         string            `json:"format"`
+	FormatLongName string            `json:"formatlongname,omitempty"`
+	DurationMS     int64             `json:"durationms,omitempty"`
+	StartTimeMS    int64             `json:"starttimems,omitempty"`
+	BitRate        int64             `json:"bitrate,omitempty"`
+	Size           int64             `json:"size,omitempty"`
+	ProbeScore     int               `json:"probescore,omitempty"`
+	Tags           map[string]string `json:"tags,omitempty"`
+	Streams        []*AVStream       `json:"streams"`
+	Chapters       []*AVChapter      `json:"chapters,omitempty"`
+}
+
+type AVStream struct {
+	Index           int    `json:"index"`
+	Type            string `json:"type"`
+	Codec           string `json:"codec,omitempty"`
+	CodecLongName   string `json:"codeclongname,omitempty"`
+	CodecTag        string `json:"codectag,omitempty"`
+	Profile         string `json:"profile,omitempty"`
+	BitRate         int64  `json:"bitrate,omitempty"`
+	DurationMS      int64  `json:"durationms,omitempty"`
+	Frames          int64  `json:"frames,omitempty"`
+	Language        string `json:"language,omitempty"`
+	Title           string `json:"title,omitempty"`
+	Default         bool   `json:"default,omitempty"`
+	AttachedPicture bool   `json:"attachedpicture,omitempty"`
+
+	// video
+	Width              int     `json:"width,omitempty"`
+	Height             int     `json:"height,omitempty"`
+	FrameRate          float64 `json:"framerate,omitempty"`
+	PixelFormat        string  `json:"pixelformat,omitempty"`
+	BitDepth           int     `json:"bitdepth,omitempty"`
+	ColorPrimaries     string  `json:"colorprimaries,omitempty"`
+	ColorTransfer      string  `json:"colortransfer,omitempty"`
+	ColorSpace         string  `json:"colorspace,omitempty"`
+	ColorRange         string  `json:"colorrange,omitempty"`
+	ScanType           string  `json:"scantype,omitempty"`
+	FieldOrder         string  `json:"fieldorder,omitempty"`
+	SampleAspectRatio  string  `json:"sampleaspectratio,omitempty"`
+	DisplayAspectRatio string  `json:"displayaspectratio,omitempty"`
+
+	// audio
+	SampleRate    int    `json:"samplerate,omitempty"`
+	Channels      int    `json:"channels,omitempty"`
+	ChannelLayout string `json:"channellayout,omitempty"`
+	SampleFormat  string `json:"sampleformat,omitempty"`
+}
+
+type AVChapter struct {
+	ID      int64  `json:"id"`
+	StartMS int64  `json:"startms"`
+	EndMS   int64  `json:"endms"`
+	Title   string `json:"title,omitempty"`
+}
+
+// MainVideo returns the default video stream or the first one. cover images are ignored
+func (av *AVMetadata) MainVideo() *AVStream {
+	var first *AVStream
+	for _, stream := range av.Streams {
+		if stream.Type != "video" || stream.AttachedPicture {
+			continue
+		}
+		if stream.Default {
+			return stream
+		}
+		if first == nil {
+			first = stream
+		}
+	}
+	return first
+}
+
+// ffprobe json output of -show_format -show_streams -show_chapters
+type ffprobeOutput struct {
+	Streams  []ffprobeStream  `json:"streams"`
+	Format   ffprobeFormat    `json:"format"`
+	Chapters []ffprobeChapter `json:"chapters"`
+}
+
+type ffprobeStream struct {
+	Index            int               `json:"index"`
+	CodecName        string            `json:"codec_name"`
+	CodecLongName    string            `json:"codec_long_name"`
+	Profile          string            `json:"profile"`
+	CodecType        string            `json:"codec_type"`
+	CodecTagString   string            `json:"codec_tag_string"`
+	Width            int               `json:"width"`
+	Height           int               `json:"height"`
+	SampleAspect     string            `json:"sample_aspect_ratio"`
+	DisplayAspect    string            `json:"display_aspect_ratio"`
+	PixFmt           string            `json:"pix_fmt"`
+	ColorRange       string            `json:"color_range"`
+	ColorSpace       string            `json:"color_space"`
+	ColorTransfer    string            `json:"color_transfer"`
+	ColorPrimaries   string            `json:"color_primaries"`
+	FieldOrder       string            `json:"field_order"`
+	BitsPerRawSample string            `json:"bits_per_raw_sample"`
+	SampleFmt        string            `json:"sample_fmt"`
+	SampleRate       string            `json:"sample_rate"`
+	Channels         int               `json:"channels"`
+	ChannelLayout    string            `json:"channel_layout"`
+	RFrameRate       string            `json:"r_frame_rate"`
+	AvgFrameRate     string            `json:"avg_frame_rate"`
+	Duration         string            `json:"duration"`
+	BitRate          string            `json:"bit_rate"`
+	NbFrames         string            `json:"nb_frames"`
+	Disposition      map[string]int    `json:"disposition"`
+	Tags             map[string]string `json:"tags"`
+}
+
+type ffprobeFormat struct {
+	FormatName     string            `json:"format_name"`
+	FormatLongName string            `json:"format_long_name"`
+	StartTime      string            `json:"start_time"`
+	Duration       string            `json:"duration"`
+	Size           string            `json:"size"`
+	BitRate        string            `json:"bit_rate"`
+	ProbeScore     int               `json:"probe_score"`
+	Tags           map[string]string `json:"tags"`
+}
+
+type ffprobeChapter struct {
+	ID        int64             `json:"id"`
+	StartTime string            `json:"start_time"`
+	EndTime   string            `json:"end_time"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// secondsToMS converts ffprobe second values like "12.345000" to milliseconds
+func secondsToMS(s string) int64 {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return int64(math.Round(f * 1000))
+}
+
+// timestampToMS converts timestamps like "01:02:03.040000000" to milliseconds
+func timestampToMS(s string) int64 {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0
+	}
+	h, err1 := strconv.ParseInt(parts[0], 10, 64)
+	m, err2 := strconv.ParseInt(parts[1], 10, 64)
+	sec, err3 := strconv.ParseFloat(parts[2], 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0
+	}
+	return (h*3600+m*60)*1000 + int64(math.Round(sec*1000))
+}
+
+func parseInt64(s string) int64 {
+	i, _ := strconv.ParseInt(s, 10, 64)
+	return i
+}
+
+// parseFrameRate converts rationals like "30000/1001" to frames per second
+func parseFrameRate(s string) float64 {
+	num, den, ok := strings.Cut(s, "/")
+	if !ok {
+		f, _ := strconv.ParseFloat(s, 64)
+		return f
+	}
+	n, err1 := strconv.ParseFloat(num, 64)
+	d, err2 := strconv.ParseFloat(den, 64)
+	if err1 != nil || err2 != nil || d == 0 {
+		return 0
+	}
+	return math.Round(n/d*1000) / 1000
+}
+
+// ffprobeTag returns a tag value case-insensitive
+func ffprobeTag(tags map[string]string, key string) string {
+	for k, v := range tags {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+func (fo *ffprobeOutput) normalize() *AVMetadata {
+	av := &AVMetadata{
+		Format:         fo.Format.FormatName,
+		FormatLongName: fo.Format.FormatLongName,
+		DurationMS:     secondsToMS(fo.Format.Duration),
+		StartTimeMS:    secondsToMS(fo.Format.StartTime),
+		BitRate:        parseInt64(fo.Format.BitRate),
+		Size:           parseInt64(fo.Format.Size),
+		ProbeScore:     fo.Format.ProbeScore,
+		Tags:           fo.Format.Tags,
+		Streams:        []*AVStream{},
+	}
+	for _, s := range fo.Streams {
+		stream := &AVStream{
+			Index:           s.Index,
+			Type:            s.CodecType,
+			Codec:           s.CodecName,
+			CodecLongName:   s.CodecLongName,
+			CodecTag:        s.CodecTagString,
+			Profile:         s.Profile,
+			BitRate:         parseInt64(s.BitRate),
+			DurationMS:      secondsToMS(s.Duration),
+			Frames:          parseInt64(s.NbFrames),
+			Language:        ffprobeTag(s.Tags, "language"),
+			Title:           ffprobeTag(s.Tags, "title"),
+			Default:         s.Disposition["default"] == 1,
+			AttachedPicture: s.Disposition["attached_pic"] == 1,
+		}
+		// matroska stores the duration as tag only
+		if stream.DurationMS == 0 {
+			stream.DurationMS = timestampToMS(ffprobeTag(s.Tags, "duration"))
+		}
+		if stream.Language == "und" {
+			stream.Language = ""
+		}
+		if stream.CodecTag == "[0][0][0][0]" {
+			stream.CodecTag = ""
+		}
+		switch s.CodecType {
+		case "video":
+			stream.Width = s.Width
+			stream.Height = s.Height
+			stream.FrameRate = parseFrameRate(s.AvgFrameRate)
+			if stream.FrameRate == 0 {
+				stream.FrameRate = parseFrameRate(s.RFrameRate)
+			}
+			stream.PixelFormat = s.PixFmt
+			stream.BitDepth = int(parseInt64(s.BitsPerRawSample))
+			stream.ColorPrimaries = s.ColorPrimaries
+			stream.ColorTransfer = s.ColorTransfer
+			stream.ColorSpace = s.ColorSpace
+			stream.ColorRange = s.ColorRange
+			stream.SampleAspectRatio = s.SampleAspect
+			stream.DisplayAspectRatio = s.DisplayAspect
+			switch s.FieldOrder {
+			case "progressive":
+				stream.ScanType = "progressive"
+			case "tt", "tb":
+				stream.ScanType = "interlaced"
+				stream.FieldOrder = "tff"
+			case "bb", "bt":
+				stream.ScanType = "interlaced"
+				stream.FieldOrder = "bff"
+			}
+		case "audio":
+			stream.SampleRate = int(parseInt64(s.SampleRate))
+			stream.Channels = s.Channels
+			stream.ChannelLayout = s.ChannelLayout
+			stream.SampleFormat = s.SampleFmt
+			stream.BitDepth = int(parseInt64(s.BitsPerRawSample))
+		}
+		av.Streams = append(av.Streams, stream)
+	}
+	for _, c := range fo.Chapters {
+		av.Chapters = append(av.Chapters, &AVChapter{
+			ID:      c.ID,
+			StartMS: secondsToMS(c.StartTime),
+			EndMS:   secondsToMS(c.EndTime),
+			Title:   ffprobeTag(c.Tags, "title"),
+		})
+	}
+	return av
+}
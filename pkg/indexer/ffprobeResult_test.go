@@ -0,0 +1,60 @@
+package indexer
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const ffprobeTestOutput = `{
+  "streams": [
+    {"index": 0, "codec_name": "mjpeg", "codec_type": "video", "width": 600, "height": 600,
+     "disposition": {"default": 0, "attached_pic": 1}},
+    {"index": 1, "codec_name": "h264", "codec_long_name": "H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10",
+     "profile": "High", "codec_type": "video", "codec_tag_string": "avc1", "width": 1920, "height": 1080,
+     "sample_aspect_ratio": "1:1", "display_aspect_ratio": "16:9", "pix_fmt": "yuv420p",
+     "color_range": "tv", "color_space": "bt709", "color_transfer": "bt709", "color_primaries": "bt709",
+     "field_order": "tt", "bits_per_raw_sample": "8", "r_frame_rate": "25/1", "avg_frame_rate": "30000/1001",
+     "duration": "150.040000", "bit_rate": "4500000", "nb_frames": "4497",
+     "disposition": {"default": 1, "attached_pic": 0}, "tags": {"language": "und"}},
+    {"index": 2, "codec_name": "aac", "codec_type": "audio", "profile": "LC", "sample_fmt": "fltp",
+     "sample_rate": "48000", "channels": 2, "channel_layout": "stereo", "bit_rate": "128000",
+     "disposition": {"default": 1}, "tags": {"LANGUAGE": "ger", "DURATION": "00:02:30.021000000"}}
+  ],
+  "chapters": [
+    {"id": 0, "start_time": "0.000000", "end_time": "75.500000", "tags": {"title": "Part 1"}},
+    {"id": 1, "start_time": "75.500000", "end_time": "150.040000", "tags": {"title": "Part 2"}}
+  ],
+  "format": {"format_name": "mov,mp4,m4a,3gp,3g2,mj2", "start_time": "0.000000", "duration": "150.040000",
+    "size": "86000000", "bit_rate": "4585000", "probe_score": 100, "tags": {"title": "Test"}}
+}`
+
+func TestFFProbeNormalize(t *testing.T) {
+	var probe ffprobeOutput
+	if err := json.Unmarshal([]byte(ffprobeTestOutput), &probe); err != nil {
+		t.Fatalf("cannot unmarshal: %v", err)
+	}
+	av := probe.normalize()
+	if av.DurationMS != 150040 || av.BitRate != 4585000 || av.Size != 86000000 || av.Tags["title"] != "Test" {
+		t.Errorf("wrong container fields: %+v", av)
+	}
+	if len(av.Streams) != 3 || len(av.Chapters) != 2 {
+		t.Fatalf("wrong number of streams or chapters: %d/%d", len(av.Streams), len(av.Chapters))
+	}
+	video := av.MainVideo()
+	if video == nil || video.Index != 1 {
+		t.Fatalf("cover image is main video: %+v", video)
+	}
+	if video.FrameRate != 29.97 || video.ScanType != "interlaced" || video.FieldOrder != "tff" ||
+		video.ColorPrimaries != "bt709" || video.DisplayAspectRatio != "16:9" || video.BitDepth != 8 ||
+		video.DurationMS != 150040 || video.Language != "" || video.Profile != "High" {
+		t.Errorf("wrong video stream: %+v", video)
+	}
+	audio := av.Streams[2]
+	if audio.SampleRate != 48000 || audio.Channels != 2 || audio.ChannelLayout != "stereo" ||
+		audio.Language != "ger" || audio.DurationMS != 150021 {
+		t.Errorf("wrong audio stream: %+v", audio)
+	}
+	if av.Chapters[1].StartMS != 75500 || av.Chapters[1].EndMS != 150040 || av.Chapters[1].Title != "Part 2" {
+		t.Errorf("wrong chapter: %+v", av.Chapters[1])
+	}
+}
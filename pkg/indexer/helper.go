@@ -17,6 +17,7 @@ import (
 	"emperror.dev/errors"
 	"fmt"
 	"github.com/op/go-logging"
+	"io"
 	"mime"
 	"net/url"
 	"os"
@@ -124,3 +125,23 @@ func wildCardToRegexp(pattern string) string {
 	}
 	return "^" + result.String() + "$"
 }
+
+// spoolTempFile copies a stream to a temporary file for actions which need random access.
+// the caller has to close and remove the file
+func spoolTempFile(reader io.Reader, tempDir string) (*os.File, error) {
+	fp, err := os.CreateTemp(tempDir, "indexer")
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create tempfile")
+	}
+	if _, err := io.Copy(fp, reader); err != nil {
+		fp.Close()
+		os.Remove(fp.Name())
+		return nil, errors.Wrapf(err, "cannot write to tempfile '%s'", fp.Name())
+	}
+	if _, err := fp.Seek(0, io.SeekStart); err != nil {
+		fp.Close()
+		os.Remove(fp.Name())
+		return nil, errors.Wrapf(err, "cannot seek tempfile '%s'", fp.Name())
+	}
+	return fp, nil
+}
@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 
 	"emperror.dev/errors"
+	badger "github.com/dgraph-io/badger/v4"
 	"github.com/je4/utils/v2/pkg/zLogger"
 )
 
@@ -38,57 +40,151 @@ func logStartup(logger zLogger.ZLogger, tool string) {
 	).Msg("")
 }
 
+// readSignature reads a signature file from disk or from an internal filesystem with the "fs:path" syntax
+func readSignature(fss map[string]fs.FS, sigFile string) ([]byte, error) {
+	found := fsRegexp.FindStringSubmatch(sigFile)
+	if found == nil {
+		return os.ReadFile(sigFile)
+	}
+	intFS, ok := fss[found[1]]
+	if !ok {
+		return nil, errors.Errorf("invalid filesystem %s", found[1])
+	}
+	return fs.ReadFile(intFS, strings.TrimLeft(found[2], "/"))
+}
+
 func InitActionDispatcher(fss map[string]fs.FS, conf IndexerConfig, logger zLogger.ZLogger) (*ActionDispatcher, error) {
+	return initActionDispatcher(fss, conf, nil, logger)
+}
+
+// initActionDispatcher creates the actions for server. without server only Stream and DoV2 are usable
+func initActionDispatcher(fss map[string]fs.FS, conf IndexerConfig, server *Server, logger zLogger.ZLogger) (*ActionDispatcher, error) {
 	mimeRelevance, err := stringMapToMimeRelevance(conf.MimeRelevance)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot convert config string map to mime relevance")
 	}
 	actionDispatcher := NewActionDispatcher(mimeRelevance)
 
-	var signatureData []byte
-	found := fsRegexp.FindStringSubmatch(conf.Siegfried.SignatureFile)
-	if found == nil {
-		signatureData, err = os.ReadFile(conf.Siegfried.SignatureFile)
-		if err != nil {
-			return nil, errors.Wrapf(err, "no siegfried signature file provided. using default signature file. please provide a recent signature file. %s", conf.Siegfried.SignatureFile)
-			//			signatureData = datasiegfried.DefaultSig
-		}
-	} else {
-		intFS, ok := fss[found[1]]
-		if !ok {
-			return nil, errors.Errorf("invalid filesystem %s", found[1])
-		}
-		signatureData, err = fs.ReadFile(intFS, strings.TrimLeft(found[2], "/"))
+	var signatures []*SiegfriedSignature
+	for _, sigFile := range append([]string{conf.Siegfried.SignatureFile}, conf.Siegfried.Signatures...) {
+		signatureData, err := readSignature(fss, sigFile)
 		if err != nil {
-			return nil, errors.Wrapf(err, "no siegfried signature file provided. using default signature file. please provide a recent signature file. %s", conf.Siegfried.SignatureFile)
+			return nil, errors.Wrapf(err, "no siegfried signature file provided. using default signature file. please provide a recent signature file. %s", sigFile)
 		}
+		signatures = append(signatures, &SiegfriedSignature{Name: filepath.Base(sigFile), Data: signatureData})
 	}
 
 	configErrorFactory(logger)
 
-	_ = NewActionSiegfried(
+	if _, err := NewActionSiegfriedSignatures(
 		NameSiegfried,
-		signatureData,
+		signatures,
 		conf.Siegfried.MimeMap,
 		conf.Siegfried.TypeMap,
-		nil,
+		server,
 		actionDispatcher,
-	)
+	); err != nil {
+		return nil, errors.Wrap(err, "cannot initialize siegfried")
+	}
 	logStartup(logger, NameSiegfried)
 	if conf.XML.Enabled {
-		_ = NewActionXML(
+		ax, err := NewActionXML(
 			NameXML,
 			conf.XML.Format,
-			nil,
+			server,
 			actionDispatcher,
 		)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot initialize xml")
+		}
+		if conf.XML.XMLLint != "" {
+			ax.SetValidation(conf.XML.XMLLint, conf.XML.Timeout.Duration, conf.TempDir)
+		}
 		logStartup(logger, NameXML)
 	}
+	if conf.Markup.Enabled {
+		_ = NewActionMarkup(
+			NameMarkup,
+			server,
+			actionDispatcher,
+		)
+		logStartup(logger, NameMarkup)
+	}
+	if conf.Font.Enabled {
+		_ = NewActionFont(
+			NameFont,
+			server,
+			actionDispatcher,
+		)
+		logStartup(logger, NameFont)
+	}
+	if conf.DICOM.Enabled {
+		_ = NewActionDICOM(
+			NameDICOM,
+			!conf.DICOM.KeepPatient,
+			server,
+			actionDispatcher,
+		)
+		logStartup(logger, NameDICOM)
+	}
+	if conf.FITS.Enabled {
+		_ = NewActionFITS(
+			NameFITS,
+			server,
+			actionDispatcher,
+		)
+		logStartup(logger, NameFITS)
+	}
+	if conf.Geo.Enabled {
+		_ = NewActionGeo(
+			NameGeo,
+			conf.TempDir,
+			server,
+			actionDispatcher,
+		)
+		logStartup(logger, NameGeo)
+	}
+	if conf.Keys.Enabled {
+		_ = NewActionKeys(
+			NameKeys,
+			server,
+			actionDispatcher,
+		)
+		logStartup(logger, NameKeys)
+	}
+	if conf.PII.Enabled {
+		custom := map[string]string{}
+		for _, pattern := range conf.PII.Custom {
+			custom[pattern.Name] = pattern.Regexp
+		}
+		sources := conf.PII.Sources
+		if len(sources) == 0 {
+			sources = []string{NameFullText}
+		}
+		maxSamples := conf.PII.MaxSamples
+		if maxSamples <= 0 {
+			maxSamples = 5
+		}
+		if _, err := NewActionPII(
+			NamePII,
+			conf.PII.Detectors,
+			custom,
+			sources,
+			maxSamples,
+			conf.PII.MaxSize,
+			server,
+			actionDispatcher,
+		); err != nil {
+			actionDispatcher.Close()
+			return nil, errors.Wrap(err, "cannot initialize pii detection")
+		}
+		logStartup(logger, NamePII)
+	}
 	if conf.Checksum.Enabled {
 		_ = NewActionChecksum(
 			NameChecksum,
 			conf.Checksum.Digest,
-			nil,
+			server,
 			actionDispatcher,
 		)
 		logStartup(logger, NameChecksum)
@@ -101,18 +197,52 @@ func InitActionDispatcher(fss map[string]fs.FS, conf IndexerConfig, logger zLogg
 			conf.FFMPEG.Timeout.Duration,
 			conf.FFMPEG.Online,
 			conf.FFMPEG.Mime,
-			nil,
+			server,
 			actionDispatcher)
 		logStartup(logger, NameFFProbe)
 	}
+	if conf.FFMPEGValidate.Enabled {
+		_ = NewActionFFMPEGValidate(
+			NameFFMPEGValidate,
+			conf.FFMPEGValidate.FFMPEG,
+			conf.FFMPEGValidate.FFProbe,
+			conf.FFMPEGValidate.Wsl,
+			conf.FFMPEGValidate.Timeout.Duration,
+			conf.FFMPEGValidate.SampleSize,
+			conf.FFMPEGValidate.SampleDuration.Duration,
+			conf.FFMPEGValidate.MaxErrors,
+			conf.TempDir,
+			server,
+			actionDispatcher)
+		logStartup(logger, NameFFMPEGValidate)
+	}
 	if conf.ImageMagick.Enabled {
-		_ = NewActionIdentifyV2(
+		ai := NewActionIdentifyV2(
 			NameIdentify,
 			conf.ImageMagick.Identify,
 			conf.ImageMagick.Convert,
 			conf.ImageMagick.Wsl,
 			conf.ImageMagick.Timeout.Duration,
-			conf.ImageMagick.Online, nil, actionDispatcher)
+			conf.ImageMagick.Online, server, actionDispatcher).(*ActionIdentifyV2)
+		if conf.ImageMagick.Validate {
+			var policy *MagickPolicy
+			if p := conf.ImageMagick.Policy; p != nil {
+				policy = &MagickPolicy{
+					Memory: p.Memory,
+					Map:    p.Map,
+					Disk:   p.Disk,
+					Area:   p.Area,
+					Width:  p.Width,
+					Height: p.Height,
+					Time:   p.Time.Duration,
+				}
+			}
+			if err := ai.SetValidation(policy, conf.TempDir); err != nil {
+				actionDispatcher.Close()
+				return nil, errors.Wrap(err, "cannot enable imagemagick validation")
+			}
+			actionDispatcher.AddCloser(ai)
+		}
 		logStartup(logger, NameIdentify)
 	}
 	if conf.Tika.Enabled {
@@ -124,9 +254,9 @@ func InitActionDispatcher(fss map[string]fs.FS, conf IndexerConfig, logger zLogg
 			conf.Tika.RegexpMimeMetaNot,
 			"",
 			conf.Tika.Online,
-			nil, actionDispatcher)
+			server, actionDispatcher)
 		logStartup(logger, NameTika)
-		_ = NewActionTika(
+		at := NewActionTika(
 			NameFullText,
 			conf.Tika.AddressFulltext,
 			conf.Tika.Timeout.Duration,
@@ -134,9 +264,153 @@ func InitActionDispatcher(fss map[string]fs.FS, conf IndexerConfig, logger zLogg
 			conf.Tika.RegexpMimeFulltextNot,
 			"X-TIKA:content",
 			conf.Tika.Online,
-			nil,
-			actionDispatcher)
+			server,
+			actionDispatcher).(*ActionTika)
+		at.SetRMeta(conf.Tika.FulltextRMeta)
 		logStartup(logger, NameFullText)
+		if conf.Tika.AddressRMeta != "" {
+			at := NewActionTika(
+				NameTikaRMeta,
+				conf.Tika.AddressRMeta,
+				conf.Tika.Timeout.Duration,
+				conf.Tika.RegexpMimeMeta,
+				conf.Tika.RegexpMimeMetaNot,
+				"",
+				conf.Tika.Online,
+				server,
+				actionDispatcher).(*ActionTika)
+			at.SetRMeta(true)
+			logStartup(logger, NameTikaRMeta)
+		}
+	}
+	if conf.Truncation.Enabled {
+		_ = NewActionTruncation(
+			NameTruncation,
+			server,
+			actionDispatcher)
+		logStartup(logger, NameTruncation)
+	}
+	if conf.Encryption.Enabled {
+		_ = NewActionEncryption(
+			NameEncryption,
+			conf.TempDir,
+			server,
+			actionDispatcher)
+		logStartup(logger, NameEncryption)
+	}
+	if conf.Email.Enabled {
+		_ = NewActionEmail(
+			NameEmail,
+			conf.Email.MaxMessages,
+			conf.Email.IndexAttachments,
+			conf.Email.Actions,
+			server,
+			actionDispatcher)
+		logStartup(logger, NameEmail)
+	}
+	if conf.WARC.Enabled {
+		_ = NewActionWARC(
+			NameWARC,
+			conf.WARC.MaxRecords,
+			conf.WARC.IdentifyPayloads,
+			conf.WARC.Actions,
+			server,
+			actionDispatcher)
+		logStartup(logger, NameWARC)
+	}
+	if conf.SQLite.Enabled {
+		_ = NewActionSQLite(
+			NameSQLite,
+			conf.TempDir,
+			server,
+			actionDispatcher)
+		logStartup(logger, NameSQLite)
+	}
+	if conf.TIFF.Enabled {
+		_ = NewActionTIFF(
+			NameTIFF,
+			conf.TIFF.Profile,
+			conf.TempDir,
+			server,
+			actionDispatcher)
+		logStartup(logger, NameTIFF)
+	}
+	if conf.NSRL.Enabled {
+		bconfig := badger.DefaultOptions(conf.NSRL.Badger)
+		bconfig.ReadOnly = true
+		bconfig.Logger = nil
+		nsrldb, err := badger.Open(bconfig)
+		if err != nil {
+			actionDispatcher.Close()
+			return nil, errors.Wrapf(err, "cannot open nsrl badger database in '%s'", conf.NSRL.Badger)
+		}
+		actionDispatcher.AddCloser(nsrldb)
+		_ = NewActionNSRL(
+			NameNSRL,
+			nsrldb,
+			server,
+			actionDispatcher)
+		logStartup(logger, NameNSRL)
+	}
+	if conf.HashSet.Enabled {
+		var sets = map[string]*badger.DB{}
+		for _, set := range conf.HashSet.Sets {
+			bconfig := badger.DefaultOptions(set.Badger)
+			bconfig.ReadOnly = true
+			bconfig.Logger = nil
+			db, err := badger.Open(bconfig)
+			if err != nil {
+				actionDispatcher.Close()
+				return nil, errors.Wrapf(err, "cannot open hash set '%s' in '%s'", set.Name, set.Badger)
+			}
+			actionDispatcher.AddCloser(db)
+			sets[set.Name] = db
+		}
+		_ = NewActionHashSet(
+			NameHashSet,
+			sets,
+			server,
+			actionDispatcher)
+		logStartup(logger, NameHashSet)
+	}
+	if conf.Clamd.Enabled {
+		_ = NewActionClamd(
+			NameClamd,
+			conf.Clamd.Network,
+			conf.Clamd.Address,
+			conf.Clamd.Timeout.Duration,
+			conf.Clamd.ChunkSize,
+			server,
+			actionDispatcher)
+		logStartup(logger, NameClamd)
+	}
+	for _, eaconfig := range conf.External {
+		var caps ActionCapability
+		for _, c := range eaconfig.ActionCapabilities {
+			caps |= c
+		}
+		_ = NewActionExternal(
+			eaconfig.Name,
+			eaconfig.Address,
+			caps,
+			eaconfig.CallType,
+			eaconfig.Mimetype,
+			&ExternalActionOptions{
+				Headers:     eaconfig.Headers,
+				BearerToken: eaconfig.BearerToken,
+				Timeout:     eaconfig.Timeout.Duration,
+				Retries:     eaconfig.Retries,
+				RetryDelay:  eaconfig.RetryDelay.Duration,
+				Response:    eaconfig.Response,
+				Args:        eaconfig.Args,
+				TempDir:     conf.TempDir,
+			},
+			server,
+			actionDispatcher)
+		logStartup(logger, eaconfig.Name)
+	}
+	for name, n := range conf.Concurrency {
+		actionDispatcher.SetConcurrency(name, n)
 	}
 
 	return actionDispatcher, nil
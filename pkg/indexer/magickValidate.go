@@ -0,0 +1,220 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indexer
+
+import (
+	"bufio"
+	"bytes"
+	"emperror.dev/errors"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MagickPolicy contains the resource limits, which are written to a generated policy.xml
+type MagickPolicy struct {
+	Memory string // e.g. 256MiB
+	Map    string
+	Disk   string
+	Area   string
+	Width  string
+	Height string
+	Time   time.Duration
+}
+
+func (mp *MagickPolicy) xml() []byte {
+	var buf = bytes.NewBufferString("<policymap>\n")
+	for _, p := range [][2]string{
+		{"memory", mp.Memory},
+		{"map", mp.Map},
+		{"disk", mp.Disk},
+		{"area", mp.Area},
+		{"width", mp.Width},
+		{"height", mp.Height},
+	} {
+		if p[1] != "" {
+			fmt.Fprintf(buf, "  <policy domain=\"resource\" name=\"%s\" value=\"%s\"/>\n", p[0], html.EscapeString(p[1]))
+		}
+	}
+	if mp.Time > 0 {
+		fmt.Fprintf(buf, "  <policy domain=\"resource\" name=\"time\" value=\"%d\"/>\n", int64(mp.Time.Seconds()))
+	}
+	buf.WriteString("</policymap>\n")
+	return buf.Bytes()
+}
+
+// writePolicy creates a folder with policy.xml for MAGICK_CONFIGURE_PATH
+func (mp *MagickPolicy) writePolicy(tempDir string) (string, error) {
+	dir, err := os.MkdirTemp(tempDir, "magickpolicy")
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot create policy folder in '%s'", tempDir)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "policy.xml"), mp.xml(), 0644); err != nil {
+		os.RemoveAll(dir)
+		return "", errors.Wrapf(err, "cannot write policy to '%s'", dir)
+	}
+	return dir, nil
+}
+
+type MagickMessage struct {
+	Severity string `json:"severity"`
+	Module   string `json:"module,omitempty"`
+	Message  string `json:"message"`
+}
+
+type MagickDimension struct {
+	Source string `json:"source"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// MagickValidation is the result of the ImageMagick validation mode
+type MagickValidation struct {
+	Valid        bool               `json:"valid"`
+	ErrorCount   int                `json:"errorcount"`
+	WarningCount int                `json:"warningcount"`
+	Messages     []*MagickMessage   `json:"messages,omitempty"`
+	Declared     []*MagickDimension `json:"declared,omitempty"`
+	ExifMismatch bool               `json:"exifmismatch,omitempty"` // exif pixel dimensions differ from the raster
+	Uniform      string             `json:"uniform,omitempty"`      // black, white or color
+	Profiles     []string           `json:"profiles,omitempty"`
+	ICC          bool               `json:"icc"`
+	Exif         bool               `json:"exif"`
+	XMP          bool               `json:"xmp"`
+}
+
+// convert: Unknown field with tag 33723 (0x83bb) encountered. `TIFFReadDirectory' @ warning/tiff.c/TIFFWarnings/960.
+var regexpMagickMessage = regexp.MustCompile("^[^:]+: (.*?)(?: [`'][^`']*')? @ ([a-zA-Z]+)/([^/]+)\\.c/[^/]+/\\d+\\.?$")
+
+func parseMagickStderr(stderr []byte) []*MagickMessage {
+	var messages []*MagickMessage
+	sc := bufio.NewScanner(bytes.NewReader(stderr))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if matches := regexpMagickMessage.FindStringSubmatch(line); matches != nil {
+			severity := strings.ToLower(matches[2])
+			if severity == "fatal" {
+				severity = "error"
+			}
+			messages = append(messages, &MagickMessage{Severity: severity, Module: matches[3], Message: matches[1]})
+			continue
+		}
+		messages = append(messages, &MagickMessage{Severity: "error", Message: line})
+	}
+	return messages
+}
+
+func magickPropertyInt(props map[string]any, key string) int {
+	str, ok := props[key].(string)
+	if !ok {
+		return 0
+	}
+	i, _ := strconv.Atoi(strings.TrimSpace(str))
+	return i
+}
+
+// declaredDimensions returns the dimensions from metadata, which may differ from the decoded raster.
+// exif dimensions are often stale after cropping or resizing.
+// container headers like png IHDR are not listed, since imagemagick takes the geometry from them
+func declaredDimensions(props map[string]any) []*MagickDimension {
+	var dims []*MagickDimension
+	if w, h := magickPropertyInt(props, "exif:PixelXDimension"), magickPropertyInt(props, "exif:PixelYDimension"); w > 0 && h > 0 {
+		dims = append(dims, &MagickDimension{Source: "exif", Width: w, Height: h})
+	}
+	return dims
+}
+
+// uniformColor checks whether all color channels have no variance
+func uniformColor(img *MagickImage) string {
+	if len(img.ChannelStatistics) == 0 {
+		return ""
+	}
+	var black, white = true, true
+	var quantum = float64(uint64(1)<<img.Depth - 1)
+	var channels int
+	for name, stat := range img.ChannelStatistics {
+		if stat == nil || strings.EqualFold(name, "alpha") {
+			continue
+		}
+		channels++
+		if stat.StandardDeviation != 0 || stat.Min != stat.Max {
+			return ""
+		}
+		if stat.Max != 0 {
+			black = false
+		}
+		if img.Depth == 0 || stat.Min != quantum {
+			white = false
+		}
+	}
+	switch {
+	case channels == 0:
+		return ""
+	case black:
+		return "black"
+	case white:
+		return "white"
+	default:
+		return "color"
+	}
+}
+
+func newMagickValidation(meta []*MagickResult, stderr []byte, runErr error) *MagickValidation {
+	mv := &MagickValidation{
+		Messages: parseMagickStderr(stderr),
+	}
+	for _, msg := range mv.Messages {
+		if msg.Severity == "error" {
+			mv.ErrorCount++
+		} else {
+			mv.WarningCount++
+		}
+	}
+	if runErr != nil && mv.ErrorCount == 0 {
+		mv.ErrorCount++
+		mv.Messages = append(mv.Messages, &MagickMessage{Severity: "error", Message: runErr.Error()})
+	}
+	mv.Valid = mv.ErrorCount == 0
+	if len(meta) == 0 || meta[0].Image == nil {
+		mv.Valid = false
+		return mv
+	}
+	img := meta[0].Image
+	mv.Declared = declaredDimensions(img.Properties)
+	if img.Geometry != nil {
+		for _, dim := range mv.Declared {
+			if float64(dim.Width) != img.Geometry.Width || float64(dim.Height) != img.Geometry.Height {
+				mv.ExifMismatch = true
+			}
+		}
+	}
+	mv.Uniform = uniformColor(img)
+	for name := range img.Profiles {
+		mv.Profiles = append(mv.Profiles, strings.ToLower(name))
+	}
+	slices.Sort(mv.Profiles)
+	mv.ICC = slices.Contains(mv.Profiles, "icc") || slices.Contains(mv.Profiles, "icm")
+	mv.Exif = slices.Contains(mv.Profiles, "exif")
+	mv.XMP = slices.Contains(mv.Profiles, "xmp")
+	return mv
+}
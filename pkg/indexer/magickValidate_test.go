@@ -0,0 +1,109 @@
+package indexer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeConvert prints the policy folder and a warning to stderr and a black 640x480 image with a wrong exif size
+const fakeConvert = `#!/bin/sh
+cat > /dev/null
+printf 'convert: Unknown field with tag 33723 (0x83bb) encountered. \140TIFFReadDirectory\047 @ warning/tiff.c/TIFFWarnings/960.\n' >&2
+echo "convert: policy $MAGICK_CONFIGURE_PATH @ warning/policy.c/LoadPolicyCache/1." >&2
+cat <<EOF
+[{"version": "1.0", "image": {
+  "format": "TIFF", "mimeType": "image/tiff", "depth": 8,
+  "geometry": {"width": 640, "height": 480, "x": 0, "y": 0},
+  "channelStatistics": {
+    "red": {"min": 0, "max": 0, "mean": 0, "standardDeviation": 0},
+    "green": {"min": 0, "max": 0, "mean": 0, "standardDeviation": 0},
+    "blue": {"min": 0, "max": 0, "mean": 0, "standardDeviation": 0},
+    "alpha": {"min": 255, "max": 255, "mean": 255, "standardDeviation": 0}
+  },
+  "properties": {"exif:PixelXDimension": "1280", "exif:PixelYDimension": "960"},
+  "profiles": {"icc": {"length": 3144}, "exif": {"length": 200}}
+}}]
+EOF
+`
+
+func TestActionIdentifyV2Validate(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no shell available")
+	}
+	convert := filepath.Join(t.TempDir(), "convert")
+	if err := os.WriteFile(convert, []byte(fakeConvert), 0755); err != nil {
+		t.Fatalf("cannot write fake convert: %v", err)
+	}
+	ad := NewActionDispatcher(nil)
+	ai := NewActionIdentifyV2(NameIdentify, "", convert, false, 5*time.Second, false, nil, ad).(*ActionIdentifyV2)
+	if err := ai.SetValidation(&MagickPolicy{Memory: "256MiB", Disk: "1GiB", Time: time.Minute}, t.TempDir()); err != nil {
+		t.Fatalf("cannot enable validation: %v", err)
+	}
+	policy, err := os.ReadFile(filepath.Join(ai.policyDir, "policy.xml"))
+	if err != nil {
+		t.Fatalf("no policy written: %v", err)
+	}
+	if !strings.Contains(string(policy), `name="memory" value="256MiB"`) || !strings.Contains(string(policy), `name="time" value="60"`) ||
+		strings.Contains(string(policy), `name="map"`) {
+		t.Errorf("wrong policy: %s", policy)
+	}
+
+	result, err := ai.Stream("image/tiff", bytes.NewReader([]byte("II*\x00")), "test.tif")
+	if err != nil {
+		t.Fatalf("cannot stream: %v", err)
+	}
+	mv := result.Metadata[NameIdentify].(FullMagickResult).Validation
+	if mv == nil {
+		t.Fatal("no validation result")
+	}
+	if !mv.Valid || mv.WarningCount != 2 || mv.ErrorCount != 0 {
+		t.Fatalf("wrong message counts: %+v", mv)
+	}
+	if mv.Messages[0].Severity != "warning" || mv.Messages[0].Module != "tiff" || mv.Messages[0].Message != "Unknown field with tag 33723 (0x83bb) encountered." {
+		t.Errorf("wrong warning: %+v", mv.Messages[0])
+	}
+	if !strings.HasSuffix(mv.Messages[1].Message, ai.policyDir) {
+		t.Errorf("policy not passed to convert: %+v", mv.Messages[1])
+	}
+	if !mv.ExifMismatch || len(mv.Declared) != 1 || mv.Declared[0].Width != 1280 {
+		t.Errorf("exif dimension mismatch not detected: %+v", mv.Declared)
+	}
+	if mv.Uniform != "black" || !mv.ICC || !mv.Exif || mv.XMP || strings.Join(mv.Profiles, ",") != "exif,icc" {
+		t.Errorf("wrong image checks: %+v", mv)
+	}
+
+	dir := ai.policyDir
+	ad.AddCloser(ai)
+	if err := ad.Close(); err != nil {
+		t.Fatalf("cannot close: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("policy folder not removed")
+	}
+}
+
+func TestMagickValidationDimensions(t *testing.T) {
+	for _, test := range []struct {
+		props        map[string]any
+		declared     int
+		exifMismatch bool
+	}{
+		{map[string]any{"exif:PixelXDimension": "640", "exif:PixelYDimension": "480"}, 1, false},
+		{map[string]any{"exif:PixelXDimension": "320", "exif:PixelYDimension": "240"}, 1, true},
+		{map[string]any{"exif:PixelXDimension": "640", "exif:PixelYDimension": "240"}, 1, true},
+		// incomplete exif dimensions are ignored
+		{map[string]any{"exif:PixelXDimension": "320"}, 0, false},
+		// imagemagick takes the geometry from the png header
+		{map[string]any{"png:IHDR.width,height": "640, 240"}, 0, false},
+	} {
+		img := &MagickImage{Geometry: &Geometry{Width: 640, Height: 480}, Properties: test.props}
+		mv := newMagickValidation([]*MagickResult{{Image: img}}, nil, nil)
+		if len(mv.Declared) != test.declared || mv.ExifMismatch != test.exifMismatch {
+			t.Errorf("%v: declared %v, exif mismatch %v", test.props, mv.Declared, mv.ExifMismatch)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indexer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/je4/utils/v2/pkg/zLogger"
+)
+
+// ConfigLoader reads the current indexer configuration, i.e. from a toml file
+type ConfigLoader func() (*IndexerConfig, error)
+
+// DispatcherLoader builds a new ActionDispatcher from the current configuration of an application.
+// hash identifies the configuration, changes of the returned files are watched
+type DispatcherLoader func() (ad *ActionDispatcher, hash string, files []string, err error)
+
+// ConfigVersion identifies the configuration of the active ActionDispatcher
+type ConfigVersion struct {
+	Version int       `json:"version"`
+	Hash    string    `json:"hash"`
+	Loaded  time.Time `json:"loaded"`
+}
+
+type dispatcherInstance struct {
+	ad      *ActionDispatcher
+	version *ConfigVersion
+	active  sync.WaitGroup
+}
+
+// Reloader rebuilds the ActionDispatcher on configuration or signature changes.
+// in-flight requests finish on the old dispatcher, which is closed afterward
+type Reloader struct {
+	load    DispatcherLoader
+	logger  zLogger.ZLogger
+	mu      sync.RWMutex
+	reload  sync.Mutex
+	current *dispatcherInstance
+	stats   map[string]string
+}
+
+// NewReloader loads the configuration and builds the first ActionDispatcher.
+// files are watched for changes in addition to the siegfried signature files.
+// the actions are bound to server, which is needed for the legacy Do
+func NewReloader(load ConfigLoader, fss map[string]fs.FS, server *Server, logger zLogger.ZLogger, files ...string) (*Reloader, error) {
+	return NewDispatcherReloader(func() (*ActionDispatcher, string, []string, error) {
+		conf, err := load()
+		if err != nil {
+			return nil, "", nil, errors.Wrap(err, "cannot load configuration")
+		}
+		watched := watchedFiles(conf, files)
+		hash, err := ConfigHash(conf, watched)
+		if err != nil {
+			return nil, "", nil, errors.WithStack(err)
+		}
+		ad, err := initActionDispatcher(fss, *conf, server, logger)
+		if err != nil {
+			return nil, "", nil, errors.Wrap(err, "cannot initialize action dispatcher")
+		}
+		return ad, hash, watched, nil
+	}, logger)
+}
+
+// NewDispatcherReloader builds the first ActionDispatcher with load.
+// applications with their own configuration format build their actions in load
+func NewDispatcherReloader(load DispatcherLoader, logger zLogger.ZLogger) (*Reloader, error) {
+	r := &Reloader{
+		load:   load,
+		logger: logger,
+	}
+	if _, err := r.Reload(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return r, nil
+}
+
+// Acquire returns the active ActionDispatcher. release must be called after the request
+func (r *Reloader) Acquire() (*ActionDispatcher, func()) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	inst := r.current
+	inst.active.Add(1)
+	return inst.ad, inst.active.Done
+}
+
+// Version returns the version of the active configuration
+func (r *Reloader) Version() *ConfigVersion {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v := *r.current.version
+	return &v
+}
+
+// Reload rebuilds the ActionDispatcher and replaces the active one
+func (r *Reloader) Reload() (*ConfigVersion, error) {
+	r.reload.Lock()
+	defer r.reload.Unlock()
+
+	ad, hash, watched, err := r.load()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	inst := &dispatcherInstance{
+		ad: ad,
+		version: &ConfigVersion{
+			Version: 1,
+			Hash:    hash,
+			Loaded:  time.Now(),
+		},
+	}
+
+	r.mu.Lock()
+	old := r.current
+	if old != nil {
+		inst.version.Version = old.version.Version + 1
+	}
+	r.current = inst
+	r.stats = statFiles(watched)
+	r.mu.Unlock()
+
+	if old != nil {
+		go func() {
+			old.active.Wait()
+			if err := old.ad.Close(); err != nil {
+				r.logger.Error().Err(err).Msgf("cannot close action dispatcher of configuration version %d", old.version.Version)
+			}
+		}()
+	}
+	r.logger.Info().Msgf("indexer configuration version %d loaded [%s]", inst.version.Version, inst.version.Hash)
+	v := *inst.version
+	return &v, nil
+}
+
+// watchedFiles are the additional files and all signature files on disk
+func watchedFiles(conf *IndexerConfig, additional []string) []string {
+	var files = append([]string{}, additional...)
+	for _, sigFile := range append([]string{conf.Siegfried.SignatureFile}, conf.Siegfried.Signatures...) {
+		if sigFile != "" && fsRegexp.FindStringSubmatch(sigFile) == nil {
+			files = append(files, sigFile)
+		}
+	}
+	return files
+}
+
+// ConfigHash is built from the json of the configuration and the content of the files
+func ConfigHash(conf any, files []string) (string, error) {
+	h := sha256.New()
+	if err := json.NewEncoder(h).Encode(conf); err != nil {
+		return "", errors.Wrap(err, "cannot marshal configuration")
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", errors.Wrapf(err, "cannot read '%s'", file)
+		}
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func statFiles(files []string) map[string]string {
+	var stats = map[string]string{}
+	for _, file := range files {
+		if fi, err := os.Stat(file); err == nil {
+			stats[file] = fmt.Sprintf("%d/%d", fi.Size(), fi.ModTime().UnixNano())
+		}
+	}
+	return stats
+}
+
+func (r *Reloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for file, stat := range r.stats {
+		fi, err := os.Stat(file)
+		if err != nil {
+			// file is replaced right now, wait for the next check
+			continue
+		}
+		if fmt.Sprintf("%d/%d", fi.Size(), fi.ModTime().UnixNano()) != stat {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch checks the watched files every interval and reloads on changes until ctx is done
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if _, err := r.Reload(); err != nil {
+				r.logger.Error().Err(err).Msg("cannot reload configuration")
+			}
+		}
+	}
+}
+
+// HandleSignal reloads on SIGHUP until ctx is done
+func (r *Reloader) HandleSignal(ctx context.Context) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	defer signal.Stop(sig)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sig:
+			if _, err := r.Reload(); err != nil {
+				r.logger.Error().Err(err).Msg("cannot reload configuration")
+			}
+		}
+	}
+}
+
+// ServeHTTP reports the active configuration version on GET and reloads on POST
+func (r *Reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var version *ConfigVersion
+	switch req.Method {
+	case http.MethodGet:
+		version = r.Version()
+	case http.MethodPost:
+		var err error
+		if version, err = r.Reload(); err != nil {
+			http.Error(w, fmt.Sprintf("cannot reload configuration: %v", err), http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(version)
+}
+
+// Close waits for the requests of the active ActionDispatcher and closes it
+func (r *Reloader) Close() error {
+	r.mu.Lock()
+	inst := r.current
+	r.mu.Unlock()
+	inst.active.Wait()
+	return errors.WithStack(inst.ad.Close())
+}
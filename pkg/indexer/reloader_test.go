@@ -0,0 +1,113 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+type closeFunc func() error
+
+func (f closeFunc) Close() error { return f() }
+
+func TestReloader(t *testing.T) {
+	data, err := os.ReadFile("../../data/siegfried/default.sig")
+	if err != nil {
+		t.Skipf("no signature file: %v", err)
+	}
+	dir := t.TempDir()
+	sigFile := filepath.Join(dir, "default.sig")
+	cfgFile := filepath.Join(dir, "indexer.toml")
+	if err := os.WriteFile(sigFile, data, 0644); err != nil {
+		t.Fatalf("cannot write signature: %v", err)
+	}
+	if err := os.WriteFile(cfgFile, []byte("# version 1"), 0644); err != nil {
+		t.Fatalf("cannot write config: %v", err)
+	}
+	load := func() (*IndexerConfig, error) {
+		return &IndexerConfig{Siegfried: ConfigSiegfried{SignatureFile: sigFile}}, nil
+	}
+	logger := zerolog.Nop()
+	srv := &Server{}
+	r, err := NewReloader(load, nil, srv, &logger, cfgFile)
+	if err != nil {
+		t.Fatalf("cannot create reloader: %v", err)
+	}
+	defer r.Close()
+	first := r.Version()
+	if first.Version != 1 || first.Hash == "" {
+		t.Fatalf("wrong version: %+v", first)
+	}
+
+	// in-flight request keeps the old dispatcher open
+	var closed atomic.Bool
+	ad, release := r.Acquire()
+	ad.AddCloser(closeFunc(func() error { closed.Store(true); return nil }))
+	v, err := r.Reload()
+	if err != nil {
+		t.Fatalf("cannot reload: %v", err)
+	}
+	if v.Version != 2 || v.Hash != first.Hash {
+		t.Errorf("wrong version after reload: %+v", v)
+	}
+	if _, ok := ad.GetAction(NameSiegfried); !ok || closed.Load() {
+		t.Fatalf("old dispatcher closed during request")
+	}
+	release()
+	// the legacy Do needs the server
+	ad, release = r.Acquire()
+	if action, ok := ad.GetAction(NameSiegfried); !ok || action.(*ActionSiegfried).server != srv {
+		t.Errorf("reloaded actions are not bound to the server")
+	}
+	release()
+	for i := 0; i < 100 && !closed.Load(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !closed.Load() {
+		t.Errorf("old dispatcher not closed")
+	}
+
+	// file change
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 10*time.Millisecond)
+	if err := os.WriteFile(cfgFile, []byte("# version 3, changed"), 0644); err != nil {
+		t.Fatalf("cannot write config: %v", err)
+	}
+	for i := 0; i < 100 && r.Version().Version < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if v := r.Version(); v.Version != 3 || v.Hash == first.Hash {
+		t.Errorf("file change not reloaded: %+v", v)
+	}
+	cancel()
+
+	// admin endpoint
+	srv.SetReloader(r, "secret")
+	admin := srv.adminAuth(r)
+	for _, auth := range []string{"", "Bearer wrong", "secret"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/admin/reload", nil)
+		req.Header.Set("Authorization", auth)
+		admin.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("admin request with authorization '%s' not rejected: %d", auth, w.Code)
+		}
+	}
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/admin/reload", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	admin.ServeHTTP(w, req)
+	var version ConfigVersion
+	if err := json.NewDecoder(w.Body).Decode(&version); err != nil || w.Code != http.StatusOK || version.Version != 4 {
+		t.Errorf("wrong admin response %d: %+v / %v", w.Code, version, err)
+	}
+}
@@ -1,21 +1,102 @@
 package indexer
 
-import "golang.org/x/exp/slices"
+import (
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
 
 type ResultV2 struct {
-	Errors    map[string]string `json:"errors,omitempty"`
-	Mimetype  string            `json:"mimetype"`
-	Mimetypes []string          `json:"mimetypes"`
-	Pronom    string            `json:"pronom"`
-	Pronoms   []string          `json:"pronoms"`
-	Checksum  map[string]string `json:"checksum,omitempty"`
-	Width     uint              `json:"width,omitempty"`
-	Height    uint              `json:"height,omitempty"`
-	Duration  uint              `json:"duration,omitempty"`
-	Size      uint64            `json:"size"`
-	Metadata  map[string]any    `json:"metadata"`
-	Type      string            `json:"type"`
-	Subtype   string            `json:"subtype"`
+	Errors     map[string]string   `json:"errors,omitempty"`
+	Mimetype   string              `json:"mimetype"`
+	Mimetypes  []string            `json:"mimetypes"`
+	Pronom     string              `json:"pronom"`
+	Pronoms    []string            `json:"pronoms"`
+	Checksum   map[string]string   `json:"checksum,omitempty"`
+	Width      uint                `json:"width,omitempty"`
+	Height     uint                `json:"height,omitempty"`
+	Duration   uint                `json:"duration,omitempty"`
+	BitDepth   uint                `json:"bitdepth,omitempty"`
+	Instrument string              `json:"instrument,omitempty"` // modality, camera or telescope
+	Date       string              `json:"date,omitempty"`       // creation or observation as YYYY-MM-DD
+	Size       uint64              `json:"size"`
+	Metadata   map[string]any      `json:"metadata"`
+	Type       string              `json:"type"`
+	Subtype    string              `json:"subtype"`
+	Encrypted  bool                `json:"encrypted,omitempty"`
+	Sensitive  bool                `json:"sensitive,omitempty"` // contains private key material
+	PII        map[string]int64    `json:"pii,omitempty"`       // number of personal data findings per detector
+	Embedded   []*EmbeddedResource `json:"embedded,omitempty"`
+	// Identifications are the format identifications with the registry, which made the decision
+	Identifications []*FormatIdentification `json:"identifications,omitempty"`
+}
+
+// FormatIdentification is a single identification of a format registry, i.e. pronom or loc
+type FormatIdentification struct {
+	Source     string    `json:"source"`
+	Identifier string    `json:"identifier"`
+	Signature  string    `json:"signature,omitempty"`
+	Details    string    `json:"details,omitempty"`
+	Created    time.Time `json:"created"`
+	ID         string    `json:"id"`
+	Format     string    `json:"format,omitempty"`
+	Version    string    `json:"version,omitempty"`
+	Mimetype   string    `json:"mimetype,omitempty"`
+	Class      string    `json:"class,omitempty"`
+	Basis      []string  `json:"basis,omitempty"`
+	Warning    string    `json:"warning,omitempty"`
+	Known      bool      `json:"known"`
+}
+
+// EmbeddedResource is a document contained in the indexed file, i.e. an attachment or a zip entry
+type EmbeddedResource struct {
+	Path     string         `json:"path"`
+	Name     string         `json:"name,omitempty"`
+	Mimetype string         `json:"mimetype,omitempty"`
+	Depth    int            `json:"depth,omitempty"`
+	Size     uint64         `json:"size,omitempty"`
+	Source   string         `json:"source,omitempty"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// merge adds the information of another action about the same embedded resource
+func (e *EmbeddedResource) merge(r *EmbeddedResource) {
+	if e.Name == "" {
+		e.Name = r.Name
+	}
+	if e.Mimetype == "" {
+		e.Mimetype = r.Mimetype
+	}
+	if e.Depth == 0 {
+		e.Depth = r.Depth
+	}
+	if r.Size > e.Size {
+		e.Size = r.Size
+	}
+	if r.Source != "" && !slices.Contains(strings.Split(e.Source, ","), r.Source) {
+		if e.Source != "" {
+			e.Source += ","
+		}
+		e.Source += r.Source
+	}
+	if len(r.Metadata) > 0 && e.Metadata == nil {
+		e.Metadata = map[string]any{}
+	}
+	for k, m := range r.Metadata {
+		e.Metadata[k] = m
+	}
+}
+
+// mergeEmbedded adds an embedded resource. resources with the same path are merged
+func (v *ResultV2) mergeEmbedded(r *EmbeddedResource) {
+	for _, e := range v.Embedded {
+		if e.Path == r.Path {
+			e.merge(r)
+			return
+		}
+	}
+	v.Embedded = append(v.Embedded, r)
 }
 
 func NewResultV2() *ResultV2 {
@@ -60,6 +141,15 @@ func (v *ResultV2) Merge(r *ResultV2) {
 	if r.Duration > v.Duration {
 		v.Duration = r.Duration
 	}
+	if r.BitDepth > v.BitDepth {
+		v.BitDepth = r.BitDepth
+	}
+	if r.Instrument != "" {
+		v.Instrument = r.Instrument
+	}
+	if r.Date != "" {
+		v.Date = r.Date
+	}
 	if r.Size > v.Size {
 		v.Size = r.Size
 	}
@@ -78,9 +168,26 @@ func (v *ResultV2) Merge(r *ResultV2) {
 		v.Type = r.Type
 		v.Subtype = r.Subtype
 	}
+	if r.Encrypted {
+		v.Encrypted = true
+	}
+	if r.Sensitive {
+		v.Sensitive = true
+	}
+	for detector, count := range r.PII {
+		if v.PII == nil {
+			v.PII = map[string]int64{}
+		}
+		v.PII[detector] += count
+	}
+	for _, e := range r.Embedded {
+		v.mergeEmbedded(e)
+	}
+	v.Identifications = append(v.Identifications, r.Identifications...)
 }
 
 type FullMagickResult struct {
-	Magick *MagickResult `json:"magick"`
-	Frames []*Geometry   `json:"frames,omitempty"`
+	Magick     *MagickResult     `json:"magick"`
+	Frames     []*Geometry       `json:"frames,omitempty"`
+	Validation *MagickValidation `json:"validation,omitempty"`
 }
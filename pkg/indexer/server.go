@@ -17,6 +17,7 @@ package indexer
 import (
 	"cmp"
 	"context"
+	"crypto/subtle"
 	"crypto/tls"
 	"emperror.dev/errors"
 	"encoding/json"
@@ -72,6 +73,8 @@ type Server struct {
 	sftp            *SFTP
 	insecureCert    bool
 	mimeRelevance   []MimeWeight
+	reloader        *Reloader
+	adminToken      string
 }
 
 func NewServer(
@@ -131,6 +134,34 @@ func (s *Server) AddActions(as ...Action) {
 	}
 }
 
+// SetReloader replaces the actions of the server with the reloadable ActionDispatcher.
+// /admin/reload is only available with a token, which has to be sent as bearer token
+func (s *Server) SetReloader(r *Reloader, token string) {
+	s.reloader = r
+	s.adminToken = token
+}
+
+// adminAuth rejects requests without the admin bearer token
+func (s *Server) adminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || s.adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// dispatcher returns the active ActionDispatcher. release must be called after the request
+func (s *Server) dispatcher() (*ActionDispatcher, func()) {
+	if s.reloader == nil {
+		return s.actions, func() {}
+	}
+	return s.reloader.Acquire()
+}
+
 /*
 holistic function to give some mimetypes a relevance
 */
@@ -385,9 +416,11 @@ func (s *Server) HandleDefault(w http.ResponseWriter, r *http.Request) {
 
 	// if no actions is given, just use all
 	if len(param.Actions) == 0 {
-		for name, _ := range s.actions.GetActions() {
+		actions, release := s.dispatcher()
+		for name, _ := range actions.GetActions() {
 			param.Actions = append(param.Actions, name)
 		}
+		release()
 	}
 
 	// todo: bad code. make it configurable
@@ -433,9 +466,11 @@ func (s *Server) HandleVersion(w http.ResponseWriter, r *http.Request) {
 
 	// if no actions is given, just use all
 	if len(param.Actions) == 0 {
-		for name, _ := range s.actions.GetActions() {
+		actions, release := s.dispatcher()
+		for name, _ := range actions.GetActions() {
 			param.Actions = append(param.Actions, name)
 		}
+		release()
 	}
 
 	// todo: bad code. make it configurable
@@ -524,7 +559,9 @@ func (s *Server) doIndex(param ActionParam, version string) (any, error) {
 		}
 	}
 
-	s.actions.Sort(param.Actions)
+	actions, release := s.dispatcher()
+	defer release()
+	actions.Sort(param.Actions)
 
 	errs := map[string]string{}
 	mimetypes := []string{mimetype}
@@ -533,7 +570,7 @@ func (s *Server) doIndex(param ActionParam, version string) (any, error) {
 	pronoms := []string{}
 	// todo: download once, start concurrent identifiers...
 	for key, actionstr := range param.Actions {
-		action, ok := s.actions.GetActions()[actionstr]
+		action, ok := actions.GetActions()[actionstr]
 		if !ok {
 			// return nil, errors.Wrapf(err, "invalid actions: %s", actionstr)
 			errs[actionstr] = "actions not available"
@@ -589,6 +626,11 @@ func (s *Server) doIndex(param ActionParam, version string) (any, error) {
 			mimetype = mimetypes[0]
 		}
 	}
+	// post processing of the collected metadata, i.e. pii detection in the extracted text
+	processed := NewResultV2()
+	processed.Errors, processed.Metadata = errs, metadata
+	actions.processResult(processed, param.Actions, true)
+
 	if version == "v1" {
 		result := map[string]interface{}{}
 		result["errors"] = errs
@@ -623,6 +665,7 @@ func (s *Server) doIndex(param ActionParam, version string) (any, error) {
 				Duration:  uint(duration.Seconds()), // uint(math.Round(float64(duration) / float64(time.Second))),
 				Size:      uint64(size),
 				Metadata:  metadata,
+				PII:       processed.PII,
 			}
 			return result, nil
 		}
@@ -634,6 +677,9 @@ func (s *Server) ListenAndServe(addr, cert, key string) error {
 	router := mux.NewRouter()
 
 	router.HandleFunc("/", s.HandleDefault).Methods("POST")
+	if s.reloader != nil && s.adminToken != "" {
+		router.Handle("/admin/reload", s.adminAuth(s.reloader)).Methods("GET", "POST")
+	}
 	router.HandleFunc("/{version}", s.HandleVersion).Methods("POST")
 
 	loggedRouter := handlers.LoggingHandler(s.accesslog, router)
@@ -0,0 +1,172 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indexer
+
+import (
+	"encoding/xml"
+	"regexp"
+	"strings"
+
+	"emperror.dev/errors"
+)
+
+// xmlPathPredicate is an attribute condition of a step, i.e. [@type] or [@type='mods']
+type xmlPathPredicate struct {
+	namespace string
+	local     string
+	value     *string
+}
+
+// xmlPathStep is a single step of a path, namespace "*" matches every namespace
+type xmlPathStep struct {
+	descendant bool
+	namespace  string
+	local      string
+	predicates []xmlPathPredicate
+}
+
+// xmlPath is a simple xpath with child and descendant steps and attribute predicates:
+//
+//	/mets:mets/mets:dmdSec//mods:mods[@version='3.7']
+//
+// prefixes are resolved with the namespace map. names without prefix match any namespace
+type xmlPath struct {
+	steps []xmlPathStep
+}
+
+var xmlPathStepRegexp = regexp.MustCompile(`^(?:([A-Za-z_][\w.-]*):)?([A-Za-z_][\w.-]*|\*)((?:\[[^\]]*\])*)$`)
+var xmlPathPredicateRegexp = regexp.MustCompile(`\[\s*@(?:([A-Za-z_][\w.-]*):)?([A-Za-z_][\w.-]*)\s*(?:=\s*(?:'([^']*)'|"([^"]*)"))?\s*\]`)
+
+func parseXMLPath(path string, namespaces map[string]string) (*xmlPath, error) {
+	if !strings.HasPrefix(path, "/") {
+		path = "//" + path
+	}
+	xp := &xmlPath{}
+	for len(path) > 0 {
+		var step = xmlPathStep{}
+		if strings.HasPrefix(path, "//") {
+			step.descendant = true
+			path = path[2:]
+		} else {
+			path = strings.TrimPrefix(path, "/")
+		}
+		// slashes within predicates are part of the step
+		end := len(path)
+		depth := 0
+	loop:
+		for i, c := range path {
+			switch c {
+			case '[':
+				depth++
+			case ']':
+				depth--
+			case '/':
+				if depth == 0 {
+					end = i
+					break loop
+				}
+			}
+		}
+		stepStr := path[:end]
+		path = path[end:]
+		found := xmlPathStepRegexp.FindStringSubmatch(stepStr)
+		if found == nil {
+			return nil, errors.Errorf("invalid xpath step '%s'", stepStr)
+		}
+		namespace, err := xmlPathNamespace(found[1], namespaces)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		step.namespace = namespace
+		step.local = found[2]
+		predicates := xmlPathPredicateRegexp.FindAllStringSubmatch(found[3], -1)
+		if len(xmlPathPredicateRegexp.ReplaceAllString(found[3], "")) > 0 {
+			return nil, errors.Errorf("unsupported xpath predicate in '%s'", stepStr)
+		}
+		for _, pred := range predicates {
+			namespace, err := xmlPathNamespace(pred[1], namespaces)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			var p = xmlPathPredicate{namespace: namespace, local: pred[2]}
+			if strings.Contains(pred[0], "=") {
+				value := pred[3] + pred[4]
+				p.value = &value
+			}
+			step.predicates = append(step.predicates, p)
+		}
+		xp.steps = append(xp.steps, step)
+	}
+	if len(xp.steps) == 0 {
+		return nil, errors.New("empty xpath")
+	}
+	return xp, nil
+}
+
+func xmlPathNamespace(prefix string, namespaces map[string]string) (string, error) {
+	if prefix == "" {
+		return "*", nil
+	}
+	ns, ok := namespaces[prefix]
+	if !ok {
+		return "", errors.Errorf("unknown namespace prefix '%s'", prefix)
+	}
+	return ns, nil
+}
+
+func (step *xmlPathStep) matches(elem *xml.StartElement) bool {
+	if step.local != "*" && step.local != elem.Name.Local {
+		return false
+	}
+	if step.namespace != "*" && step.namespace != elem.Name.Space {
+		return false
+	}
+	for _, pred := range step.predicates {
+		var found bool
+		for _, attr := range elem.Attr {
+			if attr.Name.Local != pred.local || (pred.namespace != "*" && pred.namespace != attr.Name.Space) {
+				continue
+			}
+			if pred.value == nil || *pred.value == attr.Value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// match checks, whether the path ends with the last element of the stack
+func (xp *xmlPath) match(stack []xml.StartElement) bool {
+	return xp.matchFrom(0, 0, stack)
+}
+
+func (xp *xmlPath) matchFrom(si, ei int, stack []xml.StartElement) bool {
+	if si == len(xp.steps) {
+		return ei == len(stack)
+	}
+	step := &xp.steps[si]
+	if !step.descendant {
+		return ei < len(stack) && step.matches(&stack[ei]) && xp.matchFrom(si+1, ei+1, stack)
+	}
+	for j := ei; j < len(stack); j++ {
+		if step.matches(&stack[j]) && xp.matchFrom(si+1, j+1, stack) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,509 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sqlite is a minimal read-only reader for the sqlite3 file format.
+// it reads header, schema and table b-trees directly without cgo.
+// see https://www.sqlite.org/fileformat2.html
+package sqlite
+
+import (
+	"bytes"
+	"emperror.dev/errors"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+)
+
+const Magic = "SQLite format 3\x00"
+
+const (
+	EncodingUTF8    = 1
+	EncodingUTF16LE = 2
+	EncodingUTF16BE = 3
+)
+
+var EncodingString = map[uint32]string{
+	EncodingUTF8:    "UTF-8",
+	EncodingUTF16LE: "UTF-16le",
+	EncodingUTF16BE: "UTF-16be",
+}
+
+const (
+	pageInteriorIndex = 0x02
+	pageInteriorTable = 0x05
+	pageLeafIndex     = 0x0a
+	pageLeafTable     = 0x0d
+)
+
+// ErrStop can be returned by a scan function to end the scan without error
+var ErrStop = errors.New("stop scan")
+
+// Header is the 100 byte database header
+type Header struct {
+	PageSize          uint32 `json:"pagesize"`
+	WriteVersion      uint8  `json:"writeversion"`
+	ReadVersion       uint8  `json:"readversion"`
+	ReservedSpace     uint8  `json:"reservedspace"`
+	FileChangeCounter uint32 `json:"filechangecounter"`
+	PageCount         uint32 `json:"pagecount"`
+	FreelistTrunk     uint32 `json:"freelisttrunk"`
+	FreelistCount     uint32 `json:"freelistcount"`
+	SchemaCookie      uint32 `json:"schemacookie"`
+	SchemaFormat      uint32 `json:"schemaformat"`
+	DefaultCacheSize  uint32 `json:"defaultcachesize"`
+	AutoVacuum        uint32 `json:"autovacuum"`
+	TextEncoding      uint32 `json:"textencoding"`
+	UserVersion       uint32 `json:"userversion"`
+	IncrementalVacuum uint32 `json:"incrementalvacuum"`
+	ApplicationID     uint32 `json:"applicationid"`
+	VersionValidFor   uint32 `json:"versionvalidfor"`
+	SQLiteVersion     uint32 `json:"sqliteversion"`
+}
+
+// WAL returns true if the database is in write-ahead-log mode
+func (h *Header) WAL() bool {
+	return h.WriteVersion == 2 || h.ReadVersion == 2
+}
+
+// Version returns the sqlite library version which wrote the file as string
+func (h *Header) Version() string {
+	return fmt.Sprintf("%d.%d.%d", h.SQLiteVersion/1000000, h.SQLiteVersion/1000%1000, h.SQLiteVersion%1000)
+}
+
+// SchemaEntry is a row of the sqlite_schema table
+type SchemaEntry struct {
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	TblName  string `json:"tblname"`
+	RootPage uint32 `json:"rootpage"`
+	SQL      string `json:"sql,omitempty"`
+}
+
+type DB struct {
+	r      io.ReaderAt
+	size   int64
+	usable int
+	Header Header
+}
+
+// Open reads the database header. r must stay open as long as the DB is used
+func Open(r io.ReaderAt, size int64) (*DB, error) {
+	var hdr = make([]byte, 100)
+	if _, err := r.ReadAt(hdr, 0); err != nil {
+		return nil, errors.Wrap(err, "cannot read sqlite header")
+	}
+	if !bytes.HasPrefix(hdr, []byte(Magic)) {
+		return nil, errors.New("no sqlite3 database")
+	}
+	db := &DB{r: r, size: size}
+	h := &db.Header
+	h.PageSize = uint32(binary.BigEndian.Uint16(hdr[16:18]))
+	if h.PageSize == 1 {
+		h.PageSize = 65536
+	}
+	if h.PageSize < 512 || h.PageSize&(h.PageSize-1) != 0 {
+		return nil, errors.Errorf("invalid page size %d", h.PageSize)
+	}
+	h.WriteVersion = hdr[18]
+	h.ReadVersion = hdr[19]
+	h.ReservedSpace = hdr[20]
+	h.FileChangeCounter = binary.BigEndian.Uint32(hdr[24:28])
+	h.PageCount = binary.BigEndian.Uint32(hdr[28:32])
+	h.FreelistTrunk = binary.BigEndian.Uint32(hdr[32:36])
+	h.FreelistCount = binary.BigEndian.Uint32(hdr[36:40])
+	h.SchemaCookie = binary.BigEndian.Uint32(hdr[40:44])
+	h.SchemaFormat = binary.BigEndian.Uint32(hdr[44:48])
+	h.DefaultCacheSize = binary.BigEndian.Uint32(hdr[48:52])
+	h.AutoVacuum = binary.BigEndian.Uint32(hdr[52:56])
+	h.TextEncoding = binary.BigEndian.Uint32(hdr[56:60])
+	h.UserVersion = binary.BigEndian.Uint32(hdr[60:64])
+	h.IncrementalVacuum = binary.BigEndian.Uint32(hdr[64:68])
+	h.ApplicationID = binary.BigEndian.Uint32(hdr[68:72])
+	h.VersionValidFor = binary.BigEndian.Uint32(hdr[92:96])
+	h.SQLiteVersion = binary.BigEndian.Uint32(hdr[96:100])
+	// the in-header page count is only valid if the change counter matches
+	if h.PageCount == 0 || h.VersionValidFor != h.FileChangeCounter {
+		h.PageCount = uint32(size / int64(h.PageSize))
+	}
+	if h.TextEncoding == 0 {
+		h.TextEncoding = EncodingUTF8
+	}
+	db.usable = int(h.PageSize) - int(h.ReservedSpace)
+	return db, nil
+}
+
+func (db *DB) readPage(pgno uint32) ([]byte, error) {
+	if pgno == 0 || int64(pgno)*int64(db.Header.PageSize) > db.size {
+		return nil, errors.Errorf("page %d out of range", pgno)
+	}
+	var page = make([]byte, db.Header.PageSize)
+	if _, err := db.r.ReadAt(page, int64(pgno-1)*int64(db.Header.PageSize)); err != nil && err != io.EOF {
+		return nil, errors.Wrapf(err, "cannot read page %d", pgno)
+	}
+	return page, nil
+}
+
+// varint decodes a sqlite variable length integer
+func varint(buf []byte) (int64, int) {
+	var v uint64
+	for i := 0; i < 8; i++ {
+		if i >= len(buf) {
+			return 0, 0
+		}
+		v = v<<7 | uint64(buf[i]&0x7f)
+		if buf[i]&0x80 == 0 {
+			return int64(v), i + 1
+		}
+	}
+	if len(buf) < 9 {
+		return 0, 0
+	}
+	v = v<<8 | uint64(buf[8])
+	return int64(v), 9
+}
+
+type btreePage struct {
+	typ       byte
+	cells     []int
+	rightMost uint32
+	data      []byte
+}
+
+func (db *DB) readBTreePage(pgno uint32) (*btreePage, error) {
+	data, err := db.readPage(pgno)
+	if err != nil {
+		return nil, err
+	}
+	offset := 0
+	if pgno == 1 {
+		offset = 100
+	}
+	bp := &btreePage{typ: data[offset], data: data}
+	numCells := int(binary.BigEndian.Uint16(data[offset+3 : offset+5]))
+	hdrLen := 8
+	switch bp.typ {
+	case pageInteriorIndex, pageInteriorTable:
+		hdrLen = 12
+		bp.rightMost = binary.BigEndian.Uint32(data[offset+8 : offset+12])
+	case pageLeafIndex, pageLeafTable:
+	default:
+		return nil, errors.Errorf("invalid b-tree page type 0x%02x on page %d", bp.typ, pgno)
+	}
+	ptrs := offset + hdrLen
+	if ptrs+2*numCells > len(data) {
+		return nil, errors.Errorf("invalid cell count %d on page %d", numCells, pgno)
+	}
+	bp.cells = make([]int, numCells)
+	for i := 0; i < numCells; i++ {
+		bp.cells[i] = int(binary.BigEndian.Uint16(data[ptrs+2*i : ptrs+2*i+2]))
+	}
+	return bp, nil
+}
+
+// payload assembles the record of a table leaf cell including overflow pages
+func (db *DB) payload(cell []byte, payloadSize int64) ([]byte, error) {
+	// the payload cannot be larger than the database itself
+	if payloadSize < 0 || payloadSize > min(int64(db.Header.PageCount)*int64(db.Header.PageSize), db.size) {
+		return nil, errors.Errorf("invalid payload size %d", payloadSize)
+	}
+	u := int64(db.usable)
+	x := u - 35
+	if payloadSize <= x {
+		if payloadSize > int64(len(cell)) {
+			return nil, errors.New("cell exceeds page")
+		}
+		return cell[:payloadSize], nil
+	}
+	m := ((u-12)*32)/255 - 23
+	k := m + ((payloadSize - m) % (u - 4))
+	local := m
+	if k <= x {
+		local = k
+	}
+	if local+4 > int64(len(cell)) {
+		return nil, errors.New("cell exceeds page")
+	}
+	var result = make([]byte, 0, payloadSize)
+	result = append(result, cell[:local]...)
+	next := binary.BigEndian.Uint32(cell[local : local+4])
+	for visited := 0; next != 0 && int64(len(result)) < payloadSize; visited++ {
+		if visited > int(db.Header.PageCount) {
+			return nil, errors.New("overflow chain loop")
+		}
+		page, err := db.readPage(next)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read overflow page")
+		}
+		next = binary.BigEndian.Uint32(page[0:4])
+		n := min(payloadSize-int64(len(result)), u-4)
+		result = append(result, page[4:4+n]...)
+	}
+	if int64(len(result)) < payloadSize {
+		return nil, errors.New("overflow chain too short")
+	}
+	return result, nil
+}
+
+// decodeRecord decodes a record into go values:
+// nil, int64, float64, string or []byte
+func (db *DB) decodeRecord(rec []byte) ([]any, error) {
+	hdrSize, n := varint(rec)
+	if n == 0 || hdrSize < int64(n) || hdrSize > int64(len(rec)) {
+		return nil, errors.New("invalid record header")
+	}
+	var types []int64
+	for pos := n; pos < int(hdrSize); {
+		t, l := varint(rec[pos:])
+		if l == 0 || t < 0 {
+			return nil, errors.New("invalid serial type")
+		}
+		types = append(types, t)
+		pos += l
+	}
+	var values = make([]any, 0, len(types))
+	body := rec[hdrSize:]
+	for _, t := range types {
+		var size int
+		switch {
+		case t == 0, t == 8, t == 9, t == 10, t == 11:
+			size = 0
+		case t >= 1 && t <= 4:
+			size = int(t)
+		case t == 5:
+			size = 6
+		case t == 6, t == 7:
+			size = 8
+		default:
+			size = int((t - 12) / 2)
+		}
+		if size > len(body) {
+			return nil, errors.New("record body too short")
+		}
+		field := body[:size]
+		body = body[size:]
+		switch {
+		case t == 0:
+			values = append(values, nil)
+		case t == 8:
+			values = append(values, int64(0))
+		case t == 9:
+			values = append(values, int64(1))
+		case t >= 1 && t <= 6:
+			// big endian two's complement
+			var v = int64(int8(field[0]))
+			for _, b := range field[1:] {
+				v = v<<8 | int64(b)
+			}
+			values = append(values, v)
+		case t == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(field)))
+		case t >= 12 && t%2 == 0:
+			values = append(values, bytes.Clone(field))
+		case t >= 13:
+			values = append(values, db.decodeText(field))
+		default:
+			values = append(values, nil)
+		}
+	}
+	return values, nil
+}
+
+func (db *DB) decodeText(data []byte) string {
+	var order binary.ByteOrder
+	switch db.Header.TextEncoding {
+	case EncodingUTF16LE:
+		order = binary.LittleEndian
+	case EncodingUTF16BE:
+		order = binary.BigEndian
+	default:
+		return string(data)
+	}
+	var u16 = make([]uint16, len(data)/2)
+	for i := range u16 {
+		u16[i] = order.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(u16))
+}
+
+// Scan walks the table b-tree starting at rootPage and calls fn for every row.
+// if fn returns ErrStop, the scan ends without error.
+// an INTEGER PRIMARY KEY column is stored as NULL, its value is the rowid
+func (db *DB) Scan(rootPage uint32, fn func(rowid int64, values []any) error) error {
+	err := db.walkTable(rootPage, 0, map[uint32]bool{}, func(bp *btreePage) error {
+		for _, cellOffset := range bp.cells {
+			if cellOffset >= len(bp.data) {
+				return errors.Errorf("invalid cell offset %d", cellOffset)
+			}
+			cell := bp.data[cellOffset:]
+			payloadSize, n1 := varint(cell)
+			rowid, n2 := varint(cell[n1:])
+			if n1 == 0 || n2 == 0 {
+				return errors.New("invalid cell")
+			}
+			rec, err := db.payload(cell[n1+n2:], payloadSize)
+			if err != nil {
+				return errors.Wrapf(err, "cannot read row %d", rowid)
+			}
+			values, err := db.decodeRecord(rec)
+			if err != nil {
+				return errors.Wrapf(err, "cannot decode row %d", rowid)
+			}
+			if err := fn(rowid, values); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == ErrStop {
+		return nil
+	}
+	return err
+}
+
+// CountRows counts the rows of a table by reading the leaf page headers only
+func (db *DB) CountRows(rootPage uint32) (int64, error) {
+	var count int64
+	err := db.walkTable(rootPage, 0, map[uint32]bool{}, func(bp *btreePage) error {
+		count += int64(len(bp.cells))
+		return nil
+	})
+	return count, err
+}
+
+// walkTable calls fn for every leaf page of the table b-tree.
+// visited protects against cyclic child pointers of corrupt databases
+func (db *DB) walkTable(pgno uint32, depth int, visited map[uint32]bool, fn func(bp *btreePage) error) error {
+	if depth > 64 {
+		return errors.New("b-tree too deep")
+	}
+	if visited[pgno] {
+		return errors.Errorf("b-tree page %d referenced twice", pgno)
+	}
+	visited[pgno] = true
+	bp, err := db.readBTreePage(pgno)
+	if err != nil {
+		return err
+	}
+	switch bp.typ {
+	case pageLeafTable:
+		return fn(bp)
+	case pageInteriorTable:
+		for _, cellOffset := range bp.cells {
+			if cellOffset+4 > len(bp.data) {
+				return errors.Errorf("invalid cell offset %d", cellOffset)
+			}
+			child := binary.BigEndian.Uint32(bp.data[cellOffset : cellOffset+4])
+			if err := db.walkTable(child, depth+1, visited, fn); err != nil {
+				return err
+			}
+		}
+		return db.walkTable(bp.rightMost, depth+1, visited, fn)
+	default:
+		return errors.Errorf("page %d is no table b-tree page", pgno)
+	}
+}
+
+// Schema reads all entries of the sqlite_schema table
+func (db *DB) Schema() ([]SchemaEntry, error) {
+	var result []SchemaEntry
+	if err := db.Scan(1, func(rowid int64, values []any) error {
+		if len(values) < 5 {
+			return nil
+		}
+		se := SchemaEntry{}
+		se.Type, _ = values[0].(string)
+		se.Name, _ = values[1].(string)
+		se.TblName, _ = values[2].(string)
+		if rp, ok := values[3].(int64); ok {
+			se.RootPage = uint32(rp)
+		}
+		se.SQL, _ = values[4].(string)
+		result = append(result, se)
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "cannot read schema")
+	}
+	return result, nil
+}
+
+// Table returns the schema entry of the table with the given name (case-insensitive)
+func (db *DB) Table(name string) (*SchemaEntry, error) {
+	schema, err := db.Schema()
+	if err != nil {
+		return nil, err
+	}
+	for _, se := range schema {
+		if se.Type == "table" && strings.EqualFold(se.Name, name) {
+			return &se, nil
+		}
+	}
+	return nil, errors.Errorf("table '%s' not found", name)
+}
+
+var integerPKRegexp = regexp.MustCompile(`(?i)[(,]\s*["'\x60\[]?(\w+)["'\x60\]]?\s+INTEGER\s+(?:NOT\s+NULL\s+)?PRIMARY\s+KEY`)
+
+// IntegerPrimaryKey returns the name of the INTEGER PRIMARY KEY column of a CREATE TABLE statement.
+// the column is an alias of the rowid, Scan passes its value as NULL
+func IntegerPrimaryKey(createSQL string) string {
+	if found := integerPKRegexp.FindStringSubmatch(createSQL); found != nil {
+		return found[1]
+	}
+	return ""
+}
+
+// Columns extracts the column names from a CREATE TABLE statement.
+// it is not a full sql parser but handles the usual table definitions
+func Columns(createSQL string) []string {
+	start := strings.Index(createSQL, "(")
+	end := strings.LastIndex(createSQL, ")")
+	if start < 0 || end <= start {
+		return nil
+	}
+	var columns []string
+	var depth int
+	var current strings.Builder
+	var defs []string
+	for _, r := range createSQL[start+1 : end] {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				defs = append(defs, current.String())
+				current.Reset()
+				continue
+			}
+		}
+		current.WriteRune(r)
+	}
+	defs = append(defs, current.String())
+	for _, def := range defs {
+		fields := strings.Fields(strings.TrimSpace(def))
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			continue
+		}
+		columns = append(columns, strings.Trim(fields[0], "\"`[]'"))
+	}
+	return columns
+}
@@ -0,0 +1,73 @@
+package sqlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// testDB builds a database with 512 byte pages. the b-tree headers are
+// written to the given pages, page 1 starts after the database header
+func testDB(pages ...[]byte) *bytes.Reader {
+	var data = make([]byte, 512*len(pages))
+	copy(data, Magic)
+	binary.BigEndian.PutUint16(data[16:], 512)
+	data[18], data[19] = 1, 1
+	binary.BigEndian.PutUint32(data[28:], uint32(len(pages)))
+	binary.BigEndian.PutUint32(data[56:], EncodingUTF8)
+	for i, page := range pages {
+		offset := 512 * i
+		if i == 0 {
+			offset = 100
+		}
+		copy(data[offset:512*(i+1)], page)
+	}
+	return bytes.NewReader(data)
+}
+
+func TestScanCycle(t *testing.T) {
+	var root = make([]byte, 12)
+	root[0] = pageInteriorTable
+	binary.BigEndian.PutUint32(root[8:], 2)
+	// page 2 has two cells and the right-most pointer referencing itself
+	var page = make([]byte, 512)
+	page[0] = pageInteriorTable
+	binary.BigEndian.PutUint16(page[3:], 2)
+	binary.BigEndian.PutUint32(page[8:], 2)
+	binary.BigEndian.PutUint16(page[12:], 400)
+	binary.BigEndian.PutUint16(page[14:], 400)
+	binary.BigEndian.PutUint32(page[400:], 2)
+	page[404] = 1
+	r := testDB(root, page)
+	db, err := Open(r, r.Size())
+	if err != nil {
+		t.Fatalf("cannot open database: %v", err)
+	}
+	// without cycle detection the walk only ends at the depth limit after 3^64 pages
+	if _, err := db.CountRows(1); err == nil {
+		t.Error("cyclic b-tree not detected")
+	}
+}
+
+func TestScanPayloadSize(t *testing.T) {
+	for name, size := range map[string][]byte{
+		"negative":  bytes.Repeat([]byte{0xff}, 9),
+		"too large": {0x84, 0x80, 0x80, 0x80, 0x80, 0x00},
+	} {
+		var page = make([]byte, 412)
+		page[0] = pageLeafTable
+		binary.BigEndian.PutUint16(page[3:], 1)
+		binary.BigEndian.PutUint16(page[8:], 300)
+		// page 1 starts after the header, the cell pointer is relative to the page
+		copy(page[200:], size)
+		page[200+len(size)] = 1
+		r := testDB(page)
+		db, err := Open(r, r.Size())
+		if err != nil {
+			t.Fatalf("%s: cannot open database: %v", name, err)
+		}
+		if err := db.Scan(1, func(rowid int64, values []any) error { return nil }); err == nil {
+			t.Errorf("%s: invalid payload size not detected", name)
+		}
+	}
+}
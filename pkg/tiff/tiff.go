@@ -0,0 +1,488 @@
+// Copyright 2025 Juergen Enge, info-age GmbH, Basel. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tiff is a minimal reader for the structure of TIFF and BigTIFF files.
+// it reads header and image file directories without decoding image data.
+// see TIFF Revision 6.0 and https://www.awaresystems.be/imaging/tiff/bigtiff.html
+package tiff
+
+import (
+	"bytes"
+	"emperror.dev/errors"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// field types
+const (
+	TypeByte      = 1
+	TypeASCII     = 2
+	TypeShort     = 3
+	TypeLong      = 4
+	TypeRational  = 5
+	TypeSByte     = 6
+	TypeUndefined = 7
+	TypeSShort    = 8
+	TypeSLong     = 9
+	TypeSRational = 10
+	TypeFloat     = 11
+	TypeDouble    = 12
+	TypeIFD       = 13
+	TypeLong8     = 16
+	TypeSLong8    = 17
+	TypeIFD8      = 18
+)
+
+var typeSize = map[uint16]uint64{
+	TypeByte: 1, TypeASCII: 1, TypeShort: 2, TypeLong: 4, TypeRational: 8,
+	TypeSByte: 1, TypeUndefined: 1, TypeSShort: 2, TypeSLong: 4, TypeSRational: 8,
+	TypeFloat: 4, TypeDouble: 8, TypeIFD: 4, TypeLong8: 8, TypeSLong8: 8, TypeIFD8: 8,
+}
+
+// baseline and common extension tags
+const (
+	TagNewSubfileType            = 254
+	TagImageWidth                = 256
+	TagImageLength               = 257
+	TagBitsPerSample             = 258
+	TagCompression               = 259
+	TagPhotometricInterpretation = 262
+	TagStripOffsets              = 273
+	TagSamplesPerPixel           = 277
+	TagRowsPerStrip              = 278
+	TagStripByteCounts           = 279
+	TagXResolution               = 282
+	TagYResolution               = 283
+	TagPlanarConfiguration       = 284
+	TagResolutionUnit            = 296
+	TagColorMap                  = 320
+	TagTileWidth                 = 322
+	TagTileLength                = 323
+	TagTileOffsets               = 324
+	TagTileByteCounts            = 325
+	TagSubIFDs                   = 330
+	TagExtraSamples              = 338
+	TagSampleFormat              = 339
+	TagXMP                       = 700
+	TagModelPixelScale           = 33550
+	TagIPTC                      = 33723
+	TagModelTiepoint             = 33922
+	TagModelTransformation       = 34264
+	TagPhotoshop                 = 34377
+	TagExifIFD                   = 34665
+	TagICCProfile                = 34675
+	TagGeoKeyDirectory           = 34735
+	TagGeoDoubleParams           = 34736
+	TagGeoASCIIParams            = 34737
+	TagGPSIFD                    = 34853
+)
+
+var TagNames = map[uint16]string{
+	254:   "NewSubfileType",
+	255:   "SubfileType",
+	256:   "ImageWidth",
+	257:   "ImageLength",
+	258:   "BitsPerSample",
+	259:   "Compression",
+	262:   "PhotometricInterpretation",
+	263:   "Threshholding",
+	264:   "CellWidth",
+	265:   "CellLength",
+	266:   "FillOrder",
+	269:   "DocumentName",
+	270:   "ImageDescription",
+	271:   "Make",
+	272:   "Model",
+	273:   "StripOffsets",
+	274:   "Orientation",
+	277:   "SamplesPerPixel",
+	278:   "RowsPerStrip",
+	279:   "StripByteCounts",
+	280:   "MinSampleValue",
+	281:   "MaxSampleValue",
+	282:   "XResolution",
+	283:   "YResolution",
+	284:   "PlanarConfiguration",
+	285:   "PageName",
+	286:   "XPosition",
+	287:   "YPosition",
+	288:   "FreeOffsets",
+	289:   "FreeByteCounts",
+	290:   "GrayResponseUnit",
+	291:   "GrayResponseCurve",
+	292:   "T4Options",
+	293:   "T6Options",
+	296:   "ResolutionUnit",
+	297:   "PageNumber",
+	301:   "TransferFunction",
+	305:   "Software",
+	306:   "DateTime",
+	315:   "Artist",
+	316:   "HostComputer",
+	317:   "Predictor",
+	318:   "WhitePoint",
+	319:   "PrimaryChromaticities",
+	320:   "ColorMap",
+	321:   "HalftoneHints",
+	322:   "TileWidth",
+	323:   "TileLength",
+	324:   "TileOffsets",
+	325:   "TileByteCounts",
+	330:   "SubIFDs",
+	332:   "InkSet",
+	333:   "InkNames",
+	334:   "NumberOfInks",
+	336:   "DotRange",
+	337:   "TargetPrinter",
+	338:   "ExtraSamples",
+	339:   "SampleFormat",
+	340:   "SMinSampleValue",
+	341:   "SMaxSampleValue",
+	342:   "TransferRange",
+	347:   "JPEGTables",
+	512:   "JPEGProc",
+	513:   "JPEGInterchangeFormat",
+	514:   "JPEGInterchangeFormatLength",
+	529:   "YCbCrCoefficients",
+	530:   "YCbCrSubSampling",
+	531:   "YCbCrPositioning",
+	532:   "ReferenceBlackWhite",
+	700:   "XMP",
+	33432: "Copyright",
+	33550: "ModelPixelScale",
+	33723: "IPTC",
+	33922: "ModelTiepoint",
+	34264: "ModelTransformation",
+	34377: "Photoshop",
+	34665: "ExifIFD",
+	34675: "ICCProfile",
+	34735: "GeoKeyDirectory",
+	34736: "GeoDoubleParams",
+	34737: "GeoASCIIParams",
+	34853: "GPSIFD",
+}
+
+// TagName returns the name of a tag or its number
+func TagName(tag uint16) string {
+	if name, ok := TagNames[tag]; ok {
+		return name
+	}
+	return fmt.Sprintf("Tag%d", tag)
+}
+
+var CompressionNames = map[uint64]string{
+	1:     "none",
+	2:     "ccitt-rle",
+	3:     "ccitt-g3",
+	4:     "ccitt-g4",
+	5:     "lzw",
+	6:     "ojpeg",
+	7:     "jpeg",
+	8:     "adobe-deflate",
+	32773: "packbits",
+	32946: "deflate",
+	34712: "jpeg2000",
+	34887: "lerc",
+	34925: "lzma",
+	50000: "zstd",
+	50001: "webp",
+	52546: "jpegxl",
+}
+
+var PhotometricNames = map[uint64]string{
+	0:     "whiteiszero",
+	1:     "blackiszero",
+	2:     "rgb",
+	3:     "palette",
+	4:     "mask",
+	5:     "separated",
+	6:     "ycbcr",
+	8:     "cielab",
+	9:     "icclab",
+	10:    "itulab",
+	32844: "logl",
+	32845: "logluv",
+	34892: "linearraw",
+}
+
+// Entry is a field of an image file directory
+type Entry struct {
+	Tag   uint16
+	Type  uint16
+	Count uint64
+	// Offset of the value, or of the value field, if the value fits into it
+	Offset int64
+	Inline bool
+}
+
+// Size is the length of the value in bytes
+func (e *Entry) Size() uint64 {
+	return typeSize[e.Type] * e.Count
+}
+
+type IFD struct {
+	Offset  int64
+	Entries []*Entry
+	Next    int64
+}
+
+// Entry returns the entry of a tag
+func (ifd *IFD) Entry(tag uint16) *Entry {
+	for _, e := range ifd.Entries {
+		if e.Tag == tag {
+			return e
+		}
+	}
+	return nil
+}
+
+type File struct {
+	r         io.ReaderAt
+	size      int64
+	ByteOrder binary.ByteOrder
+	BigTIFF   bool
+	IFDs      []*IFD
+}
+
+// maximum number of values, which are read for one entry
+const maxValues = 1 << 22
+
+// Open reads the header and the chain of image file directories.
+// an error in the chain is returned together with the directories read so far
+func Open(r io.ReaderAt, size int64) (*File, error) {
+	var head = make([]byte, 16)
+	if _, err := r.ReadAt(head[:8], 0); err != nil {
+		return nil, errors.Wrap(err, "cannot read tiff header")
+	}
+	f := &File{r: r, size: size}
+	switch string(head[:2]) {
+	case "II":
+		f.ByteOrder = binary.LittleEndian
+	case "MM":
+		f.ByteOrder = binary.BigEndian
+	default:
+		return nil, errors.Errorf("invalid byte order %q", head[:2])
+	}
+	var next int64
+	switch version := f.ByteOrder.Uint16(head[2:]); version {
+	case 42:
+		next = int64(f.ByteOrder.Uint32(head[4:]))
+	case 43:
+		f.BigTIFF = true
+		if _, err := r.ReadAt(head, 0); err != nil {
+			return nil, errors.Wrap(err, "cannot read bigtiff header")
+		}
+		if f.ByteOrder.Uint16(head[4:]) != 8 || f.ByteOrder.Uint16(head[6:]) != 0 {
+			return nil, errors.New("invalid bigtiff offset size")
+		}
+		next = int64(f.ByteOrder.Uint64(head[8:]))
+	default:
+		return nil, errors.Errorf("invalid tiff version %d", version)
+	}
+	if next == 0 {
+		return f, errors.New("no image file directory")
+	}
+	var seen = map[int64]bool{}
+	for next != 0 {
+		if seen[next] {
+			return f, errors.Errorf("loop in ifd chain at offset %d", next)
+		}
+		seen[next] = true
+		ifd, err := f.ReadIFD(next)
+		if err != nil {
+			return f, errors.WithStack(err)
+		}
+		f.IFDs = append(f.IFDs, ifd)
+		next = ifd.Next
+	}
+	return f, nil
+}
+
+// ReadIFD reads an image file directory at offset, i.e. an exif or sub ifd
+func (f *File) ReadIFD(offset int64) (*IFD, error) {
+	var countSize, entrySize, offsetSize int64 = 2, 12, 4
+	if f.BigTIFF {
+		countSize, entrySize, offsetSize = 8, 20, 8
+	}
+	if offset < 8 || offset+countSize > f.size {
+		return nil, errors.Errorf("ifd offset %d out of file", offset)
+	}
+	buf := make([]byte, countSize)
+	if _, err := f.r.ReadAt(buf, offset); err != nil {
+		return nil, errors.Wrapf(err, "cannot read ifd at %d", offset)
+	}
+	var rawCount uint64
+	if f.BigTIFF {
+		rawCount = f.ByteOrder.Uint64(buf)
+	} else {
+		rawCount = uint64(f.ByteOrder.Uint16(buf))
+	}
+	// the entries and the next offset must fit into the file. checked before multiplying, since
+	// a bigtiff count may exceed int64
+	if rawCount == 0 || offset+countSize+offsetSize > f.size || rawCount > uint64((f.size-offset-countSize-offsetSize)/entrySize) {
+		return nil, errors.Errorf("invalid ifd at %d with %d entries", offset, rawCount)
+	}
+	count := int64(rawCount)
+	buf = make([]byte, count*entrySize+offsetSize)
+	if _, err := f.r.ReadAt(buf, offset+countSize); err != nil {
+		return nil, errors.Wrapf(err, "cannot read ifd entries at %d", offset)
+	}
+	ifd := &IFD{Offset: offset}
+	for i := int64(0); i < count; i++ {
+		data := buf[i*entrySize : (i+1)*entrySize]
+		e := &Entry{
+			Tag:  f.ByteOrder.Uint16(data),
+			Type: f.ByteOrder.Uint16(data[2:]),
+		}
+		valuePos := offset + countSize + i*entrySize + 4
+		if f.BigTIFF {
+			e.Count = f.ByteOrder.Uint64(data[4:])
+			valuePos += 8
+		} else {
+			e.Count = uint64(f.ByteOrder.Uint32(data[4:]))
+			valuePos += 4
+		}
+		if e.Count <= uint64(offsetSize) && e.Size() <= uint64(offsetSize) {
+			e.Inline = true
+			e.Offset = valuePos
+		} else if f.BigTIFF {
+			e.Offset = int64(f.ByteOrder.Uint64(data[12:]))
+		} else {
+			e.Offset = int64(f.ByteOrder.Uint32(data[8:]))
+		}
+		ifd.Entries = append(ifd.Entries, e)
+	}
+	if f.BigTIFF {
+		ifd.Next = int64(f.ByteOrder.Uint64(buf[count*entrySize:]))
+	} else {
+		ifd.Next = int64(f.ByteOrder.Uint32(buf[count*entrySize:]))
+	}
+	return ifd, nil
+}
+
+// Size returns the size of the file
+func (f *File) Size() int64 {
+	return f.size
+}
+
+// InFile checks whether the value of an entry is located within the file
+func (f *File) InFile(e *Entry) bool {
+	if typeSize[e.Type] == 0 {
+		return true
+	}
+	// the count is checked first, the size could overflow
+	return e.Offset >= 0 && e.Count <= uint64(f.size) && uint64(e.Offset)+e.Size() <= uint64(f.size)
+}
+
+func (f *File) raw(e *Entry) ([]byte, error) {
+	if typeSize[e.Type] == 0 {
+		return nil, errors.Errorf("unknown type %d of tag %s", e.Type, TagName(e.Tag))
+	}
+	if e.Count > maxValues {
+		return nil, errors.Errorf("too many values (%d) in tag %s", e.Count, TagName(e.Tag))
+	}
+	if !f.InFile(e) {
+		return nil, errors.Errorf("value of tag %s at %d out of file", TagName(e.Tag), e.Offset)
+	}
+	buf := make([]byte, e.Size())
+	if _, err := f.r.ReadAt(buf, e.Offset); err != nil {
+		return nil, errors.Wrapf(err, "cannot read value of tag %s", TagName(e.Tag))
+	}
+	return buf, nil
+}
+
+// Uints returns the values of integer types
+func (f *File) Uints(e *Entry) ([]uint64, error) {
+	buf, err := f.raw(e)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var result = make([]uint64, 0, e.Count)
+	for i := uint64(0); i < e.Count; i++ {
+		switch e.Type {
+		case TypeByte, TypeSByte, TypeUndefined:
+			result = append(result, uint64(buf[i]))
+		case TypeShort, TypeSShort:
+			result = append(result, uint64(f.ByteOrder.Uint16(buf[i*2:])))
+		case TypeLong, TypeSLong, TypeIFD:
+			result = append(result, uint64(f.ByteOrder.Uint32(buf[i*4:])))
+		case TypeLong8, TypeSLong8, TypeIFD8:
+			result = append(result, f.ByteOrder.Uint64(buf[i*8:]))
+		default:
+			return nil, errors.Errorf("tag %s has no integer type (%d)", TagName(e.Tag), e.Type)
+		}
+	}
+	return result, nil
+}
+
+// Uint returns the first integer value of a tag or def, if the tag does not exist
+func (f *File) Uint(ifd *IFD, tag uint16, def uint64) (uint64, error) {
+	e := ifd.Entry(tag)
+	if e == nil || e.Count == 0 {
+		return def, nil
+	}
+	vals, err := f.Uints(e)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return vals[0], nil
+}
+
+// Floats returns the values of rational and floating point types
+func (f *File) Floats(e *Entry) ([]float64, error) {
+	buf, err := f.raw(e)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var result = make([]float64, 0, e.Count)
+	for i := uint64(0); i < e.Count; i++ {
+		switch e.Type {
+		case TypeRational:
+			num, den := f.ByteOrder.Uint32(buf[i*8:]), f.ByteOrder.Uint32(buf[i*8+4:])
+			if den == 0 {
+				result = append(result, 0)
+				continue
+			}
+			result = append(result, float64(num)/float64(den))
+		case TypeSRational:
+			num, den := int32(f.ByteOrder.Uint32(buf[i*8:])), int32(f.ByteOrder.Uint32(buf[i*8+4:]))
+			if den == 0 {
+				result = append(result, 0)
+				continue
+			}
+			result = append(result, float64(num)/float64(den))
+		case TypeFloat:
+			result = append(result, float64(math.Float32frombits(f.ByteOrder.Uint32(buf[i*4:]))))
+		case TypeDouble:
+			result = append(result, math.Float64frombits(f.ByteOrder.Uint64(buf[i*8:])))
+		default:
+			return nil, errors.Errorf("tag %s has no rational type (%d)", TagName(e.Tag), e.Type)
+		}
+	}
+	return result, nil
+}
+
+// ASCII returns the value of an ascii tag without trailing NUL
+func (f *File) ASCII(e *Entry) (string, error) {
+	if e.Type != TypeASCII {
+		return "", errors.Errorf("tag %s has no ascii type (%d)", TagName(e.Tag), e.Type)
+	}
+	buf, err := f.raw(e)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return string(bytes.TrimRight(buf, "\x00")), nil
+}
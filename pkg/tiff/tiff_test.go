@@ -0,0 +1,112 @@
+package tiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// classicTIFF has one ifd at offset 8 with the entries and next ifd offset
+func classicTIFF(count uint16, entries [][]byte, next uint32) []byte {
+	var data = []byte("II*\x00\x08\x00\x00\x00")
+	data = binary.LittleEndian.AppendUint16(data, count)
+	for _, e := range entries {
+		data = append(data, e...)
+	}
+	return binary.LittleEndian.AppendUint32(data, next)
+}
+
+// classicEntry is an ifd entry with a 4 byte value field
+func classicEntry(tag, typ uint16, count, value uint32) []byte {
+	var e = make([]byte, 12)
+	binary.LittleEndian.PutUint16(e, tag)
+	binary.LittleEndian.PutUint16(e[2:], typ)
+	binary.LittleEndian.PutUint32(e[4:], count)
+	binary.LittleEndian.PutUint32(e[8:], value)
+	return e
+}
+
+// bigTIFF has one ifd at offset 16 with the raw entry count
+func bigTIFF(count uint64, entries [][]byte) []byte {
+	var data = []byte("II+\x00\x08\x00\x00\x00")
+	data = binary.LittleEndian.AppendUint64(data, 16)
+	data = binary.LittleEndian.AppendUint64(data, count)
+	for _, e := range entries {
+		data = append(data, e...)
+	}
+	return binary.LittleEndian.AppendUint64(data, 0)
+}
+
+func bigEntry(tag, typ uint16, count, value uint64) []byte {
+	var e = make([]byte, 20)
+	binary.LittleEndian.PutUint16(e, tag)
+	binary.LittleEndian.PutUint16(e[2:], typ)
+	binary.LittleEndian.PutUint64(e[4:], count)
+	binary.LittleEndian.PutUint64(e[12:], value)
+	return e
+}
+
+func openTIFF(data []byte) (*File, error) {
+	return Open(bytes.NewReader(data), int64(len(data)))
+}
+
+func TestOpen(t *testing.T) {
+	f, err := openTIFF(classicTIFF(1, [][]byte{classicEntry(TagImageWidth, TypeShort, 1, 640)}, 0))
+	if err != nil {
+		t.Fatalf("cannot open tiff: %v", err)
+	}
+	if width, err := f.Uint(f.IFDs[0], TagImageWidth, 0); err != nil || width != 640 {
+		t.Errorf("wrong width %d: %v", width, err)
+	}
+	f, err = openTIFF(bigTIFF(1, [][]byte{bigEntry(TagImageLength, TypeLong8, 1, 480)}))
+	if err != nil {
+		t.Fatalf("cannot open bigtiff: %v", err)
+	}
+	if height, err := f.Uint(f.IFDs[0], TagImageLength, 0); err != nil || !f.BigTIFF || height != 480 {
+		t.Errorf("wrong height %d: %v", height, err)
+	}
+}
+
+func TestOpenMalformed(t *testing.T) {
+	width := classicEntry(TagImageWidth, TypeShort, 1, 640)
+	for name, data := range map[string][]byte{
+		"short":                  []byte("II*"),
+		"byte order":             []byte("XX*\x00\x08\x00\x00\x00"),
+		"version":                []byte("II\x2c\x00\x08\x00\x00\x00"),
+		"no ifd":                 []byte("II*\x00\x00\x00\x00\x00"),
+		"ifd out of file":        []byte("II*\x00\xff\x00\x00\x00"),
+		"count exceeds file":     classicTIFF(1000, [][]byte{width}, 0),
+		"empty ifd":              classicTIFF(0, nil, 0),
+		"ifd loop":               classicTIFF(1, [][]byte{width}, 8),
+		"bigtiff offset size":    []byte("II+\x00\x04\x00\x00\x00\x10\x00\x00\x00\x00\x00\x00\x00"),
+		"bigtiff negative count": bigTIFF(0x8000000000000001, [][]byte{bigEntry(TagImageWidth, TypeShort, 1, 640)}),
+		"bigtiff huge count":     bigTIFF(0xffffffffffffffff, [][]byte{bigEntry(TagImageWidth, TypeShort, 1, 640)}),
+		"bigtiff overflow count": bigTIFF(0x0ccccccccccccccd, [][]byte{bigEntry(TagImageWidth, TypeShort, 1, 640)}),
+		"bigtiff negative ifd":   append([]byte("II+\x00\x08\x00\x00\x00"), 0, 0, 0, 0, 0, 0, 0, 0x80),
+	} {
+		if _, err := openTIFF(data); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestValueMalformed(t *testing.T) {
+	for name, entry := range map[string][]byte{
+		"value out of file": bigEntry(TagStripOffsets, TypeLong8, 2, 1<<40),
+		"negative offset":   bigEntry(TagStripOffsets, TypeLong8, 2, 0x8000000000000000),
+		"overflow size":     bigEntry(TagStripOffsets, TypeLong8, 0x2000000000000001, 16),
+		"unknown type":      bigEntry(TagStripOffsets, 99, 1, 16),
+	} {
+		f, err := openTIFF(bigTIFF(1, [][]byte{entry}))
+		if err != nil {
+			t.Fatalf("%s: cannot open bigtiff: %v", name, err)
+		}
+		e := f.IFDs[0].Entry(TagStripOffsets)
+		if _, err := f.Uints(e); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+		if name != "unknown type" && f.InFile(e) {
+			t.Errorf("%s: value reported in file", name)
+		}
+	}
+}
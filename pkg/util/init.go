@@ -38,7 +38,9 @@ func InitIndexer(conf *indexer.IndexerConfig, logger zLogger.ZLogger) (ad *Index
 	logger.Info().Msg("indexer action siegfried added")
 
 	if conf.XML.Enabled {
-		_ = indexer.NewActionXML("xml", conf.XML.Format, nil, (*indexer.ActionDispatcher)(ad))
+		if _, err := indexer.NewActionXML("xml", conf.XML.Format, nil, (*indexer.ActionDispatcher)(ad)); err != nil {
+			return nil, errors.Wrap(err, "cannot initialize xml action")
+		}
 		logger.Info().Msg("indexer action xml added")
 	}
 